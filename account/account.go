@@ -0,0 +1,233 @@
+package account
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 密码哈希参数（PBKDF2-HMAC-SHA256）
+const (
+	passwordHashVersion    = "v1"
+	passwordSaltLen        = 16
+	passwordHashIterations = 100000
+	// MinPasswordLength 密码的最小长度
+	MinPasswordLength = 8
+)
+
+var (
+	ErrNotFound        = errors.New("user not found")
+	ErrExists          = errors.New("user already exists")
+	ErrInvalidUsername = errors.New("username must be 1-32 characters of letters, digits, '.', '_' or '-'")
+	ErrInvalidPassword = errors.New("password must be at least 8 characters")
+)
+
+// usernamePattern 用户名只允许字母、数字、点、下划线和连字符
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,32}$`)
+
+// User 表示一个用户账号，只保存密码的加盐哈希
+type User struct {
+	Username     string    `json:"username"`
+	PasswordHash string    `json:"passwordHash"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// Manager 管理用户账号
+type Manager struct {
+	mu    sync.RWMutex
+	users map[string]*User
+
+	// OnChange 在用户被创建、删除或修改密码时调用（不持有锁）
+	OnChange func()
+}
+
+// NewManager 创建用户管理器
+func NewManager() *Manager {
+	return &Manager{users: make(map[string]*User)}
+}
+
+// Load 替换当前的所有用户（从配置文件加载时使用）
+func (m *Manager) Load(users []User) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.users = make(map[string]*User, len(users))
+	for i := range users {
+		u := users[i]
+		m.users[u.Username] = &u
+	}
+}
+
+// Users 返回所有用户的副本，按用户名排序
+func (m *Manager) Users() []User {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	users := make([]User, 0, len(m.users))
+	for _, u := range m.users {
+		users = append(users, *u)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+	return users
+}
+
+// Exists 检查用户是否存在
+func (m *Manager) Exists(username string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, ok := m.users[username]
+	return ok
+}
+
+// Create 创建用户
+func (m *Manager) Create(username, password string) error {
+	if !ValidUsername(username) {
+		return ErrInvalidUsername
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	if _, ok := m.users[username]; ok {
+		m.mu.Unlock()
+		return ErrExists
+	}
+	m.users[username] = &User{Username: username, PasswordHash: hash, CreatedAt: time.Now()}
+	m.mu.Unlock()
+
+	m.changed()
+	return nil
+}
+
+// Delete 删除用户
+func (m *Manager) Delete(username string) error {
+	m.mu.Lock()
+	if _, ok := m.users[username]; !ok {
+		m.mu.Unlock()
+		return ErrNotFound
+	}
+	delete(m.users, username)
+	m.mu.Unlock()
+
+	m.changed()
+	return nil
+}
+
+// SetPassword 修改用户密码
+func (m *Manager) SetPassword(username, password string) error {
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	u, ok := m.users[username]
+	if !ok {
+		m.mu.Unlock()
+		return ErrNotFound
+	}
+	u.PasswordHash = hash
+	m.mu.Unlock()
+
+	m.changed()
+	return nil
+}
+
+// Authenticate 校验用户名和密码
+func (m *Manager) Authenticate(username, password string) bool {
+	m.mu.RLock()
+	u, ok := m.users[username]
+	var hash string
+	if ok {
+		hash = u.PasswordHash
+	}
+	m.mu.RUnlock()
+
+	if !ok {
+		// 用户不存在时同样计算一次哈希，避免通过响应时间判断用户名是否存在
+		verifyPassword(password, dummyPasswordHash)
+		return false
+	}
+	return verifyPassword(password, hash)
+}
+
+func (m *Manager) changed() {
+	if m.OnChange != nil {
+		m.OnChange()
+	}
+}
+
+// ValidUsername 检查用户名是否有效
+func ValidUsername(username string) bool {
+	return usernamePattern.MatchString(username)
+}
+
+// dummyPasswordHash 用于不存在的用户，格式有效但不对应任何密码
+var dummyPasswordHash = passwordHashVersion + ":" + strconv.Itoa(passwordHashIterations) + ":" +
+	strings.Repeat("00", passwordSaltLen) + ":" + strings.Repeat("00", sha256.Size)
+
+// hashPassword 计算密码哈希，格式为 v1:迭代次数:盐:哈希
+func hashPassword(password string) (string, error) {
+	if len(password) < MinPasswordLength {
+		return "", ErrInvalidPassword
+	}
+	salt := make([]byte, passwordSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	hash := pbkdf2SHA256([]byte(password), salt, passwordHashIterations)
+	return passwordHashVersion + ":" + strconv.Itoa(passwordHashIterations) + ":" +
+		hex.EncodeToString(salt) + ":" + hex.EncodeToString(hash), nil
+}
+
+// verifyPassword 使用常量时间比较校验密码
+func verifyPassword(password, encoded string) bool {
+	parts := strings.Split(encoded, ":")
+	if len(parts) != 4 || parts[0] != passwordHashVersion {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return false
+	}
+	salt, err := hex.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	hash, err := hex.DecodeString(parts[3])
+	if err != nil || len(hash) != sha256.Size {
+		return false
+	}
+	return subtle.ConstantTimeCompare(pbkdf2SHA256([]byte(password), salt, iterations), hash) == 1
+}
+
+// pbkdf2SHA256 计算 PBKDF2-HMAC-SHA256（单个输出块）
+func pbkdf2SHA256(password, salt []byte, iterations int) []byte {
+	mac := hmac.New(sha256.New, password)
+	mac.Write(salt)
+	var block [4]byte
+	binary.BigEndian.PutUint32(block[:], 1)
+	mac.Write(block[:])
+	u := mac.Sum(nil)
+	result := make([]byte, len(u))
+	copy(result, u)
+	for i := 1; i < iterations; i++ {
+		mac.Reset()
+		mac.Write(u)
+		u = mac.Sum(u[:0])
+		for j := range result {
+			result[j] ^= u[j]
+		}
+	}
+	return result
+}
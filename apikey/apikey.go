@@ -0,0 +1,282 @@
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 权限范围：admin 包含其他所有权限
+const (
+	ScopeRead   = "read"
+	ScopeWrite  = "write"
+	ScopeUpload = "upload"
+	ScopeAdmin  = "admin"
+)
+
+// Scopes 所有可用的权限范围
+var Scopes = []string{ScopeRead, ScopeWrite, ScopeUpload, ScopeAdmin}
+
+// tokenPrefix API key 的前缀，格式：jot_<id>_<secret>
+const tokenPrefix = "jot_"
+
+// lastUsedSaveInterval 最后使用时间的持久化间隔，避免每个请求都写配置文件
+const lastUsedSaveInterval = 5 * time.Minute
+
+var (
+	ErrNotFound     = errors.New("api key not found")
+	ErrInvalidName  = errors.New("api key name is required")
+	ErrInvalidScope = errors.New("invalid api key scope")
+	ErrNoScope      = errors.New("at least one scope is required")
+)
+
+// Key 表示一个 API key，只保存 token 的 SHA-256 哈希
+type Key struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	Hash       string     `json:"hash"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+}
+
+// Active 返回 key 当前是否可用（未吊销且未过期）
+func (k *Key) Active(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
+
+// HasScope 检查 key 是否拥有指定权限
+func (k *Key) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// Manager 管理 API key
+type Manager struct {
+	mu        sync.RWMutex
+	keys      []*Key
+	lastSaved map[string]time.Time // key ID -> 上次持久化的最后使用时间
+
+	// OnChange 在 key 被创建、吊销或最后使用时间需要持久化时调用（不持有锁）
+	OnChange func()
+}
+
+// NewManager 创建 API key 管理器
+func NewManager() *Manager {
+	return &Manager{lastSaved: make(map[string]time.Time)}
+}
+
+// Load 替换当前的所有 key（从配置文件加载时使用）
+func (m *Manager) Load(keys []Key) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keys = make([]*Key, 0, len(keys))
+	for i := range keys {
+		key := keys[i]
+		m.keys = append(m.keys, &key)
+		if key.LastUsedAt != nil {
+			m.lastSaved[key.ID] = *key.LastUsedAt
+		}
+	}
+}
+
+// Keys 返回所有 key 的副本（包括已吊销和已过期的）
+func (m *Manager) Keys() []Key {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	keys := make([]Key, len(m.keys))
+	for i, key := range m.keys {
+		keys[i] = *key
+		keys[i].Scopes = append([]string(nil), key.Scopes...)
+	}
+	return keys
+}
+
+// HasActiveKeys 返回是否存在可用的 key
+func (m *Manager) HasActiveKeys() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	now := time.Now()
+	for _, key := range m.keys {
+		if key.Active(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// Create 创建新的 key，返回明文 token（只在创建时返回一次）
+func (m *Manager) Create(name string, scopes []string, expiresAt *time.Time) (string, Key, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", Key{}, ErrInvalidName
+	}
+	scopes, err := normalizeScopes(scopes)
+	if err != nil {
+		return "", Key{}, err
+	}
+
+	secret, err := randomHex(24)
+	if err != nil {
+		return "", Key{}, err
+	}
+
+	m.mu.Lock()
+	// ID 用于查找 key，生成时避免与现有 key 重复
+	var id string
+	for id == "" || m.find(id) != nil {
+		if id, err = randomHex(4); err != nil {
+			m.mu.Unlock()
+			return "", Key{}, err
+		}
+	}
+	token := tokenPrefix + id + "_" + secret
+	key := &Key{
+		ID:        id,
+		Name:      name,
+		Scopes:    scopes,
+		Hash:      hashToken(token),
+		CreatedAt: time.Now(),
+		ExpiresAt: expiresAt,
+	}
+	m.keys = append(m.keys, key)
+	created := *key
+	m.mu.Unlock()
+
+	m.changed()
+	return token, created, nil
+}
+
+// Revoke 吊销 key，已吊销的 key 保留在列表中
+func (m *Manager) Revoke(id string) error {
+	m.mu.Lock()
+	key := m.find(id)
+	if key == nil {
+		m.mu.Unlock()
+		return ErrNotFound
+	}
+	if key.RevokedAt == nil {
+		now := time.Now()
+		key.RevokedAt = &now
+	}
+	m.mu.Unlock()
+
+	m.changed()
+	return nil
+}
+
+// Authenticate 校验 token 是否为拥有指定权限的可用 key，并记录最后使用时间
+func (m *Manager) Authenticate(token, scope string) bool {
+	id, ok := parseToken(token)
+	if !ok {
+		return false
+	}
+
+	m.mu.Lock()
+	key := m.find(id)
+	now := time.Now()
+	if key == nil || subtle.ConstantTimeCompare([]byte(key.Hash), []byte(hashToken(token))) != 1 ||
+		!key.Active(now) || !key.HasScope(scope) {
+		m.mu.Unlock()
+		return false
+	}
+	key.LastUsedAt = &now
+	save := now.Sub(m.lastSaved[id]) >= lastUsedSaveInterval
+	if save {
+		m.lastSaved[id] = now
+	}
+	m.mu.Unlock()
+
+	if save {
+		m.changed()
+	}
+	return true
+}
+
+// find 根据 ID 查找 key，调用者必须持有锁
+func (m *Manager) find(id string) *Key {
+	for _, key := range m.keys {
+		if key.ID == id {
+			return key
+		}
+	}
+	return nil
+}
+
+func (m *Manager) changed() {
+	if m.OnChange != nil {
+		m.OnChange()
+	}
+}
+
+// parseToken 从 token 中解析出 key ID
+func parseToken(token string) (string, bool) {
+	if !strings.HasPrefix(token, tokenPrefix) {
+		return "", false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(token, tokenPrefix), "_", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", false
+	}
+	return parts[0], true
+}
+
+// normalizeScopes 校验并去重权限范围
+func normalizeScopes(scopes []string) ([]string, error) {
+	result := make([]string, 0, len(scopes))
+	for _, scope := range Scopes {
+		for _, s := range scopes {
+			if s == scope {
+				result = append(result, scope)
+				break
+			}
+		}
+	}
+	for _, s := range scopes {
+		if !IsValidScope(s) {
+			return nil, ErrInvalidScope
+		}
+	}
+	if len(result) == 0 {
+		return nil, ErrNoScope
+	}
+	return result, nil
+}
+
+// IsValidScope 检查权限范围名称是否有效
+func IsValidScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// hashToken 计算 token 的 SHA-256 哈希（token 本身是高熵随机值，无需慢哈希）
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -1,6 +1,7 @@
 package backup
 
 import (
+	"context"
 	"log"
 	"time"
 
@@ -10,20 +11,31 @@ import (
 // Manager 备份管理器
 type Manager struct {
 	noteManager *note.Manager
+	uploadPath  string
+	done        chan struct{} // 调度器退出时关闭
+
+	// 孤立上传文件的保留策略（通过 getter 访问，以便配置更新后立即生效，为 nil 或 0 时不限制）
+	GetOrphanUploadDays     func() int
+	GetOrphanUploadMaxSize  func() int64
+	GetOrphanUploadKeepLast func() int
 }
 
-// NewManager 创建新的备份管理器
-func NewManager(noteManager *note.Manager) *Manager {
+// NewManager 创建新的备份管理器，uploadPath 为上传文件目录（用于清理没有被笔记引用的上传文件）
+func NewManager(noteManager *note.Manager, uploadPath string) *Manager {
 	return &Manager{
 		noteManager: noteManager,
+		uploadPath:  uploadPath,
 	}
 }
 
 // StartBackupScheduler 启动备份调度器
-// 启动时立即执行一次，然后每天执行一次
-func (m *Manager) StartBackupScheduler() {
+// 启动时立即执行一次，然后每天执行一次，ctx 取消后停止
+func (m *Manager) StartBackupScheduler(ctx context.Context) {
 	log.Printf("Starting backup scheduler...")
+	m.done = make(chan struct{})
 	go func() {
+		defer close(m.done)
+
 		// 启动时立即执行一次
 		log.Printf("Running initial backup check...")
 		if err := m.noteManager.MoveOldNotesToBackup(); err != nil {
@@ -31,18 +43,67 @@ func (m *Manager) StartBackupScheduler() {
 		} else {
 			log.Printf("Initial backup check completed")
 		}
+		m.pruneRevisions()
+		m.purgeTrash()
+		m.purgeOrphanUploads()
 
 		// 然后每天执行一次
 		ticker := time.NewTicker(24 * time.Hour)
 		defer ticker.Stop()
 
-		for range ticker.C {
+		for {
+			select {
+			case <-ctx.Done():
+				log.Printf("Backup scheduler stopped")
+				return
+			case <-ticker.C:
+			}
 			log.Printf("Running scheduled backup check...")
 			if err := m.noteManager.MoveOldNotesToBackup(); err != nil {
 				log.Printf("Error running scheduled backup: %v", err)
 			} else {
 				log.Printf("Scheduled backup check completed")
 			}
+			m.pruneRevisions()
+			m.purgeTrash()
+			m.purgeOrphanUploads()
 		}
 	}()
 }
+
+// Wait 等待调度器退出（正在执行的备份检查完成后），ctx 超时时返回 ctx 的错误
+func (m *Manager) Wait(ctx context.Context) error {
+	if m.done == nil {
+		return nil
+	}
+	select {
+	case <-m.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// pruneRevisions 清理超出数量或天数限制的修订版本
+func (m *Manager) pruneRevisions() {
+	removed, err := m.noteManager.PruneAllRevisions()
+	if err != nil {
+		log.Printf("Error pruning note revisions: %v", err)
+		return
+	}
+	if removed > 0 {
+		log.Printf("Pruned %d note revision(s)", removed)
+	}
+}
+
+// purgeTrash 永久删除在回收站中超过保留天数的笔记
+func (m *Manager) purgeTrash() {
+	purged, err := m.noteManager.PurgeExpiredTrash()
+	if err != nil {
+		log.Printf("Error purging trash: %v", err)
+		return
+	}
+	if purged > 0 {
+		log.Printf("Purged %d expired note(s) from trash", purged)
+	}
+}
@@ -0,0 +1,206 @@
+package backup
+
+import (
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// orphanUploadGrace 上传后多久内不视为孤立文件（上传后还没有保存到笔记中）
+const orphanUploadGrace = 24 * time.Hour
+
+// UploadFile 上传目录中的一个文件
+type UploadFile struct {
+	Path    string    `json:"path"` // 相对上传目录的路径，如 20240101/123-a.png
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// UploadUsage 上传文件的磁盘占用
+type UploadUsage struct {
+	Count       int   `json:"count"`
+	Size        int64 `json:"size"`
+	OrphanCount int   `json:"orphan_count"` // 没有被任何笔记引用的文件
+	OrphanSize  int64 `json:"orphan_size"`
+	PurgeCount  int   `json:"purge_count"` // 下一次清理会删除的孤立文件
+	PurgeSize   int64 `json:"purge_size"`
+}
+
+// listUploads 列出上传目录中的所有文件
+func (m *Manager) listUploads() ([]UploadFile, error) {
+	files := make([]UploadFile, 0)
+	if m.uploadPath == "" {
+		return files, nil
+	}
+	err := filepath.WalkDir(m.uploadPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(m.uploadPath, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, UploadFile{
+			Path:    filepath.ToSlash(rel),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		})
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return files, nil
+}
+
+// findOrphanUploads 返回所有上传文件和其中没有被任何笔记引用的文件（孤立文件按最近上传的优先）
+func (m *Manager) findOrphanUploads() ([]UploadFile, []UploadFile, error) {
+	files, err := m.listUploads()
+	if err != nil {
+		return nil, nil, err
+	}
+	refs, err := m.noteManager.ReferencedUploads()
+	if err != nil {
+		return nil, nil, err
+	}
+	orphans := make([]UploadFile, 0)
+	for _, file := range files {
+		if !isReferenced(refs, file.Path) {
+			orphans = append(orphans, file)
+		}
+	}
+	sort.Slice(orphans, func(i, j int) bool {
+		return orphans[i].ModTime.After(orphans[j].ModTime)
+	})
+	return files, orphans, nil
+}
+
+// isReferenced 检查上传文件是否被引用
+// 文件名包含空白时链接只能匹配到空白之前的部分，所以以引用开头的文件也视为被引用
+func isReferenced(refs map[string]bool, path string) bool {
+	if refs[path] {
+		return true
+	}
+	for ref := range refs {
+		if strings.HasPrefix(path, ref) {
+			return true
+		}
+	}
+	return false
+}
+
+// planUploadPurge 按保留策略选出要删除的孤立文件：超过天数、超出保留数量或超出总大小的文件（最早上传的先删除）
+// 上传不到 orphanUploadGrace 的文件不会被删除
+func (m *Manager) planUploadPurge(orphans []UploadFile) []UploadFile {
+	days := m.getOrphanUploadDays()
+	maxSize := m.getOrphanUploadMaxSize()
+	keepLast := m.getOrphanUploadKeepLast()
+	purge := make([]UploadFile, 0)
+	if days <= 0 && maxSize <= 0 && keepLast <= 0 {
+		return purge
+	}
+
+	now := time.Now()
+	cutoffTime := now.AddDate(0, 0, -days)
+	keptCount := 0
+	var keptSize int64
+	for _, file := range orphans {
+		expired := (days > 0 && file.ModTime.Before(cutoffTime)) ||
+			(keepLast > 0 && keptCount >= keepLast) ||
+			(maxSize > 0 && keptSize+file.Size > maxSize)
+		if expired && now.Sub(file.ModTime) >= orphanUploadGrace {
+			purge = append(purge, file)
+			continue
+		}
+		keptCount++
+		keptSize += file.Size
+	}
+	return purge
+}
+
+// UploadUsage 统计上传文件和孤立文件的数量和大小
+func (m *Manager) UploadUsage() (UploadUsage, error) {
+	var usage UploadUsage
+	files, orphans, err := m.findOrphanUploads()
+	if err != nil {
+		return usage, err
+	}
+	for _, file := range files {
+		usage.Count++
+		usage.Size += file.Size
+	}
+	for _, file := range orphans {
+		usage.OrphanCount++
+		usage.OrphanSize += file.Size
+	}
+	for _, file := range m.planUploadPurge(orphans) {
+		usage.PurgeCount++
+		usage.PurgeSize += file.Size
+	}
+	return usage, nil
+}
+
+// purgeOrphanUploads 删除超出保留策略的孤立上传文件
+func (m *Manager) purgeOrphanUploads() {
+	_, orphans, err := m.findOrphanUploads()
+	if err != nil {
+		log.Printf("Error finding orphaned uploads: %v", err)
+		return
+	}
+	purged := 0
+	var purgedSize int64
+	for _, file := range m.planUploadPurge(orphans) {
+		path := filepath.Join(m.uploadPath, filepath.FromSlash(file.Path))
+		if err := os.Remove(path); err != nil {
+			if !os.IsNotExist(err) {
+				log.Printf("Failed to remove orphaned upload %s: %v", file.Path, err)
+			}
+			continue
+		}
+		// 日期目录为空时删除，目录不为空时删除会失败，忽略错误
+		if dir := filepath.Dir(path); dir != filepath.Clean(m.uploadPath) {
+			os.Remove(dir)
+		}
+		purged++
+		purgedSize += file.Size
+		log.Printf("Purged orphaned upload %s (uploaded: %s, size: %d)", file.Path, file.ModTime.Format("2006-01-02 15:04:05"), file.Size)
+	}
+	if purged > 0 {
+		log.Printf("Purged %d orphaned upload(s), %d bytes", purged, purgedSize)
+	}
+}
+
+// getOrphanUploadDays 获取孤立上传文件的保留天数（0 表示不限制）
+func (m *Manager) getOrphanUploadDays() int {
+	if m.GetOrphanUploadDays == nil {
+		return 0
+	}
+	return m.GetOrphanUploadDays()
+}
+
+// getOrphanUploadMaxSize 获取孤立上传文件的最大总大小（0 表示不限制）
+func (m *Manager) getOrphanUploadMaxSize() int64 {
+	if m.GetOrphanUploadMaxSize == nil {
+		return 0
+	}
+	return m.GetOrphanUploadMaxSize()
+}
+
+// getOrphanUploadKeepLast 获取最多保留的孤立上传文件数量（0 表示不限制）
+func (m *Manager) getOrphanUploadKeepLast() int {
+	if m.GetOrphanUploadKeepLast == nil {
+		return 0
+	}
+	return m.GetOrphanUploadKeepLast()
+}
@@ -6,9 +6,12 @@ import (
 	"os"
 	"strings"
 	"sync"
-)
 
-const ConfigFile = "config.json" // Configuration file path
+	"github.com/hello--world/jot/account"
+	"github.com/hello--world/jot/apikey"
+	"github.com/hello--world/jot/ratelimit"
+	"github.com/hello--world/jot/webhook"
+)
 
 // Config structure for saving/loading configuration
 type Config struct {
@@ -22,11 +25,49 @@ type Config struct {
 	MaxPathLength int    `json:"maxPathLength"`
 	MaxTotalSize  int64  `json:"maxTotalSize"`
 	MaxNoteCount  int    `json:"maxNoteCount"`
+	MaxRevisions  int    `json:"maxRevisions"`
+	RevisionDays  int    `json:"revisionDays"`
+	TrashDays     int    `json:"trashDays"`
+
+	ArchiveBy          string `json:"archiveBy,omitempty"` // 归档依据：modified（最后修改时间）或 read（最后读取时间）
+	DeleteArchivedDays int    `json:"deleteArchivedDays"`  // 归档笔记保留的天数，0 表示不删除
+
+	// 备份文件夹和未被引用的上传文件的保留策略，0 表示不限制
+	ArchiveMaxSize       int64 `json:"archiveMaxSize"`       // 归档笔记的最大总大小（字节）
+	ArchiveKeepLast      int   `json:"archiveKeepLast"`      // 最多保留最近归档的多少条笔记
+	OrphanUploadDays     int   `json:"orphanUploadDays"`     // 未被引用的上传文件保留的天数
+	OrphanUploadMaxSize  int64 `json:"orphanUploadMaxSize"`  // 未被引用的上传文件的最大总大小（字节）
+	OrphanUploadKeepLast int   `json:"orphanUploadKeepLast"` // 最多保留最近上传的多少个未被引用的文件
+
+	SnapshotMaxSize int64 `json:"snapshotMaxSize"` // 导入快照时解压后的最大大小（字节）
+
+	APIKeys []apikey.Key   `json:"apiKeys,omitempty"` // token 只保存哈希
+	Users   []account.User `json:"users,omitempty"`   // 密码只保存加盐哈希
+
+	Webhooks []webhook.Hook `json:"webhooks,omitempty"`
+
+	RateLimit *ratelimit.Config `json:"rateLimit,omitempty"`
+
+	AllowedOrigins []string `json:"allowedOrigins,omitempty"` // 除同源外允许的 Origin，例如 https://notes.example.com
+
+	Workspaces []Workspace `json:"workspaces,omitempty"` // 只在默认工作区的配置文件中使用
+}
+
+// Workspace 定义一个按 Host 区分的工作区，工作区的设置、令牌、账号和笔记保存在自己的数据目录中
+type Workspace struct {
+	Name    string   `json:"name"`
+	Hosts   []string `json:"hosts"`
+	DataDir string   `json:"dataDir,omitempty"` // 默认为 <数据目录>/workspaces/<name>
+
+	// 工作区配置文件不存在时使用的初始令牌，之后以工作区自己的配置文件为准
+	AdminToken  string `json:"adminToken,omitempty"`
+	AccessToken string `json:"accessToken,omitempty"`
 }
 
 // Manager 管理配置
 type Manager struct {
 	configLoaded bool
+	configFile   *string // 配置文件路径（位于数据目录下，解析命令行参数后才确定）
 	config       *Config
 
 	// 变量引用（通过 setter/getter 访问）
@@ -42,18 +83,57 @@ type Manager struct {
 	maxTotalSizeLock *sync.RWMutex
 	maxNoteCount     *int
 	maxNoteCountLock *sync.RWMutex
+	maxRevisions     *int
+	revisionDays     *int
+	trashDays        *int
+	apiKeys          *apikey.Manager
+	users            *account.Manager
+	rateLimiter      *ratelimit.Limiter
+	webhooks         *webhook.Manager
+	allowedOrigins   *[]string
+	workspaces       *[]Workspace // 工作区的配置管理器为 nil
+
+	// 归档策略
+	archiveBy          *string
+	deleteArchivedDays *int
+
+	// 保留策略
+	archiveMaxSize       *int64
+	archiveKeepLast      *int
+	orphanUploadDays     *int
+	orphanUploadMaxSize  *int64
+	orphanUploadKeepLast *int
+
+	snapshotMaxSize *int64
 }
 
 // NewManager 创建新的配置管理器
 func NewManager(
+	configFile *string,
 	adminToken, accessToken, adminPath *string,
 	noteNameLen, backupDays, maxPathLength, maxNoteCount *int,
 	noteChars *string,
 	maxFileSize, maxTotalSize *int64,
 	maxTotalSizeLock, maxNoteCountLock *sync.RWMutex,
+	maxRevisions, revisionDays, trashDays *int,
+	archiveBy *string,
+	deleteArchivedDays *int,
+	archiveMaxSize *int64,
+	archiveKeepLast *int,
+	orphanUploadDays *int,
+	orphanUploadMaxSize *int64,
+	orphanUploadKeepLast *int,
+	snapshotMaxSize *int64,
+	apiKeys *apikey.Manager,
+	users *account.Manager,
+	rateLimiter *ratelimit.Limiter,
+	webhooks *webhook.Manager,
+	allowedOrigins *[]string,
+	workspaces *[]Workspace,
 ) *Manager {
 	return &Manager{
 		configLoaded:     false,
+		configFile:       configFile,
 		config:           &Config{},
 		adminToken:       adminToken,
 		accessToken:      accessToken,
@@ -67,13 +147,33 @@ func NewManager(
 		maxTotalSizeLock: maxTotalSizeLock,
 		maxNoteCount:     maxNoteCount,
 		maxNoteCountLock: maxNoteCountLock,
+		maxRevisions:     maxRevisions,
+		revisionDays:     revisionDays,
+		trashDays:        trashDays,
+		apiKeys:          apiKeys,
+		users:            users,
+		rateLimiter:      rateLimiter,
+		webhooks:         webhooks,
+		allowedOrigins:   allowedOrigins,
+		workspaces:       workspaces,
+
+		archiveBy:          archiveBy,
+		deleteArchivedDays: deleteArchivedDays,
+
+		archiveMaxSize:       archiveMaxSize,
+		archiveKeepLast:      archiveKeepLast,
+		orphanUploadDays:     orphanUploadDays,
+		orphanUploadMaxSize:  orphanUploadMaxSize,
+		orphanUploadKeepLast: orphanUploadKeepLast,
+
+		snapshotMaxSize: snapshotMaxSize,
 	}
 }
 
 // LoadConfig loads configuration from config.json file
 // Returns true if config file exists and was loaded successfully
 func (m *Manager) LoadConfig() bool {
-	data, err := os.ReadFile(ConfigFile)
+	data, err := os.ReadFile(*m.configFile)
 	if err != nil {
 		if os.IsNotExist(err) {
 			// Config file doesn't exist, will use defaults from env/command line
@@ -85,7 +185,9 @@ func (m *Manager) LoadConfig() bool {
 		return false
 	}
 
-	var cfg Config
+	// 限流配置中没有写出的字段使用默认值
+	defaultRateLimit := ratelimit.DefaultConfig()
+	cfg := Config{RateLimit: &defaultRateLimit}
 	if err := json.Unmarshal(data, &cfg); err != nil {
 		log.Printf("Warning: Failed to parse config file: %v", err)
 		m.configLoaded = false
@@ -130,6 +232,53 @@ func (m *Manager) LoadConfig() bool {
 		*m.maxNoteCount = cfg.MaxNoteCount
 		m.maxNoteCountLock.Unlock()
 	}
+	if cfg.MaxRevisions > 0 {
+		*m.maxRevisions = cfg.MaxRevisions
+	}
+	if cfg.RevisionDays > 0 {
+		*m.revisionDays = cfg.RevisionDays
+	}
+	if cfg.TrashDays > 0 {
+		*m.trashDays = cfg.TrashDays
+	}
+	if cfg.ArchiveBy != "" {
+		*m.archiveBy = cfg.ArchiveBy
+	}
+	if cfg.DeleteArchivedDays > 0 {
+		*m.deleteArchivedDays = cfg.DeleteArchivedDays
+	}
+	if cfg.ArchiveMaxSize > 0 {
+		*m.archiveMaxSize = cfg.ArchiveMaxSize
+	}
+	if cfg.ArchiveKeepLast > 0 {
+		*m.archiveKeepLast = cfg.ArchiveKeepLast
+	}
+	if cfg.OrphanUploadDays > 0 {
+		*m.orphanUploadDays = cfg.OrphanUploadDays
+	}
+	if cfg.OrphanUploadMaxSize > 0 {
+		*m.orphanUploadMaxSize = cfg.OrphanUploadMaxSize
+	}
+	if cfg.OrphanUploadKeepLast > 0 {
+		*m.orphanUploadKeepLast = cfg.OrphanUploadKeepLast
+	}
+	if cfg.SnapshotMaxSize > 0 {
+		*m.snapshotMaxSize = cfg.SnapshotMaxSize
+	}
+	m.apiKeys.Load(cfg.APIKeys)
+	m.users.Load(cfg.Users)
+	m.webhooks.Load(cfg.Webhooks)
+	if cfg.RateLimit != nil {
+		if err := m.rateLimiter.SetConfig(*cfg.RateLimit); err != nil {
+			log.Printf("Warning: Invalid rateLimit config, using defaults: %v", err)
+		}
+	}
+	if len(cfg.AllowedOrigins) > 0 {
+		*m.allowedOrigins = cfg.AllowedOrigins
+	}
+	if m.workspaces != nil {
+		*m.workspaces = cfg.Workspaces
+	}
 
 	m.configLoaded = true
 	return true
@@ -161,7 +310,31 @@ func (m *Manager) SaveConfig() {
 		MaxPathLength: *m.maxPathLength,
 		MaxTotalSize:  currentMaxTotalSize,
 		MaxNoteCount:  currentMaxNoteCount,
+		MaxRevisions:  *m.maxRevisions,
+		RevisionDays:  *m.revisionDays,
+		TrashDays:     *m.trashDays,
+		APIKeys:       m.apiKeys.Keys(),
+		Users:         m.users.Users(),
+		Webhooks:      m.webhooks.Hooks(),
+
+		AllowedOrigins: *m.allowedOrigins,
+
+		ArchiveBy:          *m.archiveBy,
+		DeleteArchivedDays: *m.deleteArchivedDays,
+
+		ArchiveMaxSize:       *m.archiveMaxSize,
+		ArchiveKeepLast:      *m.archiveKeepLast,
+		OrphanUploadDays:     *m.orphanUploadDays,
+		OrphanUploadMaxSize:  *m.orphanUploadMaxSize,
+		OrphanUploadKeepLast: *m.orphanUploadKeepLast,
+
+		SnapshotMaxSize: *m.snapshotMaxSize,
+	}
+	if m.workspaces != nil {
+		cfg.Workspaces = *m.workspaces
 	}
+	rateLimit := m.rateLimiter.Config()
+	cfg.RateLimit = &rateLimit
 
 	data, err := json.MarshalIndent(cfg, "", "  ")
 	if err != nil {
@@ -169,11 +342,11 @@ func (m *Manager) SaveConfig() {
 		return
 	}
 
-	if err := os.WriteFile(ConfigFile, data, 0644); err != nil {
+	if err := os.WriteFile(*m.configFile, data, 0644); err != nil {
 		log.Printf("Warning: Failed to save config file: %v", err)
 		return
 	}
 
 	m.configLoaded = true
-	log.Printf("Configuration saved to %s", ConfigFile)
+	log.Printf("Configuration saved to %s", *m.configFile)
 }
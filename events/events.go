@@ -0,0 +1,149 @@
+package events
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// bufferSize 保留用于断线续传（Last-Event-ID）的最近事件数量
+	bufferSize = 1000
+	// bufferBytes 保留的最近事件的数据总大小上限（事件中可能带有整个笔记的内容）
+	bufferBytes = 8 << 20
+	// subscriberBuffer 每个订阅者的待发送事件数量，超出时断开订阅者，客户端重连后从缓冲区续传
+	subscriberBuffer = 64
+)
+
+// Event 一条笔记事件，ID 在进程内递增
+type Event struct {
+	ID   uint64
+	Type string
+	Note string
+	Data []byte // JSON
+}
+
+// NoteData 笔记事件的 data 字段
+type NoteData struct {
+	Name      string    `json:"name"`
+	DateDir   string    `json:"dateDir,omitempty"`
+	Size      int64     `json:"size"`
+	Locked    bool      `json:"locked"`
+	Content   *string   `json:"content,omitempty"` // 不含锁标记的内容；删除、归档和有锁的笔记没有
+	ETag      string    `json:"etag,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	By        string    `json:"by,omitempty"` // 修改笔记的用户名，管理员为 "@admin"
+}
+
+// subscriber 一个事件流的订阅者，note 为空时接收所有笔记的事件
+type subscriber struct {
+	note string
+	ch   chan Event
+}
+
+// Broker 把笔记事件分发给订阅者，并保留最近的事件用于断线续传
+type Broker struct {
+	mu     sync.Mutex
+	nextID uint64
+	buffer []Event // 最近的事件，按 ID 递增
+	size   int     // buffer 中事件数据的总大小
+	subs   map[*subscriber]struct{}
+	closed bool
+}
+
+// NewBroker 创建事件分发器
+// 事件 ID 从当前时间（毫秒）的 1000 倍开始，重启后的 ID 大于重启前的 ID，旧的 Last-Event-ID 会被识别为无法续传
+func NewBroker() *Broker {
+	return &Broker{
+		nextID: uint64(time.Now().UnixMilli()) * 1000,
+		subs:   make(map[*subscriber]struct{}),
+	}
+}
+
+// Publish 发布事件，data 编码为 JSON
+func (b *Broker) Publish(eventType, note string, data interface{}) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("Error encoding %s event of note %s: %v", eventType, note, err)
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.nextID++
+	e := Event{ID: b.nextID, Type: eventType, Note: note, Data: encoded}
+
+	b.buffer = append(b.buffer, e)
+	b.size += len(e.Data)
+	for len(b.buffer) > bufferSize || (b.size > bufferBytes && len(b.buffer) > 1) {
+		b.size -= len(b.buffer[0].Data)
+		b.buffer[0] = Event{}
+		b.buffer = b.buffer[1:]
+	}
+
+	for s := range b.subs {
+		if s.note != "" && s.note != note {
+			continue
+		}
+		select {
+		case s.ch <- e:
+		default:
+			// 订阅者跟不上，断开后由客户端带着 Last-Event-ID 重连
+			delete(b.subs, s)
+			close(s.ch)
+		}
+	}
+}
+
+// Subscribe 订阅笔记（note 为空时为所有笔记）的事件
+// lastID 不为 0 时返回缓冲区中 ID 大于 lastID 的事件；缓冲区已不包含 lastID 之后的所有事件时 complete 为 false，
+// 客户端需要重新读取笔记。事件通道在 cancel、订阅者跟不上或 Close 后关闭
+func (b *Broker) Subscribe(note string, lastID uint64) (replay []Event, complete bool, ch <-chan Event, cancel func()) {
+	s := &subscriber{note: note, ch: make(chan Event, subscriberBuffer)}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	complete = true
+	if lastID != 0 {
+		oldest := b.nextID + 1
+		if len(b.buffer) > 0 {
+			oldest = b.buffer[0].ID
+		}
+		complete = lastID >= oldest-1 && lastID <= b.nextID
+		for _, e := range b.buffer {
+			if e.ID > lastID && (note == "" || e.Note == note) {
+				replay = append(replay, e)
+			}
+		}
+	}
+	if b.closed {
+		close(s.ch)
+	} else {
+		b.subs[s] = struct{}{}
+	}
+
+	cancel = func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subs[s]; ok {
+			delete(b.subs, s)
+			close(s.ch)
+		}
+	}
+	return replay, complete, s.ch, cancel
+}
+
+// Close 关闭所有订阅者的事件通道（服务关闭时结束所有事件流），之后的事件被丢弃
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for s := range b.subs {
+		delete(b.subs, s)
+		close(s.ch)
+	}
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// CSRF token 使用双重提交 cookie：页面脚本读取 csrf_token cookie，在修改请求中通过 X-CSRF-Token header 或 csrf_token 表单字段提交
+const (
+	csrfCookieName = "csrf_token"
+	csrfHeaderName = "X-CSRF-Token"
+	csrfFormField  = "csrf_token"
+)
+
+type csrfTokenKey struct{}
+
+// IsSecureRequest 检查请求是否通过 HTTPS 到达（直接 TLS 连接，或可信代理设置了 X-Forwarded-Proto: https）
+func IsSecureRequest(r *http.Request) bool {
+	deps := depsFor(r)
+	if r.TLS != nil {
+		return true
+	}
+	return deps.FromTrustedProxy(r) && strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
+
+// SetCookie 设置 cookie，通过 HTTPS 访问时自动加上 Secure
+func SetCookie(w http.ResponseWriter, r *http.Request, cookie *http.Cookie) {
+	if IsSecureRequest(r) {
+		cookie.Secure = true
+	}
+	http.SetCookie(w, cookie)
+}
+
+// EnsureCSRFToken 确保请求有 CSRF token，没有时生成新的 token 并通过 cookie 下发
+// 返回的请求在 context 中带有 token，页面模板通过 CSRFToken 读取
+func EnsureCSRFToken(w http.ResponseWriter, r *http.Request) *http.Request {
+	token := ""
+	if cookie, err := r.Cookie(csrfCookieName); err == nil && len(cookie.Value) == 64 {
+		token = cookie.Value
+	}
+	if token == "" {
+		generated, err := generateSessionToken()
+		if err != nil {
+			return r
+		}
+		token = generated
+		// 页面脚本需要读取这个 cookie，所以不能设置 HttpOnly
+		SetCookie(w, r, &http.Cookie{
+			Name:     csrfCookieName,
+			Value:    token,
+			Path:     "/",
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+	return r.WithContext(context.WithValue(r.Context(), csrfTokenKey{}, token))
+}
+
+// CSRFToken 返回请求的 CSRF token（用于在页面表单中提交）
+func CSRFToken(r *http.Request) string {
+	token, _ := r.Context().Value(csrfTokenKey{}).(string)
+	return token
+}
+
+// hasAmbientCredentials 检查请求是否带有浏览器自动发送的凭据（登录 session、访问令牌或锁令牌 cookie）
+// 带 Authorization header 的请求不会由跨站页面发起（需要 CORS 预检），不需要 CSRF token
+func hasAmbientCredentials(r *http.Request) bool {
+	if r.Header.Get("Authorization") != "" {
+		return false
+	}
+	for _, cookie := range r.Cookies() {
+		switch {
+		case cookie.Value == "":
+		case cookie.Name == "admin_session", cookie.Name == "user_session", cookie.Name == "access_token",
+			strings.HasPrefix(cookie.Name, "note_lock_"):
+			return true
+		}
+	}
+	return false
+}
+
+// VerifyCSRF 检查修改请求（POST、PUT、PATCH、DELETE）的来源
+// 请求带有 Origin（或 Referer）时必须是同源或在 allowedOrigins 中；
+// 请求带有 cookie 凭据时还需要提交与 csrf_token cookie 一致的 token
+func VerifyCSRF(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		if referer, err := url.Parse(r.Header.Get("Referer")); err == nil && referer.Host != "" {
+			origin = referer.Scheme + "://" + referer.Host
+		}
+	}
+	if origin != "" && !AllowedOrigin(r, origin) {
+		return false
+	}
+
+	if !hasAmbientCredentials(r) {
+		return true
+	}
+	cookie, err := r.Cookie(csrfCookieName)
+	if err != nil || cookie.Value == "" {
+		return false
+	}
+	submitted := r.Header.Get(csrfHeaderName)
+	if submitted == "" && strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		submitted = csrfFormValue(r)
+	}
+	return subtle.ConstantTimeCompare([]byte(submitted), []byte(cookie.Value)) == 1
+}
+
+// csrfFormValue 从表单请求体中读取 csrf_token 字段，并恢复 r.Body，
+// 以便 handler 仍能读取完整的请求体（例如以表单格式保存的笔记内容）
+func csrfFormValue(r *http.Request) string {
+	body, err := io.ReadAll(r.Body)
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return ""
+	}
+	// 其他字段（例如笔记内容）编码无效时仍返回解析到的 csrf_token
+	values, _ := url.ParseQuery(string(body))
+	return values.Get(csrfFormField)
+}
+
+// AllowedOrigin 检查 Origin 是否与请求的 Host 相同，或在配置的 allowedOrigins 中
+func AllowedOrigin(r *http.Request, origin string) bool {
+	deps := depsFor(r)
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	origin = strings.TrimSuffix(strings.ToLower(origin), "/")
+	for _, allowed := range deps.GetAllowedOrigins() {
+		if strings.TrimSuffix(strings.ToLower(strings.TrimSpace(allowed)), "/") == origin {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckWebSocketOrigin 检查 WebSocket 升级请求的来源，没有 Origin header 的请求（非浏览器客户端）直接允许
+func CheckWebSocketOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	return origin == "" || AllowedOrigin(r, origin)
+}
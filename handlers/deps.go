@@ -1,8 +1,15 @@
 package handlers
 
 import (
+	"errors"
+	"io"
 	"net/http"
 	"time"
+
+	"github.com/hello--world/jot/events"
+	"github.com/hello--world/jot/ratelimit"
+	"github.com/hello--world/jot/session"
+	"github.com/hello--world/jot/webhook"
 )
 
 // Note 表示笔记
@@ -11,33 +18,250 @@ type Note struct {
 	Content   string    `json:"content"`
 	UpdatedAt time.Time `json:"updated_at"`
 	Size      int64     `json:"size"`
-	DateDir   string    `json:"date_dir"`  // 日期目录，用于分组（格式：YYYYMMDD）
-	IsBackup  bool      `json:"is_backup"` // 是否在备份文件夹
+	DateDir   string    `json:"date_dir"`         // 日期目录，用于分组（格式：YYYYMMDD）
+	IsBackup  bool      `json:"is_backup"`        // 是否在备份文件夹
+	Owner     string    `json:"owner,omitempty"`  // 所有者用户名（没有所有者时为空）
+	Pinned    bool      `json:"pinned,omitempty"` // 是否置顶（置顶的笔记不会被归档）
+}
+
+// NoteInfo 表示笔记的存储元数据
+type NoteInfo struct {
+	Size      int64
+	ModTime   time.Time
+	CreatedAt time.Time
+	DateDir   string
+	IsBackup  bool
+}
+
+// Revision 表示笔记的修订版本
+type Revision struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Size      int64     `json:"size"`
+}
+
+// UnarchiveResult 表示恢复归档笔记的结果
+type UnarchiveResult struct {
+	Name        string `json:"name"`
+	From        string `json:"from"`
+	DateDir     string `json:"date_dir"`
+	Renamed     bool   `json:"renamed"`
+	Overwritten bool   `json:"overwritten"`
+}
+
+// ArchivePlanItem 表示归档计划中的一份笔记
+type ArchivePlanItem struct {
+	Name      string     `json:"name"`
+	DateDir   string     `json:"date_dir"`
+	IsBackup  bool       `json:"is_backup"`
+	Size      int64      `json:"size"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	LastRead  *time.Time `json:"last_read,omitempty"`
+	Since     time.Time  `json:"since"`
+	Days      int        `json:"days"`
+	Reason    string     `json:"reason,omitempty"` // 归档笔记被删除的原因：age、count 或 size
+}
+
+// ArchivePlan 表示下一次归档会执行的操作
+type ArchivePlan struct {
+	ArchiveBy          string            `json:"archive_by"`
+	BackupDays         int               `json:"backup_days"`
+	DeleteArchivedDays int               `json:"delete_archived_days"`
+	ArchiveKeepLast    int               `json:"archive_keep_last"`
+	ArchiveMaxSize     int64             `json:"archive_max_size"`
+	Archive            []ArchivePlanItem `json:"archive"`
+	Delete             []ArchivePlanItem `json:"delete"`
+	Pinned             []ArchivePlanItem `json:"pinned"`
+}
+
+// DiskUsage 表示工作区数据的磁盘占用（只有活跃笔记和上传文件计入总大小限制）
+type DiskUsage struct {
+	ActiveCount       int   `json:"active_count"`
+	ActiveSize        int64 `json:"active_size"`
+	ArchivedCount     int   `json:"archived_count"`
+	ArchivedSize      int64 `json:"archived_size"`
+	TrashCount        int   `json:"trash_count"`
+	TrashSize         int64 `json:"trash_size"`
+	RevisionCount     int   `json:"revision_count"`
+	RevisionSize      int64 `json:"revision_size"`
+	UploadCount       int   `json:"upload_count"`
+	UploadSize        int64 `json:"upload_size"`
+	OrphanUploadCount int   `json:"orphan_upload_count"` // 没有被任何笔记引用的上传文件
+	OrphanUploadSize  int64 `json:"orphan_upload_size"`
+	PurgeUploadCount  int   `json:"purge_upload_count"` // 下一次清理会删除的孤立上传文件
+	PurgeUploadSize   int64 `json:"purge_upload_size"`
+}
+
+// SnapshotConflict 表示导入快照时没有导入的文件（本地已有不同的内容）
+type SnapshotConflict struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// SnapshotReport 表示导入快照的结果
+type SnapshotReport struct {
+	Mode      string             `json:"mode"`
+	CreatedAt time.Time          `json:"created_at"`
+	Workspace string             `json:"workspace,omitempty"`
+	Notes     int                `json:"notes"`
+	Archived  int                `json:"archived"`
+	Revisions int                `json:"revisions"`
+	Trash     int                `json:"trash"`
+	Uploads   int                `json:"uploads"`
+	Config    bool               `json:"config"`
+	Skipped   int                `json:"skipped"`
+	Conflicts []SnapshotConflict `json:"conflicts"`
+}
+
+// TrashedNote 表示回收站中的一份笔记
+type TrashedNote struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	DateDir   string    `json:"date_dir"`
+	IsBackup  bool      `json:"is_backup"`
+	Size      int64     `json:"size"`
+	Locked    bool      `json:"locked"`
+	UpdatedAt time.Time `json:"updated_at"`
+	DeletedAt time.Time `json:"deleted_at"`
+	DeletedBy string    `json:"deleted_by,omitempty"`
+}
+
+// SearchResult 表示一条全文搜索结果
+type SearchResult struct {
+	Name       string    `json:"name"`
+	DateDir    string    `json:"date_dir"`
+	IsBackup   bool      `json:"is_backup"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	Size       int64     `json:"size"`
+	Score      int       `json:"score"`
+	Snippet    string    `json:"snippet"`
+	Highlights [][2]int  `json:"highlights"`
 }
 
+// APIKey 表示 API key 的元数据（不包含 token 哈希）
+type APIKey struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+	Active     bool       `json:"active"`
+}
+
+// User 表示用户账号（不包含密码哈希）
+type User struct {
+	Username  string    `json:"username"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// NoteACL 表示笔记的所有者和共享设置（Shares：用户名 -> read 或 write）
+type NoteACL struct {
+	Owner  string            `json:"owner"`
+	Public bool              `json:"public"`
+	Shares map[string]string `json:"shares"`
+}
+
+// ErrNoteModified 表示笔记在读取之后、保存之前被其他请求修改（SaveNoteIfUnchanged 没有保存）
+var ErrNoteModified = errors.New("note was modified by another request")
+
 // Dependencies 包含 handlers 需要的所有依赖
 type Dependencies struct {
+	// 工作区名称和绑定的 Host（默认工作区的名称为空）
+	Workspace      string
+	WorkspaceHosts []string
+
 	// 配置变量
-	AdminToken  string
-	AccessToken string
-	AdminPath   string
+	AdminToken string
+	AdminPath  string
 
 	// 笔记操作函数
-	GetAllNotes         func() ([]Note, error)
-	GetAllBackupNotes   func() ([]Note, error)
-	LoadNote            func(string) (string, error)
-	SaveNote            func(string, string) error
-	GenerateNoteName    func() string
-	IsSafeNoteName      func(string) bool
-	GetNotePath         func(string) string
-	FindNotePath        func(string) (string, error)
-	IsNoteExists        func(string) bool
-	GetFileCreationTime func(string) (time.Time, error)
+	GetAllNotes       func() ([]Note, error)
+	GetAllBackupNotes func() ([]Note, error)
+	LoadNote          func(string) (string, error)
+	SaveNote          func(string, string, string) error // 名称、内容、操作者（见 Actor）
+	GenerateNoteName  func() string
+	IsSafeNoteName    func(string) bool
+	StatNote          func(string) (NoteInfo, error)
+	IsNoteExists      func(string) bool
+
+	// 笔记的当前原始内容仍为读取时的内容才保存，否则返回 ErrNoteModified（名称、读取时的原始内容、内容、操作者）
+	SaveNoteIfUnchanged func(string, string, string, string) error
+
+	// 修订版本
+	ListRevisions func(string) ([]Revision, error)
+	LoadRevision  func(string, string) (string, error)
+	DiffRevisions func(string, string, string) (string, error)
+
+	// 归档笔记（名称、日期目录；日期目录为空时表示最新的副本）
+	FindArchivedNote func(string, string) (NoteInfo, error)
+	UnarchiveNote    func(string, string, string, string) (UnarchiveResult, error) // 名称、日期目录、冲突处理方式、操作者
+
+	// 回收站（名称、ID；ID 为空时表示最近删除的副本）
+	ListTrash        func() ([]TrashedNote, error)
+	FindTrash        func(string, string) (TrashedNote, error)
+	RestoreFromTrash func(string, string, string) (TrashedNote, error) // 名称、ID、操作者
+	PurgeTrash       func(string, string) (int, error)
+
+	// 快照导出和导入（导入参数：tar.gz 内容、导入模式、是否恢复配置文件）
+	ExportSnapshot func() (io.ReadCloser, error)
+	ImportSnapshot func(io.Reader, string, bool) (SnapshotReport, error)
+
+	// 归档策略和置顶
+	PlanArchive   func() (ArchivePlan, error)
+	SetNotePinned func(string, bool) error
+	TouchNote     func(string) // 记录笔记被读取（按读取时间归档时使用）
+
+	// 全文搜索（最后一个参数根据笔记名称和原始内容判断是否返回该笔记）
+	SearchNotes func(string, int, func(string, string) bool) ([]SearchResult, error)
+
+	// API key
+	AuthenticateAPIKey func(string, string) bool
+	HasActiveAPIKeys   func() bool
+	ListAPIKeys        func() []APIKey
+	CreateAPIKey       func(string, []string, *time.Time) (string, APIKey, error)
+	RevokeAPIKey       func(string) error
+
+	// 用户账号
+	AuthenticateUser func(string, string) bool
+	UserExists       func(string) bool
+	ListUsers        func() []User
+	CreateUser       func(string, string) error
+	DeleteUser       func(string) error
+	SetUserPassword  func(string, string) error
+
+	// 笔记所有权和共享
+	GetNoteACL   func(string) (NoteACL, bool)
+	SetNoteACL   func(string, NoteACL) error
+	ClaimNote    func(string, string) (bool, error)
+	GetUserNotes func(string) ([]string, map[string]string)
+
+	// 限流和认证失败锁定
+	RateLimiter *ratelimit.Limiter
+	// ClientIP 返回请求的客户端 IP（配置了可信代理时使用 X-Forwarded-For）
+	ClientIP func(*http.Request) string
+	// FromTrustedProxy 检查请求是否来自可信代理（用于判断 X-Forwarded-Proto）
+	FromTrustedProxy func(*http.Request) bool
+
+	// GetAllowedOrigins 返回除同源外允许发起修改请求和 WebSocket 连接的 Origin
+	GetAllowedOrigins func() []string
+
+	// 管理员和用户的登录 session
+	Sessions         session.Store
+	SessionStoreType string
+
+	// 笔记事件的 webhook 和 SSE 事件流
+	Webhooks *webhook.Manager
+	Events   *events.Broker
 
 	// 锁相关函数
 	HasNoteLock             func(string) bool
-	GetNoteLockToken        func(string) string
+	VerifyNoteLock          func(string, string) bool
 	GetNoteContent          func(string) string
+	KeepNoteLock            func(string, string) string
+	ContentETag             func(string) string
 	GetLockTokenFromRequest func(*http.Request, string) string
 	GetTokenFromRequest     func(*http.Request) string
 
@@ -47,6 +271,7 @@ type Dependencies struct {
 
 	// WebSocket
 	BroadcastUpdate func(string, string)
+	HandleWebSocket func(http.ResponseWriter, *http.Request)
 
 	// 配置保存
 	SaveConfig func()
@@ -70,7 +295,33 @@ type Dependencies struct {
 	GetUploadPath    func() string
 	SetAdminPath     func(string)
 	SetAccessToken   func(string)
+	GetAccessToken   func() string
 	SetAdminToken    func(string)
+	GetMaxRevisions  func() int
+	SetMaxRevisions  func(int)
+	GetRevisionDays  func() int
+	SetRevisionDays  func(int)
+	GetTrashDays     func() int
+	SetTrashDays     func(int)
+
+	// 归档策略的变量访问函数
+	GetArchiveBy          func() string
+	SetArchiveBy          func(string)
+	GetDeleteArchivedDays func() int
+	SetDeleteArchivedDays func(int)
+
+	// 备份文件夹和孤立上传文件的保留策略
+	GetArchiveMaxSize       func() int64
+	SetArchiveMaxSize       func(int64)
+	GetArchiveKeepLast      func() int
+	SetArchiveKeepLast      func(int)
+	GetOrphanUploadDays     func() int
+	SetOrphanUploadDays     func(int)
+	GetOrphanUploadMaxSize  func() int64
+	SetOrphanUploadMaxSize  func(int64)
+	GetOrphanUploadKeepLast func() int
+	SetOrphanUploadKeepLast func(int)
+	GetDiskUsage            func() (DiskUsage, error)
 
 	// 锁操作
 	RLockMaxTotalSize   func()
@@ -83,10 +334,7 @@ type Dependencies struct {
 	UnlockMaxNoteCount  func()
 }
 
-// 全局依赖变量
-var deps *Dependencies
-
-// Init 初始化 handlers 包的依赖
+// Init 初始化 handlers 包的依赖（默认工作区）
 func Init(d *Dependencies) {
-	deps = d
+	defaultDeps = d
 }
@@ -9,7 +9,10 @@ import (
 	"strings"
 	"time"
 
+	"github.com/hello--world/jot/apikey"
 	"github.com/hello--world/jot/htmlPage"
+	"github.com/hello--world/jot/ratelimit"
+	"github.com/hello--world/jot/webhook"
 )
 
 // getAdminSessionTokenFromRequest 从请求中获取 admin session token（从 cookie）
@@ -21,19 +24,30 @@ func getAdminSessionTokenFromRequest(r *http.Request) string {
 	return ""
 }
 
+// isAdminRequest 检查请求是否来自已登录的管理员或拥有 admin 权限的 API key（Authorization header）
+func isAdminRequest(r *http.Request) bool {
+	deps := depsFor(r)
+	if validateAdminSession(r, getAdminSessionTokenFromRequest(r)) {
+		return true
+	}
+	token := strings.TrimSpace(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+	return token != "" && deps.AuthenticateAPIKey(token, apikey.ScopeAdmin)
+}
+
 // HandleAdmin 处理管理后台请求
 func HandleAdmin(w http.ResponseWriter, r *http.Request) {
+	deps := depsFor(r)
 	// 首先检查是否有 session token（cookie）
 	sessionToken := getAdminSessionTokenFromRequest(r)
 	if sessionToken != "" {
 		// 验证 session token
-		if validateAdminSession(sessionToken) {
+		if validateAdminSession(r, sessionToken) {
 			// Session 有效，显示管理页面
 			serveAdminPage(w, r, sessionToken)
 			return
 		}
 		// Session 无效或过期，清除 cookie
-		http.SetCookie(w, &http.Cookie{
+		SetCookie(w, r, &http.Cookie{
 			Name:     "admin_session",
 			Value:    "",
 			Path:     "/",
@@ -60,14 +74,21 @@ func HandleAdmin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 拥有 admin 权限的 API key 可以直接访问（不创建 session）
+	if adminToken != deps.AdminToken && deps.AuthenticateAPIKey(adminToken, apikey.ScopeAdmin) {
+		serveAdminPage(w, r, "")
+		return
+	}
+
 	// 验证原始 admin token
 	if adminToken != deps.AdminToken || deps.AdminToken == "" {
+		ratelimit.MarkFailure(r)
 		http.Redirect(w, r, deps.AdminPath+"?error=invalid", http.StatusFound)
 		return
 	}
 
 	// Admin token 有效，创建 session token
-	sessionToken, err := createAdminSession()
+	sessionToken, err := createAdminSession(r)
 	if err != nil {
 		log.Printf("Error creating admin session: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -75,14 +96,13 @@ func HandleAdmin(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 设置 session token cookie
-	http.SetCookie(w, &http.Cookie{
+	SetCookie(w, r, &http.Cookie{
 		Name:     "admin_session",
 		Value:    sessionToken,
 		Path:     "/",
-		MaxAge:   int(sessionExpiry.Seconds()),
+		MaxAge:   int(sessionMaxLifetime.Seconds()),
 		HttpOnly: true,
 		SameSite: http.SameSiteStrictMode,
-		Secure:   false, // 如果使用 HTTPS，可以设置为 true
 	})
 
 	// 重定向到不带 token 的 URL
@@ -91,6 +111,7 @@ func HandleAdmin(w http.ResponseWriter, r *http.Request) {
 
 // serveAdminPage 显示管理页面
 func serveAdminPage(w http.ResponseWriter, r *http.Request, sessionToken string) {
+	deps := depsFor(r)
 
 	notes, err := deps.GetAllNotes()
 	if err != nil {
@@ -113,6 +134,22 @@ func serveAdminPage(w http.ResponseWriter, r *http.Request, sessionToken string)
 		return
 	}
 
+	trashedNotes, err := deps.ListTrash()
+	if err != nil {
+		log.Printf("Warning: Failed to list trash: %v", err)
+		trashedNotes = []TrashedNote{}
+	}
+
+	archivePlan, err := deps.PlanArchive()
+	if err != nil {
+		log.Printf("Warning: Failed to plan archive: %v", err)
+	}
+
+	diskUsage, err := deps.GetDiskUsage()
+	if err != nil {
+		log.Printf("Warning: Failed to get disk usage: %v", err)
+	}
+
 	// Calculate total size
 	var totalSize int64
 	for _, note := range notes {
@@ -223,6 +260,7 @@ func serveAdminPage(w http.ResponseWriter, r *http.Request, sessionToken string)
 		"formatDate": func(t time.Time) string {
 			return t.Format("2006-01-02 15:04:05")
 		},
+		"join": strings.Join,
 		"preview": func(content string, maxLen int) string {
 			if len(content) <= maxLen {
 				return content
@@ -242,6 +280,8 @@ func serveAdminPage(w http.ResponseWriter, r *http.Request, sessionToken string)
 		"BackupTotalSize":    backupTotalSize,
 		"TotalCount":         len(notes),
 		"BackupCount":        len(backupNotes),
+		"TrashedNotes":       trashedNotes,
+		"TrashCount":         len(trashedNotes),
 		"CurrentTotalSize":   currentTotalSize,
 		"MaxTotalSize":       currentMaxTotalSize,
 		"MaxNoteCount":       currentMaxNoteCount,
@@ -252,16 +292,38 @@ func serveAdminPage(w http.ResponseWriter, r *http.Request, sessionToken string)
 		"MaxFileSize":        deps.GetMaxFileSize(),
 		"MaxFileSizeMB":      currentMaxFileSizeMB,
 		"MaxPathLength":      deps.GetMaxPathLength(),
-		"AccessToken":        deps.AccessToken,
+		"MaxRevisions":       deps.GetMaxRevisions(),
+		"RevisionDays":       deps.GetRevisionDays(),
+		"TrashDays":          deps.GetTrashDays(),
+		"ArchiveBy":          deps.GetArchiveBy(),
+		"DeleteArchivedDays": deps.GetDeleteArchivedDays(),
+		"ArchivePlan":        archivePlan,
+		"DiskUsage":          diskUsage,
+		"AccessToken":        deps.GetAccessToken(),
+		"APIKeys":            deps.ListAPIKeys(),
+		"Users":              deps.ListUsers(),
+		"Sessions":           listSessions(r),
+		"SessionStoreType":   deps.SessionStoreType,
+		"RateLimit":          deps.RateLimiter.Config(),
+		"Lockouts":           deps.RateLimiter.Lockouts(),
+		"ClientIP":           deps.ClientIP(r),
+		"Webhooks":           listWebhooks(deps),
+		"WebhookEvents":      webhook.Events,
+		"WebhookDeliveries":  recentDeliveries(deps, adminDeliveryCount),
+
+		"ArchiveMaxSize":       deps.GetArchiveMaxSize(),
+		"ArchiveKeepLast":      deps.GetArchiveKeepLast(),
+		"OrphanUploadDays":     deps.GetOrphanUploadDays(),
+		"OrphanUploadMaxSize":  deps.GetOrphanUploadMaxSize(),
+		"OrphanUploadKeepLast": deps.GetOrphanUploadKeepLast(),
 	})
 }
 
 // HandleUpdateMaxTotalSize 处理配置更新请求
 func HandleUpdateMaxTotalSize(w http.ResponseWriter, r *http.Request) {
-	// Check session token authentication
-	sessionToken := getAdminSessionTokenFromRequest(r)
-
-	if !validateAdminSession(sessionToken) {
+	deps := depsFor(r)
+	// Check session token or admin API key authentication
+	if !isAdminRequest(r) {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
@@ -281,6 +343,18 @@ func HandleUpdateMaxTotalSize(w http.ResponseWriter, r *http.Request) {
 		MaxPathLength *int    `json:"maxPathLength,omitempty"`
 		MaxTotalSize  *string `json:"maxTotalSize,omitempty"`
 		MaxNoteCount  *int    `json:"maxNoteCount,omitempty"`
+		MaxRevisions  *int    `json:"maxRevisions,omitempty"`
+		RevisionDays  *int    `json:"revisionDays,omitempty"`
+		TrashDays     *int    `json:"trashDays,omitempty"`
+
+		ArchiveBy          *string `json:"archiveBy,omitempty"`
+		DeleteArchivedDays *int    `json:"deleteArchivedDays,omitempty"`
+
+		ArchiveMaxSize       *string `json:"archiveMaxSize,omitempty"`
+		ArchiveKeepLast      *int    `json:"archiveKeepLast,omitempty"`
+		OrphanUploadDays     *int    `json:"orphanUploadDays,omitempty"`
+		OrphanUploadMaxSize  *string `json:"orphanUploadMaxSize,omitempty"`
+		OrphanUploadKeepLast *int    `json:"orphanUploadKeepLast,omitempty"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -358,6 +432,70 @@ func HandleUpdateMaxTotalSize(w http.ResponseWriter, r *http.Request) {
 		updated = true
 	}
 
+	// Update max revisions per note if provided
+	if req.MaxRevisions != nil && *req.MaxRevisions > 0 {
+		deps.SetMaxRevisions(*req.MaxRevisions)
+		updated = true
+	}
+
+	// Update revision retention days if provided
+	if req.RevisionDays != nil && *req.RevisionDays > 0 {
+		deps.SetRevisionDays(*req.RevisionDays)
+		updated = true
+	}
+
+	// Update trash retention days if provided
+	if req.TrashDays != nil && *req.TrashDays > 0 {
+		deps.SetTrashDays(*req.TrashDays)
+		updated = true
+	}
+
+	// Update archive policy if provided（0 表示不删除归档笔记）
+	if req.ArchiveBy != nil && *req.ArchiveBy != "" {
+		if *req.ArchiveBy != "modified" && *req.ArchiveBy != "read" {
+			http.Error(w, fmt.Sprintf("Invalid archiveBy: %s (use modified or read)", *req.ArchiveBy), http.StatusBadRequest)
+			return
+		}
+		deps.SetArchiveBy(*req.ArchiveBy)
+		updated = true
+	}
+	if req.DeleteArchivedDays != nil && *req.DeleteArchivedDays >= 0 {
+		deps.SetDeleteArchivedDays(*req.DeleteArchivedDays)
+		updated = true
+	}
+
+	// Update archive and orphaned upload retention if provided（0 表示不限制）
+	if req.ArchiveMaxSize != nil && *req.ArchiveMaxSize != "" {
+		size, err := deps.ParseFileSize(*req.ArchiveMaxSize)
+		if err != nil || size < 0 {
+			http.Error(w, fmt.Sprintf("Invalid archiveMaxSize format: %s", *req.ArchiveMaxSize), http.StatusBadRequest)
+			return
+		}
+		deps.SetArchiveMaxSize(size)
+		updated = true
+	}
+	if req.ArchiveKeepLast != nil && *req.ArchiveKeepLast >= 0 {
+		deps.SetArchiveKeepLast(*req.ArchiveKeepLast)
+		updated = true
+	}
+	if req.OrphanUploadDays != nil && *req.OrphanUploadDays >= 0 {
+		deps.SetOrphanUploadDays(*req.OrphanUploadDays)
+		updated = true
+	}
+	if req.OrphanUploadMaxSize != nil && *req.OrphanUploadMaxSize != "" {
+		size, err := deps.ParseFileSize(*req.OrphanUploadMaxSize)
+		if err != nil || size < 0 {
+			http.Error(w, fmt.Sprintf("Invalid orphanUploadMaxSize format: %s", *req.OrphanUploadMaxSize), http.StatusBadRequest)
+			return
+		}
+		deps.SetOrphanUploadMaxSize(size)
+		updated = true
+	}
+	if req.OrphanUploadKeepLast != nil && *req.OrphanUploadKeepLast >= 0 {
+		deps.SetOrphanUploadKeepLast(*req.OrphanUploadKeepLast)
+		updated = true
+	}
+
 	// Save config to file
 	if updated {
 		deps.SaveConfig()
@@ -383,5 +521,17 @@ func HandleUpdateMaxTotalSize(w http.ResponseWriter, r *http.Request) {
 		"maxTotalSize":   currentMaxTotalSize,
 		"maxTotalSizeMB": currentMaxTotalSize / (1024 * 1024),
 		"maxNoteCount":   currentMaxNoteCount,
+		"maxRevisions":   deps.GetMaxRevisions(),
+		"revisionDays":   deps.GetRevisionDays(),
+		"trashDays":      deps.GetTrashDays(),
+
+		"archiveBy":          deps.GetArchiveBy(),
+		"deleteArchivedDays": deps.GetDeleteArchivedDays(),
+
+		"archiveMaxSize":       deps.GetArchiveMaxSize(),
+		"archiveKeepLast":      deps.GetArchiveKeepLast(),
+		"orphanUploadDays":     deps.GetOrphanUploadDays(),
+		"orphanUploadMaxSize":  deps.GetOrphanUploadMaxSize(),
+		"orphanUploadKeepLast": deps.GetOrphanUploadKeepLast(),
 	})
 }
@@ -0,0 +1,475 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/hello--world/jot/apikey"
+)
+
+const (
+	// defaultAPIPageSize 列表接口默认每页数量
+	defaultAPIPageSize = 50
+	// maxAPIPageSize 列表接口每页最大数量
+	maxAPIPageSize = 200
+)
+
+// APIError 表示 /api/v1 的错误响应：{"error": {"code": "...", "message": "..."}}
+type APIError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// APINote 表示 /api/v1 中的笔记资源（内容和 ETag 不包含锁标记）
+type APINote struct {
+	Name      string     `json:"name"`
+	Content   *string    `json:"content,omitempty"`
+	Size      int64      `json:"size"`
+	Locked    bool       `json:"locked"`
+	ETag      string     `json:"etag,omitempty"`
+	DateDir   string     `json:"date_dir"`
+	IsBackup  bool       `json:"is_backup"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// writeAPIJSON 写入 JSON 响应
+func writeAPIJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeAPIError 写入 JSON 错误响应
+func writeAPIError(w http.ResponseWriter, status int, code, message string) {
+	writeAPIJSON(w, status, map[string]APIError{"error": {Code: code, Message: message}})
+}
+
+// apiQuotaErrorCode 将 CheckNoteQuota 返回的状态码转换为错误码
+func apiQuotaErrorCode(status int) string {
+	if status == http.StatusForbidden {
+		return "note_limit_reached"
+	}
+	return "too_large"
+}
+
+// apiScope 返回请求需要的权限：GET 需要 read，其他方法需要 write
+func apiScope(r *http.Request) string {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return apikey.ScopeRead
+	}
+	return apikey.ScopeWrite
+}
+
+// checkAPIAccess 检查请求权限
+func checkAPIAccess(w http.ResponseWriter, r *http.Request) bool {
+	scope := apiScope(r)
+	if !Authorize(r, scope) {
+		writeAPIError(w, http.StatusUnauthorized, "unauthorized", "Access token or API key with "+scope+" scope required")
+		return false
+	}
+	return true
+}
+
+// loadAPINote 校验笔记名称、所有权和锁，返回笔记当前的原始内容（笔记不存在时为空字符串）
+// 校验失败时已写入错误响应，返回 ok=false
+func loadAPINote(w http.ResponseWriter, r *http.Request, noteName string) (string, bool) {
+	deps := depsFor(r)
+	if noteName == "" || !deps.IsSafeNoteName(noteName) {
+		writeAPIError(w, http.StatusBadRequest, "invalid_name", "Invalid note name")
+		return "", false
+	}
+	if !noteACLAllows(r, noteName, apiScope(r)) {
+		writeAPIError(w, http.StatusForbidden, "forbidden", "Note belongs to another user")
+		return "", false
+	}
+
+	rawContent, err := deps.LoadNote(noteName)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "internal_error", err.Error())
+		return "", false
+	}
+	if deps.HasNoteLock(rawContent) && !deps.VerifyNoteLock(rawContent, deps.GetLockTokenFromRequest(r, noteName)) {
+		writeAPIError(w, http.StatusUnauthorized, "note_locked", "Note is locked. Provide lock_token parameter or X-Lock-Token header.")
+		return "", false
+	}
+	return rawContent, true
+}
+
+// newAPINote 根据原始内容和存储元数据构造笔记资源
+func newAPINote(r *http.Request, noteName, rawContent string, withContent bool) APINote {
+	deps := depsFor(r)
+	n := APINote{
+		Name:   noteName,
+		Size:   int64(len(rawContent)),
+		Locked: deps.HasNoteLock(rawContent),
+		ETag:   deps.ContentETag(rawContent),
+	}
+	if info, err := deps.StatNote(noteName); err == nil {
+		createdAt := info.CreatedAt
+		n.Size = info.Size
+		n.DateDir = info.DateDir
+		n.IsBackup = info.IsBackup
+		n.CreatedAt = &createdAt
+		n.UpdatedAt = info.ModTime
+	}
+	if withContent {
+		content := deps.GetNoteContent(rawContent)
+		n.Content = &content
+	}
+	return n
+}
+
+// readAPIContent 读取请求体中的笔记内容
+// 支持 application/json（{"content": "..."}）和 text/plain（整个请求体）
+func readAPIContent(w http.ResponseWriter, r *http.Request) (name, content string, ok bool) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "text/plain" {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, "invalid_body", "Failed to read request body")
+			return "", "", false
+		}
+		return "", string(body), true
+	}
+
+	var req struct {
+		Name    string  `json:"name"`
+		Content *string `json:"content"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_body", "Request body must be JSON like {\"content\": \"...\"} or text/plain")
+		return "", "", false
+	}
+	if req.Content == nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_body", "Missing content field")
+		return "", "", false
+	}
+	return req.Name, *req.Content, true
+}
+
+// HandleAPINotes 处理 /api/v1/notes：GET 列出笔记，POST 创建笔记
+func HandleAPINotes(w http.ResponseWriter, r *http.Request) {
+	if !checkAPIAccess(w, r) {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		handleAPIListNotes(w, r)
+	case http.MethodPost:
+		handleAPICreateNote(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeAPIError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed")
+	}
+}
+
+// handleAPIListNotes 分页列出笔记元数据（按更新时间倒序）
+// 参数：page（从 1 开始）、per_page（默认 50，最大 200）、archived=1 列出备份笔记
+func handleAPIListNotes(w http.ResponseWriter, r *http.Request) {
+	deps := depsFor(r)
+	query := r.URL.Query()
+	page, perPage := 1, defaultAPIPageSize
+	if value := query.Get("page"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			writeAPIError(w, http.StatusBadRequest, "invalid_parameter", "page must be a positive integer")
+			return
+		}
+		page = n
+	}
+	if value := query.Get("per_page"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxAPIPageSize {
+			writeAPIError(w, http.StatusBadRequest, "invalid_parameter", "per_page must be between 1 and "+strconv.Itoa(maxAPIPageSize))
+			return
+		}
+		perPage = n
+	}
+
+	getNotes := deps.GetAllNotes
+	if archived := query.Get("archived"); archived == "1" || archived == "true" {
+		getNotes = deps.GetAllBackupNotes
+	}
+	allNotes, err := getNotes()
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "internal_error", err.Error())
+		return
+	}
+	// 只列出请求者可以查看的笔记
+	notes := make([]Note, 0, len(allNotes))
+	for _, n := range allNotes {
+		if noteACLAllows(r, n.Name, apikey.ScopeRead) {
+			notes = append(notes, n)
+		}
+	}
+	sort.Slice(notes, func(i, j int) bool {
+		if !notes[i].UpdatedAt.Equal(notes[j].UpdatedAt) {
+			return notes[i].UpdatedAt.After(notes[j].UpdatedAt)
+		}
+		return notes[i].Name < notes[j].Name
+	})
+
+	start := (page - 1) * perPage
+	if start > len(notes) {
+		start = len(notes)
+	}
+	end := start + perPage
+	if end > len(notes) {
+		end = len(notes)
+	}
+
+	items := make([]APINote, 0, end-start)
+	for _, n := range notes[start:end] {
+		item := APINote{
+			Name:      n.Name,
+			Size:      n.Size,
+			Locked:    deps.HasNoteLock(n.Content),
+			DateDir:   n.DateDir,
+			IsBackup:  n.IsBackup,
+			UpdatedAt: n.UpdatedAt,
+		}
+		// 加锁笔记的 ETag 由内容计算，不在列表中返回
+		if !item.Locked {
+			item.ETag = deps.ContentETag(n.Content)
+		}
+		items = append(items, item)
+	}
+
+	writeAPIJSON(w, http.StatusOK, map[string]interface{}{
+		"notes":    items,
+		"page":     page,
+		"per_page": perPage,
+		"total":    len(notes),
+		"has_more": end < len(notes),
+	})
+}
+
+// handleAPICreateNote 创建笔记，未提供名称时自动生成
+// 笔记已存在时返回 409（同时创建同名笔记的请求中只有一个能成功）
+func handleAPICreateNote(w http.ResponseWriter, r *http.Request) {
+	deps := depsFor(r)
+	noteName, content, ok := readAPIContent(w, r)
+	if !ok {
+		return
+	}
+	if content == "" {
+		writeAPIError(w, http.StatusBadRequest, "invalid_body", "Content must not be empty")
+		return
+	}
+
+	if noteName == "" {
+		noteName = deps.GenerateNoteName()
+		if noteName == "" {
+			writeAPIError(w, http.StatusInternalServerError, "internal_error", "Failed to generate note name")
+			return
+		}
+	} else {
+		if !deps.IsSafeNoteName(noteName) {
+			writeAPIError(w, http.StatusBadRequest, "invalid_name", "Invalid note name")
+			return
+		}
+		if _, err := deps.StatNote(noteName); err == nil {
+			writeAPIError(w, http.StatusConflict, "already_exists", "Note already exists")
+			return
+		}
+	}
+
+	if status, err := deps.CheckNoteQuota(noteName, int64(len(content))); err != nil {
+		writeAPIError(w, status, apiQuotaErrorCode(status), err.Error())
+		return
+	}
+	// 只有笔记仍然不存在时才保存
+	err := deps.SaveNoteIfUnchanged(noteName, "", content, Actor(r))
+	if errors.Is(err, ErrNoteModified) {
+		writeAPIError(w, http.StatusConflict, "already_exists", "Note already exists")
+		return
+	}
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "internal_error", err.Error())
+		return
+	}
+	claimNewNote(r, noteName)
+	deps.BroadcastUpdate(noteName, deps.GetNoteContent(content))
+
+	saved, _ := deps.LoadNote(noteName)
+	w.Header().Set("Location", "/api/v1/notes/"+noteName)
+	w.Header().Set("ETag", deps.ContentETag(saved))
+	writeAPIJSON(w, http.StatusCreated, newAPINote(r, noteName, saved, true))
+}
+
+// HandleAPINote 处理 /api/v1/notes/{note}：GET 读取、PUT 创建或更新、DELETE 删除
+func HandleAPINote(w http.ResponseWriter, r *http.Request) {
+	if !checkAPIAccess(w, r) {
+		return
+	}
+
+	noteName := mux.Vars(r)["note"]
+	switch r.Method {
+	case http.MethodGet:
+		handleAPIGetNote(w, r, noteName, true)
+	case http.MethodPut:
+		handleAPIUpdateNote(w, r, noteName)
+	case http.MethodDelete:
+		handleAPIDeleteNote(w, r, noteName)
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		writeAPIError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed")
+	}
+}
+
+// HandleAPINoteMeta 处理 /api/v1/notes/{note}/meta：只返回笔记元数据
+func HandleAPINoteMeta(w http.ResponseWriter, r *http.Request) {
+	if !checkAPIAccess(w, r) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		writeAPIError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed")
+		return
+	}
+	handleAPIGetNote(w, r, mux.Vars(r)["note"], false)
+}
+
+// handleAPIGetNote 读取笔记，Accept 为 text/plain 时直接返回纯文本内容
+func handleAPIGetNote(w http.ResponseWriter, r *http.Request, noteName string, withContent bool) {
+	deps := depsFor(r)
+	rawContent, ok := loadAPINote(w, r, noteName)
+	if !ok {
+		return
+	}
+	if rawContent == "" {
+		writeAPIError(w, http.StatusNotFound, "not_found", "Note not found")
+		return
+	}
+	if withContent {
+		deps.TouchNote(noteName)
+	}
+
+	w.Header().Set("ETag", deps.ContentETag(rawContent))
+	if withContent && strings.HasPrefix(r.Header.Get("Accept"), "text/plain") {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(deps.GetNoteContent(rawContent)))
+		return
+	}
+	writeAPIJSON(w, http.StatusOK, newAPINote(r, noteName, rawContent, withContent))
+}
+
+// handleAPIUpdateNote 更新笔记（不存在时创建）
+// 提交的内容不带锁标记时保留原有的锁，使用 ?unlock=1 移除锁；If-Match 不匹配时返回 412
+func handleAPIUpdateNote(w http.ResponseWriter, r *http.Request, noteName string) {
+	deps := depsFor(r)
+	existingContent, ok := loadAPINote(w, r, noteName)
+	if !ok {
+		return
+	}
+	_, content, ok := readAPIContent(w, r)
+	if !ok {
+		return
+	}
+	if content == "" {
+		writeAPIError(w, http.StatusBadRequest, "invalid_body", "Content must not be empty, use DELETE to remove a note")
+		return
+	}
+	if deps.HasNoteLock(existingContent) && !deps.HasNoteLock(content) && r.URL.Query().Get("unlock") == "" {
+		content = deps.KeepNoteLock(existingContent, content)
+	}
+
+	currentETag := deps.ContentETag(existingContent)
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch != "" && !matchETag(ifMatch, currentETag, existingContent != "") {
+		w.Header().Set("ETag", currentETag)
+		writeAPIError(w, http.StatusPreconditionFailed, "etag_mismatch", "Note has been modified")
+		return
+	}
+
+	if status, err := deps.CheckNoteQuota(noteName, int64(len(content))); err != nil {
+		writeAPIError(w, status, apiQuotaErrorCode(status), err.Error())
+		return
+	}
+	var err error
+	if ifMatch != "" {
+		err = deps.SaveNoteIfUnchanged(noteName, existingContent, content, Actor(r))
+	} else {
+		err = deps.SaveNote(noteName, content, Actor(r))
+	}
+	if errors.Is(err, ErrNoteModified) {
+		if current, err := deps.LoadNote(noteName); err == nil {
+			w.Header().Set("ETag", deps.ContentETag(current))
+		}
+		writeAPIError(w, http.StatusPreconditionFailed, "etag_mismatch", "Note has been modified")
+		return
+	}
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "internal_error", err.Error())
+		return
+	}
+	deps.BroadcastUpdate(noteName, deps.GetNoteContent(content))
+
+	status := http.StatusOK
+	if existingContent == "" {
+		claimNewNote(r, noteName)
+		status = http.StatusCreated
+		w.Header().Set("Location", "/api/v1/notes/"+noteName)
+	}
+	saved, _ := deps.LoadNote(noteName)
+	w.Header().Set("ETag", deps.ContentETag(saved))
+	writeAPIJSON(w, status, newAPINote(r, noteName, saved, true))
+}
+
+// handleAPIDeleteNote 删除笔记，If-Match 不匹配时返回 412
+func handleAPIDeleteNote(w http.ResponseWriter, r *http.Request, noteName string) {
+	deps := depsFor(r)
+	existingContent, ok := loadAPINote(w, r, noteName)
+	if !ok {
+		return
+	}
+	if existingContent == "" {
+		writeAPIError(w, http.StatusNotFound, "not_found", "Note not found")
+		return
+	}
+
+	currentETag := deps.ContentETag(existingContent)
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch != "" && !matchETag(ifMatch, currentETag, true) {
+		w.Header().Set("ETag", currentETag)
+		writeAPIError(w, http.StatusPreconditionFailed, "etag_mismatch", "Note has been modified")
+		return
+	}
+
+	// 带 If-Match 时和更新一样，只有笔记在上面的检查之后没有被修改才删除
+	var err error
+	if ifMatch != "" {
+		err = deps.SaveNoteIfUnchanged(noteName, existingContent, "", Actor(r))
+	} else {
+		err = deps.SaveNote(noteName, "", Actor(r))
+	}
+	if errors.Is(err, ErrNoteModified) {
+		if current, err := deps.LoadNote(noteName); err == nil {
+			w.Header().Set("ETag", deps.ContentETag(current))
+		}
+		writeAPIError(w, http.StatusPreconditionFailed, "etag_mismatch", "Note has been modified")
+		return
+	}
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "internal_error", err.Error())
+		return
+	}
+	deps.BroadcastUpdate(noteName, "")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleAPINotFound 处理 /api/v1 下不存在的路径
+func HandleAPINotFound(w http.ResponseWriter, r *http.Request) {
+	writeAPIError(w, http.StatusNotFound, "not_found", "Endpoint not found")
+}
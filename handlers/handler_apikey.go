@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// HandleListAPIKeys 列出所有 API key（不包含 token）
+func HandleListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	deps := depsFor(r)
+	if !isAdminRequest(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"keys": deps.ListAPIKeys(),
+	})
+}
+
+// HandleCreateAPIKey 创建 API key，明文 token 只在响应中返回一次
+// 请求体：{"name": "ci", "scopes": ["read", "write"], "expiresAt": "2025-12-31"}（expiresAt 可选，也可以是 RFC3339 时间）
+func HandleCreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	deps := depsFor(r)
+	if !isAdminRequest(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		Name      string   `json:"name"`
+		Scopes    []string `json:"scopes"`
+		ExpiresAt string   `json:"expiresAt"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var expiresAt *time.Time
+	if value := strings.TrimSpace(req.ExpiresAt); value != "" {
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			// 只有日期时在当天结束时过期
+			date, dateErr := time.ParseInLocation("2006-01-02", value, time.Local)
+			if dateErr != nil {
+				http.Error(w, "Invalid expiresAt format, use YYYY-MM-DD or RFC3339", http.StatusBadRequest)
+				return
+			}
+			t = date.AddDate(0, 0, 1).Add(-time.Second)
+		}
+		if !t.After(time.Now()) {
+			http.Error(w, "expiresAt must be in the future", http.StatusBadRequest)
+			return
+		}
+		expiresAt = &t
+	}
+
+	token, key, err := deps.CreateAPIKey(req.Name, req.Scopes, expiresAt)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"token":   token,
+		"key":     key,
+	})
+}
+
+// HandleRevokeAPIKey 吊销 API key
+func HandleRevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	deps := depsFor(r)
+	if !isAdminRequest(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := deps.RevokeAPIKey(mux.Vars(r)["id"]); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+	})
+}
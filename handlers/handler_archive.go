@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+
+	"github.com/gorilla/mux"
+
+	"github.com/hello--world/jot/apikey"
+)
+
+// HandleUnarchiveNote 将备份文件夹中的笔记移回活跃笔记：POST /api/notes/{note}/unarchive
+// date 参数指定日期目录（默认为最新的归档副本）；conflict 参数指定同名活跃笔记已存在时的处理方式：
+// fail（默认，返回 409）、overwrite（覆盖，活跃笔记的内容保存为修订版本）或 rename（恢复为 名称-日期目录）
+func HandleUnarchiveNote(w http.ResponseWriter, r *http.Request) {
+	deps := depsFor(r)
+	noteName := mux.Vars(r)["note"]
+	if _, ok := checkNoteAccess(w, r, noteName, apikey.ScopeWrite); !ok {
+		return
+	}
+	dateDir := r.URL.Query().Get("date")
+	conflict := r.URL.Query().Get("conflict")
+
+	archived, err := deps.FindArchivedNote(noteName, dateDir)
+	if err != nil {
+		writeUnarchiveError(w, err)
+		return
+	}
+	// 重命名恢复时按新笔记检查数量限制
+	quotaName := noteName
+	if conflict == "rename" && deps.IsNoteExists(noteName) {
+		quotaName = noteName + "-" + archived.DateDir
+	}
+	if status, err := deps.CheckNoteQuota(quotaName, archived.Size); err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	result, err := deps.UnarchiveNote(noteName, archived.DateDir, conflict, Actor(r))
+	if err != nil {
+		writeUnarchiveError(w, err)
+		return
+	}
+
+	// Broadcast update to WebSocket clients (without lock marker)
+	if content, err := deps.LoadNote(result.Name); err == nil {
+		deps.BroadcastUpdate(result.Name, deps.GetNoteContent(content))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"result":  result,
+	})
+}
+
+// HandleArchivePlan 预览下一次归档会移动到备份文件夹和彻底删除的笔记（管理员，不修改任何笔记）：GET /api/archive/plan
+func HandleArchivePlan(w http.ResponseWriter, r *http.Request) {
+	deps := depsFor(r)
+	if !isAdminRequest(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	plan, err := deps.PlanArchive()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(plan)
+}
+
+// HandleDiskUsage 返回备份文件夹、回收站、修订版本和上传文件的磁盘占用（管理员）：GET /api/archive/usage
+// 只有活跃笔记和上传文件计入总大小限制，备份文件夹由保留策略（archiveMaxSize 等）限制
+func HandleDiskUsage(w http.ResponseWriter, r *http.Request) {
+	deps := depsFor(r)
+	if !isAdminRequest(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	usage, err := deps.GetDiskUsage()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(usage)
+}
+
+// HandlePinNote 设置或取消笔记的置顶：POST /api/notes/{note}/pin，请求体为 {"pinned": true}
+// 置顶的笔记不会被归档，已归档的置顶笔记不会被删除；需要笔记的 write 权限
+func HandlePinNote(w http.ResponseWriter, r *http.Request) {
+	deps := depsFor(r)
+	noteName := mux.Vars(r)["note"]
+	if _, ok := checkNoteAccess(w, r, noteName, apikey.ScopeWrite); !ok {
+		return
+	}
+
+	var req struct {
+		Pinned *bool `json:"pinned"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Pinned == nil {
+		http.Error(w, "Request body must be JSON like {\"pinned\": true}", http.StatusBadRequest)
+		return
+	}
+
+	if err := deps.SetNotePinned(noteName, *req.Pinned); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			http.Error(w, "Note not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"pinned":  *req.Pinned,
+	})
+}
+
+// writeUnarchiveError 根据恢复归档笔记的错误返回 400、404、409 或 500
+func writeUnarchiveError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, os.ErrInvalid):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, os.ErrNotExist):
+		http.Error(w, "Archived note not found", http.StatusNotFound)
+	case errors.Is(err, os.ErrExist):
+		http.Error(w, "Active note already exists, use conflict=overwrite or conflict=rename", http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
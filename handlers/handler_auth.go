@@ -1,10 +1,55 @@
 package handlers
 
 import (
+	"crypto/subtle"
+	"fmt"
 	"net/http"
 	"strings"
+
+	"github.com/hello--world/jot/apikey"
 )
 
+// AuthRequired 返回站点是否需要认证（设置了 access token 或存在可用的 API key）
+func AuthRequired(r *http.Request) bool {
+	deps := depsFor(r)
+	return deps.GetAccessToken() != "" || deps.HasActiveAPIKeys()
+}
+
+// Authorize 检查请求是否拥有指定权限
+// 旧的 access token 和已登录的用户拥有 read、write、upload 权限，API key 按 scope 检查（admin 包含所有权限）
+func Authorize(r *http.Request, scope string) bool {
+	deps := depsFor(r)
+	if !AuthRequired(r) {
+		return true
+	}
+	if scope != apikey.ScopeAdmin && CurrentUser(r) != "" {
+		return true
+	}
+	return AuthorizeToken(r, deps.GetTokenFromRequest(r), scope)
+}
+
+// AuthorizeToken 检查 token 是否为 access token 或拥有指定权限的 API key
+func AuthorizeToken(r *http.Request, token, scope string) bool {
+	deps := depsFor(r)
+	if token == "" {
+		return false
+	}
+	if accessToken := deps.GetAccessToken(); accessToken != "" && scope != apikey.ScopeAdmin &&
+		subtle.ConstantTimeCompare([]byte(token), []byte(accessToken)) == 1 {
+		return true
+	}
+	return deps.AuthenticateAPIKey(token, scope)
+}
+
+// requireScope 检查请求权限，失败时写入 401 响应
+func requireScope(w http.ResponseWriter, r *http.Request, scope string) bool {
+	if Authorize(r, scope) {
+		return true
+	}
+	http.Error(w, fmt.Sprintf("Unauthorized: Access token or API key with %s scope required", scope), http.StatusUnauthorized)
+	return false
+}
+
 // GetTokenFromRequest 从请求中提取 token（优先级：cookie > query 参数 > Authorization header）
 func GetTokenFromRequest(r *http.Request) string {
 	// First try cookie
@@ -29,9 +74,13 @@ func GetTokenFromRequest(r *http.Request) string {
 	return token
 }
 
-// GetLockTokenFromRequest 从请求中提取锁 token（从 query 参数、cookie 或 Authorization header）
+// GetLockTokenFromRequest 从请求中提取锁 token（从 query 参数、X-Lock-Token header、cookie 或 Authorization header）
 func GetLockTokenFromRequest(r *http.Request, noteName string) string {
 	token := r.URL.Query().Get("lock_token")
+	if token == "" {
+		// 单独的 header，便于同时提供访问令牌和锁令牌
+		token = r.Header.Get("X-Lock-Token")
+	}
 	if token == "" {
 		// Try to get from cookie
 		cookie, err := r.Cookie("note_lock_" + noteName)
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/hello--world/jot/events"
+)
+
+// sseKeepAliveInterval 事件流发送注释行的间隔，防止代理关闭空闲连接
+const sseKeepAliveInterval = 25 * time.Second
+
+// sseRetryMillis 建议客户端断线后重连的等待时间
+const sseRetryMillis = 3000
+
+// HandleNoteEvents 以 Server-Sent Events 推送笔记的变化：GET /api/notes/{note}/events
+// 权限与读取笔记相同（read 权限、所有权和锁）；支持 Last-Event-ID 断线续传
+func HandleNoteEvents(w http.ResponseWriter, r *http.Request) {
+	if !checkAPIAccess(w, r) {
+		return
+	}
+	noteName := mux.Vars(r)["note"]
+	if _, ok := loadAPINote(w, r, noteName); !ok {
+		return
+	}
+	serveEvents(w, r, noteName)
+}
+
+// HandleEvents 以 Server-Sent Events 推送所有笔记的变化（管理员）：GET /api/events
+func HandleEvents(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	serveEvents(w, r, "")
+}
+
+// serveEvents 订阅事件并写入事件流，直到客户端断开或服务关闭
+// 无法从 Last-Event-ID 完整续传时先发送 reset 事件，客户端应重新读取笔记
+func serveEvents(w http.ResponseWriter, r *http.Request, noteName string) {
+	deps := depsFor(r)
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	// 浏览器的 EventSource 重连时发送 Last-Event-ID header，其他客户端也可以使用 lastEventId 参数
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("lastEventId")
+	}
+	var lastID uint64
+	if lastEventID != "" {
+		id, err := strconv.ParseUint(strings.TrimSpace(lastEventID), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+		lastID = id
+	}
+
+	replay, complete, ch, cancel := deps.Events.Subscribe(noteName, lastID)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // 关闭 nginx 的响应缓冲
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: %d\n\n", sseRetryMillis)
+	if !complete {
+		fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+	}
+	for _, e := range replay {
+		writeEvent(w, e)
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(sseKeepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-ch:
+			if !ok {
+				return
+			}
+			// 订阅后笔记可能被加锁、转移所有权或撤销访问凭据，每次推送前重新检查
+			if noteName != "" && !noteEventsAllowed(r, noteName) {
+				return
+			}
+			writeEvent(w, e)
+		case <-keepAlive.C:
+			if noteName != "" && !noteEventsAllowed(r, noteName) {
+				return
+			}
+			fmt.Fprint(w, ": keep-alive\n\n")
+		}
+		flusher.Flush()
+	}
+}
+
+// noteEventsAllowed 检查请求是否仍可读取笔记（与 HandleNoteEvents 订阅时的检查相同，但不写入响应）
+// 不再允许时关闭事件流，客户端重连时会收到对应的错误响应
+func noteEventsAllowed(r *http.Request, noteName string) bool {
+	deps := depsFor(r)
+	scope := apiScope(r)
+	if !Authorize(r, scope) || !noteACLAllows(r, noteName, scope) {
+		return false
+	}
+	rawContent, err := deps.LoadNote(noteName)
+	if err != nil {
+		return false
+	}
+	return !deps.HasNoteLock(rawContent) || deps.VerifyNoteLock(rawContent, deps.GetLockTokenFromRequest(r, noteName))
+}
+
+// writeEvent 写入一条事件（data 是单行 JSON）
+func writeEvent(w http.ResponseWriter, e events.Event) {
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, e.Data)
+}
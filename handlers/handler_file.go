@@ -12,22 +12,21 @@ import (
 	"time"
 
 	"github.com/gorilla/mux"
+
+	"github.com/hello--world/jot/apikey"
 )
 
 // HandleFileUpload 处理文件上传请求
 func HandleFileUpload(w http.ResponseWriter, r *http.Request) {
+	deps := depsFor(r)
 	if r.Method != "POST" {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Check access token for file uploads
-	if deps.AccessToken != "" {
-		token := deps.GetTokenFromRequest(r)
-		if token != deps.AccessToken {
-			http.Error(w, "Unauthorized: Access token required", http.StatusUnauthorized)
-			return
-		}
+	// Check upload scope for file uploads
+	if !requireScope(w, r, apikey.ScopeUpload) {
+		return
 	}
 
 	// Parse multipart form (max 100MB)
@@ -139,13 +138,10 @@ func HandleFileUpload(w http.ResponseWriter, r *http.Request) {
 
 // HandleFileDownload 处理文件下载请求
 func HandleFileDownload(w http.ResponseWriter, r *http.Request) {
-	// 检查 access token（如果站点有 token，需要验证）
-	if deps.AccessToken != "" {
-		token := deps.GetTokenFromRequest(r)
-		if token != deps.AccessToken {
-			http.Error(w, "Unauthorized: Access token required", http.StatusUnauthorized)
-			return
-		}
+	deps := depsFor(r)
+	// 检查 read 权限（如果站点需要认证）
+	if !requireScope(w, r, apikey.ScopeRead) {
+		return
 	}
 
 	vars := mux.Vars(r)
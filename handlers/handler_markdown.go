@@ -5,6 +5,8 @@ import (
 	"net/http"
 
 	"github.com/russross/blackfriday/v2"
+
+	"github.com/hello--world/jot/apikey"
 )
 
 // HandleMarkdownRender 处理 Markdown 渲染请求
@@ -14,13 +16,9 @@ func HandleMarkdownRender(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 检查 access token（如果站点有 token，需要验证）
-	if deps.AccessToken != "" {
-		token := deps.GetTokenFromRequest(r)
-		if token != deps.AccessToken {
-			http.Error(w, "Unauthorized: Access token required", http.StatusUnauthorized)
-			return
-		}
+	// 检查 read 权限（如果站点需要认证）
+	if !requireScope(w, r, apikey.ScopeRead) {
+		return
 	}
 
 	body, _ := io.ReadAll(r.Body)
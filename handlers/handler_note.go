@@ -1,23 +1,27 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"html/template"
 	"io"
 	"log"
 	"net/http"
-	"os"
+	"net/url"
 	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/russross/blackfriday/v2"
 
+	"github.com/hello--world/jot/apikey"
 	"github.com/hello--world/jot/htmlPage"
+	"github.com/hello--world/jot/ratelimit"
 )
 
 // HandleNote 处理笔记的 GET 和 POST 请求
 func HandleNote(w http.ResponseWriter, r *http.Request) {
+	deps := depsFor(r)
 	vars := mux.Vars(r)
 	noteName := vars["note"]
 
@@ -41,23 +45,35 @@ func HandleNote(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleNoteGet(w http.ResponseWriter, r *http.Request, noteName string) {
-	// 检查 access token（如果站点有 token，不带 /read 的路径需要 token）
-	if deps.AccessToken != "" {
-		token := deps.GetTokenFromRequest(r)
-		if token != deps.AccessToken {
-			// 如果是浏览器请求（不是 curl/wget），显示登录页面
-			if !strings.HasPrefix(r.UserAgent(), "curl") && !strings.HasPrefix(r.UserAgent(), "Wget") {
-				w.Header().Set("Content-Type", "text/html; charset=utf-8")
-				w.Write([]byte(htmlPage.AccessLoginHTML))
-				return
+	deps := depsFor(r)
+	// 检查 access token 或 API key（如果站点需要认证，不带 /read 的路径需要 read 权限）
+	if !Authorize(r, apikey.ScopeRead) {
+		// 如果是浏览器请求（不是 curl/wget），显示登录页面
+		if !strings.HasPrefix(r.UserAgent(), "curl") && !strings.HasPrefix(r.UserAgent(), "Wget") {
+			if deps.GetTokenFromRequest(r) != "" {
+				ratelimit.MarkFailure(r)
 			}
-			http.Error(w, "Unauthorized: Access token required", http.StatusUnauthorized)
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(htmlPage.AccessLoginHTML))
+			return
+		}
+		http.Error(w, "Unauthorized: Access token required", http.StatusUnauthorized)
+		return
+	}
+
+	// 检查笔记所有权：属于其他用户的笔记需要共享或公开才能查看
+	isCurlOrWget := strings.HasPrefix(r.UserAgent(), "curl") || strings.HasPrefix(r.UserAgent(), "Wget")
+	if !noteACLAllows(r, noteName, apikey.ScopeRead) && !isPublicNote(r, noteName) {
+		if !isCurlOrWget && CurrentUser(r) == "" {
+			http.Redirect(w, r, "/login?next="+url.QueryEscape("/"+noteName), http.StatusFound)
 			return
 		}
+		http.Error(w, "Forbidden: Note belongs to another user", http.StatusForbidden)
+		return
 	}
 
 	// 检查是否是 raw 请求或 curl/wget
-	if r.URL.Query().Get("raw") != "" || strings.HasPrefix(r.UserAgent(), "curl") || strings.HasPrefix(r.UserAgent(), "Wget") {
+	if r.URL.Query().Get("raw") != "" || isCurlOrWget {
 		content, err := deps.LoadNote(noteName)
 		if err != nil || content == "" {
 			http.NotFound(w, r)
@@ -65,19 +81,26 @@ func handleNoteGet(w http.ResponseWriter, r *http.Request, noteName string) {
 		}
 		// Check note lock for raw requests
 		if deps.HasNoteLock(content) {
-			lockToken := deps.GetNoteLockToken(content)
 			providedToken := deps.GetLockTokenFromRequest(r, noteName)
-			if providedToken != lockToken {
+			if !deps.VerifyNoteLock(content, providedToken) {
 				http.Error(w, "Unauthorized: Note is locked. Provide lock_token parameter or Authorization header.", http.StatusUnauthorized)
 				return
 			}
 			content = deps.GetNoteContent(content)
 		}
+		deps.TouchNote(noteName)
 		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("ETag", deps.ContentETag(content))
 		w.Write([]byte(content))
 		return
 	}
 
+	// 只有只读权限（只读共享或公开的笔记）时显示只读页面
+	if !noteACLAllows(r, noteName, apikey.ScopeWrite) {
+		http.Redirect(w, r, "/read/"+url.PathEscape(noteName), http.StatusFound)
+		return
+	}
+
 	// Serve HTML page
 	rawContent, err := deps.LoadNote(noteName)
 	if err != nil {
@@ -86,10 +109,13 @@ func handleNoteGet(w http.ResponseWriter, r *http.Request, noteName string) {
 	}
 
 	// Check note lock
-	if deps.HasNoteLock(rawContent) {
-		lockToken := deps.GetNoteLockToken(rawContent)
+	isLocked := deps.HasNoteLock(rawContent)
+	if isLocked {
 		providedToken := deps.GetLockTokenFromRequest(r, noteName)
-		if providedToken != lockToken {
+		if !deps.VerifyNoteLock(rawContent, providedToken) {
+			if providedToken != "" {
+				ratelimit.MarkFailure(r)
+			}
 			// Show lock login page
 			w.Header().Set("Content-Type", "text/html; charset=utf-8")
 			tmpl := template.Must(template.New("lock").Parse(htmlPage.NoteLockHTML))
@@ -103,32 +129,21 @@ func handleNoteGet(w http.ResponseWriter, r *http.Request, noteName string) {
 	}
 
 	content := rawContent
+	if content != "" {
+		deps.TouchNote(noteName)
+	}
 
-	// 获取文件信息（大小和修改时间）
+	// 获取笔记信息（大小、修改时间和创建时间）
 	var fileSize int64
 	var modTime time.Time
 	var createTime time.Time
-	notePath, err := deps.FindNotePath(noteName)
-	now := time.Now()
-
-	if err == nil {
-		if info, err := os.Stat(notePath); err == nil {
-			fileSize = info.Size()
-			modTime = info.ModTime()
-			// 尝试获取创建时间（Windows 上可用，其他平台回退到修改时间）
-			if ct, err := deps.GetFileCreationTime(notePath); err == nil {
-				createTime = ct
-			} else {
-				// 如果无法获取创建时间，回退到修改时间
-				createTime = modTime
-			}
-		} else {
-			// 文件不存在（新建笔记），使用当前时间
-			modTime = now
-			createTime = now
-		}
+	if info, err := deps.StatNote(noteName); err == nil {
+		fileSize = info.Size
+		modTime = info.ModTime
+		createTime = info.CreatedAt
 	} else {
-		// 文件不存在（新建笔记），使用当前时间
+		// 笔记不存在（新建笔记），使用当前时间
+		now := time.Now()
 		modTime = now
 		createTime = now
 	}
@@ -139,111 +154,198 @@ func handleNoteGet(w http.ResponseWriter, r *http.Request, noteName string) {
 		sizeStr = fmt.Sprintf("%.2f KB", float64(fileSize)/1024.0)
 	}
 
-	tmpl := template.Must(template.New("note").Parse(htmlPage.NotePageHTML))
-	tmpl.Execute(w, map[string]interface{}{
-		"NoteName":   noteName,
-		"Content":    template.HTML(template.HTMLEscapeString(content)),
-		"FileSize":   sizeStr,
-		"ModTime":    modTime.Format("2006-01-02 15:04:05"),
-		"CreateTime": createTime.Format("2006-01-02 15:04:05"),
-	})
+	acl, _ := deps.GetNoteACL(noteName)
 
-	// Set cookie if token was provided
+	// Set cookie if token was provided（必须在写入页面之前设置）
+	// 页面脚本需要读取锁令牌，所以不能设置 HttpOnly
 	if lockToken := r.URL.Query().Get("lock_token"); lockToken != "" {
-		http.SetCookie(w, &http.Cookie{
+		SetCookie(w, r, &http.Cookie{
 			Name:     "note_lock_" + noteName,
 			Value:    lockToken,
 			Path:     "/",
 			MaxAge:   86400, // 24 hours
 			HttpOnly: false,
+			SameSite: http.SameSiteStrictMode,
 		})
 	}
+
+	tmpl := template.Must(template.New("note").Parse(htmlPage.NotePageHTML))
+	tmpl.Execute(w, map[string]interface{}{
+		"NoteName":         noteName,
+		"NotePath":         "/" + noteName,
+		"Content":          template.HTML(template.HTMLEscapeString(content)),
+		"FileSize":         sizeStr,
+		"ModTime":          modTime.Format("2006-01-02 15:04:05"),
+		"CreateTime":       createTime.Format("2006-01-02 15:04:05"),
+		"IsLocked":         isLocked,
+		"ETag":             deps.ContentETag(content),
+		"Owner":            acl.Owner,
+		"Username":         CurrentUser(r),
+		"AccountsEnabled":  len(deps.ListUsers()) > 0,
+		"CanManageSharing": fileSize > 0 && canManageSharing(r, noteName, acl),
+	})
 }
 
 func handleNotePost(w http.ResponseWriter, r *http.Request, noteName string) {
-	// Check access token for POST requests (creating/updating notes)
-	if deps.AccessToken != "" {
-		token := deps.GetTokenFromRequest(r)
-		if token != deps.AccessToken {
-			http.Error(w, "Unauthorized: Access token required", http.StatusUnauthorized)
-			return
-		}
+	deps := depsFor(r)
+	// Check write scope and note ownership for POST requests (creating/updating notes)
+	if !requireNoteScope(w, r, noteName, apikey.ScopeWrite) {
+		return
 	}
 
 	body, _ := io.ReadAll(r.Body)
 	content := string(body)
 
-	// Handle form-encoded data
+	// Handle form-encoded data（请求体已经读取，从读取的内容中解析表单，其次是 URL 参数）
 	if r.Header.Get("Content-Type") == "application/x-www-form-urlencoded" {
-		r.ParseForm()
-		if text := r.FormValue("text"); text != "" {
+		form, _ := url.ParseQuery(content)
+		text := form.Get("text")
+		if text == "" {
+			text = r.URL.Query().Get("text")
+		}
+		if text != "" {
 			content = text
 		}
 	}
 
-	// Check file size limit
-	contentSize := int64(len([]byte(content)))
-	if contentSize > deps.GetMaxFileSize() {
-		http.Error(w, fmt.Sprintf("File size exceeds maximum limit of %d bytes (%d MB)", deps.GetMaxFileSize(), deps.GetMaxFileSize()/(1024*1024)), http.StatusRequestEntityTooLarge)
+	// Check note lock: saves, deletes and lock changes all require the lock token
+	existingContent, err := deps.LoadNote(noteName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if deps.HasNoteLock(existingContent) {
+		providedToken := deps.GetLockTokenFromRequest(r, noteName)
+		if !deps.VerifyNoteLock(existingContent, providedToken) {
+			http.Error(w, "Unauthorized: Note is locked. Provide lock_token parameter or Authorization header.", http.StatusUnauthorized)
+			return
+		}
+		// 提交的内容不带锁标记时保留原有的锁，只有显式 unlock 才移除
+		if content != "" && !deps.HasNoteLock(content) && r.URL.Query().Get("unlock") == "" {
+			content = deps.KeepNoteLock(existingContent, content)
+		}
+	}
+
+	// Check If-Match: 笔记在客户端读取之后被修改过时返回 409 和当前内容
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch != "" && !matchETag(ifMatch, deps.ContentETag(existingContent), existingContent != "") {
+		writeNoteConflict(w, r, existingContent)
+		return
+	}
+
+	// Check file size, note count and total size limits
+	if status, err := deps.CheckNoteQuota(noteName, int64(len([]byte(content)))); err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	// 带 If-Match 时只有笔记在上面的检查之后没有被修改才保存，同时提交的请求中只有一个能成功
+	if ifMatch != "" {
+		err = deps.SaveNoteIfUnchanged(noteName, existingContent, content, Actor(r))
+	} else {
+		err = deps.SaveNote(noteName, content, Actor(r))
+	}
+	if errors.Is(err, ErrNoteModified) {
+		if current, err := deps.LoadNote(noteName); err == nil {
+			writeNoteConflict(w, r, current)
+			return
+		}
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if existingContent == "" && content != "" {
+		claimNewNote(r, noteName)
+	}
+
+	// Broadcast update to WebSocket clients (without lock marker)
+	deps.BroadcastUpdate(noteName, deps.GetNoteContent(content))
+
+	w.Header().Set("ETag", deps.ContentETag(content))
+	w.WriteHeader(http.StatusOK)
+}
+
+// writeNoteConflict 返回 409 和笔记的当前内容（不含锁标记）及其 ETag
+func writeNoteConflict(w http.ResponseWriter, r *http.Request, current string) {
+	deps := depsFor(r)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("ETag", deps.ContentETag(current))
+	w.WriteHeader(http.StatusConflict)
+	w.Write([]byte(deps.GetNoteContent(current)))
+}
+
+// CheckNoteQuota 检查保存笔记是否超出单文件大小、笔记数量和总大小限制
+// 超出限制时返回对应的 HTTP 状态码和错误
+func (d *Dependencies) CheckNoteQuota(noteName string, contentSize int64) (int, error) {
+	// Check file size limit
+	if contentSize > d.GetMaxFileSize() {
+		return http.StatusRequestEntityTooLarge, fmt.Errorf("File size exceeds maximum limit of %d bytes (%d MB)", d.GetMaxFileSize(), d.GetMaxFileSize()/(1024*1024))
+	}
 
 	// Check note count limit (only for new notes)
-	wasNewNote := !deps.IsNoteExists(noteName)
+	wasNewNote := !d.IsNoteExists(noteName)
 	if wasNewNote {
-		deps.RLockMaxNoteCount()
-		currentMaxNoteCount := deps.GetMaxNoteCount()
-		deps.RUnlockMaxNoteCount()
+		d.RLockMaxNoteCount()
+		currentMaxNoteCount := d.GetMaxNoteCount()
+		d.RUnlockMaxNoteCount()
 
 		// Count existing notes
-		notes, err := deps.GetAllNotes()
+		notes, err := d.GetAllNotes()
 		if err != nil {
 			log.Printf("Error getting notes: %v", err)
 		} else {
 			if len(notes) >= currentMaxNoteCount {
-				http.Error(w, fmt.Sprintf("Maximum number of notes (%d) has been reached. Please delete some notes or increase the limit in admin panel.", currentMaxNoteCount), http.StatusForbidden)
-				return
+				return http.StatusForbidden, fmt.Errorf("Maximum number of notes (%d) has been reached. Please delete some notes or increase the limit in admin panel.", currentMaxNoteCount)
 			}
 		}
 	}
 
 	// Check total file size limit
-	deps.RLockMaxTotalSize()
-	currentMaxTotalSize := deps.GetMaxTotalSize()
-	deps.RUnlockMaxTotalSize()
+	d.RLockMaxTotalSize()
+	currentMaxTotalSize := d.GetMaxTotalSize()
+	d.RUnlockMaxTotalSize()
 
-	currentTotalSize, err := deps.GetTotalFileSize()
+	currentTotalSize, err := d.GetTotalFileSize()
 	if err != nil {
 		log.Printf("Error calculating total file size: %v", err)
 	} else {
 		// Get current note size if it exists
 		var currentNoteSize int64
-		if notePath, err := deps.FindNotePath(noteName); err == nil {
-			if info, err := os.Stat(notePath); err == nil {
-				currentNoteSize = info.Size()
-			}
+		if info, err := d.StatNote(noteName); err == nil {
+			currentNoteSize = info.Size
 		}
 		// Calculate new total size
 		newTotalSize := currentTotalSize - currentNoteSize + contentSize
 		if newTotalSize > currentMaxTotalSize {
-			http.Error(w, fmt.Sprintf("Total file size would exceed maximum limit of %d MB (current: %.2f MB, would be: %.2f MB)", currentMaxTotalSize/(1024*1024), float64(currentTotalSize)/(1024*1024), float64(newTotalSize)/(1024*1024)), http.StatusRequestEntityTooLarge)
-			return
+			return http.StatusRequestEntityTooLarge, fmt.Errorf("Total file size would exceed maximum limit of %d MB (current: %.2f MB, would be: %.2f MB)", currentMaxTotalSize/(1024*1024), float64(currentTotalSize)/(1024*1024), float64(newTotalSize)/(1024*1024))
 		}
 	}
 
-	if err := deps.SaveNote(noteName, content); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	// Broadcast update to WebSocket clients
-	deps.BroadcastUpdate(noteName, content)
+	return http.StatusOK, nil
+}
 
-	w.WriteHeader(http.StatusOK)
+// matchETag 检查 If-Match 头是否匹配当前 ETag
+// 支持 "*"（笔记存在时匹配）、逗号分隔的多个 ETag 以及弱 ETag 前缀
+func matchETag(ifMatch, currentETag string, exists bool) bool {
+	for _, tag := range strings.Split(ifMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			if exists {
+				return true
+			}
+			continue
+		}
+		if strings.TrimPrefix(tag, "W/") == currentETag {
+			return true
+		}
+	}
+	return false
 }
 
 // HandleReadNote 处理只读笔记页面
 func HandleReadNote(w http.ResponseWriter, r *http.Request) {
+	deps := depsFor(r)
 	if r.Method != "GET" {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -269,6 +371,16 @@ func HandleReadNote(w http.ResponseWriter, r *http.Request) {
 	userAgent := strings.ToLower(r.Header.Get("User-Agent"))
 	isCurlOrWget := strings.Contains(userAgent, "curl") || strings.Contains(userAgent, "wget")
 
+	// 属于用户的笔记只有公开后才能不经认证只读访问，否则需要所有者、共享用户或管理员
+	if !isPublicNote(r, noteName) && !noteACLAllows(r, noteName, apikey.ScopeRead) {
+		if !isCurlOrWget && CurrentUser(r) == "" {
+			http.Redirect(w, r, "/login?next="+url.QueryEscape("/read/"+noteName), http.StatusFound)
+			return
+		}
+		http.Error(w, "Forbidden: Note belongs to another user", http.StatusForbidden)
+		return
+	}
+
 	// 检查是否是 raw 请求（下载原始内容）
 	isRawRequest := r.URL.Query().Get("raw") != ""
 
@@ -276,9 +388,8 @@ func HandleReadNote(w http.ResponseWriter, r *http.Request) {
 	if isRawRequest || isCurlOrWget {
 		// Check note lock
 		if deps.HasNoteLock(rawContent) {
-			lockToken := deps.GetNoteLockToken(rawContent)
 			providedToken := deps.GetLockTokenFromRequest(r, noteName)
-			if providedToken != lockToken {
+			if !deps.VerifyNoteLock(rawContent, providedToken) {
 				// Token 不正确，返回错误
 				w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 				http.Error(w, "Unauthorized: Note is locked. Provide correct lock_token parameter.", http.StatusUnauthorized)
@@ -287,6 +398,9 @@ func HandleReadNote(w http.ResponseWriter, r *http.Request) {
 			// Token is correct, extract actual content (remove lock marker)
 			rawContent = deps.GetNoteContent(rawContent)
 		}
+		if rawContent != "" {
+			deps.TouchNote(noteName)
+		}
 		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 		w.Write([]byte(rawContent))
 		return
@@ -294,9 +408,11 @@ func HandleReadNote(w http.ResponseWriter, r *http.Request) {
 
 	// 非 raw 请求，检查锁
 	if deps.HasNoteLock(rawContent) {
-		lockToken := deps.GetNoteLockToken(rawContent)
 		providedToken := deps.GetLockTokenFromRequest(r, noteName)
-		if providedToken != lockToken {
+		if !deps.VerifyNoteLock(rawContent, providedToken) {
+			if providedToken != "" {
+				ratelimit.MarkFailure(r)
+			}
 			// 显示 HTML 锁登录页面
 			w.Header().Set("Content-Type", "text/html; charset=utf-8")
 			tmpl := template.Must(template.New("lock").Parse(htmlPage.NoteLockHTML))
@@ -310,25 +426,18 @@ func HandleReadNote(w http.ResponseWriter, r *http.Request) {
 	}
 
 	content := rawContent
+	if content != "" {
+		deps.TouchNote(noteName)
+	}
 
-	// 获取文件信息（大小和修改时间）
+	// 获取笔记信息（大小、修改时间和创建时间）
 	var fileSize int64
 	var modTime time.Time
 	var createTime time.Time
-	notePath, err := deps.FindNotePath(noteName)
-
-	if err == nil {
-		if info, err := os.Stat(notePath); err == nil {
-			fileSize = info.Size()
-			modTime = info.ModTime()
-			// 尝试获取创建时间（Windows 上可用，其他平台回退到修改时间）
-			if ct, err := deps.GetFileCreationTime(notePath); err == nil {
-				createTime = ct
-			} else {
-				// 如果无法获取创建时间，回退到修改时间
-				createTime = modTime
-			}
-		}
+	if info, err := deps.StatNote(noteName); err == nil {
+		fileSize = info.Size
+		modTime = info.ModTime
+		createTime = info.CreatedAt
 	}
 
 	// Format size
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/hello--world/jot/ratelimit"
+)
+
+// HandleRateLimitStatus 返回限流配置和当前被锁定的客户端 IP 和笔记（管理员）：GET /api/rate-limit
+func HandleRateLimitStatus(w http.ResponseWriter, r *http.Request) {
+	deps := depsFor(r)
+	if !isAdminRequest(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"config":   deps.RateLimiter.Config(),
+		"lockouts": deps.RateLimiter.Lockouts(),
+		"clientIP": deps.ClientIP(r),
+	})
+}
+
+// HandleRateLimitUnlock 解除客户端 IP 或笔记的锁定（管理员）：POST /api/rate-limit/unlock
+// 请求体：{"kind": "ip", "value": "203.0.113.7"} 或 {"kind": "note", "value": "abc"}
+func HandleRateLimitUnlock(w http.ResponseWriter, r *http.Request) {
+	deps := depsFor(r)
+	if !isAdminRequest(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		Kind  string `json:"kind"`
+		Value string `json:"value"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Kind != ratelimit.KindIP && req.Kind != ratelimit.KindNote {
+		http.Error(w, "Invalid kind, use ip or note", http.StatusBadRequest)
+		return
+	}
+	if !deps.RateLimiter.Unlock(req.Kind, req.Value) {
+		http.Error(w, "No failed attempts recorded", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+	})
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+
+	"github.com/gorilla/mux"
+
+	"github.com/hello--world/jot/apikey"
+)
+
+// checkNoteAccess 检查请求权限、笔记所有权和笔记锁，返回笔记当前的原始内容
+// 校验失败时已写入错误响应，返回 ok=false
+func checkNoteAccess(w http.ResponseWriter, r *http.Request, noteName, scope string) (string, bool) {
+	deps := depsFor(r)
+	if noteName == "" || !deps.IsSafeNoteName(noteName) {
+		http.Error(w, "Invalid note name", http.StatusBadRequest)
+		return "", false
+	}
+
+	if !requireNoteScope(w, r, noteName, scope) {
+		return "", false
+	}
+
+	rawContent, err := deps.LoadNote(noteName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return "", false
+	}
+	if deps.HasNoteLock(rawContent) {
+		providedToken := deps.GetLockTokenFromRequest(r, noteName)
+		if !deps.VerifyNoteLock(rawContent, providedToken) {
+			http.Error(w, "Unauthorized: Note is locked. Provide lock_token parameter or Authorization header.", http.StatusUnauthorized)
+			return "", false
+		}
+	}
+	return rawContent, true
+}
+
+// checkRevisionLock 检查修订版本自己的锁（保存修订版本时笔记可能有锁，之后锁被移除或笔记被删除后重新创建）
+// 校验失败时已写入错误响应，返回 false
+func checkRevisionLock(w http.ResponseWriter, r *http.Request, noteName, content string) bool {
+	deps := depsFor(r)
+	if deps.HasNoteLock(content) && !deps.VerifyNoteLock(content, deps.GetLockTokenFromRequest(r, noteName)) {
+		http.Error(w, "Unauthorized: Revision is locked. Provide the lock_token it was saved with.", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// writeRevisionError 写入加载修订版本失败的错误响应
+func writeRevisionError(w http.ResponseWriter, r *http.Request, err error) {
+	if os.IsNotExist(err) {
+		http.NotFound(w, r)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+// HandleListRevisions 列出笔记的修订版本
+func HandleListRevisions(w http.ResponseWriter, r *http.Request) {
+	deps := depsFor(r)
+	noteName := mux.Vars(r)["note"]
+	if _, ok := checkNoteAccess(w, r, noteName, apikey.ScopeRead); !ok {
+		return
+	}
+
+	revisions, err := deps.ListRevisions(noteName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"note":      noteName,
+		"revisions": revisions,
+	})
+}
+
+// HandleGetRevision 获取指定修订版本的内容（纯文本，不带锁标记）
+func HandleGetRevision(w http.ResponseWriter, r *http.Request) {
+	deps := depsFor(r)
+	vars := mux.Vars(r)
+	noteName := vars["note"]
+	if _, ok := checkNoteAccess(w, r, noteName, apikey.ScopeRead); !ok {
+		return
+	}
+
+	content, err := deps.LoadRevision(noteName, vars["revision"])
+	if err != nil {
+		writeRevisionError(w, r, err)
+		return
+	}
+	if !checkRevisionLock(w, r, noteName, content) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(deps.GetNoteContent(content)))
+}
+
+// HandleDiffRevisions 比较两个修订版本（from 和 to 默认为 current）
+func HandleDiffRevisions(w http.ResponseWriter, r *http.Request) {
+	deps := depsFor(r)
+	noteName := mux.Vars(r)["note"]
+	if _, ok := checkNoteAccess(w, r, noteName, apikey.ScopeRead); !ok {
+		return
+	}
+
+	from := r.URL.Query().Get("from")
+	to := r.URL.Query().Get("to")
+	if from == "" {
+		http.Error(w, "Missing from parameter", http.StatusBadRequest)
+		return
+	}
+	if to == "" {
+		to = "current"
+	}
+	for _, id := range []string{from, to} {
+		content, err := deps.LoadRevision(noteName, id)
+		if err != nil {
+			writeRevisionError(w, r, err)
+			return
+		}
+		if !checkRevisionLock(w, r, noteName, content) {
+			return
+		}
+	}
+
+	diff, err := deps.DiffRevisions(noteName, from, to)
+	if err != nil {
+		if os.IsNotExist(err) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(diff))
+}
+
+// HandleRestoreRevision 将笔记恢复到指定修订版本
+// 恢复前的内容会作为新的修订版本保存，锁状态保持不变
+func HandleRestoreRevision(w http.ResponseWriter, r *http.Request) {
+	deps := depsFor(r)
+	vars := mux.Vars(r)
+	noteName := vars["note"]
+	rawContent, ok := checkNoteAccess(w, r, noteName, apikey.ScopeWrite)
+	if !ok {
+		return
+	}
+
+	revContent, err := deps.LoadRevision(noteName, vars["revision"])
+	if err != nil {
+		writeRevisionError(w, r, err)
+		return
+	}
+	if !checkRevisionLock(w, r, noteName, revContent) {
+		return
+	}
+
+	content := deps.KeepNoteLock(rawContent, revContent)
+	if status, err := deps.CheckNoteQuota(noteName, int64(len(content))); err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	if err := deps.SaveNote(noteName, content, Actor(r)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Broadcast update to WebSocket clients (without lock marker)
+	deps.BroadcastUpdate(noteName, deps.GetNoteContent(content))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"note":     noteName,
+		"revision": vars["revision"],
+	})
+}
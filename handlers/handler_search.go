@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/hello--world/jot/apikey"
+	"github.com/hello--world/jot/ratelimit"
+)
+
+const (
+	// defaultSearchLimit 默认返回的搜索结果数
+	defaultSearchLimit = 20
+	// maxSearchLimit 单次最多返回的搜索结果数
+	maxSearchLimit = 100
+)
+
+// HandleSearch 全文搜索活跃笔记和备份笔记：GET /api/search?q=关键词&limit=20
+// 需要 read 权限（如果站点需要认证）或管理员权限
+// 属于其他用户的笔记不会返回；加锁的笔记只有在请求中提供了正确的锁 token 时才会返回，
+// 提供的锁 token 不匹配任何加锁的候选笔记时返回 401 并计入认证失败次数
+func HandleSearch(w http.ResponseWriter, r *http.Request) {
+	deps := depsFor(r)
+	if !isAdminRequest(r) && !requireScope(w, r, apikey.ScopeRead) {
+		return
+	}
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		http.Error(w, "Missing q parameter", http.StatusBadRequest)
+		return
+	}
+
+	limit := defaultSearchLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+		if n > maxSearchLimit {
+			n = maxSearchLimit
+		}
+		limit = n
+	}
+
+	// 显式提供的锁 token（lock_token 参数或 X-Lock-Token header）会与每个加锁的候选笔记比较，
+	// 不匹配任何一个时按认证失败处理，以免搜索被用来不受限制地猜测锁 token
+	explicitToken := r.URL.Query().Get("lock_token") != "" || r.Header.Get("X-Lock-Token") != ""
+	lockedCount, unlockedCount := 0, 0
+	allow := func(noteName, content string) bool {
+		if !noteACLAllows(r, noteName, apikey.ScopeRead) {
+			return false
+		}
+		if !deps.HasNoteLock(content) {
+			return true
+		}
+		lockedCount++
+		token := deps.GetLockTokenFromRequest(r, noteName)
+		if token != "" && deps.VerifyNoteLock(content, token) {
+			unlockedCount++
+			return true
+		}
+		return false
+	}
+	results, err := deps.SearchNotes(query, limit, allow)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if explicitToken && lockedCount > 0 && unlockedCount == 0 {
+		ratelimit.MarkFailure(r)
+		http.Error(w, "Unauthorized: lock_token does not match any locked note", http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"query":   query,
+		"results": results,
+	})
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/hello--world/jot/session"
+)
+
+// SessionInfo 表示管理后台显示的登录 session
+type SessionInfo struct {
+	ID         string    `json:"id"`
+	Kind       string    `json:"kind"`
+	Username   string    `json:"username,omitempty"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"userAgent"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	Current    bool      `json:"current"` // 是否是当前请求使用的 session
+}
+
+// listSessions 返回所有未过期的 session，标记当前请求使用的 session
+func listSessions(r *http.Request) []SessionInfo {
+	deps := depsFor(r)
+	currentIDs := make(map[string]bool)
+	if token := getAdminSessionTokenFromRequest(r); token != "" {
+		currentIDs[session.HashToken(token)] = true
+	}
+	if cookie, err := r.Cookie("user_session"); err == nil && cookie.Value != "" {
+		currentIDs[session.HashToken(strings.TrimSpace(cookie.Value))] = true
+	}
+
+	now := time.Now()
+	sessions := deps.Sessions.List()
+	result := make([]SessionInfo, 0, len(sessions))
+	for _, s := range sessions {
+		if s.Expired(now) {
+			continue
+		}
+		result = append(result, SessionInfo{
+			ID:         s.ID,
+			Kind:       s.Kind,
+			Username:   s.Username,
+			IP:         s.IP,
+			UserAgent:  s.UserAgent,
+			CreatedAt:  s.CreatedAt,
+			LastSeenAt: s.LastSeenAt,
+			ExpiresAt:  s.ExpiresAt,
+			Current:    currentIDs[s.ID],
+		})
+	}
+	return result
+}
+
+// HandleListSessions 列出所有登录 session（管理员）：GET /api/sessions
+func HandleListSessions(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"sessions": listSessions(r),
+	})
+}
+
+// HandleRevokeSession 退出指定的 session（管理员）：POST /api/sessions/{id}/revoke
+func HandleRevokeSession(w http.ResponseWriter, r *http.Request) {
+	deps := depsFor(r)
+	if !isAdminRequest(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	if _, ok := deps.Sessions.Get(id); !ok {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	if err := deps.Sessions.Delete(id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+	})
+}
+
+// HandleRevokeAllSessions 退出所有管理员和用户 session（管理员）：POST /api/sessions/revoke-all
+// 包括当前请求使用的 session，之后需要重新登录
+func HandleRevokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	deps := depsFor(r)
+	if !isAdminRequest(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	count, err := deps.Sessions.DeleteFunc(func(session.Session) bool { return true })
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("Revoked all %d login session(s)", count)
+
+	SetCookie(w, r, &http.Cookie{
+		Name:     "admin_session",
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"revoked": count,
+	})
+}
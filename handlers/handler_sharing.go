@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/hello--world/jot/apikey"
+)
+
+// 共享权限
+const (
+	sharePermissionRead  = "read"
+	sharePermissionWrite = "write"
+)
+
+// CurrentUser 返回请求中已登录用户的用户名（未登录时为空）
+func CurrentUser(r *http.Request) string {
+	deps := depsFor(r)
+	cookie, err := r.Cookie("user_session")
+	if err != nil || cookie.Value == "" {
+		return ""
+	}
+	username, ok := validateUserSession(r, strings.TrimSpace(cookie.Value))
+	if !ok || !deps.UserExists(username) {
+		return ""
+	}
+	return username
+}
+
+// Actor 返回记录在笔记事件中的操作者：登录用户的用户名，管理员为 "@admin"（用户名不能包含 @），匿名时为空
+func Actor(r *http.Request) string {
+	if username := CurrentUser(r); username != "" {
+		return username
+	}
+	if isAdminRequest(r) {
+		return "@admin"
+	}
+	return ""
+}
+
+// noteACLAllows 检查请求是否满足笔记的所有权限制
+// 没有所有者的笔记不受限制；有所有者的笔记只有所有者、共享用户和管理员可以访问
+func noteACLAllows(r *http.Request, noteName, scope string) bool {
+	deps := depsFor(r)
+	acl, ok := deps.GetNoteACL(noteName)
+	if !ok {
+		return true
+	}
+	if username := CurrentUser(r); username != "" {
+		if username == acl.Owner {
+			return true
+		}
+		if perm, shared := acl.Shares[username]; shared && (scope == apikey.ScopeRead || perm == sharePermissionWrite) {
+			return true
+		}
+	}
+	return isAdminRequest(r)
+}
+
+// AuthorizeNote 检查请求是否可以以指定权限访问笔记（站点认证和笔记所有权）
+func AuthorizeNote(r *http.Request, noteName, scope string) bool {
+	return Authorize(r, scope) && noteACLAllows(r, noteName, scope)
+}
+
+// requireNoteScope 检查笔记的访问权限，失败时写入 401 或 403 响应
+func requireNoteScope(w http.ResponseWriter, r *http.Request, noteName, scope string) bool {
+	if !requireScope(w, r, scope) {
+		return false
+	}
+	if !noteACLAllows(r, noteName, scope) {
+		http.Error(w, "Forbidden: Note belongs to another user", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// isPublicNote 检查笔记是否被所有者公开（所有人可以通过 /read 只读访问）
+func isPublicNote(r *http.Request, noteName string) bool {
+	deps := depsFor(r)
+	acl, ok := deps.GetNoteACL(noteName)
+	return ok && acl.Public
+}
+
+// claimNewNote 将新建的笔记归属于当前登录的用户（未登录时不记录所有者）
+func claimNewNote(r *http.Request, noteName string) {
+	deps := depsFor(r)
+	username := CurrentUser(r)
+	if username == "" {
+		return
+	}
+	if _, err := deps.ClaimNote(noteName, username); err != nil {
+		log.Printf("Error setting owner of note %s: %v", noteName, err)
+	}
+}
+
+// HandleGetSharing 返回笔记的所有者和共享设置：GET /api/notes/{note}/sharing
+func HandleGetSharing(w http.ResponseWriter, r *http.Request) {
+	deps := depsFor(r)
+	noteName := mux.Vars(r)["note"]
+	if noteName == "" || !deps.IsSafeNoteName(noteName) {
+		http.Error(w, "Invalid note name", http.StatusBadRequest)
+		return
+	}
+	if !requireNoteScope(w, r, noteName, apikey.ScopeRead) {
+		return
+	}
+
+	acl, _ := deps.GetNoteACL(noteName)
+	if acl.Shares == nil {
+		acl.Shares = map[string]string{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"note":      noteName,
+		"owner":     acl.Owner,
+		"public":    acl.Public,
+		"shares":    acl.Shares,
+		"canManage": canManageSharing(r, noteName, acl),
+	})
+}
+
+// canManageSharing 检查请求能否修改笔记的共享设置
+// 所有者和管理员可以修改；没有所有者的笔记可以被能修改笔记内容的登录用户认领
+func canManageSharing(r *http.Request, noteName string, acl NoteACL) bool {
+	if isAdminRequest(r) {
+		return true
+	}
+	username := CurrentUser(r)
+	if username == "" {
+		return false
+	}
+	if acl.Owner != "" {
+		return acl.Owner == username
+	}
+	return noteLockAllows(r, noteName)
+}
+
+// noteLockAllows 检查请求能否修改笔记内容：笔记锁定时需要提供正确的锁 token
+func noteLockAllows(r *http.Request, noteName string) bool {
+	deps := depsFor(r)
+	content, err := deps.LoadNote(noteName)
+	if err != nil {
+		return false
+	}
+	return !deps.HasNoteLock(content) || deps.VerifyNoteLock(content, deps.GetLockTokenFromRequest(r, noteName))
+}
+
+// HandleUpdateSharing 修改笔记的所有者和共享设置：POST /api/notes/{note}/sharing
+// 请求体：{"public": true, "shares": {"alice": "write", "bob": "read"}, "owner": "alice"}（字段均可选）
+// 登录用户修改没有所有者的笔记时会成为所有者（锁定的笔记需要提供锁 token）；owner 为空字符串时取消所有者（笔记恢复为公共笔记）
+func HandleUpdateSharing(w http.ResponseWriter, r *http.Request) {
+	deps := depsFor(r)
+	noteName := mux.Vars(r)["note"]
+	if noteName == "" || !deps.IsSafeNoteName(noteName) {
+		http.Error(w, "Invalid note name", http.StatusBadRequest)
+		return
+	}
+	if !requireNoteScope(w, r, noteName, apikey.ScopeWrite) {
+		return
+	}
+	if _, err := deps.StatNote(noteName); err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	acl, _ := deps.GetNoteACL(noteName)
+	if !canManageSharing(r, noteName, acl) {
+		if acl.Owner == "" && CurrentUser(r) != "" {
+			http.Error(w, "Unauthorized: Note is locked. Provide the lock_token to claim it.", http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, "Forbidden: Only the owner can change sharing settings", http.StatusForbidden)
+		return
+	}
+
+	var req struct {
+		Owner  *string           `json:"owner"`
+		Public *bool             `json:"public"`
+		Shares map[string]string `json:"shares"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if acl.Owner == "" {
+		acl.Owner = CurrentUser(r)
+	}
+	if req.Owner != nil {
+		owner := strings.TrimSpace(*req.Owner)
+		if owner != "" && !deps.UserExists(owner) {
+			http.Error(w, "User not found: "+owner, http.StatusBadRequest)
+			return
+		}
+		acl.Owner = owner
+	}
+	if acl.Owner == "" && (req.Owner == nil || req.Public != nil || req.Shares != nil) {
+		http.Error(w, "Note has no owner, specify owner", http.StatusBadRequest)
+		return
+	}
+	if req.Public != nil {
+		acl.Public = *req.Public
+	}
+	if req.Shares != nil {
+		shares := make(map[string]string, len(req.Shares))
+		for username, perm := range req.Shares {
+			if perm != sharePermissionRead && perm != sharePermissionWrite {
+				http.Error(w, "Invalid permission for "+username+", use read or write", http.StatusBadRequest)
+				return
+			}
+			if !deps.UserExists(username) {
+				http.Error(w, "User not found: "+username, http.StatusBadRequest)
+				return
+			}
+			shares[username] = perm
+		}
+		acl.Shares = shares
+	}
+	if acl.Owner == "" {
+		acl = NoteACL{}
+	}
+	delete(acl.Shares, acl.Owner)
+
+	if err := deps.SetNoteACL(noteName, acl); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if acl.Shares == nil {
+		acl.Shares = map[string]string{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"note":    noteName,
+		"owner":   acl.Owner,
+		"public":  acl.Public,
+		"shares":  acl.Shares,
+	})
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+// HandleExportSnapshot 导出整个工作区的快照（管理员）：GET /api/snapshot/export
+// 快照是 tar.gz，包含笔记、索引、归档笔记、修订版本、回收站、上传文件、所有权记录、元数据、config.json 和带校验和的清单
+func HandleExportSnapshot(w http.ResponseWriter, r *http.Request) {
+	deps := depsFor(r)
+	if !isAdminRequest(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	snapshot, err := deps.ExportSnapshot()
+	if err != nil {
+		log.Printf("Error exporting snapshot: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer snapshot.Close()
+
+	name := "jot-snapshot"
+	if deps.Workspace != "" {
+		name += "-" + deps.Workspace
+	}
+	filename := fmt.Sprintf("%s-%s.tar.gz", name, time.Now().Format("20060102-150405"))
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+	w.Header().Set("Cache-Control", "no-store")
+	if _, err := io.Copy(w, snapshot); err != nil {
+		log.Printf("Error sending snapshot: %v", err)
+	}
+}
+
+// HandleImportSnapshot 导入快照（管理员）：POST /api/snapshot/import，请求体为快照的 tar.gz 内容
+// mode 参数为 merge（默认，保留本地内容并报告冲突）或 replace（先删除所有现有数据）；
+// config=true 时同时用快照中的 config.json 替换现有配置。快照无效或校验失败时返回 400，不修改任何数据
+func HandleImportSnapshot(w http.ResponseWriter, r *http.Request) {
+	deps := depsFor(r)
+	if !isAdminRequest(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	mode := r.URL.Query().Get("mode")
+	restoreConfig := r.URL.Query().Get("config") == "true"
+	report, err := deps.ImportSnapshot(r.Body, mode, restoreConfig)
+	if err != nil {
+		log.Printf("Error importing snapshot: %v", err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, os.ErrInvalid) {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"report":  report,
+	})
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+
+	"github.com/gorilla/mux"
+
+	"github.com/hello--world/jot/apikey"
+)
+
+// HandleListTrash 列出回收站中的笔记（管理员）：GET /api/trash
+func HandleListTrash(w http.ResponseWriter, r *http.Request) {
+	deps := depsFor(r)
+	if !isAdminRequest(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	trashed, err := deps.ListTrash()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"notes":     trashed,
+		"trashDays": deps.GetTrashDays(),
+	})
+}
+
+// HandleRestoreTrash 按名称从回收站恢复笔记：POST /api/trash/{note}/restore
+// 默认恢复最近删除的副本，可以用 id 参数指定副本；同名笔记已存在时返回 409，不覆盖现有笔记
+// 需要笔记的 write 权限（回收站中的笔记保留所有者和共享设置）
+func HandleRestoreTrash(w http.ResponseWriter, r *http.Request) {
+	deps := depsFor(r)
+	noteName := mux.Vars(r)["note"]
+	if noteName == "" || !deps.IsSafeNoteName(noteName) {
+		http.Error(w, "Invalid note name", http.StatusBadRequest)
+		return
+	}
+	if !requireNoteScope(w, r, noteName, apikey.ScopeWrite) {
+		return
+	}
+	id := r.URL.Query().Get("id")
+
+	trashed, err := deps.FindTrash(noteName, id)
+	if err != nil {
+		writeTrashError(w, err)
+		return
+	}
+	if status, err := deps.CheckNoteQuota(noteName, trashed.Size); err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	trashed, err = deps.RestoreFromTrash(noteName, trashed.ID, Actor(r))
+	if err != nil {
+		writeTrashError(w, err)
+		return
+	}
+
+	// Broadcast update to WebSocket clients (without lock marker)
+	if content, err := deps.LoadNote(noteName); err == nil {
+		deps.BroadcastUpdate(noteName, deps.GetNoteContent(content))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"note":    trashed,
+	})
+}
+
+// HandlePurgeTrash 永久删除回收站中的笔记（管理员）：POST /api/trash/{note}/purge
+// 默认删除该笔记的所有副本，可以用 id 参数指定副本
+func HandlePurgeTrash(w http.ResponseWriter, r *http.Request) {
+	deps := depsFor(r)
+	if !isAdminRequest(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	noteName := mux.Vars(r)["note"]
+	if noteName == "" || !deps.IsSafeNoteName(noteName) {
+		http.Error(w, "Invalid note name", http.StatusBadRequest)
+		return
+	}
+
+	purged, err := deps.PurgeTrash(noteName, r.URL.Query().Get("id"))
+	if err != nil {
+		writeTrashError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"purged":  purged,
+	})
+}
+
+// writeTrashError 根据回收站操作的错误返回 404、409 或 500
+func writeTrashError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		http.Error(w, "Note not found in trash", http.StatusNotFound)
+	case errors.Is(err, os.ErrExist):
+		http.Error(w, "Note already exists, delete or rename it before restoring", http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"encoding/json"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/hello--world/jot/htmlPage"
+	"github.com/hello--world/jot/ratelimit"
+)
+
+// safeRedirectPath 检查登录后的跳转路径，只允许站内路径
+func safeRedirectPath(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
+
+// serveUserLogin 显示用户登录页面
+func serveUserLogin(w http.ResponseWriter, r *http.Request, next, errorMessage string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	tmpl := template.Must(template.New("login").Parse(htmlPage.UserLoginHTML))
+	tmpl.Execute(w, map[string]interface{}{
+		"Next":      safeRedirectPath(next),
+		"Error":     errorMessage,
+		"CSRFToken": CSRFToken(r),
+	})
+}
+
+// HandleUserLogin 处理用户登录：GET 显示登录页面，POST 校验用户名和密码并创建 session
+func HandleUserLogin(w http.ResponseWriter, r *http.Request) {
+	deps := depsFor(r)
+	if r.Method == "GET" {
+		serveUserLogin(w, r, r.URL.Query().Get("next"), "")
+		return
+	}
+
+	r.ParseForm()
+	username := strings.TrimSpace(r.FormValue("username"))
+	password := r.FormValue("password")
+	next := r.FormValue("next")
+
+	if username == "" || password == "" || !deps.AuthenticateUser(username, password) {
+		ratelimit.MarkFailure(r)
+		w.WriteHeader(http.StatusUnauthorized)
+		serveUserLogin(w, r, next, "用户名或密码错误")
+		return
+	}
+
+	sessionToken, err := createUserSession(r, username)
+	if err != nil {
+		log.Printf("Error creating user session: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// SameSite=Lax：从其他站点打开共享链接时仍然保持登录
+	SetCookie(w, r, &http.Cookie{
+		Name:     "user_session",
+		Value:    sessionToken,
+		Path:     "/",
+		MaxAge:   int(userSessionMaxLifetime.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, safeRedirectPath(next), http.StatusFound)
+}
+
+// HandleUserLogout 退出登录：POST /logout
+func HandleUserLogout(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie("user_session"); err == nil {
+		deleteSession(r, strings.TrimSpace(cookie.Value))
+	}
+	SetCookie(w, r, &http.Cookie{
+		Name:     "user_session",
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+	})
+}
+
+// HandleCurrentUser 返回当前登录的用户以及其拥有和被共享的笔记：GET /api/me
+func HandleCurrentUser(w http.ResponseWriter, r *http.Request) {
+	deps := depsFor(r)
+	username := CurrentUser(r)
+	if username == "" {
+		http.Error(w, "Unauthorized: Login required", http.StatusUnauthorized)
+		return
+	}
+
+	owned, shared := deps.GetUserNotes(username)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"username": username,
+		"notes":    owned,
+		"shared":   shared,
+	})
+}
+
+// HandleChangePassword 修改当前用户的密码：POST /api/me/password
+// 请求体：{"currentPassword": "...", "newPassword": "..."}，修改后该用户的其他 session 失效
+func HandleChangePassword(w http.ResponseWriter, r *http.Request) {
+	deps := depsFor(r)
+	username := CurrentUser(r)
+	if username == "" {
+		http.Error(w, "Unauthorized: Login required", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		CurrentPassword string `json:"currentPassword"`
+		NewPassword     string `json:"newPassword"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !deps.AuthenticateUser(username, req.CurrentPassword) {
+		ratelimit.MarkFailure(r)
+		http.Error(w, "Current password is incorrect", http.StatusForbidden)
+		return
+	}
+	if err := deps.SetUserPassword(username, req.NewPassword); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	current := ""
+	if cookie, err := r.Cookie("user_session"); err == nil {
+		current = strings.TrimSpace(cookie.Value)
+	}
+	deleteUserSessions(r, username, current)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+	})
+}
+
+// HandleListUsers 列出所有用户（管理员）：GET /api/users
+func HandleListUsers(w http.ResponseWriter, r *http.Request) {
+	deps := depsFor(r)
+	if !isAdminRequest(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"users": deps.ListUsers(),
+	})
+}
+
+// HandleCreateUser 创建用户（管理员）：POST /api/users
+// 请求体：{"username": "alice", "password": "..."}
+func HandleCreateUser(w http.ResponseWriter, r *http.Request) {
+	deps := depsFor(r)
+	if !isAdminRequest(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	username := strings.TrimSpace(req.Username)
+	if err := deps.CreateUser(username, req.Password); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"username": username,
+	})
+}
+
+// HandleDeleteUser 删除用户（管理员）：POST /api/users/{username}/delete
+// 用户拥有的笔记保留所有者记录，只有管理员可以访问，重新创建同名用户后恢复访问
+func HandleDeleteUser(w http.ResponseWriter, r *http.Request) {
+	deps := depsFor(r)
+	if !isAdminRequest(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	username := mux.Vars(r)["username"]
+	if err := deps.DeleteUser(username); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	deleteUserSessions(r, username, "")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+	})
+}
+
+// HandleResetUserPassword 重置用户密码（管理员）：POST /api/users/{username}/password
+// 请求体：{"password": "..."}，重置后该用户的所有 session 失效
+func HandleResetUserPassword(w http.ResponseWriter, r *http.Request) {
+	deps := depsFor(r)
+	if !isAdminRequest(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	username := mux.Vars(r)["username"]
+	if err := deps.SetUserPassword(username, req.Password); err != nil {
+		status := http.StatusBadRequest
+		if !deps.UserExists(username) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	deleteUserSessions(r, username, "")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+	})
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/hello--world/jot/webhook"
+)
+
+// listWebhooks 返回所有 webhook（不包含 secret）
+func listWebhooks(deps *Dependencies) []webhook.Hook {
+	hooks := deps.Webhooks.Hooks()
+	for i := range hooks {
+		hooks[i].Secret = ""
+	}
+	return hooks
+}
+
+// adminDeliveryCount 管理后台显示的投递记录数量
+const adminDeliveryCount = 20
+
+// recentDeliveries 返回待投递的投递和最近的投递记录，最多 limit 条
+func recentDeliveries(deps *Dependencies, limit int) []webhook.Delivery {
+	deliveries := deps.Webhooks.Deliveries()
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries
+}
+
+// HandleListWebhooks 列出所有 webhook 和最近的投递记录（管理员）：GET /api/webhooks
+func HandleListWebhooks(w http.ResponseWriter, r *http.Request) {
+	deps := depsFor(r)
+	if !isAdminRequest(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"webhooks":   listWebhooks(deps),
+		"events":     webhook.Events,
+		"deliveries": deps.Webhooks.Deliveries(),
+	})
+}
+
+// HandleCreateWebhook 创建 webhook（管理员），secret 只在响应中返回一次
+// 请求体：{"name": "chat", "url": "https://example.com/hook", "events": ["note.created"], "secret": "..."}
+// events 为空时接收所有事件，secret 为空时自动生成
+func HandleCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	deps := depsFor(r)
+	if !isAdminRequest(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		Name   string   `json:"name"`
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+		Secret string   `json:"secret"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	hook, err := deps.Webhooks.Create(req.Name, req.URL, req.Secret, req.Events)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	secret := hook.Secret
+	hook.Secret = ""
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"secret":  secret,
+		"webhook": hook,
+	})
+}
+
+// HandleDeleteWebhook 删除 webhook（管理员）：POST /api/webhooks/{id}/delete
+func HandleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	deps := depsFor(r)
+	if !isAdminRequest(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := deps.Webhooks.Delete(mux.Vars(r)["id"]); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+	})
+}
+
+// HandleTestWebhook 向 webhook 发送 ping 事件（管理员）：POST /api/webhooks/{id}/test
+func HandleTestWebhook(w http.ResponseWriter, r *http.Request) {
+	deps := depsFor(r)
+	if !isAdminRequest(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := deps.Webhooks.Ping(mux.Vars(r)["id"]); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, webhook.ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+	})
+}
@@ -1,32 +1,31 @@
 package handlers
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
-	"sync"
+	"log"
+	"net/http"
 	"time"
-)
 
-// Session 存储 session 信息
-type Session struct {
-	Token     string
-	ExpiresAt time.Time
-}
+	"github.com/hello--world/jot/session"
+)
 
 var (
-	// adminSessions 存储管理员 session（token -> 过期时间）
-	adminSessions = sync.Map{}
 	// sessionCleanupInterval session 清理间隔
 	sessionCleanupInterval = 30 * time.Minute
-	// sessionExpiry session 过期时间（2小时）
+	// sessionExpiry 管理员 session 的空闲过期时间（2小时），每次访问后顺延
 	sessionExpiry = 2 * time.Hour
+	// sessionMaxLifetime 管理员 session 的最长有效期（7天），不会被顺延
+	sessionMaxLifetime = 7 * 24 * time.Hour
+	// userSessionExpiry 用户 session 的空闲过期时间（7天）
+	userSessionExpiry = 7 * 24 * time.Hour
+	// userSessionMaxLifetime 用户 session 的最长有效期（30天）
+	userSessionMaxLifetime = 30 * 24 * time.Hour
+	// sessionTouchInterval 顺延过期时间的最小间隔，避免每个请求都写 session 存储
+	sessionTouchInterval = time.Minute
 )
 
-func init() {
-	// 启动定期清理过期 session 的 goroutine
-	go cleanupExpiredSessions()
-}
-
 // generateSessionToken 生成随机的 session token
 func generateSessionToken() (string, error) {
 	bytes := make([]byte, 32)
@@ -36,71 +35,161 @@ func generateSessionToken() (string, error) {
 	return hex.EncodeToString(bytes), nil
 }
 
-// createAdminSession 创建管理员 session
-func createAdminSession() (string, error) {
+// sessionLifetime 返回 session 类型对应的空闲过期时间和最长有效期
+func sessionLifetime(kind string) (idle, max time.Duration) {
+	if kind == session.KindUser {
+		return userSessionExpiry, userSessionMaxLifetime
+	}
+	return sessionExpiry, sessionMaxLifetime
+}
+
+// slidingExpiry 计算 session 顺延后的过期时间（不超过最长有效期）
+func slidingExpiry(s session.Session, now time.Time) time.Time {
+	idle, max := sessionLifetime(s.Kind)
+	expiresAt := now.Add(idle)
+	if limit := s.CreatedAt.Add(max); expiresAt.After(limit) {
+		expiresAt = limit
+	}
+	return expiresAt
+}
+
+// createSession 创建 session 并记录客户端 IP 和 User-Agent，返回 token
+func createSession(r *http.Request, kind, username string) (string, error) {
+	deps := depsFor(r)
 	token, err := generateSessionToken()
 	if err != nil {
 		return "", err
 	}
-	
-	session := &Session{
-		Token:     token,
-		ExpiresAt: time.Now().Add(sessionExpiry),
+
+	now := time.Now()
+	s := session.Session{
+		ID:         session.HashToken(token),
+		Kind:       kind,
+		Username:   username,
+		IP:         deps.ClientIP(r),
+		UserAgent:  r.UserAgent(),
+		CreatedAt:  now,
+		LastSeenAt: now,
+	}
+	s.ExpiresAt = slidingExpiry(s, now)
+	if err := deps.Sessions.Put(s); err != nil {
+		return "", err
 	}
-	
-	adminSessions.Store(token, session)
 	return token, nil
 }
 
-// validateAdminSession 验证管理员 session token
-func validateAdminSession(token string) bool {
+// validateSession 验证 session token，有效时顺延过期时间并更新最近访问的 IP 和 User-Agent
+func validateSession(r *http.Request, token, kind string) (session.Session, bool) {
+	deps := depsFor(r)
 	if token == "" {
-		return false
+		return session.Session{}, false
 	}
-	
-	value, ok := adminSessions.Load(token)
-	if !ok {
-		return false
+
+	id := session.HashToken(token)
+	s, ok := deps.Sessions.Get(id)
+	if !ok || s.Kind != kind {
+		return session.Session{}, false
 	}
-	
-	session, ok := value.(*Session)
+
+	now := time.Now()
+	if s.Expired(now) {
+		deps.Sessions.Delete(id)
+		return session.Session{}, false
+	}
+
+	if now.Sub(s.LastSeenAt) >= sessionTouchInterval {
+		s.LastSeenAt = now
+		s.IP = deps.ClientIP(r)
+		s.UserAgent = r.UserAgent()
+		s.ExpiresAt = slidingExpiry(s, now)
+		if err := deps.Sessions.Put(s); err != nil {
+			log.Printf("Error updating session: %v", err)
+		}
+	}
+	return s, true
+}
+
+// createAdminSession 创建管理员 session
+func createAdminSession(r *http.Request) (string, error) {
+	return createSession(r, session.KindAdmin, "")
+}
+
+// validateAdminSession 验证管理员 session token
+func validateAdminSession(r *http.Request, token string) bool {
+	_, ok := validateSession(r, token, session.KindAdmin)
+	return ok
+}
+
+// HasSession 检查请求是否带有有效的管理员或用户登录 session
+// session 令牌无法猜测，所以带 session 的请求不受笔记锁定的限制
+func HasSession(r *http.Request) bool {
+	return validateAdminSession(r, getAdminSessionTokenFromRequest(r)) || CurrentUser(r) != ""
+}
+
+// createUserSession 创建用户 session
+func createUserSession(r *http.Request, username string) (string, error) {
+	return createSession(r, session.KindUser, username)
+}
+
+// validateUserSession 验证用户 session token，返回对应的用户名
+func validateUserSession(r *http.Request, token string) (string, bool) {
+	s, ok := validateSession(r, token, session.KindUser)
 	if !ok {
-		return false
+		return "", false
 	}
-	
-	// 检查是否过期
-	if time.Now().After(session.ExpiresAt) {
-		adminSessions.Delete(token)
-		return false
+	return s.Username, true
+}
+
+// deleteSession 删除 token 对应的 session
+func deleteSession(r *http.Request, token string) {
+	deps := depsFor(r)
+	if token == "" {
+		return
+	}
+	if err := deps.Sessions.Delete(session.HashToken(token)); err != nil {
+		log.Printf("Error deleting session: %v", err)
 	}
-	
-	return true
 }
 
-// deleteAdminSession 删除管理员 session
-func deleteAdminSession(token string) {
-	adminSessions.Delete(token)
+// deleteUserSessions 删除用户的所有 session（删除用户或修改密码时使用），except 除外
+func deleteUserSessions(r *http.Request, username, except string) {
+	deps := depsFor(r)
+	exceptID := ""
+	if except != "" {
+		exceptID = session.HashToken(except)
+	}
+	_, err := deps.Sessions.DeleteFunc(func(s session.Session) bool {
+		return s.Kind == session.KindUser && s.Username == username && s.ID != exceptID
+	})
+	if err != nil {
+		log.Printf("Error deleting sessions of user %s: %v", username, err)
+	}
+}
+
+// StartSessionCleanup 启动定期清理过期 session 的 goroutine，ctx 取消后停止
+func StartSessionCleanup(ctx context.Context) {
+	go cleanupExpiredSessions(ctx)
 }
 
-// cleanupExpiredSessions 定期清理过期的 session
-func cleanupExpiredSessions() {
+// cleanupExpiredSessions 定期清理所有工作区过期的 session
+func cleanupExpiredSessions(ctx context.Context) {
 	ticker := time.NewTicker(sessionCleanupInterval)
 	defer ticker.Stop()
-	
-	for range ticker.C {
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
 		now := time.Now()
-		adminSessions.Range(func(key, value interface{}) bool {
-			session, ok := value.(*Session)
-			if !ok {
-				adminSessions.Delete(key)
-				return true
+		for _, deps := range allDeps() {
+			if deps.Sessions == nil {
+				continue
 			}
-			
-			if now.After(session.ExpiresAt) {
-				adminSessions.Delete(key)
+			if _, err := deps.Sessions.DeleteFunc(func(s session.Session) bool { return s.Expired(now) }); err != nil {
+				log.Printf("Error cleaning up expired sessions: %v", err)
 			}
-			return true
-		})
+		}
 	}
 }
-
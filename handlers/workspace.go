@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/hello--world/jot/ratelimit"
+)
+
+// 每个工作区有独立的依赖（笔记存储、配置、令牌、账号和 session），按请求的 Host 选择；
+// Host 不属于任何工作区的请求使用默认工作区
+var (
+	defaultDeps    *Dependencies
+	workspaceHosts = make(map[string]*Dependencies) // 小写、不含端口的 Host 到工作区依赖
+	workspaceList  []*Dependencies
+	workspaceLock  sync.RWMutex
+)
+
+// AddWorkspace 添加工作区，Host 已被其他工作区使用时返回错误
+func AddWorkspace(d *Dependencies) error {
+	workspaceLock.Lock()
+	defer workspaceLock.Unlock()
+
+	for _, host := range d.WorkspaceHosts {
+		host = normalizeHost(host)
+		if existing, ok := workspaceHosts[host]; ok {
+			return fmt.Errorf("host %s is already used by workspace %s", host, existing.Workspace)
+		}
+	}
+	for _, host := range d.WorkspaceHosts {
+		workspaceHosts[normalizeHost(host)] = d
+	}
+	workspaceList = append(workspaceList, d)
+	return nil
+}
+
+// normalizeHost 去掉端口并转换为小写
+func normalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
+
+// depsFor 返回请求所属工作区的依赖
+func depsFor(r *http.Request) *Dependencies {
+	workspaceLock.RLock()
+	d, ok := workspaceHosts[normalizeHost(r.Host)]
+	workspaceLock.RUnlock()
+	if ok {
+		return d
+	}
+	return defaultDeps
+}
+
+// allDeps 返回默认工作区和所有工作区的依赖
+func allDeps() []*Dependencies {
+	workspaceLock.RLock()
+	defer workspaceLock.RUnlock()
+	result := make([]*Dependencies, 0, len(workspaceList)+1)
+	if defaultDeps != nil {
+		result = append(result, defaultDeps)
+	}
+	return append(result, workspaceList...)
+}
+
+// RateLimiter 返回请求所属工作区的限流器
+func RateLimiter(r *http.Request) *ratelimit.Limiter {
+	return depsFor(r).RateLimiter
+}
+
+// GenerateNoteName 在请求所属的工作区中生成未使用的笔记名称
+func GenerateNoteName(r *http.Request) string {
+	return depsFor(r).GenerateNoteName()
+}
+
+// UploadPath 返回请求所属工作区的上传文件目录
+func UploadPath(r *http.Request) string {
+	return depsFor(r).GetUploadPath()
+}
+
+// HandleWebSocket 把 WebSocket 连接交给请求所属工作区的协同编辑管理器
+func HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+	depsFor(r).HandleWebSocket(w, r)
+}
+
+// WorkspaceInfo 表示管理后台显示的工作区
+type WorkspaceInfo struct {
+	Name      string   `json:"name"`
+	Hosts     []string `json:"hosts"`
+	NoteCount int      `json:"noteCount"`
+	TotalSize int64    `json:"totalSize"`
+}
+
+// HandleListWorkspaces 列出所有工作区及其用量（默认工作区的管理员）：GET /api/workspaces
+func HandleListWorkspaces(w http.ResponseWriter, r *http.Request) {
+	if depsFor(r) != defaultDeps || !isAdminRequest(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	workspaceLock.RLock()
+	list := append([]*Dependencies(nil), workspaceList...)
+	workspaceLock.RUnlock()
+
+	result := make([]WorkspaceInfo, 0, len(list))
+	for _, d := range list {
+		info := WorkspaceInfo{Name: d.Workspace, Hosts: d.WorkspaceHosts}
+		if notes, err := d.GetAllNotes(); err == nil {
+			info.NoteCount = len(notes)
+		}
+		info.TotalSize, _ = d.GetTotalFileSize()
+		result = append(result, info)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"workspaces": result,
+	})
+}
@@ -89,6 +89,15 @@ body {
     cursor: pointer;
     transition: background 0.2s;
 }
+.login-footer {
+    margin-top: 20px;
+    text-align: center;
+    font-size: 13px;
+}
+.login-footer a {
+    color: #0066cc;
+    text-decoration: none;
+}
 .login-button:hover {
     background: #0052a3;
 }
@@ -136,6 +145,9 @@ body {
         </div>
         <button type="submit" class="login-button">登录</button>
     </form>
+    <div class="login-footer">
+        <a href="/login" id="userLoginLink">使用账号登录</a>
+    </div>
 </div>
 <script>
 const form = document.getElementById('loginForm');
@@ -167,6 +179,9 @@ if (savedToken && urlParams.get('error') !== 'invalid') {
     throw new Error('Auto-login...');
 }
 
+// 账号登录后回到当前页面
+document.getElementById('userLoginLink').href = '/login?next=' + encodeURIComponent(window.location.pathname);
+
 // Set form action to current path
 const currentPath = window.location.pathname;
 const currentSearch = window.location.search;
@@ -199,7 +199,7 @@ form.addEventListener('submit', function(e) {
 const AdminPageHTML = `<!DOCTYPE html>
 <html>
 <head>
-<meta charset="utf-8">
+` + CSRFScript + `<meta charset="utf-8">
 <meta name="viewport" content="width=device-width, initial-scale=1.0">
 <title>管理后台 - 所有笔记</title>
 <style>
@@ -296,6 +296,11 @@ body {
     color: #0066cc;
     text-decoration: none;
 }
+.note-owner {
+    margin-left: 6px;
+    font-size: 11px;
+    color: #888;
+}
 .note-name:hover {
     text-decoration: underline;
 }
@@ -379,10 +384,53 @@ body {
     color: white;
     border-color: #0066cc;
 }
+.search-bar {
+    display: flex;
+    gap: 8px;
+    align-items: center;
+    padding: 10px 16px;
+    border-bottom: 1px solid #ddd;
+}
+.search-bar input {
+    flex: 1;
+    padding: 6px 10px;
+    border: 1px solid #ddd;
+    border-radius: 4px;
+    font-size: 13px;
+}
+.search-status {
+    color: #999;
+    font-size: 12px;
+    white-space: nowrap;
+}
+.search-snippet {
+    color: #666;
+    font-size: 13px;
+    word-break: break-all;
+}
+.search-snippet mark {
+    background: #ffe58f;
+    color: inherit;
+    padding: 0 1px;
+}
 @media (prefers-color-scheme: dark) {
     body {
         background: #333b4d;
     }
+    .search-bar {
+        border-color: #495265;
+    }
+    .search-bar input {
+        background: #1a1a1a;
+        border-color: #495265;
+        color: #fff;
+    }
+    .search-snippet {
+        color: #aaa;
+    }
+    .search-snippet mark {
+        background: #7a5c00;
+    }
     .container {
         background: #24262b;
     }
@@ -431,8 +479,14 @@ body {
     <div class="tabs">
         <button class="tab-button active" onclick="showTab('active')">📝 活跃笔记 ({{.TotalCount}})</button>
         <button class="tab-button" onclick="showTab('backup')">📦 备份笔记 ({{.BackupCount}})</button>
+        <button class="tab-button" onclick="showTab('trash')">🗑️ 回收站 ({{.TrashCount}})</button>
         <button class="tab-button" onclick="showTab('settings')">⚙️ 系统设置</button>
     </div>
+    <div class="search-bar">
+        <input type="search" id="search-input" placeholder="🔍 搜索笔记内容（活跃笔记和备份笔记，加锁的笔记不会显示）" oninput="scheduleSearch()">
+        <span class="search-status" id="search-status"></span>
+    </div>
+    <div id="search-results" class="notes-list" style="display: none;"></div>
     <div id="active-tab" class="tab-content">
     <div class="notes-list">
         <div id="active-notes">
@@ -455,15 +509,17 @@ body {
                             <th>内容预览</th>
                             <th>大小</th>
                             <th>更新时间</th>
+                            <th>操作</th>
                         </tr>
                     </thead>
                     <tbody>
                         {{range .Notes}}
                         <tr>
-                            <td><a href="/{{.Name}}" class="note-name">{{.Name}}</a></td>
+                            <td><a href="/{{.Name}}" class="note-name">{{.Name}}</a>{{if .Pinned}} 📌{{end}}{{if .Owner}}<span class="note-owner">👤 {{.Owner}}</span>{{end}}</td>
                             <td class="note-content" title="{{.Content}}">{{if .Content}}{{preview .Content 50}}{{else}}<em>空笔记</em>{{end}}</td>
                             <td class="note-size">{{formatSize .Size}}</td>
                             <td class="note-date">{{formatDate .UpdatedAt}}</td>
+                            <td><button onclick="pinNote('{{.Name}}', {{if .Pinned}}false{{else}}true{{end}})" style="padding: 3px 8px; background: {{if .Pinned}}#999{{else}}#0066cc{{end}}; color: white; border: none; border-radius: 3px; cursor: pointer; font-size: 11px;">{{if .Pinned}}取消置顶{{else}}置顶{{end}}</button></td>
                         </tr>
                         {{end}}
                     </tbody>
@@ -481,6 +537,93 @@ body {
     </div>
     <div id="backup-tab" class="tab-content" style="display: none;">
     <div class="notes-list">
+        {{with .DiskUsage}}
+        <div id="disk-usage" style="margin: 10px 16px; padding: 8px 12px; background: #f1f8ff; border-left: 4px solid #0066cc; font-size: 12px; color: #333;">
+            <h3 style="margin: 0 0 4px; font-size: 14px; font-weight: 600;">💾 磁盘占用</h3>
+            <div style="display: flex; flex-wrap: wrap; gap: 4px 16px;">
+                <span>活跃笔记：{{.ActiveCount}} 个，{{formatSize .ActiveSize}}</span>
+                <span>备份文件夹：{{.ArchivedCount}} 个，{{formatSize .ArchivedSize}}</span>
+                <span>回收站：{{.TrashCount}} 个，{{formatSize .TrashSize}}</span>
+                <span>修订版本：{{.RevisionCount}} 个，{{formatSize .RevisionSize}}</span>
+                <span>上传文件：{{.UploadCount}} 个，{{formatSize .UploadSize}}</span>
+                <span>未被引用的上传文件：{{.OrphanUploadCount}} 个，{{formatSize .OrphanUploadSize}}{{if gt .PurgeUploadCount 0}}（下一次清理删除 {{.PurgeUploadCount}} 个，{{formatSize .PurgeUploadSize}}）{{end}}</span>
+            </div>
+            <div style="margin-top: 4px; color: #666;">只有活跃笔记和上传文件计入总大小限制；备份文件夹和未被引用的上传文件由设置中的保留策略清理。</div>
+        </div>
+        {{end}}
+        <div id="snapshot" style="margin: 10px 16px; padding: 8px 12px; background: #f1f8ff; border-left: 4px solid #0066cc; font-size: 12px; color: #333;">
+            <h3 style="margin: 0 0 4px; font-size: 14px; font-weight: 600;">🗄️ 快照</h3>
+            <div style="color: #666;">快照包含所有笔记、备份文件夹、修订版本、回收站、上传文件、所有权记录和配置文件，以及带校验和的清单，导出时不需要停止服务器。合并导入时保留本地已有的不同内容并报告冲突；替换导入会先删除所有现有的笔记和上传文件。</div>
+            <div style="display: flex; flex-wrap: wrap; align-items: center; gap: 8px; margin-top: 6px;">
+                <a href="/api/snapshot/export" style="padding: 3px 8px; background: #0066cc; color: white; border-radius: 3px; text-decoration: none; font-size: 11px;">导出快照</a>
+                <input type="file" id="snapshot-file" accept=".tar.gz,.tgz,application/gzip" style="font-size: 11px;">
+                <select id="snapshot-mode" style="padding: 2px 6px; border: 1px solid #ddd; border-radius: 3px; font-size: 11px;">
+                    <option value="merge">合并</option>
+                    <option value="replace">替换</option>
+                </select>
+                <label><input type="checkbox" id="snapshot-config"> 恢复配置文件</label>
+                <button onclick="importSnapshot()" style="padding: 3px 8px; background: #0066cc; color: white; border: none; border-radius: 3px; cursor: pointer; font-size: 11px;">导入快照</button>
+            </div>
+            <pre id="snapshot-report" style="display: none; margin: 6px 0 0; white-space: pre-wrap;"></pre>
+        </div>
+        {{with .ArchivePlan}}
+        <div id="archive-plan" style="margin: 10px 16px; padding: 8px 12px; background: #fff8e1; border-left: 4px solid #ff9800; font-size: 12px; color: #333;">
+            <h3 style="margin: 0 0 4px; font-size: 14px; font-weight: 600;">🔍 归档预览</h3>
+            <div style="color: #666;">活跃笔记超过 {{.BackupDays}} 天未{{if eq .ArchiveBy "read"}}读取或修改{{else}}修改{{end}}时移动到备份文件夹；{{if gt .DeleteArchivedDays 0}}归档超过 {{.DeleteArchivedDays}} 天的笔记被彻底删除{{else}}归档笔记不会因天数被删除{{end}}；{{if gt .ArchiveKeepLast 0}}最多保留 {{.ArchiveKeepLast}} 个归档笔记；{{end}}{{if gt .ArchiveMaxSize 0}}归档笔记最多占用 {{formatSize .ArchiveMaxSize}}；{{end}}{{if or (gt .ArchiveKeepLast 0) (gt .ArchiveMaxSize 0)}}超出时最早归档的笔记先被彻底删除（不经过回收站）；{{end}}置顶的笔记不会被归档或删除。下面是下一次自动归档会执行的操作。</div>
+        </div>
+        {{if or .Archive .Delete .Pinned}}
+        <table class="notes-table">
+            <thead>
+                <tr>
+                    <th>操作</th>
+                    <th>笔记名称</th>
+                    <th>日期目录</th>
+                    <th>大小</th>
+                    <th>最后修改</th>
+                    <th>最后读取</th>
+                    <th>距今</th>
+                </tr>
+            </thead>
+            <tbody>
+                {{range .Archive}}
+                <tr>
+                    <td>📦 移到备份</td>
+                    <td><a href="{{if .IsBackup}}/read/{{.Name}}{{else}}/{{.Name}}{{end}}" class="note-name">{{.Name}}</a></td>
+                    <td class="note-date">{{.DateDir}}</td>
+                    <td class="note-size">{{formatSize .Size}}</td>
+                    <td class="note-date">{{formatDate .UpdatedAt}}</td>
+                    <td class="note-date">{{if .LastRead}}{{formatDate .LastRead}}{{else}}-{{end}}</td>
+                    <td class="note-date">{{.Days}} 天</td>
+                </tr>
+                {{end}}
+                {{range .Delete}}
+                <tr>
+                    <td>🗑️ 彻底删除{{if eq .Reason "count"}}（超出数量）{{else if eq .Reason "size"}}（超出大小）{{else}}（超过天数）{{end}}</td>
+                    <td><a href="{{if .IsBackup}}/read/{{.Name}}{{else}}/{{.Name}}{{end}}" class="note-name">{{.Name}}</a></td>
+                    <td class="note-date">{{.DateDir}}</td>
+                    <td class="note-size">{{formatSize .Size}}</td>
+                    <td class="note-date">{{formatDate .UpdatedAt}}</td>
+                    <td class="note-date">{{if .LastRead}}{{formatDate .LastRead}}{{else}}-{{end}}</td>
+                    <td class="note-date">{{.Days}} 天</td>
+                </tr>
+                {{end}}
+                {{range .Pinned}}
+                <tr>
+                    <td>📌 置顶保留</td>
+                    <td><a href="{{if .IsBackup}}/read/{{.Name}}{{else}}/{{.Name}}{{end}}" class="note-name">{{.Name}}</a></td>
+                    <td class="note-date">{{.DateDir}}</td>
+                    <td class="note-size">{{formatSize .Size}}</td>
+                    <td class="note-date">{{formatDate .UpdatedAt}}</td>
+                    <td class="note-date">{{if .LastRead}}{{formatDate .LastRead}}{{else}}-{{end}}</td>
+                    <td class="note-date">{{.Days}} 天</td>
+                </tr>
+                {{end}}
+            </tbody>
+        </table>
+        {{else}}
+        <div style="margin: 0 16px 10px; font-size: 12px; color: #666;">下一次自动归档没有需要移动的笔记。</div>
+        {{end}}
+        {{end}}
         <div id="backup-notes">
             {{if .GroupedBackupNotes}}
             {{range .GroupedBackupNotes}}
@@ -501,15 +644,17 @@ body {
                             <th>内容预览</th>
                             <th>大小</th>
                             <th>更新时间</th>
+                            <th>操作</th>
                         </tr>
                     </thead>
                     <tbody>
                         {{range .Notes}}
                         <tr>
-                            <td><a href="/read/{{.Name}}" class="note-name">{{.Name}}</a></td>
+                            <td><a href="/read/{{.Name}}" class="note-name">{{.Name}}</a>{{if .Owner}}<span class="note-owner">👤 {{.Owner}}</span>{{end}}</td>
                             <td class="note-content" title="{{.Content}}">{{if .Content}}{{preview .Content 50}}{{else}}<em>空笔记</em>{{end}}</td>
                             <td class="note-size">{{formatSize .Size}}</td>
                             <td class="note-date">{{formatDate .UpdatedAt}}</td>
+                            <td><button onclick="unarchiveNote('{{.Name}}', '{{.DateDir}}', '')" style="padding: 3px 8px; background: #0066cc; color: white; border: none; border-radius: 3px; cursor: pointer; font-size: 11px;">恢复</button></td>
                         </tr>
                         {{end}}
                     </tbody>
@@ -525,6 +670,43 @@ body {
         </div>
     </div>
     </div>
+    <div id="trash-tab" class="tab-content" style="display: none;">
+    <div class="notes-list">
+        {{if .TrashedNotes}}
+        <div style="margin: 10px 16px; font-size: 12px; color: #666;">删除的笔记在回收站中保留 {{.TrashDays}} 天，之后被永久删除。</div>
+        <table class="notes-table">
+            <thead>
+                <tr>
+                    <th>笔记名称</th>
+                    <th>大小</th>
+                    <th>删除时间</th>
+                    <th>删除者</th>
+                    <th>操作</th>
+                </tr>
+            </thead>
+            <tbody>
+                {{range .TrashedNotes}}
+                <tr>
+                    <td><span class="note-name">{{.Name}}</span>{{if .Locked}} 🔒{{end}}{{if .IsBackup}} <span class="note-date">（备份 {{.DateDir}}）</span>{{end}}</td>
+                    <td class="note-size">{{formatSize .Size}}</td>
+                    <td class="note-date">{{formatDate .DeletedAt}}</td>
+                    <td class="note-date">{{if .DeletedBy}}{{.DeletedBy}}{{else}}-{{end}}</td>
+                    <td>
+                        <button onclick="restoreTrash('{{.Name}}', '{{.ID}}')" style="padding: 3px 8px; background: #0066cc; color: white; border: none; border-radius: 3px; cursor: pointer; font-size: 11px;">恢复</button>
+                        <button onclick="purgeTrash('{{.Name}}', '{{.ID}}')" style="padding: 3px 8px; background: #d9534f; color: white; border: none; border-radius: 3px; cursor: pointer; font-size: 11px;">永久删除</button>
+                    </td>
+                </tr>
+                {{end}}
+            </tbody>
+        </table>
+        {{else}}
+        <div class="empty">
+            <div class="empty-icon">🗑️</div>
+            <p>回收站是空的（删除的笔记在回收站中保留 {{.TrashDays}} 天）</p>
+        </div>
+        {{end}}
+    </div>
+    </div>
     <div id="settings-tab" class="tab-content" style="display: none;">
     <div class="stats" style="margin-bottom: 0;">
         <div class="stat-item">
@@ -562,7 +744,7 @@ body {
             <div style="background: white; padding: 10px; border-radius: 4px; border: 1px solid #ddd;">
                 <label style="display: block; margin-bottom: 4px; font-size: 11px; color: #666;">访问令牌（用于访问笔记）</label>
                 <div style="display: flex; gap: 6px;">
-                    <input type="text" id="access-token-input" value="{{.AccessToken}}" placeholder="留空表示无需授权" style="flex: 1; padding: 5px; border: 1px solid #ddd; border-radius: 3px; font-size: 11px;">
+                    <input type="text" id="access-token-input" value="{{.AccessToken}}" placeholder="留空表示无需授权（存在 API key 时仍需认证）" style="flex: 1; padding: 5px; border: 1px solid #ddd; border-radius: 3px; font-size: 11px;">
                     <button onclick="updateConfig('accessToken')" style="padding: 5px 10px; background: #0066cc; color: white; border: none; border-radius: 3px; cursor: pointer; font-size: 11px;">更新</button>
                 </div>
                 <div style="margin-top: 3px; font-size: 10px; color: #999;">留空表示无需授权即可访问笔记</div>
@@ -610,7 +792,296 @@ body {
                     <button onclick="updateConfig('maxPathLength')" style="padding: 5px 10px; background: #0066cc; color: white; border: none; border-radius: 3px; cursor: pointer; font-size: 11px;">更新</button>
                 </div>
             </div>
+            <div style="background: white; padding: 10px; border-radius: 4px; border: 1px solid #ddd;">
+                <label style="display: block; margin-bottom: 4px; font-size: 11px; color: #666;">每个笔记最多保留的修订版本数</label>
+                <div style="display: flex; gap: 6px;">
+                    <input type="number" id="max-revisions-input" value="{{.MaxRevisions}}" min="1" style="flex: 1; padding: 5px; border: 1px solid #ddd; border-radius: 3px; font-size: 11px;">
+                    <button onclick="updateConfig('maxRevisions')" style="padding: 5px 10px; background: #0066cc; color: white; border: none; border-radius: 3px; cursor: pointer; font-size: 11px;">更新</button>
+                </div>
+            </div>
+            <div style="background: white; padding: 10px; border-radius: 4px; border: 1px solid #ddd;">
+                <label style="display: block; margin-bottom: 4px; font-size: 11px; color: #666;">修订版本保留天数</label>
+                <div style="display: flex; gap: 6px;">
+                    <input type="number" id="revision-days-input" value="{{.RevisionDays}}" min="1" style="flex: 1; padding: 5px; border: 1px solid #ddd; border-radius: 3px; font-size: 11px;">
+                    <button onclick="updateConfig('revisionDays')" style="padding: 5px 10px; background: #0066cc; color: white; border: none; border-radius: 3px; cursor: pointer; font-size: 11px;">更新</button>
+                </div>
+            </div>
+            <div style="background: white; padding: 10px; border-radius: 4px; border: 1px solid #ddd;">
+                <label style="display: block; margin-bottom: 4px; font-size: 11px; color: #666;">回收站保留天数</label>
+                <div style="display: flex; gap: 6px;">
+                    <input type="number" id="trash-days-input" value="{{.TrashDays}}" min="1" style="flex: 1; padding: 5px; border: 1px solid #ddd; border-radius: 3px; font-size: 11px;">
+                    <button onclick="updateConfig('trashDays')" style="padding: 5px 10px; background: #0066cc; color: white; border: none; border-radius: 3px; cursor: pointer; font-size: 11px;">更新</button>
+                </div>
+            </div>
+            <div style="background: white; padding: 10px; border-radius: 4px; border: 1px solid #ddd;">
+                <label style="display: block; margin-bottom: 4px; font-size: 11px; color: #666;">归档依据</label>
+                <div style="display: flex; gap: 6px;">
+                    <select id="archive-by-input" style="flex: 1; padding: 5px; border: 1px solid #ddd; border-radius: 3px; font-size: 11px;">
+                        <option value="modified" {{if ne .ArchiveBy "read"}}selected{{end}}>最后修改时间</option>
+                        <option value="read" {{if eq .ArchiveBy "read"}}selected{{end}}>最后读取时间</option>
+                    </select>
+                    <button onclick="updateConfig('archiveBy')" style="padding: 5px 10px; background: #0066cc; color: white; border: none; border-radius: 3px; cursor: pointer; font-size: 11px;">更新</button>
+                </div>
+            </div>
+            <div style="background: white; padding: 10px; border-radius: 4px; border: 1px solid #ddd;">
+                <label style="display: block; margin-bottom: 4px; font-size: 11px; color: #666;">归档笔记保留天数（0 表示不删除）</label>
+                <div style="display: flex; gap: 6px;">
+                    <input type="number" id="delete-archived-days-input" value="{{.DeleteArchivedDays}}" min="0" style="flex: 1; padding: 5px; border: 1px solid #ddd; border-radius: 3px; font-size: 11px;">
+                    <button onclick="updateConfig('deleteArchivedDays')" style="padding: 5px 10px; background: #0066cc; color: white; border: none; border-radius: 3px; cursor: pointer; font-size: 11px;">更新</button>
+                </div>
+            </div>
+            <div style="background: white; padding: 10px; border-radius: 4px; border: 1px solid #ddd;">
+                <label style="display: block; margin-bottom: 4px; font-size: 11px; color: #666;">最多保留的归档笔记数量（0 表示不限制）</label>
+                <div style="display: flex; gap: 6px;">
+                    <input type="number" id="archive-keep-last-input" value="{{.ArchiveKeepLast}}" min="0" style="flex: 1; padding: 5px; border: 1px solid #ddd; border-radius: 3px; font-size: 11px;">
+                    <button onclick="updateConfig('archiveKeepLast')" style="padding: 5px 10px; background: #0066cc; color: white; border: none; border-radius: 3px; cursor: pointer; font-size: 11px;">更新</button>
+                </div>
+            </div>
+            <div style="background: white; padding: 10px; border-radius: 4px; border: 1px solid #ddd;">
+                <label style="display: block; margin-bottom: 4px; font-size: 11px; color: #666;">归档笔记最大总大小（0 表示不限制）</label>
+                <div style="display: flex; gap: 6px;">
+                    <input type="text" id="archive-max-size-input" placeholder="如: 500MB" style="flex: 1; padding: 5px; border: 1px solid #ddd; border-radius: 3px; font-size: 11px;">
+                    <button onclick="updateConfig('archiveMaxSize')" style="padding: 5px 10px; background: #0066cc; color: white; border: none; border-radius: 3px; cursor: pointer; font-size: 11px;">更新</button>
+                </div>
+                <div style="margin-top: 3px; font-size: 10px; color: #999;">当前: {{if gt .ArchiveMaxSize 0}}{{formatSize .ArchiveMaxSize}}{{else}}不限制{{end}}</div>
+            </div>
+            <div style="background: white; padding: 10px; border-radius: 4px; border: 1px solid #ddd;">
+                <label style="display: block; margin-bottom: 4px; font-size: 11px; color: #666;">未被引用的上传文件保留天数（0 表示不限制）</label>
+                <div style="display: flex; gap: 6px;">
+                    <input type="number" id="orphan-upload-days-input" value="{{.OrphanUploadDays}}" min="0" style="flex: 1; padding: 5px; border: 1px solid #ddd; border-radius: 3px; font-size: 11px;">
+                    <button onclick="updateConfig('orphanUploadDays')" style="padding: 5px 10px; background: #0066cc; color: white; border: none; border-radius: 3px; cursor: pointer; font-size: 11px;">更新</button>
+                </div>
+                <div style="margin-top: 3px; font-size: 10px; color: #999;">上传不到 1 天的文件不会被删除</div>
+            </div>
+            <div style="background: white; padding: 10px; border-radius: 4px; border: 1px solid #ddd;">
+                <label style="display: block; margin-bottom: 4px; font-size: 11px; color: #666;">最多保留的未被引用上传文件数量（0 表示不限制）</label>
+                <div style="display: flex; gap: 6px;">
+                    <input type="number" id="orphan-upload-keep-last-input" value="{{.OrphanUploadKeepLast}}" min="0" style="flex: 1; padding: 5px; border: 1px solid #ddd; border-radius: 3px; font-size: 11px;">
+                    <button onclick="updateConfig('orphanUploadKeepLast')" style="padding: 5px 10px; background: #0066cc; color: white; border: none; border-radius: 3px; cursor: pointer; font-size: 11px;">更新</button>
+                </div>
+            </div>
+            <div style="background: white; padding: 10px; border-radius: 4px; border: 1px solid #ddd;">
+                <label style="display: block; margin-bottom: 4px; font-size: 11px; color: #666;">未被引用的上传文件最大总大小（0 表示不限制）</label>
+                <div style="display: flex; gap: 6px;">
+                    <input type="text" id="orphan-upload-max-size-input" placeholder="如: 100MB" style="flex: 1; padding: 5px; border: 1px solid #ddd; border-radius: 3px; font-size: 11px;">
+                    <button onclick="updateConfig('orphanUploadMaxSize')" style="padding: 5px 10px; background: #0066cc; color: white; border: none; border-radius: 3px; cursor: pointer; font-size: 11px;">更新</button>
+                </div>
+                <div style="margin-top: 3px; font-size: 10px; color: #999;">当前: {{if gt .OrphanUploadMaxSize 0}}{{formatSize .OrphanUploadMaxSize}}{{else}}不限制{{end}}</div>
+            </div>
+        </div>
+    </div>
+    <div style="padding: 12px 16px; background: #f9f9f9; border-top: 1px solid #ddd;">
+        <h3 style="margin-bottom: 10px; font-size: 14px; color: #333; font-weight: 600;">API Key</h3>
+        <p style="margin-bottom: 8px; font-size: 11px; color: #666;">存在可用的 API key 时站点需要认证。权限：read（查看）、write（编辑）、upload（上传）、admin（全部权限和管理接口）。Token 只在创建时显示一次。</p>
+        <div style="background: white; padding: 10px; border-radius: 4px; border: 1px solid #ddd; margin-bottom: 10px; display: flex; flex-wrap: wrap; gap: 8px; align-items: center; font-size: 11px;">
+            <input type="text" id="api-key-name-input" placeholder="名称，如: dashboard" style="padding: 5px; border: 1px solid #ddd; border-radius: 3px; font-size: 11px; width: 160px;">
+            <label><input type="checkbox" class="api-key-scope" value="read" checked> read</label>
+            <label><input type="checkbox" class="api-key-scope" value="write"> write</label>
+            <label><input type="checkbox" class="api-key-scope" value="upload"> upload</label>
+            <label><input type="checkbox" class="api-key-scope" value="admin"> admin</label>
+            <label>过期日期 <input type="date" id="api-key-expires-input" style="padding: 4px; border: 1px solid #ddd; border-radius: 3px; font-size: 11px;"></label>
+            <button onclick="createAPIKey()" style="padding: 5px 10px; background: #0066cc; color: white; border: none; border-radius: 3px; cursor: pointer; font-size: 11px;">创建</button>
+        </div>
+        {{if .APIKeys}}
+        <table class="notes-table" style="background: white;">
+            <thead>
+                <tr>
+                    <th>名称</th>
+                    <th>ID</th>
+                    <th>权限</th>
+                    <th>创建时间</th>
+                    <th>过期时间</th>
+                    <th>最后使用</th>
+                    <th>状态</th>
+                    <th></th>
+                </tr>
+            </thead>
+            <tbody>
+                {{range .APIKeys}}
+                <tr>
+                    <td>{{.Name}}</td>
+                    <td class="note-date">{{.ID}}</td>
+                    <td>{{join .Scopes ", "}}</td>
+                    <td class="note-date">{{formatDate .CreatedAt}}</td>
+                    <td class="note-date">{{if .ExpiresAt}}{{formatDate .ExpiresAt}}{{else}}永不过期{{end}}</td>
+                    <td class="note-date">{{if .LastUsedAt}}{{formatDate .LastUsedAt}}{{else}}从未使用{{end}}</td>
+                    <td>{{if .RevokedAt}}已吊销{{else if .Active}}可用{{else}}已过期{{end}}</td>
+                    <td>{{if not .RevokedAt}}<button onclick="revokeAPIKey('{{.ID}}', '{{.Name}}')" style="padding: 3px 8px; background: #d9534f; color: white; border: none; border-radius: 3px; cursor: pointer; font-size: 11px;">吊销</button>{{end}}</td>
+                </tr>
+                {{end}}
+            </tbody>
+        </table>
+        {{end}}
+    </div>
+    <div style="padding: 12px 16px; background: #f9f9f9; border-top: 1px solid #ddd;">
+        <h3 style="margin-bottom: 10px; font-size: 14px; color: #333; font-weight: 600;">Webhook</h3>
+        <p style="margin-bottom: 8px; font-size: 11px; color: #666;">笔记被创建、修改、加锁、删除或归档时向 URL 发送 POST 请求（JSON），X-Jot-Signature header 为使用 secret 计算的请求体 HMAC-SHA256。失败后按指数退避重试，最多 10 次。不选择事件时接收所有事件。Secret 只在创建时显示一次。</p>
+        <div style="background: white; padding: 10px; border-radius: 4px; border: 1px solid #ddd; margin-bottom: 10px; display: flex; flex-wrap: wrap; gap: 8px; align-items: center; font-size: 11px;">
+            <input type="text" id="webhook-name-input" placeholder="名称，如: chat" style="padding: 5px; border: 1px solid #ddd; border-radius: 3px; font-size: 11px; width: 120px;">
+            <input type="url" id="webhook-url-input" placeholder="https://example.com/hook" style="padding: 5px; border: 1px solid #ddd; border-radius: 3px; font-size: 11px; width: 240px;">
+            {{range .WebhookEvents}}<label><input type="checkbox" class="webhook-event" value="{{.}}"> {{.}}</label>
+            {{end}}<button onclick="createWebhook()" style="padding: 5px 10px; background: #0066cc; color: white; border: none; border-radius: 3px; cursor: pointer; font-size: 11px;">创建</button>
+        </div>
+        {{if .Webhooks}}
+        <table class="notes-table" style="background: white; margin-bottom: 10px;">
+            <thead>
+                <tr>
+                    <th>名称</th>
+                    <th>URL</th>
+                    <th>事件</th>
+                    <th>创建时间</th>
+                    <th></th>
+                </tr>
+            </thead>
+            <tbody>
+                {{range .Webhooks}}
+                <tr>
+                    <td>{{.Name}}</td>
+                    <td class="note-date">{{.URL}}</td>
+                    <td>{{if .Events}}{{join .Events ", "}}{{else}}所有事件{{end}}</td>
+                    <td class="note-date">{{formatDate .CreatedAt}}</td>
+                    <td>
+                        <button onclick="testWebhook('{{.ID}}')" style="padding: 3px 8px; background: #0066cc; color: white; border: none; border-radius: 3px; cursor: pointer; font-size: 11px;">测试</button>
+                        <button onclick="deleteWebhook('{{.ID}}', '{{.Name}}')" style="padding: 3px 8px; background: #d9534f; color: white; border: none; border-radius: 3px; cursor: pointer; font-size: 11px;">删除</button>
+                    </td>
+                </tr>
+                {{end}}
+            </tbody>
+        </table>
+        {{end}}
+        {{if .WebhookDeliveries}}
+        <table class="notes-table" style="background: white;">
+            <thead>
+                <tr>
+                    <th>Webhook</th>
+                    <th>事件</th>
+                    <th>状态</th>
+                    <th>尝试次数</th>
+                    <th>创建时间</th>
+                    <th>最后尝试</th>
+                    <th>结果</th>
+                </tr>
+            </thead>
+            <tbody>
+                {{range .WebhookDeliveries}}
+                <tr>
+                    <td>{{.HookName}}</td>
+                    <td>{{.Event}}</td>
+                    <td>{{if eq .Status "delivered"}}成功{{else if eq .Status "failed"}}失败{{else}}等待重试{{end}}</td>
+                    <td>{{.Attempts}}</td>
+                    <td class="note-date">{{formatDate .CreatedAt}}</td>
+                    <td class="note-date">{{if .LastAttempt}}{{formatDate .LastAttempt}}{{end}}{{if eq .Status "pending"}}{{if .Attempts}}（下次 {{formatDate .NextAttempt}}）{{end}}{{end}}</td>
+                    <td class="note-date" title="{{.Error}}">{{if .StatusCode}}{{.StatusCode}} {{end}}{{preview .Error 60}}</td>
+                </tr>
+                {{end}}
+            </tbody>
+        </table>
+        {{end}}
+    </div>
+    <div style="padding: 12px 16px; background: #f9f9f9; border-top: 1px solid #ddd;">
+        <h3 style="margin-bottom: 10px; font-size: 14px; color: #333; font-weight: 600;">用户</h3>
+        <p style="margin-bottom: 8px; font-size: 11px; color: #666;">用户通过 /login 登录。登录用户新建的笔记只有自己可以访问，可以在笔记页面共享给其他用户或公开只读。删除用户不会删除其笔记。</p>
+        <div style="background: white; padding: 10px; border-radius: 4px; border: 1px solid #ddd; margin-bottom: 10px; display: flex; flex-wrap: wrap; gap: 8px; align-items: center; font-size: 11px;">
+            <input type="text" id="user-name-input" placeholder="用户名" autocomplete="off" style="padding: 5px; border: 1px solid #ddd; border-radius: 3px; font-size: 11px; width: 140px;">
+            <input type="password" id="user-password-input" placeholder="密码（至少 8 位）" autocomplete="new-password" style="padding: 5px; border: 1px solid #ddd; border-radius: 3px; font-size: 11px; width: 160px;">
+            <button onclick="createUser()" style="padding: 5px 10px; background: #0066cc; color: white; border: none; border-radius: 3px; cursor: pointer; font-size: 11px;">创建</button>
+        </div>
+        {{if .Users}}
+        <table class="notes-table" style="background: white;">
+            <thead>
+                <tr>
+                    <th>用户名</th>
+                    <th>创建时间</th>
+                    <th></th>
+                </tr>
+            </thead>
+            <tbody>
+                {{range .Users}}
+                <tr>
+                    <td>{{.Username}}</td>
+                    <td class="note-date">{{formatDate .CreatedAt}}</td>
+                    <td>
+                        <button onclick="resetUserPassword('{{.Username}}')" style="padding: 3px 8px; background: #0066cc; color: white; border: none; border-radius: 3px; cursor: pointer; font-size: 11px;">重置密码</button>
+                        <button onclick="deleteUser('{{.Username}}')" style="padding: 3px 8px; background: #d9534f; color: white; border: none; border-radius: 3px; cursor: pointer; font-size: 11px;">删除</button>
+                    </td>
+                </tr>
+                {{end}}
+            </tbody>
+        </table>
+        {{end}}
+    </div>
+    <div style="padding: 12px 16px; background: #f9f9f9; border-top: 1px solid #ddd;">
+        <h3 style="margin-bottom: 10px; font-size: 14px; color: #333; font-weight: 600;">限流和暴力破解防护</h3>
+        <p style="margin-bottom: 8px; font-size: 11px; color: #666;">在 config.json 的 rateLimit 中修改，重启后生效。错误的管理员令牌、访问令牌、API key、锁令牌和用户密码计入失败次数，达到上限后客户端 IP 和笔记被锁定，之后每次失败锁定时间翻倍。</p>
+        <table class="notes-table" style="background: white; margin-bottom: 10px;">
+            <tbody>
+                <tr><td>状态</td><td>{{if .RateLimit.Enabled}}已启用{{else}}已关闭{{end}}</td></tr>
+                <tr><td>每个 IP</td><td>{{.RateLimit.IPRequestsPerMinute}} 次/分钟，突发 {{.RateLimit.IPBurst}} 次</td></tr>
+                <tr><td>每个笔记</td><td>{{.RateLimit.NoteRequestsPerMinute}} 次/分钟，突发 {{.RateLimit.NoteBurst}} 次</td></tr>
+                <tr><td>失败锁定</td><td>连续失败 {{.RateLimit.MaxFailures}} 次后锁定 {{.RateLimit.LockoutSeconds}} 秒，最长 {{.RateLimit.MaxLockoutSeconds}} 秒</td></tr>
+                <tr><td>可信代理</td><td>{{if .RateLimit.TrustedProxies}}{{join .RateLimit.TrustedProxies ", "}}{{else}}无（忽略 X-Forwarded-For）{{end}}</td></tr>
+                <tr><td>当前客户端 IP</td><td>{{.ClientIP}}</td></tr>
+            </tbody>
+        </table>
+        {{if .Lockouts}}
+        <table class="notes-table" style="background: white;">
+            <thead>
+                <tr>
+                    <th>锁定对象</th>
+                    <th>失败次数</th>
+                    <th>解锁时间</th>
+                    <th></th>
+                </tr>
+            </thead>
+            <tbody>
+                {{range .Lockouts}}
+                <tr>
+                    <td>{{if eq .Kind "ip"}}IP {{.Value}}{{else}}笔记 {{.Value}}{{end}}</td>
+                    <td>{{.Failures}}</td>
+                    <td class="note-date">{{formatDate .Until}}</td>
+                    <td><button onclick="unlockRateLimit('{{.Kind}}', '{{.Value}}')" style="padding: 3px 8px; background: #0066cc; color: white; border: none; border-radius: 3px; cursor: pointer; font-size: 11px;">解除锁定</button></td>
+                </tr>
+                {{end}}
+            </tbody>
+        </table>
+        {{else}}
+        <p style="font-size: 11px; color: #666;">当前没有被锁定的 IP 或笔记。</p>
+        {{end}}
+    </div>
+    <div style="padding: 12px 16px; background: #f9f9f9; border-top: 1px solid #ddd;">
+        <h3 style="margin-bottom: 10px; font-size: 14px; color: #333; font-weight: 600;">登录 Session</h3>
+        <p style="margin-bottom: 8px; font-size: 11px; color: #666;">管理员 session 空闲 2 小时后过期，用户 session 空闲 7 天后过期，每次访问后顺延。存储类型：{{.SessionStoreType}}</p>
+        <div style="margin-bottom: 10px;">
+            <button onclick="revokeAllSessions()" style="padding: 5px 10px; background: #d9534f; color: white; border: none; border-radius: 3px; cursor: pointer; font-size: 11px;">退出所有 session</button>
         </div>
+        {{if .Sessions}}
+        <table class="notes-table" style="background: white;">
+            <thead>
+                <tr>
+                    <th>类型</th>
+                    <th>IP</th>
+                    <th>User-Agent</th>
+                    <th>登录时间</th>
+                    <th>最后活动</th>
+                    <th>过期时间</th>
+                    <th></th>
+                </tr>
+            </thead>
+            <tbody>
+                {{range .Sessions}}
+                <tr>
+                    <td>{{if eq .Kind "admin"}}管理员{{else}}用户 {{.Username}}{{end}}{{if .Current}} <span class="note-owner">当前</span>{{end}}</td>
+                    <td class="note-date">{{.IP}}</td>
+                    <td class="note-date" title="{{.UserAgent}}">{{preview .UserAgent 60}}</td>
+                    <td class="note-date">{{formatDate .CreatedAt}}</td>
+                    <td class="note-date">{{formatDate .LastSeenAt}}</td>
+                    <td class="note-date">{{formatDate .ExpiresAt}}</td>
+                    <td><button onclick="revokeSession('{{.ID}}', {{.Current}})" style="padding: 3px 8px; background: #d9534f; color: white; border: none; border-radius: 3px; cursor: pointer; font-size: 11px;">退出</button></td>
+                </tr>
+                {{end}}
+            </tbody>
+        </table>
+        {{end}}
     </div>
     </div>
 </div>
@@ -620,9 +1091,18 @@ body {
 
 
 function showTab(tabName) {
+    currentTab = tabName;
+    const searchInput = document.getElementById('search-input');
+    if (searchInput && searchInput.value) {
+        searchInput.value = '';
+        runSearch();
+        return;
+    }
+
     // Hide all tab contents
     document.getElementById('active-tab').style.display = 'none';
     document.getElementById('backup-tab').style.display = 'none';
+    document.getElementById('trash-tab').style.display = 'none';
     document.getElementById('settings-tab').style.display = 'none';
     
     // Remove active class from all buttons
@@ -637,6 +1117,9 @@ function showTab(tabName) {
     } else if (tabName === 'backup') {
         document.getElementById('backup-tab').style.display = 'block';
         document.querySelector('.tab-button:nth-child(2)').classList.add('active');
+    } else if (tabName === 'trash') {
+        document.getElementById('trash-tab').style.display = 'block';
+        document.querySelector('.tab-button:nth-child(3)').classList.add('active');
     } else if (tabName === 'settings') {
         document.getElementById('settings-tab').style.display = 'block';
         document.querySelector('.tab-button:last-child').classList.add('active');
@@ -682,11 +1165,443 @@ function filterByDate() {
     });
 }
 
-// Auto refresh every 30 seconds
+let searchTimer = null;
+let searchSeq = 0;
+let currentTab = 'active';
+
+function escapeHtml(text) {
+    return text.replace(/[&<>"']/g, c => ({'&': '&amp;', '<': '&lt;', '>': '&gt;', '"': '&quot;', "'": '&#39;'}[c]));
+}
+
+// highlightSnippet 根据 highlights 区间（UTF-16 下标）为摘要添加高亮
+function highlightSnippet(snippet, highlights) {
+    let html = '';
+    let pos = 0;
+    (highlights || []).forEach(([start, end]) => {
+        html += escapeHtml(snippet.slice(pos, start)) + '<mark>' + escapeHtml(snippet.slice(start, end)) + '</mark>';
+        pos = end;
+    });
+    return html + escapeHtml(snippet.slice(pos));
+}
+
+function scheduleSearch() {
+    clearTimeout(searchTimer);
+    searchTimer = setTimeout(runSearch, 300);
+}
+
+function runSearch() {
+    const query = document.getElementById('search-input').value.trim();
+    const results = document.getElementById('search-results');
+    const status = document.getElementById('search-status');
+    const seq = ++searchSeq;
+
+    if (!query) {
+        results.style.display = 'none';
+        status.textContent = '';
+        showTab(currentTab);
+        return;
+    }
+
+    status.textContent = '搜索中...';
+    fetch('/api/search?limit=100&q=' + encodeURIComponent(query), { credentials: 'include' })
+    .then(res => {
+        if (!res.ok) throw new Error(res.status);
+        return res.json();
+    })
+    .then(data => {
+        if (seq !== searchSeq) return;
+        ['active-tab', 'backup-tab', 'settings-tab'].forEach(id => {
+            document.getElementById(id).style.display = 'none';
+        });
+        results.style.display = 'block';
+        status.textContent = '找到 ' + data.results.length + ' 条结果';
+        if (data.results.length === 0) {
+            results.innerHTML = '<div class="empty"><div class="empty-icon">🔍</div><p>没有匹配的笔记</p></div>';
+            return;
+        }
+        let rows = '';
+        data.results.forEach(r => {
+            const href = (r.is_backup ? '/read/' : '/') + encodeURIComponent(r.name);
+            const date = r.date_dir.slice(0, 4) + '-' + r.date_dir.slice(4, 6) + '-' + r.date_dir.slice(6, 8);
+            rows += '<tr>' +
+                '<td><a href="' + href + '" class="note-name">' + escapeHtml(r.name) + '</a>' + (r.is_backup ? ' 📦' : '') + '</td>' +
+                '<td class="search-snippet">' + highlightSnippet(r.snippet, r.highlights) + '</td>' +
+                '<td class="note-date">' + date + '</td>' +
+                '<td class="note-date">' + new Date(r.updated_at).toLocaleString() + '</td>' +
+                '</tr>';
+        });
+        results.innerHTML = '<table class="notes-table"><thead><tr><th>笔记名称</th><th>匹配内容</th><th>日期目录</th><th>更新时间</th></tr></thead><tbody>' + rows + '</tbody></table>';
+    })
+    .catch(err => {
+        if (seq !== searchSeq) return;
+        console.error('Search error:', err);
+        status.textContent = '搜索失败';
+    });
+}
+
+// Auto refresh every 30 seconds (skipped while searching)
 setInterval(() => {
+    if (document.getElementById('search-input').value.trim()) return;
     location.reload();
 }, 30000);
 
+function createAPIKey() {
+    const name = document.getElementById('api-key-name-input').value.trim();
+    if (!name) {
+        alert('请输入 API key 名称');
+        return;
+    }
+    const scopes = Array.from(document.querySelectorAll('.api-key-scope:checked')).map(el => el.value);
+    if (scopes.length === 0) {
+        alert('请至少选择一个权限');
+        return;
+    }
+    const expiresAt = document.getElementById('api-key-expires-input').value;
+
+    fetch('/api/keys', {
+        method: 'POST',
+        headers: {
+            'Content-Type': 'application/json'
+        },
+        credentials: 'include',
+        body: JSON.stringify({ name: name, scopes: scopes, expiresAt: expiresAt })
+    })
+    .then(res => res.ok ? res.json() : res.text().then(text => { throw new Error(text); }))
+    .then(data => {
+        prompt('API key 已创建，请立即复制保存（只显示这一次）：', data.token);
+        location.reload();
+    })
+    .catch(err => {
+        console.error('Create API key error:', err);
+        alert('创建失败: ' + err.message);
+    });
+}
+
+function createUser() {
+    const username = document.getElementById('user-name-input').value.trim();
+    const password = document.getElementById('user-password-input').value;
+    if (!username || !password) {
+        alert('请输入用户名和密码');
+        return;
+    }
+
+    fetch('/api/users', {
+        method: 'POST',
+        headers: {
+            'Content-Type': 'application/json'
+        },
+        credentials: 'include',
+        body: JSON.stringify({ username: username, password: password })
+    })
+    .then(res => res.ok ? res.json() : res.text().then(text => { throw new Error(text); }))
+    .then(() => location.reload())
+    .catch(err => {
+        console.error('Create user error:', err);
+        alert('创建失败: ' + err.message);
+    });
+}
+
+function resetUserPassword(username) {
+    const password = prompt('请输入用户 "' + username + '" 的新密码（至少 8 位）:');
+    if (!password) {
+        return;
+    }
+    fetch('/api/users/' + encodeURIComponent(username) + '/password', {
+        method: 'POST',
+        headers: {
+            'Content-Type': 'application/json'
+        },
+        credentials: 'include',
+        body: JSON.stringify({ password: password })
+    })
+    .then(res => res.ok ? res.json() : res.text().then(text => { throw new Error(text); }))
+    .then(() => alert('密码已重置，该用户需要重新登录'))
+    .catch(err => {
+        console.error('Reset password error:', err);
+        alert('重置失败: ' + err.message);
+    });
+}
+
+function deleteUser(username) {
+    if (!confirm('确定要删除用户 "' + username + '" 吗？其笔记将保留，只有管理员可以访问。')) {
+        return;
+    }
+    fetch('/api/users/' + encodeURIComponent(username) + '/delete', {
+        method: 'POST',
+        credentials: 'include'
+    })
+    .then(res => {
+        if (!res.ok) throw new Error(res.status);
+        location.reload();
+    })
+    .catch(err => {
+        console.error('Delete user error:', err);
+        alert('删除失败');
+    });
+}
+
+function unlockRateLimit(kind, value) {
+    fetch('/api/rate-limit/unlock', {
+        method: 'POST',
+        headers: {
+            'Content-Type': 'application/json'
+        },
+        credentials: 'include',
+        body: JSON.stringify({ kind: kind, value: value })
+    })
+    .then(res => {
+        if (!res.ok) throw new Error(res.status);
+        location.reload();
+    })
+    .catch(err => {
+        console.error('Unlock error:', err);
+        alert('解除锁定失败');
+    });
+}
+
+function revokeSession(id, current) {
+    if (!confirm(current ? '这是当前使用的 session，退出后需要重新登录。确定要退出吗？' : '确定要退出这个 session 吗？')) {
+        return;
+    }
+    fetch('/api/sessions/' + encodeURIComponent(id) + '/revoke', {
+        method: 'POST',
+        credentials: 'include'
+    })
+    .then(res => {
+        if (!res.ok) throw new Error(res.status);
+        location.reload();
+    })
+    .catch(err => {
+        console.error('Revoke session error:', err);
+        alert('退出失败');
+    });
+}
+
+function revokeAllSessions() {
+    if (!confirm('确定要退出所有管理员和用户的 session 吗？包括当前 session，所有人都需要重新登录。')) {
+        return;
+    }
+    fetch('/api/sessions/revoke-all', {
+        method: 'POST',
+        credentials: 'include'
+    })
+    .then(res => {
+        if (!res.ok) throw new Error(res.status);
+        location.reload();
+    })
+    .catch(err => {
+        console.error('Revoke all sessions error:', err);
+        alert('退出失败');
+    });
+}
+
+function createWebhook() {
+    const name = document.getElementById('webhook-name-input').value.trim();
+    const url = document.getElementById('webhook-url-input').value.trim();
+    if (!name || !url) {
+        alert('请输入 webhook 名称和 URL');
+        return;
+    }
+    const events = Array.from(document.querySelectorAll('.webhook-event:checked')).map(el => el.value);
+
+    fetch('/api/webhooks', {
+        method: 'POST',
+        headers: {
+            'Content-Type': 'application/json'
+        },
+        credentials: 'include',
+        body: JSON.stringify({ name: name, url: url, events: events })
+    })
+    .then(res => res.ok ? res.json() : res.text().then(text => { throw new Error(text); }))
+    .then(data => {
+        prompt('Webhook 已创建，请立即复制 secret 用于校验签名（只显示这一次）：', data.secret);
+        location.reload();
+    })
+    .catch(err => {
+        console.error('Create webhook error:', err);
+        alert('创建失败: ' + err.message);
+    });
+}
+
+function testWebhook(id) {
+    fetch('/api/webhooks/' + encodeURIComponent(id) + '/test', {
+        method: 'POST',
+        credentials: 'include'
+    })
+    .then(res => {
+        if (!res.ok) throw new Error(res.status);
+        setTimeout(() => location.reload(), 1000);
+    })
+    .catch(err => {
+        console.error('Test webhook error:', err);
+        alert('发送失败');
+    });
+}
+
+function deleteWebhook(id, name) {
+    if (!confirm('确定要删除 webhook "' + name + '" 吗？未完成的投递会被丢弃。')) {
+        return;
+    }
+    fetch('/api/webhooks/' + encodeURIComponent(id) + '/delete', {
+        method: 'POST',
+        credentials: 'include'
+    })
+    .then(res => {
+        if (!res.ok) throw new Error(res.status);
+        location.reload();
+    })
+    .catch(err => {
+        console.error('Delete webhook error:', err);
+        alert('删除失败');
+    });
+}
+
+function unarchiveNote(name, dateDir, conflict) {
+    let url = '/api/notes/' + encodeURIComponent(name) + '/unarchive?date=' + encodeURIComponent(dateDir);
+    if (conflict) {
+        url += '&conflict=' + encodeURIComponent(conflict);
+    }
+    fetch(url, {
+        method: 'POST',
+        credentials: 'include'
+    })
+    .then(res => {
+        if (res.status === 409) {
+            const choice = prompt('活跃笔记 "' + name + '" 已存在。输入 rename 以新名称（' + name + '-' + dateDir + '）恢复，或输入 overwrite 覆盖现有笔记（现有内容保存为修订版本）：', 'rename');
+            if (choice === 'rename' || choice === 'overwrite') {
+                unarchiveNote(name, dateDir, choice);
+            }
+            return null;
+        }
+        if (!res.ok) return res.text().then(text => { throw new Error(text || res.status); });
+        return res.json();
+    })
+    .then(data => {
+        if (!data) return;
+        if (data.result.renamed) {
+            alert('已恢复为 ' + data.result.name);
+        }
+        location.reload();
+    })
+    .catch(err => {
+        console.error('Unarchive note error:', err);
+        alert('恢复失败: ' + err.message);
+    });
+}
+
+function importSnapshot() {
+    const file = document.getElementById('snapshot-file').files[0];
+    if (!file) {
+        alert('请选择快照文件');
+        return;
+    }
+    const mode = document.getElementById('snapshot-mode').value;
+    const restoreConfig = document.getElementById('snapshot-config').checked;
+    if (mode === 'replace' && !confirm('替换导入会先删除所有现有的笔记和上传文件，确定要继续吗？')) {
+        return;
+    }
+    let url = '/api/snapshot/import?mode=' + encodeURIComponent(mode);
+    if (restoreConfig) {
+        url += '&config=true';
+    }
+    fetch(url, {
+        method: 'POST',
+        credentials: 'include',
+        headers: { 'Content-Type': 'application/gzip' },
+        body: file
+    })
+    .then(res => {
+        if (!res.ok) return res.text().then(text => { throw new Error(text || res.status); });
+        return res.json();
+    })
+    .then(data => {
+        const r = data.report;
+        let text = '导入完成：笔记 ' + r.notes + ' 个，归档笔记 ' + r.archived + ' 个，修订版本 ' + r.revisions + ' 个，回收站 ' + r.trash + ' 个，上传文件 ' + r.uploads + ' 个，跳过相同内容 ' + r.skipped + ' 个' + (r.config ? '，已恢复配置文件' : '');
+        if (r.conflicts.length > 0) {
+            text += '\n以下 ' + r.conflicts.length + ' 个文件与本地内容不同，保留了本地内容：';
+            r.conflicts.forEach(c => { text += '\n  ' + c.path; });
+        }
+        const report = document.getElementById('snapshot-report');
+        report.textContent = text;
+        report.style.display = 'block';
+    })
+    .catch(err => {
+        console.error('Import snapshot error:', err);
+        alert('导入失败: ' + err.message);
+    });
+}
+
+function pinNote(name, pinned) {
+    fetch('/api/notes/' + encodeURIComponent(name) + '/pin', {
+        method: 'POST',
+        headers: {
+            'Content-Type': 'application/json'
+        },
+        credentials: 'include',
+        body: JSON.stringify({ pinned: pinned })
+    })
+    .then(res => {
+        if (!res.ok) throw new Error(res.status);
+        location.reload();
+    })
+    .catch(err => {
+        console.error('Pin note error:', err);
+        alert((pinned ? '置顶' : '取消置顶') + '失败: ' + err.message);
+    });
+}
+
+function restoreTrash(name, id) {
+    fetch('/api/trash/' + encodeURIComponent(name) + '/restore?id=' + encodeURIComponent(id), {
+        method: 'POST',
+        credentials: 'include'
+    })
+    .then(res => {
+        if (res.status === 409) throw new Error('笔记 "' + name + '" 已存在，请先删除或重命名现有笔记');
+        if (!res.ok) throw new Error(res.status);
+        location.reload();
+    })
+    .catch(err => {
+        console.error('Restore note error:', err);
+        alert('恢复失败: ' + err.message);
+    });
+}
+
+function purgeTrash(name, id) {
+    if (!confirm('确定要永久删除笔记 "' + name + '" 吗？此操作无法撤销。')) {
+        return;
+    }
+    fetch('/api/trash/' + encodeURIComponent(name) + '/purge?id=' + encodeURIComponent(id), {
+        method: 'POST',
+        credentials: 'include'
+    })
+    .then(res => {
+        if (!res.ok) throw new Error(res.status);
+        location.reload();
+    })
+    .catch(err => {
+        console.error('Purge note error:', err);
+        alert('删除失败');
+    });
+}
+
+function revokeAPIKey(id, name) {
+    if (!confirm('确定要吊销 API key "' + name + '" 吗？使用它的客户端将立即无法访问。')) {
+        return;
+    }
+    fetch('/api/keys/' + encodeURIComponent(id) + '/revoke', {
+        method: 'POST',
+        credentials: 'include'
+    })
+    .then(res => {
+        if (!res.ok) throw new Error(res.status);
+        location.reload();
+    })
+    .catch(err => {
+        console.error('Revoke API key error:', err);
+        alert('吊销失败');
+    });
+}
+
 function updateMaxTotalSize() {
     updateConfig('maxTotalSize');
 }
@@ -769,6 +1684,81 @@ function updateConfig(field) {
             }
             payload.maxNoteCount = value;
             break;
+        case 'maxRevisions':
+            value = parseInt(document.getElementById('max-revisions-input').value);
+            if (isNaN(value) || value <= 0) {
+                alert('请输入有效的数字');
+                return;
+            }
+            payload.maxRevisions = value;
+            break;
+        case 'revisionDays':
+            value = parseInt(document.getElementById('revision-days-input').value);
+            if (isNaN(value) || value <= 0) {
+                alert('请输入有效的数字');
+                return;
+            }
+            payload.revisionDays = value;
+            break;
+        case 'trashDays':
+            value = parseInt(document.getElementById('trash-days-input').value);
+            if (isNaN(value) || value <= 0) {
+                alert('请输入有效的数字');
+                return;
+            }
+            payload.trashDays = value;
+            break;
+        case 'archiveBy':
+            payload.archiveBy = document.getElementById('archive-by-input').value;
+            break;
+        case 'deleteArchivedDays':
+            value = parseInt(document.getElementById('delete-archived-days-input').value);
+            if (isNaN(value) || value < 0) {
+                alert('请输入有效的数字');
+                return;
+            }
+            payload.deleteArchivedDays = value;
+            break;
+        case 'archiveKeepLast':
+            value = parseInt(document.getElementById('archive-keep-last-input').value);
+            if (isNaN(value) || value < 0) {
+                alert('请输入有效的数字');
+                return;
+            }
+            payload.archiveKeepLast = value;
+            break;
+        case 'archiveMaxSize':
+            value = document.getElementById('archive-max-size-input').value.trim();
+            if (!value) {
+                alert('请输入大小限制（如: 500MB，0 表示不限制）');
+                return;
+            }
+            payload.archiveMaxSize = value;
+            break;
+        case 'orphanUploadDays':
+            value = parseInt(document.getElementById('orphan-upload-days-input').value);
+            if (isNaN(value) || value < 0) {
+                alert('请输入有效的数字');
+                return;
+            }
+            payload.orphanUploadDays = value;
+            break;
+        case 'orphanUploadKeepLast':
+            value = parseInt(document.getElementById('orphan-upload-keep-last-input').value);
+            if (isNaN(value) || value < 0) {
+                alert('请输入有效的数字');
+                return;
+            }
+            payload.orphanUploadKeepLast = value;
+            break;
+        case 'orphanUploadMaxSize':
+            value = document.getElementById('orphan-upload-max-size-input').value.trim();
+            if (!value) {
+                alert('请输入大小限制（如: 500MB，0 表示不限制）');
+                return;
+            }
+            payload.orphanUploadMaxSize = value;
+            break;
         default:
             alert('未知的配置项');
             return;
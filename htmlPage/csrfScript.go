@@ -0,0 +1,26 @@
+package htmlPage
+
+// CSRFScript 包装 fetch，同源的修改请求自动带上 csrf_token cookie 中的 CSRF token
+const CSRFScript = `<script>
+(function() {
+    const originalFetch = window.fetch;
+    function csrfToken() {
+        const match = document.cookie.match(/(?:^|;\s*)csrf_token=([^;]*)/);
+        return match ? decodeURIComponent(match[1]) : '';
+    }
+    window.fetch = function(input, init) {
+        init = init || {};
+        const method = (init.method || (input instanceof Request ? input.method : 'GET')).toUpperCase();
+        const url = new URL(input instanceof Request ? input.url : input, window.location.href);
+        if (method !== 'GET' && method !== 'HEAD' && url.origin === window.location.origin) {
+            const headers = new Headers(init.headers || (input instanceof Request ? input.headers : undefined));
+            if (!headers.has('X-CSRF-Token')) {
+                headers.set('X-CSRF-Token', csrfToken());
+            }
+            init.headers = headers;
+        }
+        return originalFetch.call(this, input, init);
+    };
+})();
+</script>
+`
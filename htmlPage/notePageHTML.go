@@ -3,7 +3,7 @@ package htmlPage
 const NotePageHTML = `<!DOCTYPE html>
 <html>
 <head>
-<meta charset="utf-8">
+` + CSRFScript + `<meta charset="utf-8">
 <meta name="viewport" content="width=device-width, initial-scale=1.0">
 <title>{{.NoteName}}</title>
 <style>
@@ -154,6 +154,40 @@ body {
     background: #fff;
     border-left: 1px solid #ddd;
 }
+.editor-wrapper {
+    flex: 1;
+    display: flex;
+    position: relative;
+    min-height: 0;
+}
+/* 协同编辑：远程光标和选区，叠加在编辑器上方 */
+#remote-cursors {
+    position: absolute;
+    top: 0;
+    left: 0;
+    overflow: hidden;
+    pointer-events: none;
+    white-space: pre-wrap;
+    word-wrap: break-word;
+    color: transparent;
+    box-sizing: border-box;
+}
+.remote-caret {
+    position: relative;
+    border-left: 2px solid;
+    margin-right: -2px;
+}
+.remote-caret-label {
+    position: absolute;
+    top: -1.2em;
+    left: -2px;
+    padding: 0 4px;
+    font-size: 10px;
+    line-height: 1.2em;
+    color: #fff;
+    border-radius: 3px;
+    white-space: nowrap;
+}
 #preview h1, #preview h2, #preview h3 {
     margin-top: 1em;
     margin-bottom: 0.5em;
@@ -472,11 +506,22 @@ body {
                     <span class="file-info-label">修改:</span>
                     <span class="file-info-value" id="mod-time">{{.ModTime}}</span>
                 </div>
+                {{if .Owner}}
+                <div class="file-info-item">
+                    <span class="file-info-label">所有者:</span>
+                    <span class="file-info-value" id="note-owner">{{.Owner}}</span>
+                </div>
+                {{end}}
             </div>
             <div style="display: flex; gap: 8px; align-items: center;">
+                {{if .CanManageSharing}}<button onclick="openSharingDialog()" class="header-btn" style="background: #6f42c1;">👥 {{if .Owner}}共享{{else}}设为私有{{end}}</button>{{end}}
+                {{if .Username}}<button onclick="logoutUser()" class="header-btn" style="background: #6c757d;" title="退出登录">👤 {{.Username}} 退出</button>{{else if .AccountsEnabled}}<a href="/login?next={{.NotePath}}" class="header-btn" style="background: #6c757d; text-decoration: none;">👤 登录</a>{{end}}
             </div>
         </div>
-        <textarea id="editor" placeholder="开始输入 Markdown 内容...">{{.Content}}</textarea>
+        <div class="editor-wrapper">
+            <textarea id="editor" placeholder="开始输入 Markdown 内容...">{{.Content}}</textarea>
+            <div id="remote-cursors"></div>
+        </div>
     </div>
     <div class="preview-panel" id="preview-panel">
         <div class="panel-header">
@@ -500,6 +545,9 @@ const connectionStatus = document.getElementById('connection-status');
 let lastContent = editor.value;
 let ws = null;
 let saveTimeout = null;
+// 当前内容的 ETag，保存时通过 If-Match 检查笔记是否已被其他地方修改
+let currentETag = {{.ETag}};
+let conflictPending = false;
 
 function updatePreview() {
     const content = editor.value;
@@ -519,21 +567,34 @@ function updatePreview() {
 }
 
 function saveNote() {
+    // 协同编辑连接可用时，修改通过 WebSocket 操作保存
+    if (collabReady) return;
     const content = editor.value;
-    if (content === lastContent) return;
+    if (content === lastContent || conflictPending) return;
 
-    const { url } = addTokenToRequest(window.location.pathname);
+    const { url } = addTokenToRequest(addLockTokenToUrl(window.location.pathname));
+    const headers = {'Content-Type': 'text/plain'};
+    if (currentETag) {
+        headers['If-Match'] = currentETag;
+    }
     fetch(url, {
         method: 'POST',
-        headers: {'Content-Type': 'text/plain'},
+        headers: headers,
         body: content
     })
     .then(res => {
         if (res.ok) {
             lastContent = content;
+            currentETag = res.headers.get('ETag') || currentETag;
             showStatus('已保存', false);
             // Update file size and modification time
             updateFileInfo();
+        } else if (res.status === 409) {
+            conflictPending = true;
+            const etag = res.headers.get('ETag');
+            return res.text().then(serverContent => handleConflict(serverContent, etag));
+        } else if (res.status === 401) {
+            showStatus('笔记已锁定，保存失败', true);
         } else {
             showStatus('保存失败', true);
         }
@@ -544,6 +605,23 @@ function saveNote() {
     });
 }
 
+// 处理保存冲突：笔记在其他地方被修改过
+function handleConflict(serverContent, etag) {
+    const overwrite = confirm('笔记已在其他地方被修改。\n\n确定：用当前编辑器中的内容覆盖（对方的内容会保存为修订版本）\n取消：加载最新内容（当前内容会暂存到本地浏览器）');
+    currentETag = etag;
+    conflictPending = false;
+    if (overwrite) {
+        saveNote();
+        return;
+    }
+    // 暂存本地内容，避免丢失
+    localStorage.setItem('jot_conflict_' + window.location.pathname.substring(1), editor.value);
+    editor.value = serverContent;
+    lastContent = serverContent;
+    updatePreview();
+    showStatus('已加载最新内容，本地修改已暂存', true);
+}
+
 function updateFileInfo() {
     const content = editor.value;
     const size = new Blob([content]).size;
@@ -577,6 +655,386 @@ function showStatus(message, isError) {
     }, 2000);
 }
 
+// 协同编辑（OT）
+// 操作格式与服务器一致：正数表示保留，负数表示删除，字符串表示插入，位置以 UTF-16 码元计
+function TextOp() {
+    this.ops = [];
+    this.baseLength = 0;
+    this.targetLength = 0;
+}
+
+TextOp.prototype.retain = function(n) {
+    if (n <= 0) return this;
+    this.baseLength += n;
+    this.targetLength += n;
+    const last = this.ops.length - 1;
+    if (last >= 0 && typeof this.ops[last] === 'number' && this.ops[last] > 0) {
+        this.ops[last] += n;
+    } else {
+        this.ops.push(n);
+    }
+    return this;
+};
+
+TextOp.prototype.insert = function(str) {
+    if (!str) return this;
+    this.targetLength += str.length;
+    const ops = this.ops;
+    const last = ops.length - 1;
+    if (last >= 0 && typeof ops[last] === 'string') {
+        ops[last] += str;
+    } else if (last >= 0 && ops[last] < 0) {
+        // 插入总是放在删除之前
+        if (last > 0 && typeof ops[last - 1] === 'string') {
+            ops[last - 1] += str;
+        } else {
+            ops.push(ops[last]);
+            ops[last] = str;
+        }
+    } else {
+        ops.push(str);
+    }
+    return this;
+};
+
+TextOp.prototype.remove = function(n) {
+    if (n <= 0) return this;
+    this.baseLength += n;
+    const last = this.ops.length - 1;
+    if (last >= 0 && typeof this.ops[last] === 'number' && this.ops[last] < 0) {
+        this.ops[last] -= n;
+    } else {
+        this.ops.push(-n);
+    }
+    return this;
+};
+
+TextOp.prototype.isNoop = function() {
+    return this.ops.every(o => typeof o === 'number' && o > 0);
+};
+
+TextOp.prototype.apply = function(str) {
+    if (str.length !== this.baseLength) {
+        throw new Error('operation length mismatch');
+    }
+    const parts = [];
+    let pos = 0;
+    for (const o of this.ops) {
+        if (typeof o === 'string') {
+            parts.push(o);
+        } else if (o > 0) {
+            parts.push(str.slice(pos, pos + o));
+            pos += o;
+        } else {
+            pos -= o;
+        }
+    }
+    return parts.join('');
+};
+
+// 将位置按操作进行变换（用于光标）
+TextOp.prototype.transformIndex = function(index) {
+    let newIndex = index;
+    let pos = 0;
+    for (const o of this.ops) {
+        if (pos > index) break;
+        if (typeof o === 'string') {
+            newIndex += o.length;
+        } else if (o > 0) {
+            pos += o;
+        } else {
+            newIndex -= Math.min(index - pos, -o);
+            pos -= o;
+        }
+    }
+    return newIndex;
+};
+
+// 合并两个连续的操作：先应用 this，再应用 other
+TextOp.prototype.compose = function(other) {
+    if (this.targetLength !== other.baseLength) {
+        throw new Error('operation length mismatch');
+    }
+    const result = new TextOp();
+    const ops1 = this.ops, ops2 = other.ops;
+    let i1 = 0, i2 = 0;
+    let a = ops1[i1++], b = ops2[i2++];
+    while (a !== undefined || b !== undefined) {
+        if (typeof a === 'number' && a < 0) {
+            result.remove(-a);
+            a = ops1[i1++];
+            continue;
+        }
+        if (typeof b === 'string') {
+            result.insert(b);
+            b = ops2[i2++];
+            continue;
+        }
+        if (a === undefined || b === undefined) {
+            throw new Error('operation length mismatch');
+        }
+        if (typeof a === 'string') {
+            const n = Math.min(a.length, Math.abs(b));
+            if (b > 0) result.insert(a.slice(0, n));
+            a = a.length > n ? a.slice(n) : ops1[i1++];
+            b = b > 0 ? b - n : b + n;
+        } else {
+            const n = Math.min(a, Math.abs(b));
+            if (b > 0) result.retain(n); else result.remove(n);
+            a = a > n ? a - n : ops1[i1++];
+            b = b > 0 ? b - n : b + n;
+        }
+        if (b === 0) b = ops2[i2++];
+    }
+    return result;
+};
+
+// 变换两个并发操作，返回 [a', b']，同一位置插入时 a 在前
+TextOp.transform = function(a, b) {
+    if (a.baseLength !== b.baseLength) {
+        throw new Error('operation length mismatch');
+    }
+    const aPrime = new TextOp(), bPrime = new TextOp();
+    const ops1 = a.ops, ops2 = b.ops;
+    let i1 = 0, i2 = 0;
+    let o1 = ops1[i1++], o2 = ops2[i2++];
+    while (o1 !== undefined || o2 !== undefined) {
+        if (typeof o1 === 'string') {
+            aPrime.insert(o1);
+            bPrime.retain(o1.length);
+            o1 = ops1[i1++];
+            continue;
+        }
+        if (typeof o2 === 'string') {
+            aPrime.retain(o2.length);
+            bPrime.insert(o2);
+            o2 = ops2[i2++];
+            continue;
+        }
+        if (o1 === undefined || o2 === undefined) {
+            throw new Error('operation length mismatch');
+        }
+        const n = Math.min(Math.abs(o1), Math.abs(o2));
+        if (o1 > 0 && o2 > 0) {
+            aPrime.retain(n);
+            bPrime.retain(n);
+        } else if (o1 < 0 && o2 > 0) {
+            aPrime.remove(n);
+        } else if (o1 > 0 && o2 < 0) {
+            bPrime.remove(n);
+        }
+        o1 = o1 > 0 ? o1 - n : o1 + n;
+        o2 = o2 > 0 ? o2 - n : o2 + n;
+        if (o1 === 0) o1 = ops1[i1++];
+        if (o2 === 0) o2 = ops2[i2++];
+    }
+    return [aPrime, bPrime];
+};
+
+TextOp.fromJSON = function(ops) {
+    const op = new TextOp();
+    for (const o of ops) {
+        if (typeof o === 'string') op.insert(o);
+        else if (o > 0) op.retain(o);
+        else op.remove(-o);
+    }
+    return op;
+};
+
+// 根据公共前缀和后缀生成从 oldStr 到 newStr 的操作
+TextOp.fromDiff = function(oldStr, newStr) {
+    let prefix = 0;
+    while (prefix < oldStr.length && prefix < newStr.length && oldStr[prefix] === newStr[prefix]) {
+        prefix++;
+    }
+    let suffix = 0;
+    while (suffix < oldStr.length - prefix && suffix < newStr.length - prefix &&
+        oldStr[oldStr.length - 1 - suffix] === newStr[newStr.length - 1 - suffix]) {
+        suffix++;
+    }
+    return new TextOp()
+        .retain(prefix)
+        .remove(oldStr.length - prefix - suffix)
+        .insert(newStr.slice(prefix, newStr.length - suffix))
+        .retain(suffix);
+};
+
+let collabReady = false;   // 已收到服务器的 init 消息
+let collabRev = 0;         // 已知的服务器版本
+let collabValue = editor.value; // 已转换为操作的编辑器内容
+let outstanding = null;    // 已发送、等待确认的操作
+let buffer = null;         // 等待确认期间产生的本地操作
+let resyncing = false;     // 服务器拒绝操作后等待重新同步
+let previewTimeout = null;
+const remoteCursors = {};  // client_id -> {color, anchor, head}
+const remoteLayer = document.getElementById('remote-cursors');
+
+function sendOperation(op) {
+    ws.send(JSON.stringify({ type: 'op', rev: collabRev, op: op.ops }));
+}
+
+// 将编辑器中尚未转换的修改作为本地操作发送
+function syncLocalChanges() {
+    if (!collabReady || editor.value === collabValue) return;
+    const op = TextOp.fromDiff(collabValue, editor.value);
+    collabValue = editor.value;
+    transformRemoteCursors(op);
+    if (outstanding === null) {
+        outstanding = op;
+        sendOperation(op);
+    } else if (buffer === null) {
+        buffer = op;
+    } else {
+        buffer = buffer.compose(op);
+    }
+    sendCursor();
+}
+
+function handleAck(data) {
+    collabRev = data.rev;
+    currentETag = data.etag;
+    if (buffer !== null) {
+        outstanding = buffer;
+        buffer = null;
+        sendOperation(outstanding);
+    } else {
+        outstanding = null;
+        lastContent = collabValue;
+        updateFileInfo();
+    }
+}
+
+function handleRemoteOperation(data) {
+    let op = TextOp.fromJSON(data.op);
+    if (outstanding !== null) {
+        [outstanding, op] = TextOp.transform(outstanding, op);
+        if (buffer !== null) {
+            [buffer, op] = TextOp.transform(buffer, op);
+        }
+    }
+    collabRev = data.rev;
+    currentETag = data.etag;
+
+    // 应用远程操作，同时保持本地光标和滚动位置
+    const start = op.transformIndex(editor.selectionStart);
+    const end = op.transformIndex(editor.selectionEnd);
+    const scrollTop = editor.scrollTop;
+    editor.value = op.apply(editor.value);
+    collabValue = editor.value;
+    editor.setSelectionRange(start, end, editor.selectionDirection);
+    editor.scrollTop = scrollTop;
+    if (outstanding === null) {
+        lastContent = collabValue;
+    }
+    transformRemoteCursors(op);
+    schedulePreview();
+}
+
+function handleInit(data) {
+    collabRev = data.rev;
+    currentETag = data.etag;
+    outstanding = null;
+    buffer = null;
+    for (const id in remoteCursors) {
+        delete remoteCursors[id];
+    }
+    data.cursors.forEach(c => {
+        remoteCursors[c.client_id] = c;
+    });
+
+    // 本地有尚未同步到服务器的修改（例如断线期间的编辑）
+    const local = editor.value;
+    const unsynced = local !== data.content && local !== lastContent;
+    collabValue = data.content;
+    lastContent = data.content;
+    collabReady = true;
+    if (unsynced && !resyncing && confirm('断线期间的修改与服务器上的最新内容不同。\n\n确定：用当前编辑器中的内容覆盖\n取消：加载最新内容（当前内容会暂存到本地浏览器）')) {
+        syncLocalChanges();
+    } else if (local !== data.content) {
+        if (unsynced) {
+            localStorage.setItem('jot_conflict_' + window.location.pathname.substring(1), local);
+            showStatus('已加载最新内容，本地修改已暂存', true);
+        }
+        editor.value = data.content;
+        updatePreview();
+    }
+    resyncing = false;
+    renderRemoteCursors();
+    sendCursor();
+}
+
+function transformRemoteCursors(op) {
+    for (const id in remoteCursors) {
+        remoteCursors[id].anchor = op.transformIndex(remoteCursors[id].anchor);
+        remoteCursors[id].head = op.transformIndex(remoteCursors[id].head);
+    }
+    renderRemoteCursors();
+}
+
+let lastSentCursor = '';
+function sendCursor() {
+    if (!collabReady || !ws || ws.readyState !== WebSocket.OPEN) return;
+    const backward = editor.selectionDirection === 'backward';
+    const anchor = backward ? editor.selectionEnd : editor.selectionStart;
+    const head = backward ? editor.selectionStart : editor.selectionEnd;
+    const key = anchor + ':' + head;
+    if (key === lastSentCursor) return;
+    lastSentCursor = key;
+    ws.send(JSON.stringify({ type: 'cursor', anchor: anchor, head: head }));
+}
+
+function escapeHtml(text) {
+    return text.replace(/&/g, '&amp;').replace(/</g, '&lt;').replace(/>/g, '&gt;');
+}
+
+// 在编辑器上方的叠加层中绘制远程光标和选区
+function renderRemoteCursors() {
+    const cursors = Object.keys(remoteCursors).map(id => Object.assign({ id: id }, remoteCursors[id]));
+    if (cursors.length === 0) {
+        remoteLayer.innerHTML = '';
+        return;
+    }
+    const text = editor.value;
+    const clamp = n => Math.max(0, Math.min(n, text.length));
+    const points = new Set([0, text.length]);
+    cursors.forEach(c => {
+        points.add(clamp(c.anchor));
+        points.add(clamp(c.head));
+    });
+    const sorted = Array.from(points).sort((a, b) => a - b);
+
+    let html = '';
+    for (let i = 0; i < sorted.length; i++) {
+        const pos = sorted[i];
+        cursors.forEach(c => {
+            if (clamp(c.head) === pos) {
+                html += '<span class="remote-caret" style="border-color:' + c.color + '"><span class="remote-caret-label" style="background:' + c.color + '">' + escapeHtml(c.id) + '</span></span>';
+            }
+        });
+        if (i + 1 < sorted.length) {
+            const next = sorted[i + 1];
+            const segment = escapeHtml(text.slice(pos, next));
+            const sel = cursors.find(c => c.anchor !== c.head && Math.min(c.anchor, c.head) <= pos && Math.max(c.anchor, c.head) >= next);
+            html += sel ? '<span style="background:' + sel.color + '40">' + segment + '</span>' : segment;
+        }
+    }
+    // 末尾换行在 textarea 中会显示为新的一行
+    remoteLayer.innerHTML = html + '\n';
+
+    const style = getComputedStyle(editor);
+    remoteLayer.style.width = editor.clientWidth + 'px';
+    remoteLayer.style.height = editor.clientHeight + 'px';
+    remoteLayer.style.padding = style.padding;
+    remoteLayer.style.font = style.font;
+    remoteLayer.style.lineHeight = style.lineHeight;
+    remoteLayer.scrollTop = editor.scrollTop;
+}
+
+function schedulePreview() {
+    clearTimeout(previewTimeout);
+    previewTimeout = setTimeout(updatePreview, 300);
+}
+
 function connectWebSocket() {
     const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
     let wsUrl = protocol + '//' + window.location.host + '/ws' + window.location.pathname;
@@ -595,10 +1053,22 @@ function connectWebSocket() {
     
     ws.onmessage = (event) => {
         const data = JSON.parse(event.data);
-        if (data.type === 'update' && data.content !== editor.value) {
-            editor.value = data.content;
-            lastContent = data.content;
-            updatePreview();
+        if (data.type === 'init') {
+            handleInit(data);
+        } else if (data.type === 'ack') {
+            handleAck(data);
+        } else if (data.type === 'op') {
+            handleRemoteOperation(data);
+        } else if (data.type === 'cursor') {
+            remoteCursors[data.client_id] = data;
+            renderRemoteCursors();
+        } else if (data.type === 'leave') {
+            delete remoteCursors[data.client_id];
+            renderRemoteCursors();
+        } else if (data.type === 'error') {
+            // resync 表示服务器拒绝了操作，随后会发送 init 重新同步
+            resyncing = !!data.resync;
+            showStatus('保存失败: ' + data.message, true);
         }
     };
     
@@ -608,18 +1078,45 @@ function connectWebSocket() {
     };
     
     ws.onclose = () => {
+        // 断线后回退到 HTTP 保存，未确认的操作由 If-Match 检查冲突
+        collabReady = false;
+        outstanding = null;
+        buffer = null;
+        lastSentCursor = '';
+        for (const id in remoteCursors) {
+            delete remoteCursors[id];
+        }
+        renderRemoteCursors();
         connectionStatus.textContent = '已断开';
         connectionStatus.className = 'disconnected';
         setTimeout(connectWebSocket, 3000);
     };
 }
 
+// 重新连接 WebSocket（例如锁变化后需要用新的锁密码订阅）
+function reconnectWebSocket() {
+    if (ws) {
+        ws.close();
+    }
+}
+
 editor.addEventListener('input', () => {
+    syncLocalChanges();
     updatePreview();
     clearTimeout(saveTimeout);
     saveTimeout = setTimeout(saveNote, 500);
 });
 
+['select', 'keyup', 'mouseup', 'focus'].forEach(eventName => {
+    editor.addEventListener(eventName, sendCursor);
+});
+
+editor.addEventListener('scroll', () => {
+    remoteLayer.scrollTop = editor.scrollTop;
+});
+
+window.addEventListener('resize', renderRemoteCursors);
+
 editor.addEventListener('paste', () => {
     setTimeout(() => {
         updatePreview();
@@ -757,7 +1254,7 @@ function handleFiles(files) {
                 const textAfter = editor.value.substring(cursorPos);
                 editor.value = textBefore + data.markdown + '\n' + textAfter;
                 editor.selectionStart = editor.selectionEnd = cursorPos + data.markdown.length + 1;
-                lastContent = editor.value;
+                syncLocalChanges();
                 updatePreview();
                 saveNote();
                 showStatus('上传成功', false);
@@ -862,33 +1359,41 @@ connectWebSocket();
 setInterval(saveNote, 2000);
 
 // Note lock management
-let noteLockToken = '';
-let isLocked = false;
-
-// Check if note is locked on page load
-const rawContent = editor.value;
-if (rawContent.startsWith('<!-- LOCK:')) {
-    const endIdx = rawContent.indexOf(' -->\n');
-    if (endIdx !== -1) {
-        noteLockToken = rawContent.substring('<!-- LOCK:'.length, endIdx);
-        isLocked = true;
-        document.getElementById('lockBtn').textContent = '🔒 解锁';
-        document.getElementById('lockBtn').style.background = '#e74c3c';
-    }
+// 服务器端渲染时已去除锁标记，锁 token 从 cookie 中读取（由锁页面设置）
+let isLocked = {{.IsLocked}};
+let noteLockToken = isLocked ? getNoteLockCookie() : '';
+if (isLocked) {
+    document.getElementById('lockBtn').textContent = '🔒 解锁';
+    document.getElementById('lockBtn').style.background = '#e74c3c';
 }
 
-// Get current lock token from editor content
-function getCurrentLockToken() {
-    const currentContent = editor.value;
-    if (currentContent.startsWith('<!-- LOCK:')) {
-        const endIdx = currentContent.indexOf(' -->\n');
-        if (endIdx !== -1) {
-            return currentContent.substring('<!-- LOCK:'.length, endIdx);
+// Get note lock token from cookie
+function getNoteLockCookie() {
+    const cookieName = 'note_lock_' + window.location.pathname.substring(1);
+    const cookies = document.cookie.split(';');
+    for (let cookie of cookies) {
+        const [name, value] = cookie.trim().split('=');
+        if (decodeURIComponent(name) === cookieName && value) {
+            return decodeURIComponent(value);
         }
     }
     return '';
 }
 
+// Add lock token to URL if note is locked
+function addLockTokenToUrl(url) {
+    if (noteLockToken) {
+        const separator = url.includes('?') ? '&' : '?';
+        url = url + separator + 'lock_token=' + encodeURIComponent(noteLockToken);
+    }
+    return url;
+}
+
+// Get current lock token
+function getCurrentLockToken() {
+    return noteLockToken;
+}
+
 // Share note function - copy URL only
 function shareNote() {
     const noteName = window.location.pathname.substring(1);
@@ -953,23 +1458,143 @@ function fallbackCopyToClipboard(text) {
     document.body.removeChild(textArea);
 }
 
+// 退出用户登录
+function logoutUser() {
+    fetch('/logout', { method: 'POST', credentials: 'include' })
+        .then(() => { window.location.href = '/login?next=' + encodeURIComponent(window.location.pathname); });
+}
+
+// Note sharing dialog: 所有者可以公开笔记（/read 只读）或共享给指定用户
+const sharingApiUrl = '/api/notes/' + encodeURIComponent(decodeURIComponent(window.location.pathname.substring(1))) + '/sharing';
+
+function openSharingDialog() {
+    fetch(sharingApiUrl, { credentials: 'include' })
+        .then(res => res.ok ? res.json() : res.text().then(text => { throw new Error(text); }))
+        .then(showSharingDialog)
+        .catch(err => showStatus('读取共享设置失败: ' + err.message, true));
+}
+
+function addShareRow(list, username, permission) {
+    const row = document.createElement('div');
+    row.className = 'share-row';
+    row.style.cssText = 'display: flex; gap: 6px; margin-bottom: 6px;';
+    const input = document.createElement('input');
+    input.type = 'text';
+    input.placeholder = '用户名';
+    input.value = username || '';
+    input.style.cssText = 'flex: 1; padding: 6px; border: 1px solid #ddd; border-radius: 4px; font-size: 13px;';
+    const select = document.createElement('select');
+    select.innerHTML = '<option value="read">只读</option><option value="write">可编辑</option>';
+    select.value = permission || 'read';
+    select.style.cssText = 'padding: 6px; border: 1px solid #ddd; border-radius: 4px; font-size: 13px;';
+    const remove = document.createElement('button');
+    remove.textContent = '✕';
+    remove.style.cssText = 'padding: 6px 10px; background: #f5f5f5; border: none; border-radius: 4px; cursor: pointer;';
+    remove.onclick = () => row.remove();
+    row.appendChild(input);
+    row.appendChild(select);
+    row.appendChild(remove);
+    list.appendChild(row);
+}
+
+function showSharingDialog(data) {
+    const dialog = document.createElement('div');
+    dialog.style.cssText = 'position: fixed; top: 50%; left: 50%; transform: translate(-50%, -50%); background: white; color: #333; border-radius: 8px; box-shadow: 0 4px 20px rgba(0,0,0,0.3); z-index: 1000; padding: 24px; min-width: 360px; max-width: 90vw;';
+    dialog.innerHTML = '<h3 style="margin: 0 0 6px 0; font-size: 18px;">共享设置</h3>' +
+        '<p id="sharing-owner" style="margin: 0 0 14px 0; font-size: 13px; color: #666;"></p>' +
+        '<label style="display: block; font-size: 14px; margin-bottom: 14px;"><input type="checkbox" id="sharing-public"> 公开（所有人可以通过 /read 只读访问）</label>' +
+        '<div style="font-size: 14px; margin-bottom: 8px;">共享给用户：</div>' +
+        '<div id="sharing-list"></div>' +
+        '<button id="sharing-add" style="padding: 6px 12px; background: #f5f5f5; border: none; border-radius: 4px; cursor: pointer; font-size: 13px; margin-bottom: 16px;">+ 添加用户</button>' +
+        '<div style="display: flex; gap: 8px; justify-content: flex-end;">' +
+        '<button id="sharing-cancel" style="padding: 8px 16px; background: #f5f5f5; color: #666; border: none; border-radius: 4px; cursor: pointer;">取消</button>' +
+        '<button id="sharing-save" style="padding: 8px 16px; background: #0066cc; color: white; border: none; border-radius: 4px; cursor: pointer;">保存</button></div>';
+    document.body.appendChild(dialog);
+
+    document.getElementById('sharing-owner').textContent = data.owner
+        ? '所有者：' + data.owner
+        : '这个笔记还没有所有者，保存后你将成为所有者，其他用户只能通过共享访问';
+    document.getElementById('sharing-public').checked = data.public;
+    const list = document.getElementById('sharing-list');
+    Object.keys(data.shares).sort().forEach(username => addShareRow(list, username, data.shares[username]));
+    document.getElementById('sharing-add').onclick = () => addShareRow(list, '', 'read');
+    document.getElementById('sharing-cancel').onclick = () => dialog.remove();
+    document.getElementById('sharing-save').onclick = () => {
+        const shares = {};
+        list.querySelectorAll('.share-row').forEach(row => {
+            const username = row.querySelector('input').value.trim();
+            if (username) {
+                shares[username] = row.querySelector('select').value;
+            }
+        });
+        fetch(sharingApiUrl, {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            credentials: 'include',
+            body: JSON.stringify({ public: document.getElementById('sharing-public').checked, shares: shares })
+        })
+        .then(res => res.ok ? res.json() : res.text().then(text => { throw new Error(text); }))
+        .then(() => {
+            dialog.remove();
+            showStatus('共享设置已保存', false);
+            if (!data.owner) {
+                window.location.reload();
+            }
+        })
+        .catch(err => alert('保存失败: ' + err.message));
+    };
+}
+
+// Send lock change to server (lock state is kept by the server, not in editor content)
+function postLockChange(url, body, onSuccess) {
+    fetch(addTokenToRequest(url).url, {
+        method: 'POST',
+        headers: {'Content-Type': 'text/plain'},
+        body: body
+    })
+    .then(res => {
+        if (res.ok) {
+            lastContent = editor.value;
+            currentETag = res.headers.get('ETag') || currentETag;
+            onSuccess();
+            reconnectWebSocket();
+        } else if (res.status === 401) {
+            showStatus('锁令牌无效', true);
+        } else {
+            showStatus('保存失败', true);
+        }
+    })
+    .catch(err => {
+        console.error('Lock error:', err);
+        showStatus('保存失败', true);
+    });
+}
+
+function setNoteLockCookie(token) {
+    const cookieName = 'note_lock_' + window.location.pathname.substring(1);
+    if (token) {
+        document.cookie = cookieName + '=' + encodeURIComponent(token) + '; path=/; max-age=86400; samesite=strict' + (location.protocol === 'https:' ? '; secure' : ''); // 24 hours
+    } else {
+        document.cookie = cookieName + '=; path=/; max-age=0';
+    }
+}
+
 function toggleLock() {
     const lockBtn = document.getElementById('lockBtn');
     if (isLocked) {
         // Remove lock
         if (confirm('确定要移除笔记锁吗？')) {
-            const currentContent = editor.value;
-            const endIdx = currentContent.indexOf(' -->\n');
-            if (endIdx !== -1) {
-                editor.value = currentContent.substring(endIdx + 6); // Remove ' -->\n'
+            const url = addLockTokenToUrl(window.location.pathname + '?unlock=1');
+            postLockChange(url, editor.value, () => {
                 isLocked = false;
                 noteLockToken = '';
+                setNoteLockCookie('');
                 if (lockBtn) {
                     lockBtn.textContent = '🔓 加锁';
                     lockBtn.style.background = '#0066cc';
                 }
-                saveNote();
-            }
+                showStatus('已移除笔记锁', false);
+            });
         }
     } else {
         // Set lock
@@ -981,17 +1606,17 @@ function toggleLock() {
             alert('令牌不能为空');
             return;
         }
-        const currentContent = editor.value;
-        if (!currentContent.startsWith('<!-- LOCK:')) {
-            editor.value = '<!-- LOCK:' + token.trim() + ' -->\n' + currentContent;
+        const content = '<!-- LOCK:' + token.trim() + ' -->\n' + editor.value;
+        postLockChange(window.location.pathname, content, () => {
             isLocked = true;
             noteLockToken = token.trim();
+            setNoteLockCookie(noteLockToken);
             if (lockBtn) {
                 lockBtn.textContent = '🔒 解锁';
                 lockBtn.style.background = '#e74c3c';
             }
-            saveNote();
-        }
+            showStatus('已加锁', false);
+        });
     }
 }
 </script>
@@ -583,7 +583,7 @@ function handleLockSubmit(event) {
     }
     
     // Set cookie and redirect
-    document.cookie = 'note_lock_' + noteName + '=' + encodeURIComponent(token) + '; path=/; max-age=86400'; // 24 hours
+    document.cookie = 'note_lock_' + noteName + '=' + encodeURIComponent(token) + '; path=/; max-age=86400; samesite=strict' + (location.protocol === 'https:' ? '; secure' : ''); // 24 hours
     window.location.href = window.location.pathname + '?lock_token=' + encodeURIComponent(token);
 }
 </script>
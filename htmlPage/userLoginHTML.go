@@ -0,0 +1,156 @@
+package htmlPage
+
+// UserLoginHTML 用户账号登录页面（模板参数：Next 登录后跳转的路径，Error 错误信息）
+const UserLoginHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1.0">
+<title>用户登录</title>
+<style>
+* {
+    margin: 0;
+    padding: 0;
+    box-sizing: border-box;
+}
+body {
+    font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, Oxygen, Ubuntu, Cantarell, sans-serif;
+    background: #ebeef1;
+    display: flex;
+    justify-content: center;
+    align-items: center;
+    min-height: 100vh;
+    padding: 20px;
+}
+.login-container {
+    background: #fff;
+    border-radius: 8px;
+    box-shadow: 0 2px 8px rgba(0,0,0,0.1);
+    padding: 40px;
+    width: 100%;
+    max-width: 400px;
+}
+.login-header {
+    text-align: center;
+    margin-bottom: 30px;
+}
+.login-header h1 {
+    font-size: 24px;
+    color: #333;
+    margin-bottom: 8px;
+}
+.login-header p {
+    color: #666;
+    font-size: 14px;
+}
+.login-form {
+    display: flex;
+    flex-direction: column;
+    gap: 20px;
+}
+.form-group {
+    display: flex;
+    flex-direction: column;
+    gap: 8px;
+}
+.form-group label {
+    font-size: 14px;
+    color: #333;
+    font-weight: 500;
+}
+.form-group input {
+    padding: 12px;
+    border: 1px solid #ddd;
+    border-radius: 4px;
+    font-size: 14px;
+    font-family: 'Monaco', 'Menlo', 'Ubuntu Mono', monospace;
+    transition: border-color 0.2s;
+}
+.form-group input:focus {
+    outline: none;
+    border-color: #0066cc;
+}
+.error-message {
+    color: #f44336;
+    font-size: 14px;
+    margin-top: -10px;
+}
+.login-footer {
+    margin-top: 20px;
+    text-align: center;
+    font-size: 13px;
+}
+.login-footer a {
+    color: #0066cc;
+    text-decoration: none;
+}
+.login-button {
+    padding: 12px;
+    background: #0066cc;
+    color: white;
+    border: none;
+    border-radius: 4px;
+    font-size: 16px;
+    font-weight: 500;
+    cursor: pointer;
+    transition: background 0.2s;
+}
+.login-button:hover {
+    background: #0052a3;
+}
+.login-button:active {
+    background: #003d7a;
+}
+@media (prefers-color-scheme: dark) {
+    body {
+        background: #333b4d;
+    }
+    .login-container {
+        background: #24262b;
+    }
+    .login-header h1 {
+        color: #fff;
+    }
+    .login-header p {
+        color: #aaa;
+    }
+    .form-group label {
+        color: #fff;
+    }
+    .form-group input {
+        background: #1a1a1a;
+        border-color: #495265;
+        color: #fff;
+    }
+    .form-group input:focus {
+        border-color: #0066cc;
+    }
+}
+</style>
+</head>
+<body>
+<div class="login-container">
+    <div class="login-header">
+        <h1>👤 用户登录</h1>
+        <p>登录后可以访问自己的笔记和共享给你的笔记</p>
+    </div>
+    <form class="login-form" method="POST" action="/login">
+        <input type="hidden" name="next" value="{{.Next}}">
+        <input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+        <div class="form-group">
+            <label for="username">用户名</label>
+            <input type="text" id="username" name="username" placeholder="输入用户名" autocomplete="username" required autofocus>
+        </div>
+        <div class="form-group">
+            <label for="password">密码</label>
+            <input type="password" id="password" name="password" placeholder="输入密码" autocomplete="current-password" required>
+            {{if .Error}}<div class="error-message">{{.Error}}</div>{{end}}
+        </div>
+        <button type="submit" class="login-button">登录</button>
+    </form>
+    <div class="login-footer">
+        <a href="/">使用访问令牌</a>
+    </div>
+</div>
+</body>
+</html>`
@@ -1,35 +1,43 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
+	"github.com/hello--world/jot/apikey"
 	"github.com/hello--world/jot/backup"
 	"github.com/hello--world/jot/config"
 	"github.com/hello--world/jot/handlers"
 	"github.com/hello--world/jot/note"
 	"github.com/hello--world/jot/router"
+	"github.com/hello--world/jot/session"
 	"github.com/hello--world/jot/setup"
+	"github.com/hello--world/jot/tlscert"
 	"github.com/hello--world/jot/utils"
 	"github.com/hello--world/jot/vars"
-	"github.com/hello--world/jot/websocket"
 )
 
+// shutdownTimeout 优雅关闭的最长等待时间（Docker 默认在 SIGTERM 10 秒后强制结束进程）
+const shutdownTimeout = 8 * time.Second
+
 var (
-	// 全局变量管理器
-	v *vars.Vars
-	// 笔记管理器
-	noteManager *note.Manager
-	// 配置管理器
-	configManager *config.Manager
-	// WebSocket 管理器
-	wsManager *websocket.Manager
+	// 默认工作区（由命令行参数、环境变量和数据目录下的 config.json 配置）
+	mainWorkspace *workspace
+	// 默认工作区配置文件中定义的其他工作区
+	workspaces []*workspace
+	// 工作区定义，保存在默认工作区的配置文件中
+	workspaceDefs []config.Workspace
 )
 
 // convertNoteToHandlerNote 将 note.Note 转换为 handlers.Note
-func convertNoteToHandlerNote(n note.Note) handlers.Note {
+func (ws *workspace) convertNoteToHandlerNote(n note.Note) handlers.Note {
 	return handlers.Note{
 		Name:      n.Name,
 		Content:   n.Content,
@@ -37,56 +45,256 @@ func convertNoteToHandlerNote(n note.Note) handlers.Note {
 		Size:      n.Size,
 		DateDir:   n.DateDir,
 		IsBackup:  n.IsBackup,
+		Owner:     ws.noteOwner(n.Name),
+		Pinned:    ws.noteManager.IsNotePinned(n.Name),
+	}
+}
+
+// noteOwner 返回笔记的所有者用户名（没有所有者时为空）
+func (ws *workspace) noteOwner(name string) string {
+	acl, _ := ws.noteManager.ACL.Get(name)
+	return acl.Owner
+}
+
+// convertNoteACL 将 note.NoteACL 转换为 handlers.NoteACL
+func convertNoteACL(acl note.NoteACL) handlers.NoteACL {
+	return handlers.NoteACL{
+		Owner:  acl.Owner,
+		Public: acl.Public,
+		Shares: acl.Shares,
+	}
+}
+
+// convertTrashedNote 将 note.TrashedNote 转换为 handlers.TrashedNote
+func convertTrashedNote(t note.TrashedNote) handlers.TrashedNote {
+	return handlers.TrashedNote{
+		ID:        t.ID,
+		Name:      t.Name,
+		DateDir:   t.DateDir,
+		IsBackup:  t.IsBackup,
+		Size:      t.Size,
+		Locked:    t.Locked,
+		UpdatedAt: t.UpdatedAt,
+		DeletedAt: t.DeletedAt,
+		DeletedBy: t.DeletedBy,
+	}
+}
+
+// convertArchivePlan 将 note.ArchivePlan 转换为 handlers.ArchivePlan
+func convertArchivePlan(plan note.ArchivePlan) handlers.ArchivePlan {
+	convertItems := func(items []note.ArchivePlanItem) []handlers.ArchivePlanItem {
+		result := make([]handlers.ArchivePlanItem, len(items))
+		for i, item := range items {
+			result[i] = handlers.ArchivePlanItem(item)
+		}
+		return result
+	}
+	return handlers.ArchivePlan{
+		ArchiveBy:          plan.ArchiveBy,
+		BackupDays:         plan.BackupDays,
+		DeleteArchivedDays: plan.DeleteArchivedDays,
+		ArchiveKeepLast:    plan.ArchiveKeepLast,
+		ArchiveMaxSize:     plan.ArchiveMaxSize,
+		Archive:            convertItems(plan.Archive),
+		Delete:             convertItems(plan.Delete),
+		Pinned:             convertItems(plan.Pinned),
+	}
+}
+
+// convertAPIKey 将 apikey.Key 转换为 handlers.APIKey（不包含 token 哈希）
+func convertAPIKey(k apikey.Key) handlers.APIKey {
+	return handlers.APIKey{
+		ID:         k.ID,
+		Name:       k.Name,
+		Scopes:     k.Scopes,
+		CreatedAt:  k.CreatedAt,
+		ExpiresAt:  k.ExpiresAt,
+		LastUsedAt: k.LastUsedAt,
+		RevokedAt:  k.RevokedAt,
+		Active:     k.Active(time.Now()),
 	}
 }
 
 // initSetup 初始化 setup 包
-func initSetup() {
+func initSetup(ws *workspace) {
 	loader := &setup.ConfigLoader{
 		LoadEnvFile:       utils.LoadEnvFile,
-		LoadConfig:        func() bool { return configManager.LoadConfig() },
-		SaveConfig:        func() { configManager.SaveConfig() },
+		LoadConfig:        func() bool { return ws.configManager.LoadConfig() },
+		SaveConfig:        func() { ws.configManager.SaveConfig() },
 		ParseFileSize:     utils.ParseFileSize,
-		LoadExistingNotes: func() error { return noteManager.LoadExistingNotes() },
-		GetConfigLoaded:   func() bool { return configManager.IsConfigLoaded() },
+		LoadExistingNotes: ws.initNoteManager,
+		OpenSessionStore:  ws.initSessionStore,
+		GetConfigLoaded:   func() bool { return ws.configManager.IsConfigLoaded() },
 		SetConfigLoaded:   func(v bool) { /* 由 configManager 管理 */ },
 
-		SetAdminPath:     func(val string) { v.AdminPath = val },
-		SetPort:          func(val string) { v.Port = val },
-		SetNoteNameLen:   func(val int) { v.NoteNameLen = val },
-		SetBackupDays:    func(val int) { v.BackupDays = val },
-		SetNoteChars:     func(val string) { v.NoteChars = val },
-		SetMaxFileSize:   func(val int64) { v.MaxFileSize = val },
-		SetMaxPathLength: func(val int) { v.MaxPathLength = val },
-		SetMaxTotalSize:  func(val int64) { v.MaxTotalSizeLock.Lock(); v.MaxTotalSize = val; v.MaxTotalSizeLock.Unlock() },
-		SetMaxNoteCount:  func(val int) { v.MaxNoteCountLock.Lock(); v.MaxNoteCount = val; v.MaxNoteCountLock.Unlock() },
-		SetAdminToken:    func(val string) { v.AdminToken = val },
-		SetAccessToken:   func(val string) { v.AccessToken = val },
-
-		GetAdminPath:     func() string { return v.AdminPath },
-		GetPort:          func() string { return v.Port },
-		GetNoteNameLen:   func() int { return v.NoteNameLen },
-		GetBackupDays:    func() int { return v.BackupDays },
-		GetNoteChars:     func() string { return v.NoteChars },
-		GetMaxFileSize:   func() int64 { return v.MaxFileSize },
-		GetMaxPathLength: func() int { return v.MaxPathLength },
-		GetMaxTotalSize:  func() int64 { v.MaxTotalSizeLock.RLock(); defer v.MaxTotalSizeLock.RUnlock(); return v.MaxTotalSize },
-		GetMaxNoteCount:  func() int { v.MaxNoteCountLock.RLock(); defer v.MaxNoteCountLock.RUnlock(); return v.MaxNoteCount },
-		GetAdminToken:    func() string { return v.AdminToken },
-		GetAccessToken:   func() string { return v.AccessToken },
+		SetAdminPath:        func(val string) { ws.v.AdminPath = val },
+		SetPort:             func(val string) { ws.v.Port = val },
+		SetNoteNameLen:      func(val int) { ws.v.NoteNameLen = val },
+		SetBackupDays:       func(val int) { ws.v.BackupDays = val },
+		SetNoteChars:        func(val string) { ws.v.NoteChars = val },
+		SetMaxFileSize:      func(val int64) { ws.v.MaxFileSize = val },
+		SetMaxPathLength:    func(val int) { ws.v.MaxPathLength = val },
+		SetMaxTotalSize:     func(val int64) { ws.v.MaxTotalSizeLock.Lock(); ws.v.MaxTotalSize = val; ws.v.MaxTotalSizeLock.Unlock() },
+		SetMaxNoteCount:     func(val int) { ws.v.MaxNoteCountLock.Lock(); ws.v.MaxNoteCount = val; ws.v.MaxNoteCountLock.Unlock() },
+		SetAdminToken:       func(val string) { ws.v.AdminToken = val },
+		SetAccessToken:      func(val string) { ws.v.AccessToken = val },
+		SetMaxRevisions:     func(val int) { ws.v.MaxRevisions = val },
+		SetRevisionDays:     func(val int) { ws.v.RevisionDays = val },
+		SetTrashDays:        func(val int) { ws.v.TrashDays = val },
+		SetArchiveBy:        func(val string) { ws.v.ArchiveBy = val },
+		SetDeleteArchived:   func(val int) { ws.v.DeleteArchivedDays = val },
+		SetArchiveMaxSize:   func(val int64) { ws.v.ArchiveMaxSize = val },
+		SetArchiveKeepLast:  func(val int) { ws.v.ArchiveKeepLast = val },
+		SetOrphanUploadDays: func(val int) { ws.v.OrphanUploadDays = val },
+		SetOrphanUploadSize: func(val int64) { ws.v.OrphanUploadMaxSize = val },
+		SetOrphanUploadLast: func(val int) { ws.v.OrphanUploadKeepLast = val },
+		SetSnapshotMaxSize:  func(val int64) { ws.v.SnapshotMaxSize = val },
+		SetStoreType:        func(val string) { ws.v.StoreType = val },
+		SetSessionStoreType: func(val string) { ws.v.SessionStoreType = val },
+		SetAllowedOrigins:   func(val []string) { ws.v.AllowedOrigins = val },
+		SetDataDir:          func(val string) { ws.v.DataDir = val },
+		SetSavePath:         func(val string) { ws.v.SavePath = val },
+		SetBackupPath:       func(val string) { ws.v.BackupPath = val },
+		SetUploadPath:       func(val string) { ws.v.UploadPath = val },
+		SetRevisionPath:     func(val string) { ws.v.RevisionPath = val },
+		InitDataDirs:        ws.v.InitDataDirs,
+		SetTLSCertFile:      func(val string) { ws.v.TLSCertFile = val },
+		SetTLSKeyFile:       func(val string) { ws.v.TLSKeyFile = val },
+		SetHTTPRedirectPort: func(val string) { ws.v.HTTPRedirectPort = val },
+		SetHSTSMaxAge:       func(val int) { ws.v.HSTSMaxAge = val },
+
+		GetAdminPath:     func() string { return ws.v.AdminPath },
+		GetPort:          func() string { return ws.v.Port },
+		GetNoteNameLen:   func() int { return ws.v.NoteNameLen },
+		GetBackupDays:    func() int { return ws.v.BackupDays },
+		GetNoteChars:     func() string { return ws.v.NoteChars },
+		GetMaxFileSize:   func() int64 { return ws.v.MaxFileSize },
+		GetMaxPathLength: func() int { return ws.v.MaxPathLength },
+		GetMaxTotalSize: func() int64 {
+			ws.v.MaxTotalSizeLock.RLock()
+			defer ws.v.MaxTotalSizeLock.RUnlock()
+			return ws.v.MaxTotalSize
+		},
+		GetMaxNoteCount: func() int {
+			ws.v.MaxNoteCountLock.RLock()
+			defer ws.v.MaxNoteCountLock.RUnlock()
+			return ws.v.MaxNoteCount
+		},
+		GetAdminToken:       func() string { return ws.v.AdminToken },
+		GetAccessToken:      func() string { return ws.v.AccessToken },
+		GetMaxRevisions:     func() int { return ws.v.MaxRevisions },
+		GetRevisionDays:     func() int { return ws.v.RevisionDays },
+		GetStoreType:        func() string { return ws.v.StoreType },
+		GetSessionStoreType: func() string { return ws.v.SessionStoreType },
+		GetTLSCertFile:      func() string { return ws.v.TLSCertFile },
+		GetTLSKeyFile:       func() string { return ws.v.TLSKeyFile },
 	}
 	setup.InitConfigLoader(loader)
 }
 
-// initHandlerInitializer 初始化 handler 初始化器
-func initHandlerInitializer() {
+// initNoteManager 打开笔记存储、初始化笔记管理器并加载现有笔记到缓存
+// 在加载配置之后调用，以便使用配置的存储类型和限制
+func (ws *workspace) initNoteManager() error {
+	store, err := note.OpenStore(ws.v.StoreType, ws.v.SavePath, ws.v.BackupPath, ws.v.DataFile(vars.StoreFile))
+	if err != nil {
+		return err
+	}
+	ws.noteManager = note.NewManager(
+		store,
+		ws.v.RevisionPath,
+		ws.v.TrashPath,
+		ws.v.MaxPathLength,
+		ws.v.NoteNameLen,
+		ws.v.BackupDays,
+		ws.v.NoteChars,
+	)
+	ws.noteManager.GetMaxRevisions = func() int { return ws.v.MaxRevisions }
+	ws.noteManager.GetRevisionDays = func() int { return ws.v.RevisionDays }
+	ws.noteManager.GetTrashDays = func() int { return ws.v.TrashDays }
+	ws.noteManager.GetBackupDays = func() int { return ws.v.BackupDays }
+	ws.noteManager.GetArchiveBy = func() string { return ws.v.ArchiveBy }
+	ws.noteManager.GetDeleteArchivedDays = func() int { return ws.v.DeleteArchivedDays }
+	ws.noteManager.GetArchiveKeepLast = func() int { return ws.v.ArchiveKeepLast }
+	ws.noteManager.GetArchiveMaxSize = func() int64 { return ws.v.ArchiveMaxSize }
+	if ws.noteManager.ACL, err = note.OpenACLStore(ws.v.DataFile(vars.ACLFile)); err != nil {
+		return err
+	}
+	if ws.noteManager.Meta, err = note.OpenMetaStore(ws.v.DataFile(vars.MetaFile)); err != nil {
+		return err
+	}
+	if err := ws.webhooks.Open(ws.v.DataFile(vars.WebhookFile)); err != nil {
+		return err
+	}
+	ws.noteManager.OnEvent = ws.noteEvent
+	if err := ws.noteManager.LoadExistingNotes(); err != nil {
+		return err
+	}
+	if err := ws.noteManager.MigrateNoteLocks(); err != nil {
+		return err
+	}
+	return ws.noteManager.BuildSearchIndex()
+}
+
+// initSessionStore 打开登录 session 存储（file 存储保存在配置文件旁边）
+func (ws *workspace) initSessionStore() error {
+	var err error
+	ws.sessionStore, err = session.OpenStore(ws.v.SessionStoreType, ws.v.DataFile(vars.SessionFile))
+	return err
+}
+
+// getTotalFileSize 计算活跃笔记和上传文件的总大小（不包括备份文件夹）
+func (ws *workspace) getTotalFileSize() (int64, error) {
+	notesSize, err := ws.noteManager.TotalSize()
+	if err != nil {
+		return 0, err
+	}
+	uploadsSize, err := utils.GetDirSize(ws.v.UploadPath)
+	if err != nil {
+		return 0, err
+	}
+	return notesSize + uploadsSize, nil
+}
+
+// getDiskUsage 统计笔记、备份文件夹、回收站、修订版本和上传文件的磁盘占用
+func (ws *workspace) getDiskUsage() (handlers.DiskUsage, error) {
+	notes, err := ws.noteManager.DiskUsage()
+	if err != nil {
+		return handlers.DiskUsage{}, err
+	}
+	uploads, err := ws.backupManager.UploadUsage()
+	if err != nil {
+		return handlers.DiskUsage{}, err
+	}
+	return handlers.DiskUsage{
+		ActiveCount:       notes.ActiveCount,
+		ActiveSize:        notes.ActiveSize,
+		ArchivedCount:     notes.ArchivedCount,
+		ArchivedSize:      notes.ArchivedSize,
+		TrashCount:        notes.TrashCount,
+		TrashSize:         notes.TrashSize,
+		RevisionCount:     notes.RevisionCount,
+		RevisionSize:      notes.RevisionSize,
+		UploadCount:       uploads.Count,
+		UploadSize:        uploads.Size,
+		OrphanUploadCount: uploads.OrphanCount,
+		OrphanUploadSize:  uploads.OrphanSize,
+		PurgeUploadCount:  uploads.PurgeCount,
+		PurgeUploadSize:   uploads.PurgeSize,
+	}, nil
+}
+
+// handlerInitializer 创建工作区的 handler 初始化器
+func (ws *workspace) handlerInitializer() *setup.HandlerInitializer {
 	init := &setup.HandlerInitializer{
+		Workspace:      ws.name,
+		WorkspaceHosts: ws.hosts,
+
 		ConvertNoteToHandlerNote: func(n interface{}) handlers.Note {
 			note := n.(note.Note)
-			return convertNoteToHandlerNote(note)
+			return ws.convertNoteToHandlerNote(note)
 		},
 		GetAllNotes: func() ([]interface{}, error) {
-			notes, err := noteManager.GetAllNotes()
+			notes, err := ws.noteManager.GetAllNotes()
 			if err != nil {
 				return nil, err
 			}
@@ -97,7 +305,7 @@ func initHandlerInitializer() {
 			return result, nil
 		},
 		GetAllBackupNotes: func() ([]interface{}, error) {
-			notes, err := noteManager.GetAllBackupNotes()
+			notes, err := ws.noteManager.GetAllBackupNotes()
 			if err != nil {
 				return nil, err
 			}
@@ -107,124 +315,352 @@ func initHandlerInitializer() {
 			}
 			return result, nil
 		},
-		LoadNote:            func(name string) (string, error) { return noteManager.LoadNote(name) },
-		SaveNote:            func(name, content string) error { return noteManager.SaveNote(name, content) },
-		GenerateNoteName:    func() string { return noteManager.GenerateNoteName() },
-		IsSafeNoteName:      func(name string) bool { return noteManager.IsSafeNoteName(name) },
-		GetNotePath:         func(name string) string { return noteManager.GetNotePath(name) },
-		FindNotePath:        func(name string) (string, error) { return noteManager.FindNotePath(name) },
-		IsNoteExists:        func(name string) bool { return noteManager.IsNoteExists(name) },
-		GetFileCreationTime: func(path string) (time.Time, error) { return utils.GetFileCreationTime(path) },
+		LoadNote:         func(name string) (string, error) { return ws.noteManager.LoadNote(name) },
+		SaveNote:         func(name, content, actor string) error { return ws.noteManager.SaveNoteAs(name, content, actor) },
+		GenerateNoteName: func() string { return ws.noteManager.GenerateNoteName() },
+		IsSafeNoteName:   func(name string) bool { return ws.noteManager.IsSafeNoteName(name) },
+		StatNote: func(name string) (handlers.NoteInfo, error) {
+			info, err := ws.noteManager.StatNote(name)
+			if err != nil {
+				return handlers.NoteInfo{}, err
+			}
+			return handlers.NoteInfo{
+				Size:      info.Size,
+				ModTime:   info.ModTime,
+				CreatedAt: info.CreatedAt,
+				DateDir:   info.DateDir,
+				IsBackup:  info.IsBackup,
+			}, nil
+		},
+		IsNoteExists: func(name string) bool { return ws.noteManager.IsNoteExists(name) },
+		SaveNoteIfUnchanged: func(name, expected, content, actor string) error {
+			err := ws.noteManager.SaveNoteIfUnchanged(name, expected, content, actor)
+			if errors.Is(err, note.ErrNoteModified) {
+				return handlers.ErrNoteModified
+			}
+			return err
+		},
+		ListRevisions: func(name string) ([]handlers.Revision, error) {
+			revisions, err := ws.noteManager.ListRevisions(name)
+			if err != nil {
+				return nil, err
+			}
+			result := make([]handlers.Revision, len(revisions))
+			for i, rev := range revisions {
+				result[i] = handlers.Revision{
+					ID:        rev.ID,
+					CreatedAt: rev.CreatedAt,
+					UpdatedAt: rev.UpdatedAt,
+					Size:      rev.Size,
+				}
+			}
+			return result, nil
+		},
+		LoadRevision:  func(name, id string) (string, error) { return ws.noteManager.LoadRevision(name, id) },
+		DiffRevisions: func(name, from, to string) (string, error) { return ws.noteManager.DiffRevisions(name, from, to) },
+		FindArchivedNote: func(name, dateDir string) (handlers.NoteInfo, error) {
+			info, err := ws.noteManager.FindArchivedNote(name, dateDir)
+			if err != nil {
+				return handlers.NoteInfo{}, err
+			}
+			return handlers.NoteInfo{
+				Size:      info.Size,
+				ModTime:   info.ModTime,
+				CreatedAt: info.CreatedAt,
+				DateDir:   info.DateDir,
+				IsBackup:  info.IsBackup,
+			}, nil
+		},
+		UnarchiveNote: func(name, dateDir, conflict, actor string) (handlers.UnarchiveResult, error) {
+			result, err := ws.noteManager.UnarchiveNote(name, dateDir, conflict, actor)
+			return handlers.UnarchiveResult(result), err
+		},
+		ListTrash: func() ([]handlers.TrashedNote, error) {
+			trashed, err := ws.noteManager.ListTrash()
+			if err != nil {
+				return nil, err
+			}
+			result := make([]handlers.TrashedNote, len(trashed))
+			for i, t := range trashed {
+				result[i] = convertTrashedNote(t)
+			}
+			return result, nil
+		},
+		FindTrash: func(name, id string) (handlers.TrashedNote, error) {
+			t, err := ws.noteManager.FindTrash(name, id)
+			return convertTrashedNote(t), err
+		},
+		RestoreFromTrash: func(name, id, actor string) (handlers.TrashedNote, error) {
+			t, err := ws.noteManager.RestoreFromTrash(name, id, actor)
+			return convertTrashedNote(t), err
+		},
+		PurgeTrash: func(name, id string) (int, error) { return ws.noteManager.PurgeTrash(name, id) },
+		PlanArchive: func() (handlers.ArchivePlan, error) {
+			plan, err := ws.noteManager.PlanArchive()
+			return convertArchivePlan(plan), err
+		},
+		ExportSnapshot:        ws.exportSnapshot,
+		ImportSnapshot:        ws.importSnapshot,
+		SetNotePinned:         func(name string, pinned bool) error { return ws.noteManager.SetNotePinned(name, pinned) },
+		TouchNote:             func(name string) { ws.noteManager.TouchNote(name) },
+		GetArchiveBy:          func() string { return ws.v.ArchiveBy },
+		SetArchiveBy:          func(val string) { ws.v.ArchiveBy = val },
+		GetDeleteArchivedDays: func() int { return ws.v.DeleteArchivedDays },
+		SetDeleteArchivedDays: func(val int) { ws.v.DeleteArchivedDays = val },
+
+		GetArchiveMaxSize:       func() int64 { return ws.v.ArchiveMaxSize },
+		SetArchiveMaxSize:       func(val int64) { ws.v.ArchiveMaxSize = val },
+		GetArchiveKeepLast:      func() int { return ws.v.ArchiveKeepLast },
+		SetArchiveKeepLast:      func(val int) { ws.v.ArchiveKeepLast = val },
+		GetOrphanUploadDays:     func() int { return ws.v.OrphanUploadDays },
+		SetOrphanUploadDays:     func(val int) { ws.v.OrphanUploadDays = val },
+		GetOrphanUploadMaxSize:  func() int64 { return ws.v.OrphanUploadMaxSize },
+		SetOrphanUploadMaxSize:  func(val int64) { ws.v.OrphanUploadMaxSize = val },
+		GetOrphanUploadKeepLast: func() int { return ws.v.OrphanUploadKeepLast },
+		SetOrphanUploadKeepLast: func(val int) { ws.v.OrphanUploadKeepLast = val },
+		GetDiskUsage:            ws.getDiskUsage,
+
+		SearchNotes: func(query string, limit int, allow func(string, string) bool) ([]handlers.SearchResult, error) {
+			results, err := ws.noteManager.SearchNotes(query, limit, allow)
+			if err != nil {
+				return nil, err
+			}
+			converted := make([]handlers.SearchResult, len(results))
+			for i, res := range results {
+				converted[i] = handlers.SearchResult{
+					Name:       res.Name,
+					DateDir:    res.DateDir,
+					IsBackup:   res.IsBackup,
+					UpdatedAt:  res.UpdatedAt,
+					Size:       res.Size,
+					Score:      res.Score,
+					Snippet:    res.Snippet,
+					Highlights: res.Highlights,
+				}
+			}
+			return converted, nil
+		},
+		AuthenticateAPIKey: ws.apiKeyManager.Authenticate,
+		HasActiveAPIKeys:   ws.apiKeyManager.HasActiveKeys,
+		ListAPIKeys: func() []handlers.APIKey {
+			keys := ws.apiKeyManager.Keys()
+			result := make([]handlers.APIKey, len(keys))
+			for i, k := range keys {
+				result[i] = convertAPIKey(k)
+			}
+			return result
+		},
+		CreateAPIKey: func(name string, scopes []string, expiresAt *time.Time) (string, handlers.APIKey, error) {
+			token, k, err := ws.apiKeyManager.Create(name, scopes, expiresAt)
+			return token, convertAPIKey(k), err
+		},
+		RevokeAPIKey:     ws.apiKeyManager.Revoke,
+		AuthenticateUser: ws.accountManager.Authenticate,
+		UserExists:       ws.accountManager.Exists,
+		ListUsers: func() []handlers.User {
+			users := ws.accountManager.Users()
+			result := make([]handlers.User, len(users))
+			for i, u := range users {
+				result[i] = handlers.User{Username: u.Username, CreatedAt: u.CreatedAt}
+			}
+			return result
+		},
+		CreateUser:      ws.accountManager.Create,
+		DeleteUser:      ws.accountManager.Delete,
+		SetUserPassword: ws.accountManager.SetPassword,
+		GetNoteACL: func(name string) (handlers.NoteACL, bool) {
+			acl, ok := ws.noteManager.ACL.Get(name)
+			return convertNoteACL(acl), ok
+		},
+		SetNoteACL: func(name string, acl handlers.NoteACL) error {
+			return ws.noteManager.ACL.Set(name, note.NoteACL{Owner: acl.Owner, Public: acl.Public, Shares: acl.Shares})
+		},
+		ClaimNote:           func(name, owner string) (bool, error) { return ws.noteManager.ACL.Claim(name, owner) },
+		GetUserNotes:        func(username string) ([]string, map[string]string) { return ws.noteManager.ACL.UserNotes(username) },
+		RateLimiter:         ws.rateLimiter,
+		GetAllowedOrigins:   func() []string { return ws.v.AllowedOrigins },
+		Sessions:            ws.sessionStore,
+		Webhooks:            ws.webhooks,
+		Events:              ws.events,
+		GetSessionStoreType: func() string { return ws.v.SessionStoreType },
 		HasNoteLock:         func(content string) bool { return note.HasNoteLock(content) },
-		GetNoteLockToken:    func(content string) string { return note.GetNoteLockToken(content) },
+		VerifyNoteLock:      func(content, token string) bool { return note.VerifyNoteLock(content, token) },
 		GetNoteContent:      func(content string) string { return note.GetNoteContent(content) },
-		GetTotalFileSize:    func() (int64, error) { return utils.GetTotalFileSize(vars.SavePath, vars.UploadPath) },
+		KeepNoteLock:        func(locked, content string) string { return note.KeepNoteLock(locked, content) },
+		ContentETag:         func(content string) string { return note.ContentETag(content) },
+		GetTotalFileSize:    ws.getTotalFileSize,
 		ParseFileSize:       utils.ParseFileSize,
-		BroadcastUpdate:     websocket.BroadcastUpdate,
-		SaveConfig:          func() { configManager.SaveConfig() },
-		GetMaxFileSize:      func() int64 { return v.MaxFileSize },
-		SetMaxFileSize:      func(val int64) { v.MaxFileSize = val },
-		GetMaxPathLength:    func() int { return v.MaxPathLength },
-		SetMaxPathLength:    func(val int) { v.MaxPathLength = val },
-		GetMaxTotalSize:     func() int64 { v.MaxTotalSizeLock.RLock(); defer v.MaxTotalSizeLock.RUnlock(); return v.MaxTotalSize },
-		SetMaxTotalSize:     func(val int64) { v.MaxTotalSizeLock.Lock(); v.MaxTotalSize = val; v.MaxTotalSizeLock.Unlock() },
-		GetMaxNoteCount:     func() int { v.MaxNoteCountLock.RLock(); defer v.MaxNoteCountLock.RUnlock(); return v.MaxNoteCount },
-		SetMaxNoteCount:     func(val int) { v.MaxNoteCountLock.Lock(); v.MaxNoteCount = val; v.MaxNoteCountLock.Unlock() },
-		GetNoteNameLen:      func() int { return v.NoteNameLen },
-		SetNoteNameLen:      func(val int) { v.NoteNameLen = val },
-		GetBackupDays:       func() int { return v.BackupDays },
-		SetBackupDays:       func(val int) { v.BackupDays = val },
-		GetNoteChars:        func() string { return v.NoteChars },
-		SetNoteChars:        func(val string) { v.NoteChars = val },
-		GetSavePath:         func() string { return vars.SavePath },
-		GetUploadPath:       func() string { return vars.UploadPath },
-		SetAdminPath:        func(val string) { v.AdminPath = val },
-		SetAccessToken:      func(val string) { v.AccessToken = val },
-		SetAdminToken:       func(val string) { v.AdminToken = val },
-		GetAdminToken:       func() string { return v.AdminToken },
-		GetAccessToken:      func() string { return v.AccessToken },
-		GetAdminPath:        func() string { return v.AdminPath },
-		RLockMaxTotalSize:   func() { v.MaxTotalSizeLock.RLock() },
-		RUnlockMaxTotalSize: func() { v.MaxTotalSizeLock.RUnlock() },
-		LockMaxTotalSize:    func() { v.MaxTotalSizeLock.Lock() },
-		UnlockMaxTotalSize:  func() { v.MaxTotalSizeLock.Unlock() },
-		RLockMaxNoteCount:   func() { v.MaxNoteCountLock.RLock() },
-		RUnlockMaxNoteCount: func() { v.MaxNoteCountLock.RUnlock() },
-		LockMaxNoteCount:    func() { v.MaxNoteCountLock.Lock() },
-		UnlockMaxNoteCount:  func() { v.MaxNoteCountLock.Unlock() },
-	}
-	setup.InitHandlerInitializer(init)
+		BroadcastUpdate:     func(name, content string) { ws.wsManager.BroadcastUpdate(name, content) },
+		HandleWebSocket:     ws.wsManager.HandleWebSocket,
+		SaveConfig:          func() { ws.configManager.SaveConfig() },
+		GetMaxFileSize:      func() int64 { return ws.v.MaxFileSize },
+		SetMaxFileSize:      func(val int64) { ws.v.MaxFileSize = val },
+		GetMaxPathLength:    func() int { return ws.v.MaxPathLength },
+		SetMaxPathLength:    func(val int) { ws.v.MaxPathLength = val },
+		GetMaxTotalSize: func() int64 {
+			ws.v.MaxTotalSizeLock.RLock()
+			defer ws.v.MaxTotalSizeLock.RUnlock()
+			return ws.v.MaxTotalSize
+		},
+		SetMaxTotalSize: func(val int64) { ws.v.MaxTotalSizeLock.Lock(); ws.v.MaxTotalSize = val; ws.v.MaxTotalSizeLock.Unlock() },
+		GetMaxNoteCount: func() int {
+			ws.v.MaxNoteCountLock.RLock()
+			defer ws.v.MaxNoteCountLock.RUnlock()
+			return ws.v.MaxNoteCount
+		},
+		SetMaxNoteCount:     func(val int) { ws.v.MaxNoteCountLock.Lock(); ws.v.MaxNoteCount = val; ws.v.MaxNoteCountLock.Unlock() },
+		GetNoteNameLen:      func() int { return ws.v.NoteNameLen },
+		SetNoteNameLen:      func(val int) { ws.v.NoteNameLen = val },
+		GetBackupDays:       func() int { return ws.v.BackupDays },
+		SetBackupDays:       func(val int) { ws.v.BackupDays = val },
+		GetNoteChars:        func() string { return ws.v.NoteChars },
+		SetNoteChars:        func(val string) { ws.v.NoteChars = val },
+		GetSavePath:         func() string { return ws.v.SavePath },
+		GetUploadPath:       func() string { return ws.v.UploadPath },
+		SetAdminPath:        func(val string) { ws.v.AdminPath = val },
+		SetAccessToken:      func(val string) { ws.v.AccessToken = val },
+		SetAdminToken:       func(val string) { ws.v.AdminToken = val },
+		GetAdminToken:       func() string { return ws.v.AdminToken },
+		GetAccessToken:      func() string { return ws.v.AccessToken },
+		GetAdminPath:        func() string { return ws.v.AdminPath },
+		GetMaxRevisions:     func() int { return ws.v.MaxRevisions },
+		SetMaxRevisions:     func(val int) { ws.v.MaxRevisions = val },
+		GetRevisionDays:     func() int { return ws.v.RevisionDays },
+		SetRevisionDays:     func(val int) { ws.v.RevisionDays = val },
+		GetTrashDays:        func() int { return ws.v.TrashDays },
+		SetTrashDays:        func(val int) { ws.v.TrashDays = val },
+		RLockMaxTotalSize:   func() { ws.v.MaxTotalSizeLock.RLock() },
+		RUnlockMaxTotalSize: func() { ws.v.MaxTotalSizeLock.RUnlock() },
+		LockMaxTotalSize:    func() { ws.v.MaxTotalSizeLock.Lock() },
+		UnlockMaxTotalSize:  func() { ws.v.MaxTotalSizeLock.Unlock() },
+		RLockMaxNoteCount:   func() { ws.v.MaxNoteCountLock.RLock() },
+		RUnlockMaxNoteCount: func() { ws.v.MaxNoteCountLock.RUnlock() },
+		LockMaxNoteCount:    func() { ws.v.MaxNoteCountLock.Lock() },
+		UnlockMaxNoteCount:  func() { ws.v.MaxNoteCountLock.Unlock() },
+	}
+	return init
 }
 
 func main() {
-	// 初始化全局变量
-	v = vars.NewVars()
-
-	// 初始化笔记管理器
-	noteManager = note.NewManager(
-		vars.SavePath,
-		vars.BackupPath,
-		v.MaxPathLength,
-		v.NoteNameLen,
-		v.BackupDays,
-		v.NoteChars,
-	)
-	// 加载现有笔记到缓存
-	noteManager.LoadExistingNotes()
-
-	// 初始化配置管理器
-	configManager = config.NewManager(
-		&v.AdminToken,
-		&v.AccessToken,
-		&v.AdminPath,
-		&v.NoteNameLen,
-		&v.BackupDays,
-		&v.MaxPathLength,
-		&v.MaxNoteCount,
-		&v.NoteChars,
-		&v.MaxFileSize,
-		&v.MaxTotalSize,
-		v.MaxTotalSizeLock,
-		v.MaxNoteCountLock,
-	)
-	// 先尝试从配置文件加载
-	configManager.LoadConfig()
-
-	// 初始化 WebSocket 管理器
-	wsManager = websocket.NewManager(
-		noteManager.IsSafeNoteName,
-		func() string { return v.AccessToken },
-		handlers.GetTokenFromRequest,
-	)
+	// jot snapshot export|import：导出或导入快照后退出，不启动服务器
+	if len(os.Args) > 1 && os.Args[1] == "snapshot" {
+		os.Exit(runSnapshotCommand(os.Args[2:]))
+	}
+
+	// 初始化默认工作区
+	mainWorkspace = newWorkspace("", nil, &workspaceDefs)
 
 	// 初始化 setup 包
-	initSetup()
+	initSetup(mainWorkspace)
 
-	// 加载配置（从命令行、环境变量等）
+	// 确定数据目录，加载配置（配置文件、命令行、环境变量等），并初始化笔记管理器
 	setup.LoadConfiguration()
 
-	// 初始化 handler 初始化器
-	initHandlerInitializer()
-
 	// 初始化 handlers
-	setup.InitHandlers()
+	mainWorkspace.deps = setup.NewDependencies(mainWorkspace.handlerInitializer())
+	handlers.Init(mainWorkspace.deps)
+
+	// 加载配置文件中定义的其他工作区
+	loadWorkspaces()
 
 	// 设置路由
+	v := mainWorkspace.v
 	routerConfig := &router.RouterConfig{
-		AdminPath:        v.AdminPath,
-		UploadPath:       vars.UploadPath,
-		HandleWebSocket:  wsManager.HandleWebSocket,
-		GenerateNoteName: func() string { return noteManager.GenerateNoteName() },
-		GetAccessToken:   func() string { return v.AccessToken },
+		AdminPath:  v.AdminPath,
+		HSTSMaxAge: v.HSTSMaxAge,
 	}
 	router.InitRouter(routerConfig)
 	r := router.SetupRoutes()
 
-	// 初始化备份管理器并启动备份调度器
-	backupManager := backup.NewManager(noteManager)
-	backupManager.StartBackupScheduler()
+	// 收到 SIGINT/SIGTERM 时取消 ctx，停止调度器并开始优雅关闭
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// 启动定期清理过期 session 的 goroutine
+	handlers.StartSessionCleanup(ctx)
 
-	fmt.Printf("Server starting on http://localhost%s\n", v.Port)
-	fmt.Printf("Admin panel: http://localhost%s%s\n", v.Port, v.AdminPath)
-	log.Fatal(http.ListenAndServe(v.Port, r))
+	// 初始化每个工作区的备份管理器并启动备份调度器
+	for _, ws := range allWorkspaces() {
+		ws.backupManager = backup.NewManager(ws.noteManager, ws.v.UploadPath)
+		ws.backupManager.GetOrphanUploadDays = func() int { return ws.v.OrphanUploadDays }
+		ws.backupManager.GetOrphanUploadMaxSize = func() int64 { return ws.v.OrphanUploadMaxSize }
+		ws.backupManager.GetOrphanUploadKeepLast = func() int { return ws.v.OrphanUploadKeepLast }
+		ws.backupManager.StartBackupScheduler(ctx)
+	}
+
+	// 启动每个工作区的 webhook 投递（包括上次关闭时未完成的投递）
+	for _, ws := range allWorkspaces() {
+		ws.webhooks.Start(ctx)
+	}
+
+	servers := []*http.Server{{Addr: v.Port, Handler: r}}
+	serverErr := make(chan error, 2)
+	serve := func(listen func() error) {
+		if err := listen(); err != nil && err != http.ErrServerClosed {
+			serverErr <- err
+		}
+	}
+
+	if v.TLSCertFile == "" {
+		fmt.Printf("Server starting on http://localhost%s\n", v.Port)
+		fmt.Printf("Admin panel: http://localhost%s%s\n", v.Port, v.AdminPath)
+		go serve(servers[0].ListenAndServe)
+	} else {
+		// HTTPS：证书文件修改后自动重新加载
+		certs, err := tlscert.New(v.TLSCertFile, v.TLSKeyFile)
+		if err != nil {
+			log.Fatalf("Error: Failed to load TLS certificate: %v", err)
+		}
+		servers[0].TLSConfig = certs.TLSConfig()
+		if v.HTTPRedirectPort != "" {
+			redirectServer := &http.Server{Addr: v.HTTPRedirectPort, Handler: router.HTTPSRedirectHandler(v.Port)}
+			servers = append(servers, redirectServer)
+			go serve(redirectServer.ListenAndServe)
+			fmt.Printf("Redirecting http://localhost%s to HTTPS\n", v.HTTPRedirectPort)
+		}
+		fmt.Printf("Server starting on https://localhost%s\n", v.Port)
+		fmt.Printf("Admin panel: https://localhost%s%s\n", v.Port, v.AdminPath)
+		go serve(func() error { return servers[0].ListenAndServeTLS("", "") })
+	}
+
+	select {
+	case err := <-serverErr:
+		log.Fatal(err)
+	case <-ctx.Done():
+	}
+	stop()
+	shutdown(servers)
+}
+
+// shutdown 优雅关闭：停止接收新请求并等待正在处理的请求（包括保存）完成，
+// 关闭 WebSocket 连接并保存协同编辑的修改，等待调度器退出后刷新笔记索引
+func shutdown(servers []*http.Server) {
+	log.Printf("Shutting down (waiting up to %s for in-flight requests)...", shutdownTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	// 先结束事件流，否则 Shutdown 会一直等待这些长连接
+	for _, ws := range allWorkspaces() {
+		ws.events.Close()
+	}
+	for _, server := range servers {
+		if err := server.Shutdown(ctx); err != nil {
+			log.Printf("Error shutting down HTTP server %s: %v", server.Addr, err)
+		}
+	}
+	for _, ws := range allWorkspaces() {
+		if err := ws.wsManager.Shutdown(ctx); err != nil {
+			log.Printf("Error closing WebSocket connections: %v", err)
+		}
+		if err := ws.backupManager.Wait(ctx); err != nil {
+			log.Printf("Error waiting for backup scheduler: %v", err)
+		}
+		if err := ws.webhooks.Wait(ctx); err != nil {
+			log.Printf("Error waiting for webhook delivery: %v", err)
+		}
+		if err := ws.noteManager.Close(); err != nil {
+			log.Printf("Error closing note store: %v", err)
+		}
+	}
+	log.Printf("Server stopped")
 }
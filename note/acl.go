@@ -0,0 +1,153 @@
+package note
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"sync"
+)
+
+// 共享权限
+const (
+	PermissionRead  = "read"
+	PermissionWrite = "write"
+)
+
+// NoteACL 笔记的所有者和共享设置
+// 没有记录的笔记不属于任何用户，按站点的访问令牌规则访问
+type NoteACL struct {
+	Owner  string            `json:"owner"`
+	Public bool              `json:"public,omitempty"` // 所有人可以通过 /read/{note} 只读访问
+	Shares map[string]string `json:"shares,omitempty"` // 用户名 -> read 或 write
+}
+
+// ACLStore 保存笔记的所有权记录（按笔记名称，备份笔记沿用同一条记录）
+// 记录保存在单独的 JSON 文件中，与笔记存储后端无关
+type ACLStore struct {
+	mu      sync.RWMutex
+	path    string
+	entries map[string]NoteACL
+}
+
+// OpenACLStore 打开所有权记录文件，文件不存在时创建空记录
+func OpenACLStore(path string) (*ACLStore, error) {
+	s := &ACLStore{path: path, entries: make(map[string]NoteACL)}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.entries); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Get 返回笔记的所有权记录
+func (s *ACLStore) Get(name string) (NoteACL, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	acl, ok := s.entries[name]
+	if !ok {
+		return NoteACL{}, false
+	}
+	return copyACL(acl), true
+}
+
+// Set 设置笔记的所有权记录，Owner 为空时删除记录
+func (s *ACLStore) Set(name string, acl NoteACL) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if acl.Owner == "" {
+		delete(s.entries, name)
+	} else {
+		s.entries[name] = copyACL(acl)
+	}
+	return s.save()
+}
+
+// Claim 在笔记还没有所有者时将 owner 设为所有者，返回是否设置成功
+func (s *ACLStore) Claim(name, owner string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.entries[name]; ok {
+		return false, nil
+	}
+	s.entries[name] = NoteACL{Owner: owner}
+	return true, s.save()
+}
+
+// Delete 删除笔记的所有权记录
+func (s *ACLStore) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.entries[name]; !ok {
+		return nil
+	}
+	delete(s.entries, name)
+	return s.save()
+}
+
+// UserNotes 返回用户拥有的笔记和共享给用户的笔记（笔记名称 -> 权限）
+func (s *ACLStore) UserNotes(username string) (owned []string, shared map[string]string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	owned = make([]string, 0)
+	shared = make(map[string]string)
+	for name, acl := range s.entries {
+		if acl.Owner == username {
+			owned = append(owned, name)
+		} else if perm, ok := acl.Shares[username]; ok {
+			shared[name] = perm
+		}
+	}
+	sort.Strings(owned)
+	return owned, shared
+}
+
+// snapshot 返回所有记录的 JSON（用于导出快照）
+func (s *ACLStore) snapshot() ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return json.MarshalIndent(s.entries, "", "  ")
+}
+
+// merge 导入快照中的记录：replace 为 true 时替换所有记录，否则只为 names 中本地没有记录的笔记添加记录
+func (s *ACLStore) merge(entries map[string]NoteACL, names map[string]bool, replace bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if replace {
+		s.entries = make(map[string]NoteACL, len(entries))
+	}
+	for name, acl := range entries {
+		if acl.Owner == "" {
+			continue
+		}
+		if _, ok := s.entries[name]; replace || (!ok && names[name]) {
+			s.entries[name] = copyACL(acl)
+		}
+	}
+	return s.save()
+}
+
+// save 将记录写入文件，调用者必须持有写锁
+func (s *ACLStore) save() error {
+	data, err := json.MarshalIndent(s.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, data, 0644)
+}
+
+func copyACL(acl NoteACL) NoteACL {
+	if acl.Shares != nil {
+		shares := make(map[string]string, len(acl.Shares))
+		for user, perm := range acl.Shares {
+			shares[user] = perm
+		}
+		acl.Shares = shares
+	}
+	return acl
+}
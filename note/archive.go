@@ -0,0 +1,496 @@
+package note
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// 恢复归档笔记时同名活跃笔记已存在的处理方式
+const (
+	ConflictFail      = "fail"      // 不恢复，返回包装 os.ErrExist 的错误（默认）
+	ConflictOverwrite = "overwrite" // 覆盖活跃笔记，活跃笔记的内容保存为修订版本
+	ConflictRename    = "rename"    // 恢复为新名称：名称-日期目录（已存在时加 -2、-3……）
+)
+
+// UnarchiveResult 恢复归档笔记的结果
+type UnarchiveResult struct {
+	Name        string `json:"name"`        // 恢复后的笔记名称（重命名时与原名称不同）
+	From        string `json:"from"`        // 归档笔记的日期目录
+	DateDir     string `json:"date_dir"`    // 恢复到的日期目录（当天）
+	Renamed     bool   `json:"renamed"`     // 是否因为冲突恢复为新名称
+	Overwritten bool   `json:"overwritten"` // 是否覆盖了同名的活跃笔记
+}
+
+// FindArchivedNote 查找归档笔记，dateDir 为空时返回最新日期目录中的副本，不存在时返回 os.ErrNotExist
+func (m *Manager) FindArchivedNote(name, dateDir string) (NoteInfo, error) {
+	infos, err := m.Store.List(true)
+	if err != nil {
+		return NoteInfo{}, err
+	}
+	var found NoteInfo
+	ok := false
+	for _, info := range infos {
+		if info.Name != name || (dateDir != "" && info.DateDir != dateDir) {
+			continue
+		}
+		if !ok || info.DateDir > found.DateDir {
+			found, ok = info, true
+		}
+	}
+	if !ok {
+		return NoteInfo{}, os.ErrNotExist
+	}
+	return found, nil
+}
+
+// UnarchiveNote 将归档笔记移回活跃笔记（保存到当天的日期目录），并删除备份文件夹中的副本
+// dateDir 为空时恢复最新的归档副本；同名活跃笔记已存在时按 conflict（ConflictFail、ConflictOverwrite 或 ConflictRename）处理
+func (m *Manager) UnarchiveNote(name, dateDir, conflict, actor string) (UnarchiveResult, error) {
+	m.snapshotLock.RLock()
+	defer m.snapshotLock.RUnlock()
+	defer m.lockNote(name)()
+	switch conflict {
+	case "":
+		conflict = ConflictFail
+	case ConflictFail, ConflictOverwrite, ConflictRename:
+	default:
+		return UnarchiveResult{}, fmt.Errorf("unknown conflict mode %q (use %s, %s or %s): %w", conflict, ConflictFail, ConflictOverwrite, ConflictRename, os.ErrInvalid)
+	}
+
+	archived, err := m.FindArchivedNote(name, dateDir)
+	if err != nil {
+		return UnarchiveResult{}, err
+	}
+	content, err := m.Store.Load(archived)
+	if err != nil {
+		return UnarchiveResult{}, err
+	}
+	content = HashNoteLock(content)
+
+	result := UnarchiveResult{Name: name, From: archived.DateDir, DateDir: time.Now().Format("20060102")}
+	if active, err := m.Store.Stat(name); err == nil && !active.IsBackup {
+		switch conflict {
+		case ConflictFail:
+			return UnarchiveResult{}, fmt.Errorf("active note %s already exists: %w", name, os.ErrExist)
+		case ConflictOverwrite:
+			activeContent, err := m.Store.Load(active)
+			if err != nil {
+				return UnarchiveResult{}, err
+			}
+			m.saveRevision(name, activeContent, false)
+			result.Overwritten = true
+		case ConflictRename:
+			if result.Name, err = m.unarchiveName(name, archived.DateDir); err != nil {
+				return UnarchiveResult{}, err
+			}
+			result.Renamed = true
+		}
+	}
+
+	info := NoteInfo{Name: result.Name, DateDir: result.DateDir}
+	if err := m.Store.Save(info, content); err != nil {
+		return UnarchiveResult{}, err
+	}
+	if err := m.Store.Delete(archived); err != nil {
+		log.Printf("Failed to remove archived note %s/%s after restoring it: %v", archived.DateDir, archived.Name, err)
+	}
+	info.Size = int64(len(content))
+	info.ModTime = time.Now()
+	m.Search.Remove(archived)
+	m.deleteArchivedRecord(archived)
+	m.Search.Update(info, content)
+	m.AddNoteToCache(result.Name)
+	if result.Renamed && m.ACL != nil {
+		// 新名称的笔记沿用原笔记的所有者和共享设置
+		if acl, ok := m.ACL.Get(name); ok {
+			if err := m.ACL.Set(result.Name, acl); err != nil {
+				log.Printf("Failed to copy ACL of note %s to %s: %v", name, result.Name, err)
+			}
+		}
+	}
+
+	e := Event{
+		Type:    EventUnarchived,
+		Name:    result.Name,
+		DateDir: result.DateDir,
+		Size:    info.Size,
+		Locked:  HasNoteLock(content),
+		ETag:    ContentETag(content),
+		Actor:   actor,
+	}
+	if !e.Locked {
+		e.Content = GetNoteContent(content)
+	}
+	m.emit(e)
+	return result, nil
+}
+
+// unarchiveName 返回重命名恢复时使用的新名称：名称-日期目录，已存在时加 -2、-3……
+func (m *Manager) unarchiveName(name, dateDir string) (string, error) {
+	base := name + "-" + dateDir
+	candidate := base
+	for i := 2; ; i++ {
+		if !m.IsSafeNoteName(candidate) {
+			return "", fmt.Errorf("cannot rename note %s: %s exceeds the maximum name length: %w", name, candidate, os.ErrInvalid)
+		}
+		if _, err := m.Store.Stat(candidate); os.IsNotExist(err) && !m.IsNoteExists(candidate) {
+			return candidate, nil
+		}
+		candidate = base + "-" + strconv.Itoa(i)
+	}
+}
+
+// 活跃笔记的归档依据
+const (
+	ArchiveByModified = "modified" // 最后修改时间（默认）
+	ArchiveByRead     = "read"     // 最后读取时间（没有读取记录或修改更晚时使用修改时间）
+)
+
+// ArchivePlanItem 归档计划中的一份笔记
+type ArchivePlanItem struct {
+	Name      string     `json:"name"`
+	DateDir   string     `json:"date_dir"`
+	IsBackup  bool       `json:"is_backup"`
+	Size      int64      `json:"size"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	LastRead  *time.Time `json:"last_read,omitempty"`
+	Since     time.Time  `json:"since"`            // 计算期限的起点：活跃笔记为最后使用时间，归档笔记为归档时间
+	Days      int        `json:"days"`             // 距 Since 的天数
+	Reason    string     `json:"reason,omitempty"` // 归档笔记被删除的原因：RetentionAge、RetentionCount 或 RetentionSize
+}
+
+// 归档笔记被保留策略删除的原因
+const (
+	RetentionAge   = "age"   // 归档超过 DeleteArchivedDays 天
+	RetentionCount = "count" // 超出 ArchiveKeepLast 条（最早归档的先删除）
+	RetentionSize  = "size"  // 超出 ArchiveMaxSize（最早归档的先删除）
+)
+
+// ArchivePlan 下一次归档会执行的操作（用于管理后台的预览，不修改任何笔记）
+type ArchivePlan struct {
+	ArchiveBy          string            `json:"archive_by"`
+	BackupDays         int               `json:"backup_days"`
+	DeleteArchivedDays int               `json:"delete_archived_days"`
+	ArchiveKeepLast    int               `json:"archive_keep_last"`
+	ArchiveMaxSize     int64             `json:"archive_max_size"`
+	Archive            []ArchivePlanItem `json:"archive"` // 将移动到备份文件夹的活跃笔记
+	Delete             []ArchivePlanItem `json:"delete"`  // 将被彻底删除的归档笔记
+	Pinned             []ArchivePlanItem `json:"pinned"`  // 已超过期限但因置顶而保留的笔记
+}
+
+// PlanArchive 按归档策略计算下一次归档会移动和删除的笔记
+// 活跃笔记超过 BackupDays 天未使用（按修改时间或读取时间）时移动到备份文件夹；
+// 归档笔记超过 DeleteArchivedDays 天、超出 ArchiveKeepLast 条或 ArchiveMaxSize 时彻底删除（最早归档的先删除，不经过回收站）；
+// 置顶的笔记不会被归档或删除
+func (m *Manager) PlanArchive() (ArchivePlan, error) {
+	now := time.Now()
+	plan := ArchivePlan{
+		ArchiveBy:          m.getArchiveBy(),
+		BackupDays:         m.getBackupDays(),
+		DeleteArchivedDays: m.getDeleteArchivedDays(),
+		ArchiveKeepLast:    m.getArchiveKeepLast(),
+		ArchiveMaxSize:     m.getArchiveMaxSize(),
+		Archive:            make([]ArchivePlanItem, 0),
+		Delete:             make([]ArchivePlanItem, 0),
+		Pinned:             make([]ArchivePlanItem, 0),
+	}
+
+	if plan.BackupDays > 0 {
+		infos, err := m.Store.List(false)
+		if err != nil {
+			return plan, err
+		}
+		cutoffTime := now.AddDate(0, 0, -plan.BackupDays)
+		for _, info := range infos {
+			item := m.archivePlanItem(info)
+			item.Since = info.ModTime
+			if plan.ArchiveBy == ArchiveByRead && item.LastRead != nil && item.LastRead.After(item.Since) {
+				item.Since = *item.LastRead
+			}
+			if !item.Since.Before(cutoffTime) {
+				continue
+			}
+			plan.addItem(item, m.isPinned(info.Name), now)
+		}
+	}
+
+	if plan.DeleteArchivedDays > 0 || plan.ArchiveKeepLast > 0 || plan.ArchiveMaxSize > 0 {
+		infos, err := m.Store.List(true)
+		if err != nil {
+			return plan, err
+		}
+		archived := make([]ArchivePlanItem, 0, len(infos))
+		for _, info := range infos {
+			item := m.archivePlanItem(info)
+			// 没有归档时间记录（升级前归档的笔记）时使用修改时间
+			item.Since = info.ModTime
+			if m.Meta != nil {
+				if archivedAt, ok := m.Meta.ArchivedAt(info.Name, info.DateDir); ok {
+					item.Since = archivedAt
+				}
+			}
+			archived = append(archived, item)
+		}
+		// 最近归档的优先保留
+		sort.Slice(archived, func(i, j int) bool {
+			return archived[i].Since.After(archived[j].Since)
+		})
+
+		// 这次将要归档的笔记是最新的归档笔记，先计入数量和大小（这次不会删除它们）
+		keptCount := len(plan.Archive)
+		var keptSize int64
+		for _, item := range plan.Archive {
+			keptSize += item.Size
+		}
+		cutoffTime := now.AddDate(0, 0, -plan.DeleteArchivedDays)
+		for _, item := range archived {
+			switch {
+			case plan.DeleteArchivedDays > 0 && item.Since.Before(cutoffTime):
+				item.Reason = RetentionAge
+			case plan.ArchiveKeepLast > 0 && keptCount >= plan.ArchiveKeepLast:
+				item.Reason = RetentionCount
+			case plan.ArchiveMaxSize > 0 && keptSize+item.Size > plan.ArchiveMaxSize:
+				item.Reason = RetentionSize
+			}
+			pinned := m.isPinned(item.Name)
+			if item.Reason == "" || pinned {
+				keptCount++
+				keptSize += item.Size
+			}
+			if item.Reason != "" {
+				plan.addItem(item, pinned, now)
+			}
+		}
+	}
+
+	for _, items := range [][]ArchivePlanItem{plan.Archive, plan.Delete, plan.Pinned} {
+		sort.Slice(items, func(i, j int) bool {
+			return items[i].Since.Before(items[j].Since)
+		})
+	}
+	return plan, nil
+}
+
+// addItem 把超过期限的笔记加入计划
+func (p *ArchivePlan) addItem(item ArchivePlanItem, pinned bool, now time.Time) {
+	item.Days = int(now.Sub(item.Since).Hours() / 24)
+	switch {
+	case pinned:
+		p.Pinned = append(p.Pinned, item)
+	case item.IsBackup:
+		p.Delete = append(p.Delete, item)
+	default:
+		p.Archive = append(p.Archive, item)
+	}
+}
+
+// archivePlanItem 根据笔记的元数据生成计划中的条目
+func (m *Manager) archivePlanItem(info NoteInfo) ArchivePlanItem {
+	item := ArchivePlanItem{
+		Name:      info.Name,
+		DateDir:   info.DateDir,
+		IsBackup:  info.IsBackup,
+		Size:      info.Size,
+		UpdatedAt: info.ModTime,
+	}
+	if m.Meta != nil {
+		if lastRead, ok := m.Meta.LastRead(info.Name); ok {
+			item.LastRead = &lastRead
+		}
+	}
+	return item
+}
+
+// MoveOldNotesToBackup 按归档策略把长时间未使用的活跃笔记移动到备份文件夹，并彻底删除过期的归档笔记
+// 备份文件夹结构: bak/YYYYMMDD/笔记名称（保留笔记原来的日期目录）
+func (m *Manager) MoveOldNotesToBackup() error {
+	m.snapshotLock.RLock()
+	defer m.snapshotLock.RUnlock()
+	plan, err := m.PlanArchive()
+	if err != nil {
+		return err
+	}
+
+	archivedCount := 0
+	for _, item := range plan.Archive {
+		// 笔记在计算计划之后被修改、删除或置顶时跳过
+		if ok, err := m.archiveNote(item, plan.ArchiveBy); !ok {
+			if err != nil && !os.IsNotExist(err) {
+				log.Printf("Failed to move note %s/%s to backup: %v", item.DateDir, item.Name, err)
+			}
+			continue
+		}
+		m.emit(Event{Type: EventArchived, Name: item.Name, DateDir: item.DateDir})
+		archivedCount++
+		log.Printf("Moved note %s/%s to backup (last used: %s, archive by %s)", item.DateDir, item.Name, item.Since.Format("2006-01-02 15:04:05"), plan.ArchiveBy)
+	}
+	if archivedCount > 0 {
+		log.Printf("Moved %d note(s) to backup folder", archivedCount)
+	}
+
+	deleted := make(map[string]int)
+	deletedCount := 0
+	for _, item := range plan.Delete {
+		if ok, err := m.deleteArchivedNote(item); !ok {
+			if err != nil && !os.IsNotExist(err) {
+				log.Printf("Failed to delete archived note %s/%s: %v", item.DateDir, item.Name, err)
+			}
+			continue
+		}
+		deleted[item.Reason]++
+		deletedCount++
+		log.Printf("Purged archived note %s/%s (%s, archived: %s, size: %d)", item.DateDir, item.Name, retentionReason(plan, item.Reason), item.Since.Format("2006-01-02 15:04:05"), item.Size)
+	}
+	if deletedCount > 0 {
+		log.Printf("Purged %d archived note(s) (age: %d, count: %d, size: %d)", deletedCount, deleted[RetentionAge], deleted[RetentionCount], deleted[RetentionSize])
+	}
+	return nil
+}
+
+// retentionReason 返回删除归档笔记的原因说明（用于日志）
+func retentionReason(plan ArchivePlan, reason string) string {
+	switch reason {
+	case RetentionAge:
+		return fmt.Sprintf("older than %d days", plan.DeleteArchivedDays)
+	case RetentionCount:
+		return fmt.Sprintf("beyond the last %d archived notes", plan.ArchiveKeepLast)
+	case RetentionSize:
+		return fmt.Sprintf("archive exceeds %d bytes", plan.ArchiveMaxSize)
+	}
+	return reason
+}
+
+// archiveNote 把计划中的活跃笔记移动到备份文件夹，调用者必须持有 snapshotLock 的读锁
+// 持有笔记的写锁重新检查笔记，在计算计划之后被修改、读取（按读取时间归档时）或置顶时不归档并返回 false
+func (m *Manager) archiveNote(item ArchivePlanItem, archiveBy string) (bool, error) {
+	defer m.lockNote(item.Name)()
+	info, err := m.Store.Stat(item.Name)
+	if err != nil {
+		return false, err
+	}
+	if info.IsBackup || info.DateDir != item.DateDir || !info.ModTime.Equal(item.UpdatedAt) || m.isPinned(item.Name) {
+		return false, nil
+	}
+	if archiveBy == ArchiveByRead && m.Meta != nil {
+		if lastRead, ok := m.Meta.LastRead(item.Name); ok && (item.LastRead == nil || !lastRead.Equal(*item.LastRead)) {
+			return false, nil
+		}
+	}
+	if err := m.Store.Archive(info); err != nil {
+		return false, err
+	}
+	m.RemoveNoteFromCache(item.Name)
+	m.Search.Archive([]string{item.Name}, item.DateDir)
+	if m.Meta != nil {
+		if err := m.Meta.SetArchived(item.Name, item.DateDir, time.Now()); err != nil {
+			log.Printf("Failed to record archive time of note %s/%s: %v", item.DateDir, item.Name, err)
+		}
+	}
+	return true, nil
+}
+
+// deleteArchivedNote 彻底删除过期的归档笔记（不经过回收站，立即释放空间），调用者必须持有 snapshotLock 的读锁
+// 持有笔记的写锁重新检查归档副本，在计算计划之后被替换（恢复后重新归档）或置顶时不删除并返回 false；
+// 笔记没有活跃副本和回收站副本时同时删除它的所有权记录和修订版本
+func (m *Manager) deleteArchivedNote(item ArchivePlanItem) (bool, error) {
+	defer m.lockNote(item.Name)()
+	info, err := m.FindArchivedNote(item.Name, item.DateDir)
+	if err != nil {
+		return false, err
+	}
+	if !info.ModTime.Equal(item.UpdatedAt) || m.isPinned(item.Name) {
+		return false, nil
+	}
+	if err := m.Store.Delete(info); err != nil {
+		return false, err
+	}
+	m.Search.Remove(info)
+	m.deleteArchivedRecord(info)
+	m.deleteUnusedRecords(info.Name)
+	m.deleteUnusedRevisions(info.Name)
+	return true, nil
+}
+
+// deleteArchivedRecord 归档副本被恢复或删除后删除它的归档时间记录
+func (m *Manager) deleteArchivedRecord(info NoteInfo) {
+	if m.Meta == nil {
+		return
+	}
+	if err := m.Meta.DeleteArchived(info.Name, info.DateDir); err != nil {
+		log.Printf("Failed to delete archive time of note %s/%s: %v", info.DateDir, info.Name, err)
+	}
+}
+
+// SetNotePinned 设置或取消笔记的置顶，笔记不存在时返回 os.ErrNotExist
+func (m *Manager) SetNotePinned(name string, pinned bool) error {
+	if m.Meta == nil {
+		return fmt.Errorf("note metadata is not available")
+	}
+	if _, err := m.Store.Stat(name); err != nil {
+		return err
+	}
+	return m.Meta.SetPinned(name, pinned)
+}
+
+// IsNotePinned 返回笔记是否置顶
+func (m *Manager) IsNotePinned(name string) bool {
+	return m.isPinned(name)
+}
+
+// TouchNote 记录笔记被读取（按读取时间归档时使用）
+func (m *Manager) TouchNote(name string) {
+	if m.Meta == nil {
+		return
+	}
+	if err := m.Meta.Touch(name); err != nil {
+		log.Printf("Failed to save note metadata: %v", err)
+	}
+}
+
+func (m *Manager) isPinned(name string) bool {
+	return m.Meta != nil && m.Meta.IsPinned(name)
+}
+
+// getBackupDays 获取活跃笔记未使用多少天后归档
+func (m *Manager) getBackupDays() int {
+	if m.GetBackupDays == nil {
+		return m.BackupDays
+	}
+	return m.GetBackupDays()
+}
+
+// getArchiveBy 获取活跃笔记的归档依据（未知的值按修改时间处理）
+func (m *Manager) getArchiveBy() string {
+	if m.GetArchiveBy == nil || m.GetArchiveBy() != ArchiveByRead {
+		return ArchiveByModified
+	}
+	return ArchiveByRead
+}
+
+// getDeleteArchivedDays 获取归档笔记保留的天数（0 表示不删除）
+func (m *Manager) getDeleteArchivedDays() int {
+	if m.GetDeleteArchivedDays == nil {
+		return 0
+	}
+	return m.GetDeleteArchivedDays()
+}
+
+// getArchiveKeepLast 获取最多保留的归档笔记数量（0 表示不限制）
+func (m *Manager) getArchiveKeepLast() int {
+	if m.GetArchiveKeepLast == nil {
+		return 0
+	}
+	return m.GetArchiveKeepLast()
+}
+
+// getArchiveMaxSize 获取归档笔记的最大总大小（0 表示不限制）
+func (m *Manager) getArchiveMaxSize() int64 {
+	if m.GetArchiveMaxSize == nil {
+		return 0
+	}
+	return m.GetArchiveMaxSize()
+}
@@ -0,0 +1,60 @@
+package note
+
+import "time"
+
+// 笔记事件类型
+const (
+	EventCreated    = "created"
+	EventUpdated    = "updated"
+	EventLocked     = "locked" // 原来没有锁的笔记加上了锁
+	EventDeleted    = "deleted"
+	EventArchived   = "archived"   // 被 MoveOldNotesToBackup 移动到备份文件夹
+	EventUnarchived = "unarchived" // 被 UnarchiveNote 从备份文件夹移回活跃笔记
+)
+
+// Event 表示笔记的一次变化，通过 Manager.OnEvent 通知
+type Event struct {
+	Type    string
+	Name    string
+	DateDir string
+	Size    int64
+	Locked  bool
+	Content string // 不含锁标记的内容；删除、归档和有锁的笔记为空
+	ETag    string // 保存后内容的 ETag（归档事件为空）
+	Actor   string // 修改笔记的用户名，管理员为 "@admin"；匿名和系统操作为空
+	Time    time.Time
+}
+
+// emit 通知笔记事件（OnEvent 为 nil 时忽略）
+func (m *Manager) emit(e Event) {
+	if m.OnEvent == nil {
+		return
+	}
+	e.Time = time.Now()
+	m.OnEvent(e)
+}
+
+// saveEvent 根据保存前后的内容（包含锁标记）返回保存笔记对应的事件，内容没有变化时返回 false
+func saveEvent(name, dateDir, oldContent, content string) (Event, bool) {
+	e := Event{
+		Name:    name,
+		DateDir: dateDir,
+		Size:    int64(len(content)),
+		Locked:  HasNoteLock(content),
+		ETag:    ContentETag(content),
+	}
+	switch {
+	case oldContent == "":
+		e.Type = EventCreated
+	case e.Locked && !HasNoteLock(oldContent):
+		e.Type = EventLocked
+	case oldContent != content:
+		e.Type = EventUpdated
+	default:
+		return Event{}, false
+	}
+	if !e.Locked {
+		e.Content = GetNoteContent(content)
+	}
+	return e, true
+}
@@ -0,0 +1,155 @@
+package note
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/hex"
+	"strings"
+)
+
+// Note lock functions
+// 锁以头部注释的形式保存在笔记文件开头：
+//   - 旧格式（明文）: <!-- LOCK:token -->
+//   - v1 格式（加盐哈希）: <!-- LOCK:v1:salt:hash -->
+//
+// 旧格式只用于兼容已有笔记和客户端提交的加锁请求，保存时总是转换为 v1 格式
+const lockPrefix = "<!-- LOCK:"
+const lockSuffix = " -->\n"
+
+const (
+	lockHashVersion    = "v1"
+	lockSaltLen        = 16
+	lockHashLen        = sha256.Size
+	lockHashIterations = 10000
+)
+
+// HasNoteLock checks if a note has a lock
+func HasNoteLock(content string) bool {
+	return strings.HasPrefix(content, lockPrefix)
+}
+
+// getLockHeaderValue extracts the raw value from <!-- LOCK:value -->
+// Returns empty string if no lock
+func getLockHeaderValue(content string) string {
+	if !HasNoteLock(content) {
+		return ""
+	}
+	endIdx := strings.Index(content, lockSuffix)
+	if endIdx == -1 {
+		return ""
+	}
+	return content[len(lockPrefix):endIdx]
+}
+
+// parseHashedLock 解析 v1 格式的锁，返回盐和哈希
+func parseHashedLock(value string) (salt, hash []byte, ok bool) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 3 || parts[0] != lockHashVersion {
+		return nil, nil, false
+	}
+	salt, err := hex.DecodeString(parts[1])
+	if err != nil || len(salt) != lockSaltLen {
+		return nil, nil, false
+	}
+	hash, err = hex.DecodeString(parts[2])
+	if err != nil || len(hash) != lockHashLen {
+		return nil, nil, false
+	}
+	return salt, hash, true
+}
+
+// IsLegacyNoteLock checks if a note is locked with a plaintext token
+func IsLegacyNoteLock(content string) bool {
+	value := getLockHeaderValue(content)
+	if value == "" {
+		return false
+	}
+	_, _, ok := parseHashedLock(value)
+	return !ok
+}
+
+// hashLockToken 使用 PBKDF2-HMAC-SHA256 计算锁 token 的哈希（单个输出块）
+func hashLockToken(token string, salt []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(token))
+	mac.Write(salt)
+	var block [4]byte
+	binary.BigEndian.PutUint32(block[:], 1)
+	mac.Write(block[:])
+	u := mac.Sum(nil)
+	result := make([]byte, len(u))
+	copy(result, u)
+	for i := 1; i < lockHashIterations; i++ {
+		mac.Reset()
+		mac.Write(u)
+		u = mac.Sum(u[:0])
+		for j := range result {
+			result[j] ^= u[j]
+		}
+	}
+	return result
+}
+
+// VerifyNoteLock checks the given token against the lock of note content
+// Returns false if the note has no lock or the token is wrong
+func VerifyNoteLock(content, token string) bool {
+	value := getLockHeaderValue(content)
+	if value == "" || token == "" {
+		return false
+	}
+	if salt, hash, ok := parseHashedLock(value); ok {
+		return subtle.ConstantTimeCompare(hashLockToken(token, salt), hash) == 1
+	}
+	// 旧格式：明文比较
+	return subtle.ConstantTimeCompare([]byte(value), []byte(token)) == 1
+}
+
+// GetNoteContent extracts the actual content from a locked note
+func GetNoteContent(content string) string {
+	if !HasNoteLock(content) {
+		return content
+	}
+	// Remove <!-- LOCK:... -->\n prefix
+	endIdx := strings.Index(content, lockSuffix)
+	if endIdx == -1 {
+		return content
+	}
+	return content[endIdx+len(lockSuffix):]
+}
+
+// SetNoteLock adds a hashed lock to note content
+func SetNoteLock(content, token string) string {
+	// Remove existing lock first
+	actualContent := GetNoteContent(content)
+	if token == "" {
+		return actualContent
+	}
+	salt := make([]byte, lockSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		// crypto/rand 不应失败；不能退回到明文保存
+		panic("note: failed to generate lock salt: " + err.Error())
+	}
+	value := lockHashVersion + ":" + hex.EncodeToString(salt) + ":" + hex.EncodeToString(hashLockToken(token, salt))
+	return lockPrefix + value + lockSuffix + actualContent
+}
+
+// KeepNoteLock applies the lock header of locked to content
+// If locked has no lock, content is returned without lock
+func KeepNoteLock(locked, content string) string {
+	actualContent := GetNoteContent(content)
+	if !HasNoteLock(locked) {
+		return actualContent
+	}
+	return lockPrefix + getLockHeaderValue(locked) + lockSuffix + actualContent
+}
+
+// HashNoteLock converts a plaintext lock header into the hashed format
+// Content without lock or with a hashed lock is returned unchanged
+func HashNoteLock(content string) string {
+	if !IsLegacyNoteLock(content) {
+		return content
+	}
+	return SetNoteLock(content, getLockHeaderValue(content))
+}
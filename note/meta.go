@@ -0,0 +1,213 @@
+package note
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// metaSaveInterval 最近读取时间写入文件的最小间隔（其他修改立即写入）
+const metaSaveInterval = time.Minute
+
+// MetaStore 保存笔记的置顶状态、最近读取时间和归档时间（按笔记名称，备份笔记沿用同一条记录）
+// 记录保存在单独的 JSON 文件中，与笔记存储后端无关
+// 读取笔记很频繁，最近读取时间先在内存中更新，最多每分钟写入一次文件，关闭时写入剩余的修改
+type MetaStore struct {
+	mu      sync.Mutex
+	path    string
+	data    metaData
+	dirty   bool      // 有尚未写入文件的读取时间
+	savedAt time.Time // 上次写入文件的时间
+}
+
+// metaData 元数据文件的内容
+type metaData struct {
+	Pinned   map[string]bool      `json:"pinned"`
+	LastRead map[string]time.Time `json:"lastRead"`
+	Archived map[string]time.Time `json:"archived"` // 日期目录/笔记名称 -> 移动到备份文件夹的时间
+}
+
+// OpenMetaStore 打开笔记元数据文件，文件不存在时创建空记录
+func OpenMetaStore(path string) (*MetaStore, error) {
+	s := &MetaStore{path: path}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &s.data); err != nil {
+			return nil, err
+		}
+	}
+	if s.data.Pinned == nil {
+		s.data.Pinned = make(map[string]bool)
+	}
+	if s.data.LastRead == nil {
+		s.data.LastRead = make(map[string]time.Time)
+	}
+	if s.data.Archived == nil {
+		s.data.Archived = make(map[string]time.Time)
+	}
+	return s, nil
+}
+
+// IsPinned 返回笔记是否置顶（置顶的笔记不会被归档或删除）
+func (s *MetaStore) IsPinned(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data.Pinned[name]
+}
+
+// SetPinned 设置或取消笔记的置顶
+func (s *MetaStore) SetPinned(name string, pinned bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.data.Pinned[name] == pinned {
+		return nil
+	}
+	if pinned {
+		s.data.Pinned[name] = true
+	} else {
+		delete(s.data.Pinned, name)
+	}
+	return s.save()
+}
+
+// Touch 记录笔记被读取，距离上次写入文件超过 metaSaveInterval 时写入文件
+func (s *MetaStore) Touch(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.data.LastRead[name] = now
+	s.dirty = true
+	if now.Sub(s.savedAt) < metaSaveInterval {
+		return nil
+	}
+	return s.save()
+}
+
+// LastRead 返回笔记的最近读取时间
+func (s *MetaStore) LastRead(name string) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.data.LastRead[name]
+	return t, ok
+}
+
+// SetArchived 记录日期目录中的笔记被移动到备份文件夹的时间
+func (s *MetaStore) SetArchived(name, dateDir string, t time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Archived[dateDir+"/"+name] = t
+	return s.save()
+}
+
+// ArchivedAt 返回日期目录中的笔记被移动到备份文件夹的时间
+func (s *MetaStore) ArchivedAt(name, dateDir string) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.data.Archived[dateDir+"/"+name]
+	return t, ok
+}
+
+// DeleteArchived 删除归档笔记的归档时间记录（归档副本被恢复或删除后调用）
+func (s *MetaStore) DeleteArchived(name, dateDir string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := dateDir + "/" + name
+	if _, ok := s.data.Archived[key]; !ok {
+		return nil
+	}
+	delete(s.data.Archived, key)
+	return s.save()
+}
+
+// Delete 删除笔记的所有记录（笔记不再有任何副本时调用）
+func (s *MetaStore) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	changed := false
+	if _, ok := s.data.Pinned[name]; ok {
+		delete(s.data.Pinned, name)
+		changed = true
+	}
+	if _, ok := s.data.LastRead[name]; ok {
+		delete(s.data.LastRead, name)
+		changed = true
+	}
+	for key := range s.data.Archived {
+		// 键的格式为 YYYYMMDD/笔记名称
+		if len(key) == len(name)+9 && strings.HasSuffix(key, "/"+name) {
+			delete(s.data.Archived, key)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return s.save()
+}
+
+// Flush 写入尚未保存的读取时间
+func (s *MetaStore) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.dirty {
+		return nil
+	}
+	return s.save()
+}
+
+// snapshot 返回所有记录的 JSON（用于导出快照，包括尚未写入文件的读取时间）
+func (s *MetaStore) snapshot() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return json.MarshalIndent(s.data, "", "  ")
+}
+
+// merge 导入快照中的记录：replace 为 true 时替换所有记录，否则只为 names 中的笔记添加本地没有的记录
+func (s *MetaStore) merge(data metaData, names map[string]bool, replace bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if replace {
+		s.data = metaData{
+			Pinned:   make(map[string]bool),
+			LastRead: make(map[string]time.Time),
+			Archived: make(map[string]time.Time),
+		}
+	}
+	for name, pinned := range data.Pinned {
+		if _, ok := s.data.Pinned[name]; pinned && (replace || (!ok && names[name])) {
+			s.data.Pinned[name] = true
+		}
+	}
+	for name, t := range data.LastRead {
+		if _, ok := s.data.LastRead[name]; replace || (!ok && names[name]) {
+			s.data.LastRead[name] = t
+		}
+	}
+	for key, t := range data.Archived {
+		// 键的格式为 YYYYMMDD/笔记名称
+		_, ok := s.data.Archived[key]
+		if i := strings.IndexByte(key, '/'); i >= 0 && (replace || (!ok && names[key[i+1:]])) {
+			s.data.Archived[key] = t
+		}
+	}
+	return s.save()
+}
+
+// save 将记录写入文件，调用者必须持有锁
+func (s *MetaStore) save() error {
+	data, err := json.MarshalIndent(s.data, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(s.path, data, 0644); err != nil {
+		return err
+	}
+	s.dirty = false
+	s.savedAt = time.Now()
+	return nil
+}
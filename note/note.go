@@ -1,16 +1,24 @@
 package note
 
 import (
-	"encoding/json"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash/fnv"
 	"log"
 	"math/rand"
 	"os"
-	"path/filepath"
 	"strings"
 	"sync"
 	"time"
 )
 
+// ErrNoteModified 笔记在读取之后、保存之前被其他请求修改（SaveNoteIfUnchanged 没有保存）
+var ErrNoteModified = errors.New("note was modified by another request")
+
+// noteLockCount 笔记写锁的数量，笔记按名称的哈希共用写锁
+const noteLockCount = 64
+
 // Note represents a note with its metadata
 type Note struct {
 	Name      string    `json:"name"`
@@ -28,86 +36,72 @@ type NoteListResponse struct {
 
 // Manager 管理笔记操作
 type Manager struct {
-	SavePath      string
-	BackupPath    string
+	Store         Store // 笔记存储后端
+	RevisionPath  string
+	TrashPath     string // 回收站目录，为空时删除的笔记不进入回收站
 	MaxPathLength int
 	NoteNameLen   int
 	NoteChars     string
 	BackupDays    int
 	ExistingNotes *sync.Map
-	NoteIndex     *sync.Map  // 存储 noteName -> dateDir 的映射
-	indexFile     string     // 索引文件路径
-	indexLock     sync.Mutex // 索引文件读写锁
+	Search        *SearchIndex // 笔记内容的全文索引
+	ACL           *ACLStore    // 笔记的所有者和共享设置（为 nil 时不记录）
+	Meta          *MetaStore   // 笔记的置顶状态和最近读取时间（为 nil 时不记录）
+
+	// 导出或导入快照时持有写锁，修改笔记、修订版本和回收站的方法持有读锁
+	snapshotLock sync.RWMutex
+	// 保存同一笔记时持有的写锁（见 lockNote），保证读取、比较和保存之间笔记不被修改
+	noteLocks [noteLockCount]sync.Mutex
+
+	// 修订版本限制（通过 getter 访问，以便配置更新后立即生效）
+	GetMaxRevisions func() int
+	GetRevisionDays func() int
+
+	// 回收站保留天数（通过 getter 访问，0 表示不自动清理）
+	GetTrashDays func() int
+	trashLock    sync.Mutex // 保护回收站的恢复和清理
+
+	// 归档策略（通过 getter 访问，为 nil 时使用 BackupDays、按修改时间归档、不删除归档笔记）
+	GetBackupDays         func() int
+	GetArchiveBy          func() string
+	GetDeleteArchivedDays func() int
+	GetArchiveKeepLast    func() int
+	GetArchiveMaxSize     func() int64
+
+	// OnEvent 在笔记被创建、修改、加锁、删除或归档后调用（为 nil 时不通知）
+	OnEvent func(Event)
 }
 
 // NewManager 创建新的笔记管理器
-func NewManager(savePath, backupPath string, maxPathLength, noteNameLen, backupDays int, noteChars string) *Manager {
-	indexFile := filepath.Join(savePath, ".notes_index")
-	m := &Manager{
-		SavePath:      savePath,
-		BackupPath:    backupPath,
+func NewManager(store Store, revisionPath, trashPath string, maxPathLength, noteNameLen, backupDays int, noteChars string) *Manager {
+	return &Manager{
+		Store:         store,
+		RevisionPath:  revisionPath,
+		TrashPath:     trashPath,
 		MaxPathLength: maxPathLength,
 		NoteNameLen:   noteNameLen,
 		NoteChars:     noteChars,
 		BackupDays:    backupDays,
 		ExistingNotes: &sync.Map{},
-		NoteIndex:     &sync.Map{},
-		indexFile:     indexFile,
-	}
-	// 加载索引文件
-	m.LoadNoteIndex()
-	return m
-}
-
-// Note lock functions
-const lockPrefix = "<!-- LOCK:"
-const lockSuffix = " -->\n"
-
-// HasNoteLock checks if a note has a lock
-func HasNoteLock(content string) bool {
-	return strings.HasPrefix(content, lockPrefix)
-}
-
-// GetNoteLockToken extracts the lock token from note content
-// Returns empty string if no lock
-func GetNoteLockToken(content string) string {
-	if !HasNoteLock(content) {
-		return ""
-	}
-	// Extract token from <!-- LOCK:token -->
-	endIdx := strings.Index(content, lockSuffix)
-	if endIdx == -1 {
-		return ""
+		Search:        NewSearchIndex(),
 	}
-	return content[len(lockPrefix):endIdx]
 }
 
-// GetNoteContent extracts the actual content from a locked note
-func GetNoteContent(content string) string {
-	if !HasNoteLock(content) {
-		return content
-	}
-	// Remove <!-- LOCK:token -->\n prefix
-	endIdx := strings.Index(content, lockSuffix)
-	if endIdx == -1 {
-		return content
+// Close 关闭笔记存储（fs 存储会把索引日志合并到快照，bolt 存储关闭数据库文件），并写入尚未保存的读取时间
+func (m *Manager) Close() error {
+	if m.Meta != nil {
+		if err := m.Meta.Flush(); err != nil {
+			log.Printf("Failed to save note metadata: %v", err)
+		}
 	}
-	return content[endIdx+len(lockSuffix):]
+	return m.Store.Close()
 }
 
-// SetNoteLock adds a lock to note content
-func SetNoteLock(content, token string) string {
-	if token == "" {
-		// Remove lock if token is empty
-		return GetNoteContent(content)
-	}
-	// If already locked, replace the token
-	if HasNoteLock(content) {
-		actualContent := GetNoteContent(content)
-		return lockPrefix + token + lockSuffix + actualContent
-	}
-	// Add new lock
-	return lockPrefix + token + lockSuffix + content
+// ContentETag 计算笔记内容的 ETag（基于不含锁标记的内容哈希）
+// 不存在的笔记（空内容）也有固定的 ETag，用于新建笔记时的并发检查
+func ContentETag(content string) string {
+	sum := sha256.Sum256([]byte(GetNoteContent(content)))
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
 }
 
 // IsSafeNoteName checks if a note name is safe (prevents path traversal attacks)
@@ -143,209 +137,34 @@ func (m *Manager) IsValidGeneratedName(name string) bool {
 	return true
 }
 
-// LoadNoteIndex 从索引文件加载笔记索引
-func (m *Manager) LoadNoteIndex() {
-	m.indexLock.Lock()
-	defer m.indexLock.Unlock()
-
-	data, err := os.ReadFile(m.indexFile)
-	if err != nil {
-		if os.IsNotExist(err) {
-			// 索引文件不存在，扫描目录重建索引
-			m.rebuildIndex()
-			return
-		}
-		log.Printf("Error reading note index: %v", err)
-		return
-	}
-
-	var index map[string]string
-	if err := json.Unmarshal(data, &index); err != nil {
-		log.Printf("Error parsing note index: %v, rebuilding...", err)
-		m.rebuildIndex()
-		return
-	}
-
-	// 加载到内存
-	for noteName, dateDir := range index {
-		m.NoteIndex.Store(noteName, dateDir)
-		m.ExistingNotes.Store(noteName, true)
-	}
-	log.Printf("Loaded %d notes from index", len(index))
+// StatNote 返回笔记的元数据（包括备份文件夹中的笔记）
+func (m *Manager) StatNote(name string) (NoteInfo, error) {
+	return m.Store.Stat(name)
 }
 
-// SaveNoteIndex 保存笔记索引到文件
-func (m *Manager) SaveNoteIndex() {
-	m.indexLock.Lock()
-	defer m.indexLock.Unlock()
-
-	index := make(map[string]string)
-	m.NoteIndex.Range(func(key, value interface{}) bool {
-		noteName := key.(string)
-		dateDir := value.(string)
-		index[noteName] = dateDir
-		return true
-	})
-
-	data, err := json.Marshal(index)
+// LoadExistingNotes 将所有已存在的笔记名称加载到内存中
+func (m *Manager) LoadExistingNotes() error {
+	infos, err := m.Store.List(false)
 	if err != nil {
-		log.Printf("Error marshaling note index: %v", err)
-		return
+		return err
 	}
-
-	if err := os.WriteFile(m.indexFile, data, 0644); err != nil {
-		log.Printf("Error saving note index: %v", err)
+	for _, info := range infos {
+		m.ExistingNotes.Store(info.Name, true)
 	}
+	return nil
 }
 
-// rebuildIndex 重建索引（扫描所有日期目录）
-// 注意：调用此函数时，调用者必须已经持有 indexLock
-func (m *Manager) rebuildIndex() {
-	m.NoteIndex = &sync.Map{}
-	m.ExistingNotes = &sync.Map{}
-
-	// 确保 SavePath 目录存在
-	if err := os.MkdirAll(m.SavePath, 0755); err != nil {
-		log.Printf("Error creating save path: %v", err)
-		return
-	}
-
-	files, err := os.ReadDir(m.SavePath)
+// TotalSize 返回所有活跃笔记的总大小（不包括备份文件夹）
+func (m *Manager) TotalSize() (int64, error) {
+	infos, err := m.Store.List(false)
 	if err != nil {
-		log.Printf("Error reading save path: %v", err)
-		return
+		return 0, err
 	}
-
-	for _, file := range files {
-		if !file.IsDir() {
-			continue
-		}
-
-		dirName := file.Name()
-		// 检查是否是日期格式目录（YYYYMMDD，8位数字）
-		if len(dirName) == 8 {
-			isDateDir := true
-			for _, r := range dirName {
-				if r < '0' || r > '9' {
-					isDateDir = false
-					break
-				}
-			}
-			if !isDateDir {
-				continue
-			}
-		} else {
-			continue
-		}
-
-		// 读取日期目录中的笔记
-		datePath := filepath.Join(m.SavePath, dirName)
-		noteFiles, err := os.ReadDir(datePath)
-		if err != nil {
-			continue
-		}
-
-		for _, noteFile := range noteFiles {
-			if !noteFile.IsDir() && m.IsSafeNoteName(noteFile.Name()) {
-				noteName := noteFile.Name()
-				m.NoteIndex.Store(noteName, dirName)
-				m.ExistingNotes.Store(noteName, true)
-			}
-		}
-	}
-
-	// 保存重建的索引（不获取锁，因为调用者已经持有）
-	index := make(map[string]string)
-	m.NoteIndex.Range(func(key, value interface{}) bool {
-		noteName := key.(string)
-		dateDir := value.(string)
-		index[noteName] = dateDir
-		return true
-	})
-
-	data, err := json.Marshal(index)
-	if err != nil {
-		log.Printf("Error marshaling note index: %v", err)
-		return
-	}
-
-	if err := os.WriteFile(m.indexFile, data, 0644); err != nil {
-		log.Printf("Error saving note index: %v", err)
-		return
-	}
-
-	log.Printf("Rebuilt note index with %d notes", len(index))
-}
-
-// getIndexMap 获取索引的副本（用于统计）
-func (m *Manager) getIndexMap() map[string]string {
-	index := make(map[string]string)
-	m.NoteIndex.Range(func(key, value interface{}) bool {
-		index[key.(string)] = value.(string)
-		return true
-	})
-	return index
-}
-
-// GetNotePath 获取笔记文件路径（保存时使用当前日期目录）
-func (m *Manager) GetNotePath(name string) string {
-	dateDir := time.Now().Format("20060102")
-	return filepath.Join(m.SavePath, dateDir, name)
-}
-
-// FindNotePath 从索引中查找笔记文件路径（包括备份文件夹）
-func (m *Manager) FindNotePath(name string) (string, error) {
-	// 首先从活跃笔记索引中查找
-	value, exists := m.NoteIndex.Load(name)
-	if exists {
-		dateDir := value.(string)
-		notePath := filepath.Join(m.SavePath, dateDir, name)
-		if _, err := os.Stat(notePath); err == nil {
-			return notePath, nil
-		}
-		// 文件不存在，从索引中移除
-		m.NoteIndex.Delete(name)
-		m.ExistingNotes.Delete(name)
-		m.SaveNoteIndex()
-	}
-
-	// 如果活跃笔记中找不到，从备份文件夹中查找
-	if _, err := os.Stat(m.BackupPath); err == nil {
-		dateDirs, err := os.ReadDir(m.BackupPath)
-		if err == nil {
-			// 按日期倒序查找（最新的优先）
-			for _, dateDir := range dateDirs {
-				if !dateDir.IsDir() {
-					continue
-				}
-				dirName := dateDir.Name()
-				// 检查是否是日期格式目录
-				if len(dirName) == 8 {
-					isDateDir := true
-					for _, r := range dirName {
-						if r < '0' || r > '9' {
-							isDateDir = false
-							break
-						}
-					}
-					if isDateDir {
-						notePath := filepath.Join(m.BackupPath, dirName, name)
-						if _, err := os.Stat(notePath); err == nil {
-							return notePath, nil
-						}
-					}
-				}
-			}
-		}
+	var total int64
+	for _, info := range infos {
+		total += info.Size
 	}
-
-	return "", os.ErrNotExist
-}
-
-// LoadExistingNotes 将所有已存在的笔记名称加载到内存中（已由 LoadNoteIndex 处理）
-func (m *Manager) LoadExistingNotes() error {
-	// 索引加载已在 NewManager 中完成
-	return nil
+	return total, nil
 }
 
 // IsNoteExists 检查笔记名称是否已存在（使用内存缓存）
@@ -417,285 +236,186 @@ func (m *Manager) GenerateNoteName() string {
 	return ""
 }
 
+// MigrateNoteLocks 将所有旧格式（明文）锁的笔记（包括备份文件夹中的笔记）迁移为哈希格式，在启动时调用
+// 读取笔记时只在内存中转换旧格式的锁，不会写回存储
+func (m *Manager) MigrateNoteLocks() error {
+	m.snapshotLock.RLock()
+	defer m.snapshotLock.RUnlock()
+	for _, archived := range []bool{false, true} {
+		infos, err := m.Store.List(archived)
+		if err != nil {
+			return err
+		}
+		for _, info := range infos {
+			m.migrateNoteLock(info)
+		}
+	}
+	return nil
+}
+
+// migrateNoteLock 在笔记的写锁内重新读取并迁移笔记的旧格式锁，调用者必须持有 snapshotLock 的读锁
+// 迁移时保留笔记的修改时间，避免影响备份判断
+func (m *Manager) migrateNoteLock(info NoteInfo) {
+	defer m.lockNote(info.Name)()
+	content, err := m.Store.Load(info)
+	if err != nil || !IsLegacyNoteLock(content) {
+		return
+	}
+	if err := m.Store.Save(info, HashNoteLock(content)); err != nil {
+		log.Printf("Failed to migrate note lock %s/%s: %v", info.DateDir, info.Name, err)
+		return
+	}
+	log.Printf("Migrated plaintext note lock to hashed format: %s/%s", info.DateDir, info.Name)
+}
+
 // SaveNote 保存笔记（保存到当前日期目录）
+// 内容中的明文锁标记会被转换为哈希格式后再保存
 func (m *Manager) SaveNote(name, content string) error {
-	// 检查这是否是新笔记
-	wasNewNote := !m.IsNoteExists(name)
+	return m.SaveNoteAs(name, content, "")
+}
+
+// SaveNoteAs 保存笔记并在事件中记录操作者（用户名、"@admin"，匿名时为空）
+func (m *Manager) SaveNoteAs(name, content, actor string) error {
+	m.snapshotLock.RLock()
+	defer m.snapshotLock.RUnlock()
+	defer m.lockNote(name)()
+	return m.saveNote(name, content, actor)
+}
+
+// SaveNoteIfUnchanged 只有笔记的当前原始内容仍为 expected（调用者检查 If-Match、锁和限制时读取的内容）时才保存，
+// 否则返回 ErrNoteModified。比较和保存在笔记的写锁内完成，同时提交的请求中只有一个能保存成功
+func (m *Manager) SaveNoteIfUnchanged(name, expected, content, actor string) error {
+	m.snapshotLock.RLock()
+	defer m.snapshotLock.RUnlock()
+	defer m.lockNote(name)()
+	current, err := m.LoadNote(name)
+	if err != nil {
+		return err
+	}
+	if current != expected {
+		return ErrNoteModified
+	}
+	return m.saveNote(name, content, actor)
+}
+
+// lockNote 获取笔记的写锁，返回释放写锁的函数
+func (m *Manager) lockNote(name string) func() {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	mu := &m.noteLocks[h.Sum32()%noteLockCount]
+	mu.Lock()
+	return mu.Unlock
+}
+
+// saveNote 保存笔记，调用者必须持有 snapshotLock 的读锁和笔记的写锁
+func (m *Manager) saveNote(name, content, actor string) error {
+	content = HashNoteLock(content)
 
-	// 如果内容为空，删除笔记
+	// 保存旧内容为修订版本（包括备份文件夹中的笔记）
+	previous, statErr := m.Store.Stat(name)
+	oldContent, err := m.LoadNote(name)
+	if err == nil && oldContent != content {
+		m.saveRevision(name, oldContent, content != "")
+	}
+
+	// 如果内容为空，删除笔记（先移动到回收站）
 	if content == "" {
-		notePath, err := m.FindNotePath(name)
-		if err == nil {
-			os.Remove(notePath)
+		if info, err := m.Store.Stat(name); err == nil {
+			if m.TrashPath != "" && oldContent != "" {
+				if err := m.moveToTrash(info, oldContent, actor); err != nil {
+					return err
+				}
+			}
+			if err := m.Store.Delete(info); err != nil {
+				return err
+			}
+			m.Search.Remove(info)
 		}
-		m.NoteIndex.Delete(name)
 		m.RemoveNoteFromCache(name)
-		m.SaveNoteIndex()
+		m.deleteUnusedRecords(name)
+		if oldContent != "" {
+			m.emit(Event{Type: EventDeleted, Name: name, ETag: ContentETag(""), Actor: actor})
+		}
 		return nil
 	}
 
-	// 获取当前日期目录
-	currentDateDir := time.Now().Format("20060102")
-	path := filepath.Join(m.SavePath, currentDateDir, name)
-
-	// 如果笔记已存在但在其他日期目录，先删除旧文件
-	if !wasNewNote {
-		oldPath, err := m.FindNotePath(name)
-		if err == nil && oldPath != path {
-			os.Remove(oldPath)
-		}
+	// 保存到当前日期目录（存储会删除其他日期目录中的旧副本）
+	info := NoteInfo{
+		Name:    name,
+		DateDir: time.Now().Format("20060102"),
 	}
-
-	// 创建日期目录（如果不存在）
-	dateDir := filepath.Join(m.SavePath, currentDateDir)
-	if err := os.MkdirAll(dateDir, 0755); err != nil {
+	if err := m.Store.Save(info, content); err != nil {
 		return err
 	}
-
-	// 保存到当前日期目录
-	err := os.WriteFile(path, []byte(content), 0644)
-	if err == nil {
-		// 更新索引
-		m.NoteIndex.Store(name, currentDateDir)
-		if wasNewNote {
-			m.AddNoteToCache(name)
+	// 修改的是归档笔记时删除备份文件夹中的旧副本（旧内容已保存为修订版本）
+	if statErr == nil && previous.IsBackup {
+		if err := m.Store.Delete(previous); err != nil {
+			log.Printf("Failed to remove archived note %s/%s: %v", previous.DateDir, previous.Name, err)
 		}
-		// 保存索引文件
-		m.SaveNoteIndex()
+		m.Search.Remove(previous)
+		m.deleteArchivedRecord(previous)
+	}
+	info.Size = int64(len(content))
+	info.ModTime = time.Now()
+	m.Search.Update(info, content)
+	m.AddNoteToCache(name)
+	if e, ok := saveEvent(name, info.DateDir, oldContent, content); ok {
+		e.Actor = actor
+		m.emit(e)
 	}
-	return err
+	return nil
 }
 
-// LoadNote 加载笔记（从所有日期目录中查找）
+// LoadNote 加载笔记（活跃笔记优先，其次是备份文件夹中的笔记），笔记不存在时返回空字符串
 func (m *Manager) LoadNote(name string) (string, error) {
-	path, err := m.FindNotePath(name)
+	info, err := m.Store.Stat(name)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return "", nil
 		}
 		return "", err
 	}
-	data, err := os.ReadFile(path)
+	content, err := m.Store.Load(info)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return "", nil
 		}
 		return "", err
 	}
-	return string(data), nil
+	return HashNoteLock(content), nil
 }
 
-// GetAllNotes 获取所有笔记（从索引中读取）
+// GetAllNotes 获取所有活跃笔记
 func (m *Manager) GetAllNotes() ([]Note, error) {
-	notes := make([]Note, 0)
-
-	m.NoteIndex.Range(func(key, value interface{}) bool {
-		noteName := key.(string)
-		dateDir := value.(string)
-		notePath := filepath.Join(m.SavePath, dateDir, noteName)
-
-		// 读取文件信息
-		info, err := os.Stat(notePath)
-		if err != nil {
-			// 文件不存在，从索引中移除
-			m.NoteIndex.Delete(noteName)
-			m.ExistingNotes.Delete(noteName)
-			return true
-		}
-
-		// 读取文件内容
-		content, err := os.ReadFile(notePath)
-		if err != nil {
-			return true
-		}
-
-		notes = append(notes, Note{
-			Name:      noteName,
-			Content:   string(content),
-			UpdatedAt: info.ModTime(),
-			Size:      info.Size(),
-			DateDir:   dateDir,
-			IsBackup:  false,
-		})
-		return true
-	})
-
-	// 如果索引中有无效条目，保存更新后的索引
-	m.SaveNoteIndex()
-
-	return notes, nil
-}
-
-// MoveOldNotesToBackup 将超过 backupDays 天未修改的日期目录移动到备份文件夹
-// 备份文件夹结构: bak/YYYYMMDD/（整个日期目录）
-func (m *Manager) MoveOldNotesToBackup() error {
-	files, err := os.ReadDir(m.SavePath)
-	if err != nil {
-		return err
-	}
-
-	cutoffTime := time.Now().AddDate(0, 0, -m.BackupDays)
-	movedCount := 0
-
-	for _, file := range files {
-		if !file.IsDir() {
-			continue
-		}
-
-		// 检查是否是日期格式目录（YYYYMMDD，8位数字）
-		dirName := file.Name()
-		if len(dirName) != 8 {
-			continue
-		}
-		isDateDir := true
-		for _, r := range dirName {
-			if r < '0' || r > '9' {
-				isDateDir = false
-				break
-			}
-		}
-		if !isDateDir {
-			continue
-		}
-
-		// 检查日期目录的最后修改时间
-		// 使用目录中最新文件的修改时间作为目录的修改时间
-		datePath := filepath.Join(m.SavePath, dirName)
-		noteFiles, err := os.ReadDir(datePath)
-		if err != nil {
-			continue
-		}
-
-		// 找到目录中最新的文件修改时间
-		var latestModTime time.Time
-		hasNotes := false
-		for _, noteFile := range noteFiles {
-			if noteFile.IsDir() {
-				continue
-			}
-			info, err := noteFile.Info()
-			if err != nil {
-				continue
-			}
-			if !hasNotes || info.ModTime().After(latestModTime) {
-				latestModTime = info.ModTime()
-				hasNotes = true
-			}
-		}
-
-		// 如果目录中没有笔记，或者最新修改时间早于截止时间，移动整个目录
-		if !hasNotes || latestModTime.Before(cutoffTime) {
-			sourcePath := filepath.Join(m.SavePath, dirName)
-			backupPath := filepath.Join(m.BackupPath, dirName)
-
-			// 如果备份目录已存在，合并文件
-			if _, err := os.Stat(backupPath); err == nil {
-				// 备份目录已存在，移动目录中的文件
-				for _, noteFile := range noteFiles {
-					if noteFile.IsDir() {
-						continue
-					}
-					noteName := noteFile.Name()
-					if !m.IsSafeNoteName(noteName) {
-						continue
-					}
-					sourceFilePath := filepath.Join(sourcePath, noteName)
-					backupFilePath := filepath.Join(backupPath, noteName)
-					if err := os.Rename(sourceFilePath, backupFilePath); err != nil {
-						log.Printf("Failed to move note %s/%s to backup: %v", dirName, noteName, err)
-						continue
-					}
-					m.RemoveNoteFromCache(noteName)
-					m.NoteIndex.Delete(noteName)
-					movedCount++
-				}
-				// 删除空的源目录
-				os.Remove(sourcePath)
-				// 保存更新后的索引
-				m.SaveNoteIndex()
-			} else {
-				// 备份目录不存在，直接移动整个目录
-				if err := os.Rename(sourcePath, backupPath); err != nil {
-					log.Printf("Failed to move date directory %s to backup: %v", dirName, err)
-					continue
-				}
-
-				// 从缓存和索引中移除该目录下的所有笔记
-				for _, noteFile := range noteFiles {
-					if !noteFile.IsDir() && m.IsSafeNoteName(noteFile.Name()) {
-						m.RemoveNoteFromCache(noteFile.Name())
-						m.NoteIndex.Delete(noteFile.Name())
-					}
-				}
-				// 保存更新后的索引
-				m.SaveNoteIndex()
-
-				movedCount++
-				log.Printf("Moved date directory %s to backup (latest modified: %s)", dirName, latestModTime.Format("2006-01-02 15:04:05"))
-			}
-		}
-	}
-
-	if movedCount > 0 {
-		log.Printf("Moved %d date directory/directories to backup folder", movedCount)
-	}
-
-	return nil
+	return m.loadNotes(false)
 }
 
 // GetAllBackupNotes 返回备份文件夹中的所有笔记
 func (m *Manager) GetAllBackupNotes() ([]Note, error) {
-	backupNotes := make([]Note, 0)
-
-	// Check if backup directory exists
-	if _, err := os.Stat(m.BackupPath); os.IsNotExist(err) {
-		return backupNotes, nil
-	}
+	return m.loadNotes(true)
+}
 
-	// Read all date directories in backup folder
-	dateDirs, err := os.ReadDir(m.BackupPath)
+// loadNotes 读取所有活跃笔记或备份笔记的内容
+func (m *Manager) loadNotes(archived bool) ([]Note, error) {
+	infos, err := m.Store.List(archived)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, dateDir := range dateDirs {
-		if !dateDir.IsDir() {
-			continue
-		}
-
-		datePath := filepath.Join(m.BackupPath, dateDir.Name())
-		files, err := os.ReadDir(datePath)
+	notes := make([]Note, 0, len(infos))
+	for _, info := range infos {
+		content, err := m.Store.Load(info)
 		if err != nil {
 			continue
 		}
-
-		for _, file := range files {
-			if file.IsDir() || !m.IsSafeNoteName(file.Name()) {
-				continue
-			}
-
-			info, err := file.Info()
-			if err != nil {
-				continue
-			}
-
-			// Read note content
-			notePath := filepath.Join(datePath, file.Name())
-			content, err := os.ReadFile(notePath)
-			if err != nil {
-				continue
-			}
-
-			backupNotes = append(backupNotes, Note{
-				Name:      file.Name(),
-				Content:   string(content),
-				UpdatedAt: info.ModTime(),
-				Size:      info.Size(),
-				DateDir:   dateDir.Name(),
-				IsBackup:  true,
-			})
-		}
+		notes = append(notes, Note{
+			Name:      info.Name,
+			Content:   HashNoteLock(content),
+			UpdatedAt: info.ModTime,
+			Size:      info.Size,
+			DateDir:   info.DateDir,
+			IsBackup:  info.IsBackup,
+		})
 	}
-
-	return backupNotes, nil
+	return notes, nil
 }
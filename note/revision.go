@@ -0,0 +1,222 @@
+package note
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// revisionMergeWindow 同一窗口内的连续保存合并为一个修订版本
+// 合并时保留较新的内容，这样被其他标签页覆盖前的最后内容总能找回
+const revisionMergeWindow = time.Minute
+
+// CurrentRevision 表示笔记当前内容的修订版本 ID
+const CurrentRevision = "current"
+
+// Revision represents a saved revision of a note
+type Revision struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Size      int64     `json:"size"`
+}
+
+// getRevisionDir 获取笔记的修订版本目录
+func (m *Manager) getRevisionDir(name string) string {
+	return filepath.Join(m.RevisionPath, name)
+}
+
+// deleteUnusedRevisions 笔记被永久删除（同名笔记已不存在，包括备份文件夹和回收站）时删除它的所有修订版本，
+// 以免之后创建同名笔记的人读取旧内容
+func (m *Manager) deleteUnusedRevisions(name string) {
+	if m.RevisionPath == "" || !m.isNoteUnused(name) {
+		return
+	}
+	if err := os.RemoveAll(m.getRevisionDir(name)); err != nil {
+		log.Printf("Failed to delete revisions of note %s: %v", name, err)
+	}
+}
+
+// parseRevisionID 解析修订版本 ID（创建时间的 UnixNano）
+func parseRevisionID(id string) (time.Time, bool) {
+	nano, err := strconv.ParseInt(id, 10, 64)
+	if err != nil || nano <= 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(0, nano), true
+}
+
+// ListRevisions 返回笔记的所有修订版本（按时间倒序）
+func (m *Manager) ListRevisions(name string) ([]Revision, error) {
+	revisions := make([]Revision, 0)
+	if m.RevisionPath == "" {
+		return revisions, nil
+	}
+
+	files, err := os.ReadDir(m.getRevisionDir(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return revisions, nil
+		}
+		return nil, err
+	}
+
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		createdAt, ok := parseRevisionID(file.Name())
+		if !ok {
+			continue
+		}
+		info, err := file.Info()
+		if err != nil {
+			continue
+		}
+		revisions = append(revisions, Revision{
+			ID:        file.Name(),
+			CreatedAt: createdAt,
+			UpdatedAt: info.ModTime(),
+			Size:      info.Size(),
+		})
+	}
+
+	sort.Slice(revisions, func(i, j int) bool {
+		return revisions[i].CreatedAt.After(revisions[j].CreatedAt)
+	})
+	return revisions, nil
+}
+
+// LoadRevision 加载指定修订版本的内容（CurrentRevision 表示当前内容）
+func (m *Manager) LoadRevision(name, id string) (string, error) {
+	if id == CurrentRevision {
+		return m.LoadNote(name)
+	}
+	if _, ok := parseRevisionID(id); !ok {
+		return "", os.ErrNotExist
+	}
+	data, err := os.ReadFile(filepath.Join(m.getRevisionDir(name), id))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// DiffRevisions 比较两个修订版本，返回 unified diff（不包含锁标记）
+func (m *Manager) DiffRevisions(name, from, to string) (string, error) {
+	fromContent, err := m.LoadRevision(name, from)
+	if err != nil {
+		return "", err
+	}
+	toContent, err := m.LoadRevision(name, to)
+	if err != nil {
+		return "", err
+	}
+	return UnifiedDiff(name+"@"+from, name+"@"+to, GetNoteContent(fromContent), GetNoteContent(toContent)), nil
+}
+
+// saveRevision 在覆盖笔记前保存旧内容为修订版本
+// merge 为 false 时总是创建新的修订版本（用于删除等操作）
+func (m *Manager) saveRevision(name, content string, merge bool) {
+	if m.RevisionPath == "" || content == "" || m.getMaxRevisions() <= 0 {
+		return
+	}
+
+	revisions, err := m.ListRevisions(name)
+	if err != nil {
+		return
+	}
+
+	revDir := m.getRevisionDir(name)
+	if err := os.MkdirAll(revDir, 0755); err != nil {
+		return
+	}
+
+	now := time.Now()
+	revPath := filepath.Join(revDir, strconv.FormatInt(now.UnixNano(), 10))
+	if len(revisions) > 0 {
+		latest := revisions[0]
+		latestPath := filepath.Join(revDir, latest.ID)
+		if merge && now.Sub(latest.CreatedAt) < revisionMergeWindow {
+			// 在合并窗口内，用较新的内容替换最新的修订版本
+			revPath = latestPath
+		} else if data, err := os.ReadFile(latestPath); err == nil && string(data) == content {
+			// 内容与最新修订版本相同，不重复保存
+			return
+		}
+	}
+
+	if err := os.WriteFile(revPath, []byte(content), 0644); err != nil {
+		return
+	}
+	m.pruneRevisions(name)
+}
+
+// pruneRevisions 按数量和天数清理笔记的旧修订版本
+func (m *Manager) pruneRevisions(name string) int {
+	revisions, err := m.ListRevisions(name)
+	if err != nil {
+		return 0
+	}
+
+	maxRevisions := m.getMaxRevisions()
+	revisionDays := m.getRevisionDays()
+	cutoffTime := time.Now().AddDate(0, 0, -revisionDays)
+
+	removed := 0
+	for i, rev := range revisions {
+		tooMany := i >= maxRevisions
+		tooOld := revisionDays > 0 && rev.UpdatedAt.Before(cutoffTime)
+		if tooMany || tooOld {
+			if err := os.Remove(filepath.Join(m.getRevisionDir(name), rev.ID)); err == nil {
+				removed++
+			}
+		}
+	}
+	if removed == len(revisions) {
+		os.Remove(m.getRevisionDir(name))
+	}
+	return removed
+}
+
+// PruneAllRevisions 清理所有笔记中超出限制的修订版本
+func (m *Manager) PruneAllRevisions() (int, error) {
+	if m.RevisionPath == "" {
+		return 0, nil
+	}
+	m.snapshotLock.RLock()
+	defer m.snapshotLock.RUnlock()
+	dirs, err := os.ReadDir(m.RevisionPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	removed := 0
+	for _, dir := range dirs {
+		if dir.IsDir() && m.IsSafeNoteName(dir.Name()) {
+			removed += m.pruneRevisions(dir.Name())
+		}
+	}
+	return removed, nil
+}
+
+// getMaxRevisions 获取每个笔记最多保留的修订版本数量
+func (m *Manager) getMaxRevisions() int {
+	if m.GetMaxRevisions == nil {
+		return 0
+	}
+	return m.GetMaxRevisions()
+}
+
+// getRevisionDays 获取修订版本保留天数（0 表示不按天数清理）
+func (m *Manager) getRevisionDays() int {
+	if m.GetRevisionDays == nil {
+		return 0
+	}
+	return m.GetRevisionDays()
+}
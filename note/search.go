@@ -0,0 +1,402 @@
+package note
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf16"
+)
+
+const (
+	// maxTermLength 单个词条的最大长度（字符数），更长的部分会被截断
+	maxTermLength = 64
+	// snippetBefore 摘要中第一个匹配位置之前保留的字符数
+	snippetBefore = 60
+	// snippetLength 摘要的最大字符数
+	snippetLength = 200
+)
+
+// SearchResult 表示一条搜索结果
+// Highlights 是 Snippet 中匹配位置的 [start, end) 区间，单位为 UTF-16 码元（与 JavaScript 字符串下标一致）
+type SearchResult struct {
+	Name       string    `json:"name"`
+	DateDir    string    `json:"date_dir"`
+	IsBackup   bool      `json:"is_backup"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	Size       int64     `json:"size"`
+	Score      int       `json:"score"`
+	Snippet    string    `json:"snippet"`
+	Highlights [][2]int  `json:"highlights"`
+}
+
+// searchDoc 表示倒排索引中的一篇笔记
+type searchDoc struct {
+	info  NoteInfo
+	terms map[string]int // 词条 -> 出现次数
+}
+
+// SearchIndex 笔记内容的倒排索引（活跃笔记和备份笔记）
+// 拉丁字母和数字按单词切分，中日韩文字按单字和相邻双字切分
+type SearchIndex struct {
+	mu       sync.RWMutex
+	docs     map[string]*searchDoc          // 文档键 -> 文档
+	postings map[string]map[string]struct{} // 词条 -> 文档键集合
+}
+
+// NewSearchIndex 创建空的搜索索引
+func NewSearchIndex() *SearchIndex {
+	return &SearchIndex{
+		docs:     make(map[string]*searchDoc),
+		postings: make(map[string]map[string]struct{}),
+	}
+}
+
+// searchKey 返回笔记在索引中的键（活跃笔记只有一份，备份笔记按日期目录区分）
+func searchKey(info NoteInfo) string {
+	if info.IsBackup {
+		return "bak/" + info.DateDir + "/" + info.Name
+	}
+	return info.Name
+}
+
+// isCJK 判断字符是否按单字/双字切分
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
+
+// tokenize 将文本切分为词条，返回每个词条的出现次数
+func tokenize(text string) map[string]int {
+	terms := make(map[string]int)
+	word := make([]rune, 0, maxTermLength)
+	flush := func() {
+		if len(word) > 0 {
+			terms[string(word)]++
+			word = word[:0]
+		}
+	}
+
+	var prev rune
+	for _, r := range text {
+		r = unicode.ToLower(r)
+		switch {
+		case isCJK(r):
+			flush()
+			terms[string(r)]++
+			if prev != 0 {
+				terms[string([]rune{prev, r})]++
+			}
+			prev = r
+			continue
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if len(word) < maxTermLength {
+				word = append(word, r)
+			}
+		default:
+			flush()
+		}
+		prev = 0
+	}
+	flush()
+	return terms
+}
+
+// Update 添加或更新笔记的索引（content 为原始内容，锁标记不会被索引）
+func (s *SearchIndex) Update(info NoteInfo, content string) {
+	key := searchKey(info)
+	terms := tokenize(GetNoteContent(content))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remove(key)
+	s.docs[key] = &searchDoc{info: info, terms: terms}
+	for term := range terms {
+		docs, ok := s.postings[term]
+		if !ok {
+			docs = make(map[string]struct{})
+			s.postings[term] = docs
+		}
+		docs[key] = struct{}{}
+	}
+}
+
+// Remove 从索引中移除笔记
+func (s *SearchIndex) Remove(info NoteInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remove(searchKey(info))
+}
+
+// remove 移除文档，调用者必须已经持有写锁
+func (s *SearchIndex) remove(key string) *searchDoc {
+	doc, ok := s.docs[key]
+	if !ok {
+		return nil
+	}
+	for term := range doc.terms {
+		if docs, ok := s.postings[term]; ok {
+			delete(docs, key)
+			if len(docs) == 0 {
+				delete(s.postings, term)
+			}
+		}
+	}
+	delete(s.docs, key)
+	return doc
+}
+
+// Archive 将已移动到备份文件夹的活跃笔记标记为备份笔记（无需重新分词）
+func (s *SearchIndex) Archive(names []string, dateDir string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, name := range names {
+		doc := s.remove(name)
+		if doc == nil {
+			continue
+		}
+		doc.info.DateDir = dateDir
+		doc.info.IsBackup = true
+		key := searchKey(doc.info)
+		s.remove(key)
+		s.docs[key] = doc
+		for term := range doc.terms {
+			docs, ok := s.postings[term]
+			if !ok {
+				docs = make(map[string]struct{})
+				s.postings[term] = docs
+			}
+			docs[key] = struct{}{}
+		}
+	}
+}
+
+// candidates 返回包含查询中所有词条的笔记
+// 拉丁词条按前缀匹配，以便输入单词的一部分也能找到笔记
+func (s *SearchIndex) candidates(query string) []NoteInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var matched map[string]struct{}
+	for term := range tokenize(query) {
+		docs := make(map[string]struct{})
+		if isCJK([]rune(term)[0]) {
+			for key := range s.postings[term] {
+				docs[key] = struct{}{}
+			}
+		} else {
+			for indexed, keys := range s.postings {
+				if strings.HasPrefix(indexed, term) {
+					for key := range keys {
+						docs[key] = struct{}{}
+					}
+				}
+			}
+		}
+
+		if matched == nil {
+			matched = docs
+		} else {
+			for key := range matched {
+				if _, ok := docs[key]; !ok {
+					delete(matched, key)
+				}
+			}
+		}
+		if len(matched) == 0 {
+			return nil
+		}
+	}
+
+	infos := make([]NoteInfo, 0, len(matched))
+	for key := range matched {
+		infos = append(infos, s.docs[key].info)
+	}
+	return infos
+}
+
+// BuildSearchIndex 读取所有活跃笔记和备份笔记，重建搜索索引
+func (m *Manager) BuildSearchIndex() error {
+	count := 0
+	for _, archived := range []bool{false, true} {
+		infos, err := m.Store.List(archived)
+		if err != nil {
+			return err
+		}
+		for _, info := range infos {
+			content, err := m.Store.Load(info)
+			if err != nil {
+				continue
+			}
+			m.Search.Update(info, content)
+			count++
+		}
+	}
+	log.Printf("Built search index with %d notes", count)
+	return nil
+}
+
+// SearchNotes 搜索笔记内容，按匹配次数和更新时间排序，最多返回 limit 条结果
+// 查询按空白分隔为多个关键词，笔记必须包含所有关键词（不区分大小写）
+// allow 根据笔记名称和原始内容过滤结果（例如加锁或无权访问的笔记），为 nil 时不返回加锁的笔记
+func (m *Manager) SearchNotes(query string, limit int, allow func(name, content string) bool) ([]SearchResult, error) {
+	keywords := make([][]rune, 0)
+	for _, field := range strings.Fields(query) {
+		keywords = append(keywords, lowerRunes(field))
+	}
+
+	results := make([]SearchResult, 0)
+	if len(keywords) == 0 || len(tokenize(query)) == 0 {
+		return results, nil
+	}
+
+	for _, info := range m.Search.candidates(query) {
+		content, err := m.Store.Load(info)
+		if err != nil {
+			continue
+		}
+		if allow != nil {
+			if !allow(info.Name, content) {
+				continue
+			}
+		} else if HasNoteLock(content) {
+			continue
+		}
+
+		text := []rune(GetNoteContent(content))
+		lower := lowerRunes(string(text))
+		score := 0
+		matches := make([][2]int, 0)
+		for _, keyword := range keywords {
+			found := findRunes(lower, keyword)
+			if len(found) == 0 {
+				score = 0
+				break
+			}
+			score += len(found)
+			matches = append(matches, found...)
+		}
+		if score == 0 {
+			continue
+		}
+
+		snippet, highlights := buildSnippet(text, matches)
+		results = append(results, SearchResult{
+			Name:       info.Name,
+			DateDir:    info.DateDir,
+			IsBackup:   info.IsBackup,
+			UpdatedAt:  info.ModTime,
+			Size:       info.Size,
+			Score:      score,
+			Snippet:    snippet,
+			Highlights: highlights,
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].UpdatedAt.After(results[j].UpdatedAt)
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// lowerRunes 逐字符转换为小写（保持字符数不变，以便下标与原文对应）
+func lowerRunes(s string) []rune {
+	runes := []rune(s)
+	for i, r := range runes {
+		runes[i] = unicode.ToLower(r)
+	}
+	return runes
+}
+
+// findRunes 返回 pattern 在 text 中所有不重叠出现的位置
+func findRunes(text, pattern []rune) [][2]int {
+	found := make([][2]int, 0)
+	for i := 0; i+len(pattern) <= len(text); {
+		if runesEqual(text[i:i+len(pattern)], pattern) {
+			found = append(found, [2]int{i, i + len(pattern)})
+			i += len(pattern)
+			continue
+		}
+		i++
+	}
+	return found
+}
+
+func runesEqual(a, b []rune) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// buildSnippet 截取第一个匹配位置附近的文本作为摘要，并返回摘要中的高亮区间
+func buildSnippet(text []rune, matches [][2]int) (string, [][2]int) {
+	sort.Slice(matches, func(i, j int) bool { return matches[i][0] < matches[j][0] })
+
+	start := 0
+	if len(matches) > 0 && matches[0][0] > snippetBefore {
+		start = matches[0][0] - snippetBefore
+	}
+	end := start + snippetLength
+	if len(matches) > 0 && matches[0][1] > end {
+		end = matches[0][1]
+	}
+	if end > len(text) {
+		end = len(text)
+	}
+
+	var b strings.Builder
+	offset := 0 // 当前写入位置（UTF-16 码元）
+	positions := make([]int, end-start+1)
+	if start > 0 {
+		b.WriteRune('…')
+		offset++
+	}
+	for i := start; i < end; i++ {
+		positions[i-start] = offset
+		r := text[i]
+		if unicode.IsSpace(r) {
+			r = ' '
+		}
+		b.WriteRune(r)
+		offset += len(utf16.Encode([]rune{r}))
+	}
+	positions[end-start] = offset
+	if end < len(text) {
+		b.WriteRune('…')
+	}
+
+	// 合并重叠的匹配区间，只保留摘要范围内的部分
+	highlights := make([][2]int, 0)
+	for _, match := range matches {
+		if match[1] <= start || match[0] >= end {
+			continue
+		}
+		from, to := match[0], match[1]
+		if from < start {
+			from = start
+		}
+		if to > end {
+			to = end
+		}
+		span := [2]int{positions[from-start], positions[to-start]}
+		if n := len(highlights); n > 0 && span[0] <= highlights[n-1][1] {
+			if span[1] > highlights[n-1][1] {
+				highlights[n-1][1] = span[1]
+			}
+			continue
+		}
+		highlights = append(highlights, span)
+	}
+	return b.String(), highlights
+}
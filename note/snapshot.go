@@ -0,0 +1,415 @@
+package note
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 快照中笔记数据的路径（使用 /，与存储后端无关）
+const (
+	SnapshotIndexFile    = "notes/index.json" // 所有笔记的索引（SnapshotNote 列表）
+	SnapshotActiveDir    = "notes/active"     // notes/active/YYYYMMDD/笔记名称
+	SnapshotArchivedDir  = "notes/archived"   // notes/archived/YYYYMMDD/笔记名称
+	SnapshotRevisionsDir = "revisions"        // revisions/笔记名称/修订版本 ID
+	SnapshotTrashDir     = "trash"            // trash/笔记名称/删除时间
+	SnapshotACLFile      = "acl.json"
+	SnapshotMetaFile     = "meta.json"
+)
+
+// SnapshotNote 快照索引中的一份笔记
+type SnapshotNote struct {
+	Name     string    `json:"name"`
+	DateDir  string    `json:"date_dir"`
+	Archived bool      `json:"archived"`
+	Size     int64     `json:"size"`
+	ModTime  time.Time `json:"mod_time"`
+}
+
+// Path 返回笔记内容在快照中的路径
+func (n SnapshotNote) Path() string {
+	dir := SnapshotActiveDir
+	if n.Archived {
+		dir = SnapshotArchivedDir
+	}
+	return path.Join(dir, n.DateDir, n.Name)
+}
+
+// SnapshotConflict 合并导入时没有导入的文件（本地已有不同的内容，保留本地内容）
+type SnapshotConflict struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// SnapshotImportResult 导入快照中笔记数据的结果
+type SnapshotImportResult struct {
+	Notes     int                `json:"notes"`     // 导入的活跃笔记
+	Archived  int                `json:"archived"`  // 导入的归档笔记
+	Revisions int                `json:"revisions"` // 导入的修订版本
+	Trash     int                `json:"trash"`     // 导入的回收站文件
+	Skipped   int                `json:"skipped"`   // 本地已有相同内容而跳过的文件
+	Conflicts []SnapshotConflict `json:"conflicts"`
+}
+
+// ExportSnapshot 导出所有活跃笔记、归档笔记、修订版本、回收站、所有权记录和元数据，每个文件调用一次 add
+// 导出期间阻止修改笔记，导出的数据是同一时刻的状态；add 应尽快返回（例如写入本地临时文件）
+func (m *Manager) ExportSnapshot(add func(path string, modTime time.Time, data []byte) error) error {
+	m.snapshotLock.Lock()
+	defer m.snapshotLock.Unlock()
+
+	index := make([]SnapshotNote, 0)
+	for _, archived := range []bool{false, true} {
+		infos, err := m.Store.List(archived)
+		if err != nil {
+			return err
+		}
+		for _, info := range infos {
+			content, err := m.Store.Load(info)
+			if err != nil {
+				return err
+			}
+			n := SnapshotNote{
+				Name:     info.Name,
+				DateDir:  info.DateDir,
+				Archived: archived,
+				Size:     int64(len(content)),
+				ModTime:  info.ModTime,
+			}
+			if err := add(n.Path(), info.ModTime, []byte(content)); err != nil {
+				return err
+			}
+			index = append(index, n)
+		}
+	}
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if err := add(SnapshotIndexFile, now, data); err != nil {
+		return err
+	}
+
+	for _, dir := range []struct{ src, dst string }{
+		{m.RevisionPath, SnapshotRevisionsDir},
+		{m.TrashPath, SnapshotTrashDir},
+	} {
+		if err := m.exportNoteFiles(dir.src, dir.dst, add); err != nil {
+			return err
+		}
+	}
+
+	if m.ACL != nil {
+		if data, err = m.ACL.snapshot(); err != nil {
+			return err
+		}
+		if err := add(SnapshotACLFile, now, data); err != nil {
+			return err
+		}
+	}
+	if m.Meta != nil {
+		if data, err = m.Meta.snapshot(); err != nil {
+			return err
+		}
+		if err := add(SnapshotMetaFile, now, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exportNoteFiles 导出修订版本或回收站目录中的文件（目录结构：笔记名称/ID）
+func (m *Manager) exportNoteFiles(src, dst string, add func(string, time.Time, []byte) error) error {
+	if src == "" {
+		return nil
+	}
+	dirs, err := os.ReadDir(src)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		files, err := os.ReadDir(filepath.Join(src, dir.Name()))
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			// 跳过写入中的临时文件
+			if _, ok := parseRevisionID(file.Name()); !ok || file.IsDir() {
+				continue
+			}
+			fi, err := file.Info()
+			if err != nil {
+				return err
+			}
+			data, err := os.ReadFile(filepath.Join(src, dir.Name(), file.Name()))
+			if err != nil {
+				return err
+			}
+			if err := add(path.Join(dst, dir.Name(), file.Name()), fi.ModTime(), data); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ImportSnapshot 从 dir（解压后的快照，已校验）导入笔记数据，导入期间阻止修改笔记
+// replace 为 true 时先删除所有现有的笔记、修订版本、回收站、所有权记录和元数据；
+// 否则合并：本地没有的文件直接导入，内容相同的跳过，内容不同的保留本地内容并记录为冲突，
+// 只为导入的笔记补充本地没有的所有权记录和元数据
+// 所有权记录中的用户名只在同时恢复了账号（withACL 为 true）时才有意义，否则不导入，导入的笔记没有所有者，
+// 避免本地同名的其他用户成为所有者
+// 导入不会发送笔记事件，也不检查笔记数量和总大小限制
+func (m *Manager) ImportSnapshot(dir string, replace, withACL bool) (SnapshotImportResult, error) {
+	m.snapshotLock.Lock()
+	defer m.snapshotLock.Unlock()
+
+	result := SnapshotImportResult{Conflicts: make([]SnapshotConflict, 0)}
+	data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(SnapshotIndexFile)))
+	if err != nil {
+		return result, err
+	}
+	var index []SnapshotNote
+	if err := json.Unmarshal(data, &index); err != nil {
+		return result, fmt.Errorf("invalid %s: %v: %w", SnapshotIndexFile, err, os.ErrInvalid)
+	}
+	for _, n := range index {
+		if !isSnapshotName(n.Name) || !isDateDir(n.DateDir) {
+			return result, fmt.Errorf("invalid note %q in %s: %w", path.Join(n.DateDir, n.Name), SnapshotIndexFile, os.ErrInvalid)
+		}
+	}
+
+	// 先读取所有权记录和元数据，无效时不修改任何数据
+	var acl map[string]NoteACL
+	var meta *metaData
+	if data, err := os.ReadFile(filepath.Join(dir, SnapshotACLFile)); err == nil {
+		if err := json.Unmarshal(data, &acl); err != nil {
+			return result, fmt.Errorf("invalid %s: %v: %w", SnapshotACLFile, err, os.ErrInvalid)
+		}
+	} else if !os.IsNotExist(err) {
+		return result, err
+	}
+	if !withACL && len(acl) > 0 {
+		log.Printf("Snapshot accounts are not restored, skipping ownership of %d note(s)", len(acl))
+		acl = nil
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, SnapshotMetaFile)); err == nil {
+		meta = &metaData{}
+		if err := json.Unmarshal(data, meta); err != nil {
+			return result, fmt.Errorf("invalid %s: %v: %w", SnapshotMetaFile, err, os.ErrInvalid)
+		}
+	} else if !os.IsNotExist(err) {
+		return result, err
+	}
+
+	if replace {
+		if err := m.clearNotes(); err != nil {
+			return result, err
+		}
+	}
+
+	// 本地的活跃笔记和归档笔记（合并时用于判断冲突）
+	local := make(map[string]NoteInfo)
+	for _, archived := range []bool{false, true} {
+		infos, err := m.Store.List(archived)
+		if err != nil {
+			return result, err
+		}
+		for _, info := range infos {
+			local[snapshotNote(info).Path()] = info
+		}
+	}
+
+	imported := make(map[string]bool)
+	for _, n := range index {
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(n.Path())))
+		if err != nil {
+			return result, err
+		}
+		content := string(data)
+		key := n.Path()
+		if !n.Archived {
+			// 同名活跃笔记可能在其他日期目录中
+			if info, err := m.Store.Stat(n.Name); err == nil && !info.IsBackup {
+				key = snapshotNote(info).Path()
+			}
+		}
+		if info, ok := local[key]; ok {
+			existing, err := m.Store.Load(info)
+			if err != nil {
+				return result, err
+			}
+			if existing == content || sameLegacyLock(existing, content) {
+				result.Skipped++
+			} else {
+				result.Conflicts = append(result.Conflicts, SnapshotConflict{Path: n.Path(), Reason: "note exists with different content"})
+			}
+			continue
+		}
+
+		// 旧格式（明文）锁保存前转换为哈希格式，读取笔记时不会再写回存储
+		content = HashNoteLock(content)
+		info := NoteInfo{Name: n.Name, DateDir: n.DateDir, IsBackup: n.Archived, ModTime: n.ModTime}
+		if err := m.Store.Save(info, content); err != nil {
+			return result, err
+		}
+		info.Size = int64(len(content))
+		m.Search.Update(info, content)
+		if n.Archived {
+			result.Archived++
+		} else {
+			m.AddNoteToCache(n.Name)
+			result.Notes++
+		}
+		imported[n.Name] = true
+	}
+
+	for _, files := range []struct {
+		src, dst string
+		count    *int
+	}{
+		{SnapshotRevisionsDir, m.RevisionPath, &result.Revisions},
+		{SnapshotTrashDir, m.TrashPath, &result.Trash},
+	} {
+		if files.dst == "" {
+			continue
+		}
+		if err := m.importNoteFiles(dir, files.src, files.dst, files.count, imported, &result); err != nil {
+			return result, err
+		}
+	}
+
+	// 替换导入时快照中没有的记录也会被清空
+	if m.ACL != nil && (acl != nil || replace) {
+		if err := m.ACL.merge(acl, imported, replace); err != nil {
+			return result, err
+		}
+	}
+	if m.Meta != nil && (meta != nil || replace) {
+		if meta == nil {
+			meta = &metaData{}
+		}
+		if err := m.Meta.merge(*meta, imported, replace); err != nil {
+			return result, err
+		}
+	}
+
+	sort.Slice(result.Conflicts, func(i, j int) bool {
+		return result.Conflicts[i].Path < result.Conflicts[j].Path
+	})
+	return result, nil
+}
+
+// sameLegacyLock 判断快照中旧格式（明文）锁的笔记是否与已迁移为哈希格式的本地笔记相同
+func sameLegacyLock(existing, content string) bool {
+	return IsLegacyNoteLock(content) && GetNoteContent(existing) == GetNoteContent(content) &&
+		VerifyNoteLock(existing, getLockHeaderValue(content))
+}
+
+// importNoteFiles 导入快照中的修订版本或回收站文件，导入的文件所属的笔记加入 imported
+func (m *Manager) importNoteFiles(dir, src, dst string, count *int, imported map[string]bool, result *SnapshotImportResult) error {
+	srcDir := filepath.Join(dir, src)
+	names, err := os.ReadDir(srcDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, name := range names {
+		if !name.IsDir() || !isSnapshotName(name.Name()) {
+			continue
+		}
+		files, err := os.ReadDir(filepath.Join(srcDir, name.Name()))
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			if _, ok := parseRevisionID(file.Name()); !ok || file.IsDir() {
+				continue
+			}
+			data, err := os.ReadFile(filepath.Join(srcDir, name.Name(), file.Name()))
+			if err != nil {
+				return err
+			}
+			target := filepath.Join(dst, name.Name(), file.Name())
+			if existing, err := os.ReadFile(target); err == nil {
+				if bytes.Equal(existing, data) {
+					result.Skipped++
+				} else {
+					result.Conflicts = append(result.Conflicts, SnapshotConflict{Path: path.Join(src, name.Name(), file.Name()), Reason: "file exists with different content"})
+				}
+				continue
+			} else if !os.IsNotExist(err) {
+				return err
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			if err := writeFileAtomic(target, data, 0644); err != nil {
+				return err
+			}
+			*count++
+			imported[name.Name()] = true
+		}
+	}
+	return nil
+}
+
+// clearNotes 删除所有活跃笔记、归档笔记、修订版本、回收站、所有权记录和元数据（替换导入前调用）
+func (m *Manager) clearNotes() error {
+	for _, archived := range []bool{false, true} {
+		infos, err := m.Store.List(archived)
+		if err != nil {
+			return err
+		}
+		for _, info := range infos {
+			if err := m.Store.Delete(info); err != nil {
+				return err
+			}
+			m.Search.Remove(info)
+			if !archived {
+				m.RemoveNoteFromCache(info.Name)
+			}
+		}
+	}
+	for _, dir := range []string{m.RevisionPath, m.TrashPath} {
+		if dir == "" {
+			continue
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		for _, entry := range entries {
+			if err := os.RemoveAll(filepath.Join(dir, entry.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	log.Printf("Removed all notes before importing snapshot")
+	return nil
+}
+
+// snapshotNote 返回存储中笔记对应的快照索引条目
+func snapshotNote(info NoteInfo) SnapshotNote {
+	return SnapshotNote{Name: info.Name, DateDir: info.DateDir, Archived: info.IsBackup, Size: info.Size, ModTime: info.ModTime}
+}
+
+// isSnapshotName 检查快照中的笔记名称能否安全地用作文件名
+func isSnapshotName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, "/\\\x00")
+}
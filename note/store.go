@@ -0,0 +1,119 @@
+package note
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// 存储后端类型
+const (
+	StoreFS   = "fs"   // 文件系统：_tmp/YYYYMMDD/笔记名称，bak/YYYYMMDD/笔记名称
+	StoreBolt = "bolt" // 嵌入式键值数据库：单个 bbolt 文件
+)
+
+// NoteInfo 存储中一份笔记的元数据
+type NoteInfo struct {
+	Name      string
+	DateDir   string // 日期目录（格式：YYYYMMDD）
+	Size      int64
+	ModTime   time.Time
+	CreatedAt time.Time
+	IsBackup  bool // 是否在归档（备份）中
+}
+
+// Store 笔记存储后端
+// 活跃笔记按名称唯一；归档笔记按日期目录分组，同名笔记可能出现在多个日期目录中
+type Store interface {
+	// Stat 查找笔记：优先活跃笔记，其次最新的归档笔记，不存在时返回 os.ErrNotExist
+	Stat(name string) (NoteInfo, error)
+	// Load 读取 info 指定的那份笔记的内容
+	Load(info NoteInfo) (string, error)
+	// Save 保存笔记到 info.DateDir（info.IsBackup 为 true 时保存到归档）
+	// 保存活跃笔记时会删除其他日期目录中的旧副本；info.ModTime 不为零时作为修改时间
+	Save(info NoteInfo, content string) error
+	// Delete 删除 info 指定的那份笔记
+	Delete(info NoteInfo) error
+	// List 列出所有活跃笔记（archived 为 false）或归档笔记
+	List(archived bool) ([]NoteInfo, error)
+	// Archive 将 info 指定的活跃笔记移动到归档（保留日期目录和修改时间）
+	Archive(info NoteInfo) error
+	// Close 关闭存储
+	Close() error
+}
+
+// OpenStore 按类型打开存储后端
+// bolt 存储为空时会从文件系统布局中导入已有的笔记
+func OpenStore(kind, savePath, backupPath, storeFile string) (Store, error) {
+	switch kind {
+	case "", StoreFS:
+		return NewFileStore(savePath, backupPath), nil
+	case StoreBolt:
+		store, err := OpenBoltStore(storeFile)
+		if err != nil {
+			return nil, err
+		}
+		if empty, err := store.IsEmpty(); err == nil && empty {
+			if _, err := os.Stat(savePath); err == nil {
+				count, err := CopyStore(store, NewFileStore(savePath, backupPath))
+				if err != nil {
+					log.Printf("Error importing notes into %s: %v", storeFile, err)
+				} else if count > 0 {
+					log.Printf("Imported %d note(s) from %s and %s into %s", count, savePath, backupPath, storeFile)
+				}
+			}
+		}
+		return store, nil
+	default:
+		return nil, fmt.Errorf("unknown store type: %s (use %s or %s)", kind, StoreFS, StoreBolt)
+	}
+}
+
+// CopyStore 将 src 中的所有活跃和归档笔记复制到 dst，保留日期目录和修改时间
+func CopyStore(dst, src Store) (int, error) {
+	count := 0
+	for _, archived := range []bool{false, true} {
+		infos, err := src.List(archived)
+		if err != nil {
+			return count, err
+		}
+		for _, info := range infos {
+			content, err := src.Load(info)
+			if err != nil {
+				return count, err
+			}
+			if err := dst.Save(info, content); err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+	return count, nil
+}
+
+// isDateDir 检查是否是日期格式目录（YYYYMMDD，8位数字）
+func isDateDir(name string) bool {
+	if len(name) != 8 {
+		return false
+	}
+	for _, r := range name {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// isNoteFileName 检查文件名能否作为笔记名称（跳过隐藏文件和包含路径分隔符的名称）
+func isNoteFileName(name string) bool {
+	if name == "" || name[0] == '.' {
+		return false
+	}
+	for _, r := range name {
+		if r == '/' || r == '\\' || (r < 32 && r != '\t' && r != '\n' && r != '\r') {
+			return false
+		}
+	}
+	return true
+}
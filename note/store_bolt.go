@@ -0,0 +1,257 @@
+package note
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	boltNotesBucket   = []byte("notes")   // 活跃笔记：笔记名称 -> 记录
+	boltArchiveBucket = []byte("archive") // 归档笔记：日期目录/笔记名称 -> 记录
+)
+
+// boltMeta 记录头部的元数据
+// 记录格式：元数据 JSON + "\n" + 笔记内容
+type boltMeta struct {
+	DateDir   string    `json:"date_dir"`
+	ModTime   time.Time `json:"mod_time"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// BoltStore 基于 bbolt 的单文件键值存储
+// 适合大量小笔记的场景：不产生大量小文件，保存时也不需要重写索引文件
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// OpenBoltStore 打开（或创建）bbolt 数据库文件
+func OpenBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(boltNotesBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(boltArchiveBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltStore{db: db}, nil
+}
+
+// IsEmpty 检查数据库中是否没有任何笔记
+func (s *BoltStore) IsEmpty() (bool, error) {
+	empty := true
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if k, _ := tx.Bucket(boltNotesBucket).Cursor().First(); k != nil {
+			empty = false
+		}
+		if k, _ := tx.Bucket(boltArchiveBucket).Cursor().First(); k != nil {
+			empty = false
+		}
+		return nil
+	})
+	return empty, err
+}
+
+func encodeBoltRecord(meta boltMeta, content string) ([]byte, error) {
+	header, err := json.Marshal(meta)
+	if err != nil {
+		return nil, err
+	}
+	record := make([]byte, 0, len(header)+1+len(content))
+	record = append(record, header...)
+	record = append(record, '\n')
+	record = append(record, content...)
+	return record, nil
+}
+
+// decodeBoltMeta 解析记录的元数据，返回元数据和内容部分
+func decodeBoltMeta(record []byte) (boltMeta, []byte, error) {
+	var meta boltMeta
+	idx := bytes.IndexByte(record, '\n')
+	if idx < 0 {
+		return meta, nil, fmt.Errorf("invalid note record")
+	}
+	if err := json.Unmarshal(record[:idx], &meta); err != nil {
+		return meta, nil, err
+	}
+	return meta, record[idx+1:], nil
+}
+
+func boltArchiveKey(dateDir, name string) []byte {
+	return []byte(dateDir + "/" + name)
+}
+
+// boltInfo 根据记录生成笔记元数据
+func boltInfo(name string, isBackup bool, record []byte) (NoteInfo, error) {
+	meta, content, err := decodeBoltMeta(record)
+	if err != nil {
+		return NoteInfo{}, err
+	}
+	return NoteInfo{
+		Name:      name,
+		DateDir:   meta.DateDir,
+		Size:      int64(len(content)),
+		ModTime:   meta.ModTime,
+		CreatedAt: meta.CreatedAt,
+		IsBackup:  isBackup,
+	}, nil
+}
+
+// Stat 查找笔记：优先活跃笔记，其次最新的归档笔记
+func (s *BoltStore) Stat(name string) (NoteInfo, error) {
+	info := NoteInfo{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if record := tx.Bucket(boltNotesBucket).Get([]byte(name)); record != nil {
+			var err error
+			info, err = boltInfo(name, false, record)
+			return err
+		}
+		// 归档的键以日期目录开头，倒序遍历即最新的优先
+		c := tx.Bucket(boltArchiveBucket).Cursor()
+		suffix := "/" + name
+		for k, record := c.Last(); k != nil; k, record = c.Prev() {
+			if strings.HasSuffix(string(k), suffix) && len(k) == 8+len(suffix) {
+				var err error
+				info, err = boltInfo(name, true, record)
+				return err
+			}
+		}
+		return os.ErrNotExist
+	})
+	return info, err
+}
+
+// Load 读取笔记内容
+func (s *BoltStore) Load(info NoteInfo) (string, error) {
+	var content string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var record []byte
+		if info.IsBackup {
+			record = tx.Bucket(boltArchiveBucket).Get(boltArchiveKey(info.DateDir, info.Name))
+		} else {
+			record = tx.Bucket(boltNotesBucket).Get([]byte(info.Name))
+		}
+		if record == nil {
+			return os.ErrNotExist
+		}
+		_, data, err := decodeBoltMeta(record)
+		if err != nil {
+			return err
+		}
+		// bbolt 返回的数据只在事务内有效，转换为字符串时会复制
+		content = string(data)
+		return nil
+	})
+	return content, err
+}
+
+// Save 保存笔记，保留已有记录的创建时间
+func (s *BoltStore) Save(info NoteInfo, content string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltNotesBucket)
+		key := []byte(info.Name)
+		if info.IsBackup {
+			bucket = tx.Bucket(boltArchiveBucket)
+			key = boltArchiveKey(info.DateDir, info.Name)
+		}
+
+		now := time.Now()
+		meta := boltMeta{
+			DateDir:   info.DateDir,
+			ModTime:   info.ModTime,
+			CreatedAt: info.CreatedAt,
+		}
+		if meta.ModTime.IsZero() {
+			meta.ModTime = now
+		}
+		if meta.CreatedAt.IsZero() {
+			meta.CreatedAt = now
+			if old := bucket.Get(key); old != nil {
+				if oldMeta, _, err := decodeBoltMeta(old); err == nil {
+					meta.CreatedAt = oldMeta.CreatedAt
+				}
+			}
+		}
+
+		record, err := encodeBoltRecord(meta, content)
+		if err != nil {
+			return err
+		}
+		return bucket.Put(key, record)
+	})
+}
+
+// Delete 删除笔记
+func (s *BoltStore) Delete(info NoteInfo) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if info.IsBackup {
+			return tx.Bucket(boltArchiveBucket).Delete(boltArchiveKey(info.DateDir, info.Name))
+		}
+		return tx.Bucket(boltNotesBucket).Delete([]byte(info.Name))
+	})
+}
+
+// List 列出活跃笔记或归档笔记
+func (s *BoltStore) List(archived bool) ([]NoteInfo, error) {
+	infos := make([]NoteInfo, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltNotesBucket)
+		if archived {
+			bucket = tx.Bucket(boltArchiveBucket)
+		}
+		return bucket.ForEach(func(k, record []byte) error {
+			name := string(k)
+			if archived {
+				name = name[strings.IndexByte(name, '/')+1:]
+			}
+			info, err := boltInfo(name, archived, record)
+			if err != nil {
+				return err
+			}
+			infos = append(infos, info)
+			return nil
+		})
+	})
+	return infos, err
+}
+
+// Archive 将活跃笔记移动到归档
+func (s *BoltStore) Archive(info NoteInfo) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		notes := tx.Bucket(boltNotesBucket)
+		record := notes.Get([]byte(info.Name))
+		if record == nil {
+			return os.ErrNotExist
+		}
+		meta, _, err := decodeBoltMeta(record)
+		if err != nil {
+			return err
+		}
+		if meta.DateDir != info.DateDir {
+			return os.ErrNotExist
+		}
+		record = append([]byte(nil), record...)
+		if err := tx.Bucket(boltArchiveBucket).Put(boltArchiveKey(meta.DateDir, info.Name), record); err != nil {
+			return err
+		}
+		return notes.Delete([]byte(info.Name))
+	})
+}
+
+// Close 关闭数据库
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,256 @@
+package note
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/hello--world/jot/utils"
+)
+
+// FileStore 文件系统存储
+// 活跃笔记保存在 savePath/YYYYMMDD/笔记名称，归档笔记保存在 backupPath/YYYYMMDD/笔记名称
+// 活跃笔记所在的日期目录记录在 savePath/.notes_index 快照和 .notes_index.journal 日志中
+type FileStore struct {
+	SavePath   string
+	BackupPath string
+	index      *sync.Map  // 存储 noteName -> dateDir 的映射
+	indexFile  string     // 索引快照文件路径
+	indexLock  sync.Mutex // 索引文件读写锁
+
+	journalFile    string   // 索引日志文件路径
+	journal        *os.File // 追加写入的索引日志
+	journalEntries int      // 快照之后日志中的条目数
+}
+
+// NewFileStore 创建文件系统存储并加载笔记索引
+func NewFileStore(savePath, backupPath string) *FileStore {
+	s := &FileStore{
+		SavePath:    savePath,
+		BackupPath:  backupPath,
+		index:       &sync.Map{},
+		indexFile:   filepath.Join(savePath, ".notes_index"),
+		journalFile: filepath.Join(savePath, ".notes_index.journal"),
+	}
+	s.loadIndex()
+	return s
+}
+
+// path 返回 info 对应的文件路径
+func (s *FileStore) path(info NoteInfo) string {
+	if info.IsBackup {
+		return filepath.Join(s.BackupPath, info.DateDir, info.Name)
+	}
+	return filepath.Join(s.SavePath, info.DateDir, info.Name)
+}
+
+// statFile 读取文件信息并填充 info
+func (s *FileStore) statFile(info NoteInfo) (NoteInfo, error) {
+	path := s.path(info)
+	fi, err := os.Stat(path)
+	if err != nil {
+		return info, err
+	}
+	info.Size = fi.Size()
+	info.ModTime = fi.ModTime()
+	info.CreatedAt = utils.GetFileCreationTime(fi)
+	return info, nil
+}
+
+// Stat 从索引中查找笔记，找不到时从备份文件夹中查找
+func (s *FileStore) Stat(name string) (NoteInfo, error) {
+	// 首先从活跃笔记索引中查找
+	if value, exists := s.index.Load(name); exists {
+		info, err := s.statFile(NoteInfo{Name: name, DateDir: value.(string)})
+		if err == nil {
+			return info, nil
+		}
+		// 文件不存在，从索引中移除
+		s.deleteIndex(name)
+	}
+
+	// 如果活跃笔记中找不到，从备份文件夹中查找（最新的日期优先）
+	dateDirs, err := os.ReadDir(s.BackupPath)
+	if err == nil {
+		for i := len(dateDirs) - 1; i >= 0; i-- {
+			if !dateDirs[i].IsDir() || !isDateDir(dateDirs[i].Name()) {
+				continue
+			}
+			info, err := s.statFile(NoteInfo{Name: name, DateDir: dateDirs[i].Name(), IsBackup: true})
+			if err == nil {
+				return info, nil
+			}
+		}
+	}
+
+	return NoteInfo{}, os.ErrNotExist
+}
+
+// Load 读取笔记内容
+func (s *FileStore) Load(info NoteInfo) (string, error) {
+	data, err := os.ReadFile(s.path(info))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// Save 保存笔记
+func (s *FileStore) Save(info NoteInfo, content string) error {
+	path := s.path(info)
+
+	// 如果笔记已存在但在其他日期目录，先删除旧文件
+	if !info.IsBackup {
+		if value, exists := s.index.Load(info.Name); exists && value.(string) != info.DateDir {
+			s.removeFile(NoteInfo{Name: info.Name, DateDir: value.(string)})
+		}
+	}
+
+	// 创建日期目录（如果不存在）
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return err
+	}
+	if !info.ModTime.IsZero() {
+		os.Chtimes(path, info.ModTime, info.ModTime)
+	}
+
+	if !info.IsBackup {
+		// 更新索引
+		s.setIndex(info.Name, info.DateDir)
+	}
+	return nil
+}
+
+// Delete 删除笔记
+func (s *FileStore) Delete(info NoteInfo) error {
+	err := s.removeFile(info)
+	if !info.IsBackup {
+		s.deleteIndex(info.Name)
+	}
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// removeFile 删除笔记文件，日期目录为空时一并删除
+func (s *FileStore) removeFile(info NoteInfo) error {
+	path := s.path(info)
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	// 目录不为空时删除会失败，忽略错误
+	os.Remove(filepath.Dir(path))
+	return nil
+}
+
+// List 列出活跃笔记（从索引中读取）或归档笔记（扫描备份文件夹）
+func (s *FileStore) List(archived bool) ([]NoteInfo, error) {
+	if archived {
+		return s.listBackup()
+	}
+
+	infos := make([]NoteInfo, 0)
+	missing := make([]string, 0)
+	s.index.Range(func(key, value interface{}) bool {
+		info, err := s.statFile(NoteInfo{Name: key.(string), DateDir: value.(string)})
+		if err != nil {
+			missing = append(missing, key.(string))
+			return true
+		}
+		infos = append(infos, info)
+		return true
+	})
+
+	// 文件不存在的条目从索引中移除
+	if len(missing) > 0 {
+		s.deleteIndex(missing...)
+	}
+	return infos, nil
+}
+
+// listBackup 列出备份文件夹中的所有笔记
+func (s *FileStore) listBackup() ([]NoteInfo, error) {
+	infos := make([]NoteInfo, 0)
+
+	// Read all date directories in backup folder
+	dateDirs, err := os.ReadDir(s.BackupPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return infos, nil
+		}
+		return nil, err
+	}
+
+	for _, dateDir := range dateDirs {
+		if !dateDir.IsDir() {
+			continue
+		}
+
+		files, err := os.ReadDir(filepath.Join(s.BackupPath, dateDir.Name()))
+		if err != nil {
+			continue
+		}
+
+		for _, file := range files {
+			if file.IsDir() || !isNoteFileName(file.Name()) {
+				continue
+			}
+			fi, err := file.Info()
+			if err != nil {
+				continue
+			}
+			infos = append(infos, NoteInfo{
+				Name:      file.Name(),
+				DateDir:   dateDir.Name(),
+				Size:      fi.Size(),
+				ModTime:   fi.ModTime(),
+				CreatedAt: utils.GetFileCreationTime(fi),
+				IsBackup:  true,
+			})
+		}
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].DateDir != infos[j].DateDir {
+			return infos[i].DateDir < infos[j].DateDir
+		}
+		return infos[i].Name < infos[j].Name
+	})
+	return infos, nil
+}
+
+// Archive 将笔记移动到备份文件夹中的同名日期目录
+func (s *FileStore) Archive(info NoteInfo) error {
+	sourcePath := filepath.Join(s.SavePath, info.DateDir)
+	backupPath := filepath.Join(s.BackupPath, info.DateDir)
+	if err := os.MkdirAll(backupPath, 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(filepath.Join(sourcePath, info.Name), filepath.Join(backupPath, info.Name)); err != nil {
+		return err
+	}
+
+	// 从索引中移除笔记，删除空的源目录（目录不为空时删除会失败，忽略错误）
+	if value, exists := s.index.Load(info.Name); exists && value.(string) == info.DateDir {
+		s.deleteIndex(info.Name)
+	}
+	os.Remove(sourcePath)
+	return nil
+}
+
+// Close 将索引日志合并到快照并关闭日志文件
+func (s *FileStore) Close() error {
+	s.indexLock.Lock()
+	defer s.indexLock.Unlock()
+	err := s.compactIndex()
+	if s.journal != nil {
+		s.journal.Close()
+		s.journal = nil
+	}
+	return err
+}
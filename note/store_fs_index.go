@@ -0,0 +1,259 @@
+package note
+
+import (
+	"bufio"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// 笔记索引由快照（.notes_index，JSON 对象 noteName -> dateDir）和追加写入的日志组成
+// 每次保存或删除只向日志追加一行，日志条目达到阈值时才重写快照（写入临时文件后原子替换）
+// 进程崩溃时最多丢失日志最后一行未写完的条目，加载时会被跳过
+
+// indexCompactThreshold 日志条目数达到该值时合并到快照
+const indexCompactThreshold = 1000
+
+// indexJournalEntry 索引日志中的一行，DateDir 为空表示删除
+type indexJournalEntry struct {
+	Name    string `json:"name"`
+	DateDir string `json:"date_dir,omitempty"`
+}
+
+// loadIndex 加载索引快照并重放日志
+func (s *FileStore) loadIndex() {
+	s.indexLock.Lock()
+	defer s.indexLock.Unlock()
+
+	data, err := os.ReadFile(s.indexFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// 索引文件不存在，扫描目录重建索引
+			s.rebuildIndex()
+			return
+		}
+		log.Printf("Error reading note index: %v", err)
+		return
+	}
+
+	var index map[string]string
+	if err := json.Unmarshal(data, &index); err != nil {
+		log.Printf("Error parsing note index: %v, rebuilding...", err)
+		s.rebuildIndex()
+		return
+	}
+
+	// 加载到内存
+	for noteName, dateDir := range index {
+		s.index.Store(noteName, dateDir)
+	}
+
+	// 重放快照之后的日志
+	// 有无效的行时也要合并：日志被删除后，之后追加的条目不会接在写了一半的行后面
+	replayed, skipped := s.replayJournal()
+	log.Printf("Loaded %d notes from index (%d journal entries)", len(index), replayed)
+	if replayed > 0 || skipped > 0 {
+		if err := s.compactIndex(); err != nil {
+			log.Printf("Error compacting note index: %v", err)
+		}
+	}
+}
+
+// replayJournal 将日志中的条目应用到内存索引，返回应用的条目数和跳过的行数
+// 无法解析的行（例如崩溃时写了一半的最后一行）会被跳过
+func (s *FileStore) replayJournal() (int, int) {
+	file, err := os.Open(s.journalFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Error reading note index journal: %v", err)
+		}
+		return 0, 0
+	}
+	defer file.Close()
+
+	count, skipped := 0, 0
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 4096), 1024*1024)
+	for scanner.Scan() {
+		var entry indexJournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil || entry.Name == "" {
+			log.Printf("Skipping invalid note index journal entry: %q", scanner.Text())
+			skipped++
+			continue
+		}
+		if entry.DateDir == "" {
+			s.index.Delete(entry.Name)
+		} else {
+			s.index.Store(entry.Name, entry.DateDir)
+		}
+		count++
+	}
+	if err := scanner.Err(); err != nil {
+		log.Printf("Error reading note index journal: %v", err)
+	}
+	s.journalEntries = count
+	return count, skipped
+}
+
+// setIndex 记录笔记所在的日期目录
+func (s *FileStore) setIndex(name, dateDir string) {
+	s.indexLock.Lock()
+	defer s.indexLock.Unlock()
+	s.index.Store(name, dateDir)
+	s.appendJournal([]indexJournalEntry{{Name: name, DateDir: dateDir}})
+}
+
+// deleteIndex 从索引中移除笔记
+func (s *FileStore) deleteIndex(names ...string) {
+	if len(names) == 0 {
+		return
+	}
+	s.indexLock.Lock()
+	defer s.indexLock.Unlock()
+	entries := make([]indexJournalEntry, 0, len(names))
+	for _, name := range names {
+		s.index.Delete(name)
+		entries = append(entries, indexJournalEntry{Name: name})
+	}
+	s.appendJournal(entries)
+}
+
+// appendJournal 向日志追加条目，调用者必须已经持有 indexLock
+func (s *FileStore) appendJournal(entries []indexJournalEntry) {
+	var data []byte
+	for _, entry := range entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			log.Printf("Error marshaling note index entry: %v", err)
+			return
+		}
+		data = append(data, line...)
+		data = append(data, '\n')
+	}
+
+	if s.journal == nil {
+		file, err := os.OpenFile(s.journalFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			log.Printf("Error opening note index journal: %v", err)
+			return
+		}
+		s.journal = file
+	}
+	// 多个条目一次写入，避免只写入一部分
+	if _, err := s.journal.Write(data); err != nil {
+		log.Printf("Error writing note index journal: %v", err)
+		return
+	}
+
+	s.journalEntries += len(entries)
+	if s.journalEntries >= indexCompactThreshold {
+		if err := s.compactIndex(); err != nil {
+			log.Printf("Error compacting note index: %v", err)
+		}
+	}
+}
+
+// compactIndex 将内存索引写入快照并清空日志，调用者必须已经持有 indexLock
+// 快照先写入临时文件并同步到磁盘，再原子替换旧快照，写入过程中崩溃不会损坏索引
+func (s *FileStore) compactIndex() error {
+	index := make(map[string]string)
+	s.index.Range(func(key, value interface{}) bool {
+		index[key.(string)] = value.(string)
+		return true
+	})
+
+	data, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(s.indexFile, data, 0644); err != nil {
+		return err
+	}
+
+	// 快照已包含日志中的所有条目，清空日志
+	if s.journal != nil {
+		s.journal.Close()
+		s.journal = nil
+	}
+	if err := os.Remove(s.journalFile); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	s.journalEntries = 0
+	return nil
+}
+
+// rebuildIndex 重建索引（扫描所有日期目录）
+// 注意：调用此函数时，调用者必须已经持有 indexLock
+func (s *FileStore) rebuildIndex() {
+	s.index = &sync.Map{}
+
+	// 确保 SavePath 目录存在
+	if err := os.MkdirAll(s.SavePath, 0755); err != nil {
+		log.Printf("Error creating save path: %v", err)
+		return
+	}
+
+	files, err := os.ReadDir(s.SavePath)
+	if err != nil {
+		log.Printf("Error reading save path: %v", err)
+		return
+	}
+
+	count := 0
+	for _, file := range files {
+		if !file.IsDir() || !isDateDir(file.Name()) {
+			continue
+		}
+
+		// 读取日期目录中的笔记
+		dirName := file.Name()
+		noteFiles, err := os.ReadDir(filepath.Join(s.SavePath, dirName))
+		if err != nil {
+			continue
+		}
+
+		for _, noteFile := range noteFiles {
+			if !noteFile.IsDir() && isNoteFileName(noteFile.Name()) {
+				s.index.Store(noteFile.Name(), dirName)
+				count++
+			}
+		}
+	}
+
+	// 保存重建的索引（不获取锁，因为调用者已经持有）
+	if err := s.compactIndex(); err != nil {
+		log.Printf("Error saving note index: %v", err)
+		return
+	}
+	log.Printf("Rebuilt note index with %d notes", count)
+}
+
+// writeFileAtomic 写入临时文件并同步后重命名为目标文件
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
@@ -0,0 +1,321 @@
+package note
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// TrashedNote 回收站中的一份笔记
+// 文件格式：trash/笔记名称/删除时间（UnixNano），内容为元数据 JSON + "\n" + 笔记内容（包含锁标记）
+type TrashedNote struct {
+	ID        string    `json:"id"` // 删除时间的 UnixNano
+	Name      string    `json:"name"`
+	DateDir   string    `json:"date_dir"`  // 删除前所在的日期目录
+	IsBackup  bool      `json:"is_backup"` // 删除前是否在备份文件夹
+	Size      int64     `json:"size"`
+	Locked    bool      `json:"locked"`
+	UpdatedAt time.Time `json:"updated_at"` // 删除前的修改时间
+	DeletedAt time.Time `json:"deleted_at"`
+	DeletedBy string    `json:"deleted_by,omitempty"` // 删除笔记的用户名，管理员为 "@admin"
+}
+
+// trashMeta 回收站文件头部的元数据
+type trashMeta struct {
+	DateDir   string    `json:"date_dir"`
+	IsBackup  bool      `json:"is_backup"`
+	Locked    bool      `json:"locked"`
+	ModTime   time.Time `json:"mod_time"`
+	DeletedBy string    `json:"deleted_by,omitempty"`
+}
+
+// getTrashDir 获取笔记在回收站中的目录
+func (m *Manager) getTrashDir(name string) string {
+	return filepath.Join(m.TrashPath, name)
+}
+
+// moveToTrash 把即将删除的笔记写入回收站
+func (m *Manager) moveToTrash(info NoteInfo, content, actor string) error {
+	header, err := json.Marshal(trashMeta{
+		DateDir:   info.DateDir,
+		IsBackup:  info.IsBackup,
+		Locked:    HasNoteLock(content),
+		ModTime:   info.ModTime,
+		DeletedBy: actor,
+	})
+	if err != nil {
+		return err
+	}
+	dir := m.getTrashDir(info.Name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	data := make([]byte, 0, len(header)+1+len(content))
+	data = append(data, header...)
+	data = append(data, '\n')
+	data = append(data, content...)
+	path := filepath.Join(dir, strconv.FormatInt(time.Now().UnixNano(), 10))
+	return writeFileAtomic(path, data, 0644)
+}
+
+// readTrashMeta 读取回收站文件的元数据
+func (m *Manager) readTrashMeta(name, id string) (TrashedNote, error) {
+	deletedAt, ok := parseRevisionID(id)
+	if !ok {
+		return TrashedNote{}, os.ErrNotExist
+	}
+	f, err := os.Open(filepath.Join(m.getTrashDir(name), id))
+	if err != nil {
+		return TrashedNote{}, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return TrashedNote{}, err
+	}
+	header, err := bufio.NewReader(f).ReadBytes('\n')
+	if err != nil {
+		return TrashedNote{}, fmt.Errorf("invalid trash file %s/%s", name, id)
+	}
+	var meta trashMeta
+	if err := json.Unmarshal(header, &meta); err != nil {
+		return TrashedNote{}, err
+	}
+	return TrashedNote{
+		ID:        id,
+		Name:      name,
+		DateDir:   meta.DateDir,
+		IsBackup:  meta.IsBackup,
+		Size:      fi.Size() - int64(len(header)),
+		Locked:    meta.Locked,
+		UpdatedAt: meta.ModTime,
+		DeletedAt: deletedAt,
+		DeletedBy: meta.DeletedBy,
+	}, nil
+}
+
+// listTrash 返回笔记在回收站中的所有副本（最近删除的优先）
+func (m *Manager) listTrash(name string) ([]TrashedNote, error) {
+	trashed := make([]TrashedNote, 0)
+	files, err := os.ReadDir(m.getTrashDir(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return trashed, nil
+		}
+		return nil, err
+	}
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		t, err := m.readTrashMeta(name, file.Name())
+		if err != nil {
+			continue
+		}
+		trashed = append(trashed, t)
+	}
+	sort.Slice(trashed, func(i, j int) bool {
+		return trashed[i].DeletedAt.After(trashed[j].DeletedAt)
+	})
+	return trashed, nil
+}
+
+// ListTrash 返回回收站中的所有笔记（最近删除的优先）
+func (m *Manager) ListTrash() ([]TrashedNote, error) {
+	trashed := make([]TrashedNote, 0)
+	if m.TrashPath == "" {
+		return trashed, nil
+	}
+	dirs, err := os.ReadDir(m.TrashPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return trashed, nil
+		}
+		return nil, err
+	}
+	for _, dir := range dirs {
+		if !dir.IsDir() || !m.IsSafeNoteName(dir.Name()) {
+			continue
+		}
+		notes, err := m.listTrash(dir.Name())
+		if err != nil {
+			continue
+		}
+		trashed = append(trashed, notes...)
+	}
+	sort.Slice(trashed, func(i, j int) bool {
+		return trashed[i].DeletedAt.After(trashed[j].DeletedAt)
+	})
+	return trashed, nil
+}
+
+// findTrash 查找回收站中的笔记，id 为空时返回最近删除的副本
+func (m *Manager) findTrash(name, id string) (TrashedNote, error) {
+	if m.TrashPath == "" {
+		return TrashedNote{}, os.ErrNotExist
+	}
+	if id != "" {
+		return m.readTrashMeta(name, id)
+	}
+	trashed, err := m.listTrash(name)
+	if err != nil {
+		return TrashedNote{}, err
+	}
+	if len(trashed) == 0 {
+		return TrashedNote{}, os.ErrNotExist
+	}
+	return trashed[0], nil
+}
+
+// FindTrash 查找回收站中的笔记，id 为空时返回最近删除的副本，不存在时返回 os.ErrNotExist
+func (m *Manager) FindTrash(name, id string) (TrashedNote, error) {
+	m.trashLock.Lock()
+	defer m.trashLock.Unlock()
+	return m.findTrash(name, id)
+}
+
+// RestoreFromTrash 把回收站中的笔记恢复为活跃笔记（保存到当前日期目录，保留锁），并从回收站中移除
+// id 为空时恢复最近删除的副本；同名的活跃笔记已存在时返回包装 os.ErrExist 的错误，不覆盖现有笔记
+func (m *Manager) RestoreFromTrash(name, id, actor string) (TrashedNote, error) {
+	m.snapshotLock.RLock()
+	defer m.snapshotLock.RUnlock()
+	m.trashLock.Lock()
+	defer m.trashLock.Unlock()
+	defer m.lockNote(name)()
+
+	t, err := m.findTrash(name, id)
+	if err != nil {
+		return TrashedNote{}, err
+	}
+	if info, err := m.Store.Stat(name); err == nil && !info.IsBackup {
+		return TrashedNote{}, fmt.Errorf("note %s already exists: %w", name, os.ErrExist)
+	}
+
+	path := filepath.Join(m.getTrashDir(name), t.ID)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return TrashedNote{}, err
+	}
+	idx := bytes.IndexByte(data, '\n')
+	if idx < 0 {
+		return TrashedNote{}, fmt.Errorf("invalid trash file %s/%s", name, t.ID)
+	}
+	if err := m.saveNote(name, string(data[idx+1:]), actor); err != nil {
+		return TrashedNote{}, err
+	}
+	m.removeTrash(name, t.ID)
+	return t, nil
+}
+
+// PurgeTrash 永久删除回收站中的笔记，id 为空时删除该笔记的所有副本，返回删除的数量
+func (m *Manager) PurgeTrash(name, id string) (int, error) {
+	m.snapshotLock.RLock()
+	defer m.snapshotLock.RUnlock()
+	m.trashLock.Lock()
+	defer m.trashLock.Unlock()
+
+	if id != "" {
+		if _, err := m.readTrashMeta(name, id); err != nil {
+			return 0, err
+		}
+		m.removeTrash(name, id)
+		return 1, nil
+	}
+	trashed, err := m.listTrash(name)
+	if err != nil {
+		return 0, err
+	}
+	if len(trashed) == 0 {
+		return 0, os.ErrNotExist
+	}
+	for _, t := range trashed {
+		m.removeTrash(name, t.ID)
+	}
+	return len(trashed), nil
+}
+
+// PurgeExpiredTrash 永久删除在回收站中超过保留天数的笔记
+func (m *Manager) PurgeExpiredTrash() (int, error) {
+	days := m.getTrashDays()
+	if days <= 0 {
+		return 0, nil
+	}
+	m.snapshotLock.RLock()
+	defer m.snapshotLock.RUnlock()
+	trashed, err := m.ListTrash()
+	if err != nil {
+		return 0, err
+	}
+
+	m.trashLock.Lock()
+	defer m.trashLock.Unlock()
+	cutoffTime := time.Now().AddDate(0, 0, -days)
+	purged := 0
+	for _, t := range trashed {
+		if t.DeletedAt.Before(cutoffTime) {
+			m.removeTrash(t.Name, t.ID)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// removeTrash 删除回收站文件，笔记不再有任何副本时删除它的所有权记录
+func (m *Manager) removeTrash(name, id string) {
+	if err := os.Remove(filepath.Join(m.getTrashDir(name), id)); err != nil && !os.IsNotExist(err) {
+		log.Printf("Failed to remove trashed note %s/%s: %v", name, id, err)
+		return
+	}
+	// 目录不为空时删除会失败，忽略错误
+	os.Remove(m.getTrashDir(name))
+	m.deleteUnusedRecords(name)
+	m.deleteUnusedRevisions(name)
+}
+
+// isNoteUnused 检查同名笔记是否已不存在（包括备份文件夹和回收站）
+func (m *Manager) isNoteUnused(name string) bool {
+	if _, err := m.Store.Stat(name); !os.IsNotExist(err) {
+		return false
+	}
+	if m.TrashPath != "" {
+		if _, err := os.Stat(m.getTrashDir(name)); err == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// deleteUnusedRecords 同名笔记已不存在（包括备份文件夹和回收站）时删除所有权记录和元数据
+func (m *Manager) deleteUnusedRecords(name string) {
+	if m.ACL == nil && m.Meta == nil {
+		return
+	}
+	if !m.isNoteUnused(name) {
+		return
+	}
+	if m.ACL != nil {
+		if err := m.ACL.Delete(name); err != nil {
+			log.Printf("Failed to delete ACL of note %s: %v", name, err)
+		}
+	}
+	if m.Meta != nil {
+		if err := m.Meta.Delete(name); err != nil {
+			log.Printf("Failed to delete metadata of note %s: %v", name, err)
+		}
+	}
+}
+
+// getTrashDays 获取回收站保留天数（0 表示不自动清理）
+func (m *Manager) getTrashDays() int {
+	if m.GetTrashDays == nil {
+		return 0
+	}
+	return m.GetTrashDays()
+}
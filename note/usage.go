@@ -0,0 +1,131 @@
+package note
+
+import (
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// uploadRefPattern 匹配笔记内容中的上传文件链接（/uploads/YYYYMMDD/文件名 或旧格式 /uploads/文件名）
+// 链接在空白或 Markdown/HTML 的分隔符处结束
+var uploadRefPattern = regexp.MustCompile(`/uploads/([^\s()\[\]<>"'` + "`" + `]+)`)
+
+// DiskUsage 笔记数据的磁盘占用（活跃笔记计入总大小限制，其余不计入）
+type DiskUsage struct {
+	ActiveCount   int   `json:"active_count"`
+	ActiveSize    int64 `json:"active_size"`
+	ArchivedCount int   `json:"archived_count"`
+	ArchivedSize  int64 `json:"archived_size"`
+	TrashCount    int   `json:"trash_count"`
+	TrashSize     int64 `json:"trash_size"`
+	RevisionCount int   `json:"revision_count"`
+	RevisionSize  int64 `json:"revision_size"`
+}
+
+// DiskUsage 统计活跃笔记、备份文件夹、回收站和修订版本的数量和大小
+func (m *Manager) DiskUsage() (DiskUsage, error) {
+	var usage DiskUsage
+	for _, archived := range []bool{false, true} {
+		infos, err := m.Store.List(archived)
+		if err != nil {
+			return usage, err
+		}
+		for _, info := range infos {
+			if archived {
+				usage.ArchivedCount++
+				usage.ArchivedSize += info.Size
+			} else {
+				usage.ActiveCount++
+				usage.ActiveSize += info.Size
+			}
+		}
+	}
+	var err error
+	if usage.TrashCount, usage.TrashSize, err = countFiles(m.TrashPath); err != nil {
+		return usage, err
+	}
+	if usage.RevisionCount, usage.RevisionSize, err = countFiles(m.RevisionPath); err != nil {
+		return usage, err
+	}
+	return usage, nil
+}
+
+// ReferencedUploads 返回笔记引用的上传文件（相对上传目录的路径，如 20240101/123-a.png）
+// 活跃笔记、备份笔记、回收站和修订版本中的引用都会计入，这样恢复笔记后附件仍然可用
+// 链接中的文件名包含空白时只能匹配到空白之前的部分，调用者应把以引用开头的文件也视为被引用
+func (m *Manager) ReferencedUploads() (map[string]bool, error) {
+	refs := make(map[string]bool)
+	addRefs := func(content string) {
+		for _, match := range uploadRefPattern.FindAllStringSubmatch(content, -1) {
+			refs[match[1]] = true
+			if unescaped, err := url.PathUnescape(match[1]); err == nil {
+				refs[unescaped] = true
+			}
+		}
+	}
+
+	for _, archived := range []bool{false, true} {
+		infos, err := m.Store.List(archived)
+		if err != nil {
+			return nil, err
+		}
+		for _, info := range infos {
+			content, err := m.Store.Load(info)
+			if err != nil {
+				return nil, err
+			}
+			addRefs(content)
+		}
+	}
+	for _, dir := range []string{m.TrashPath, m.RevisionPath} {
+		if err := walkFiles(dir, func(path string, _ fs.FileInfo) error {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			addRefs(string(data))
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return refs, nil
+}
+
+// countFiles 统计目录中所有文件的数量和大小，目录为空字符串或不存在时返回 0
+func countFiles(dir string) (int, int64, error) {
+	count := 0
+	var size int64
+	err := walkFiles(dir, func(_ string, info fs.FileInfo) error {
+		count++
+		size += info.Size()
+		return nil
+	})
+	return count, size, err
+}
+
+// walkFiles 遍历目录中的所有文件，目录为空字符串或不存在时不做任何事
+func walkFiles(dir string, fn func(path string, info fs.FileInfo) error) error {
+	if dir == "" {
+		return nil
+	}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return fn(path, info)
+	})
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
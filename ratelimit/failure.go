@@ -0,0 +1,27 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+)
+
+type failureKey struct{}
+
+// failureMarker 在一次请求中记录 handler 是否报告了认证失败
+type failureMarker struct {
+	failed bool
+}
+
+// TrackFailures 返回带有失败标记的请求，以及在 handler 返回后检查是否报告了认证失败的函数
+func TrackFailures(r *http.Request) (*http.Request, func() bool) {
+	marker := &failureMarker{}
+	return r.WithContext(context.WithValue(r.Context(), failureKey{}, marker)), func() bool { return marker.failed }
+}
+
+// MarkFailure 报告请求提供了错误的凭据
+// 用于不返回 401 的失败（例如显示锁定页面或重定向到登录页面），请求没有经过限流中间件时不做任何事
+func MarkFailure(r *http.Request) {
+	if marker, ok := r.Context().Value(failureKey{}).(*failureMarker); ok {
+		marker.failed = true
+	}
+}
@@ -0,0 +1,422 @@
+package ratelimit
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// pruneInterval 清理已经恢复满的令牌桶和过期失败记录的间隔
+const pruneInterval = time.Minute
+
+// 锁定对象的类型
+const (
+	KindIP   = "ip"
+	KindNote = "note"
+)
+
+// Config 限流配置（保存在 config.json 的 rateLimit 中）
+type Config struct {
+	Enabled               bool     `json:"enabled"`
+	IPRequestsPerMinute   int      `json:"ipRequestsPerMinute"`   // 每个客户端 IP 每分钟的请求数
+	IPBurst               int      `json:"ipBurst"`               // 每个客户端 IP 允许的突发请求数
+	NoteRequestsPerMinute int      `json:"noteRequestsPerMinute"` // 每个笔记每分钟的请求数（所有客户端合计）
+	NoteBurst             int      `json:"noteBurst"`             // 每个笔记允许的突发请求数
+	MaxFailures           int      `json:"maxFailures"`           // 连续认证失败多少次后开始锁定
+	LockoutSeconds        int      `json:"lockoutSeconds"`        // 第一次锁定的时长，之后每次失败翻倍
+	MaxLockoutSeconds     int      `json:"maxLockoutSeconds"`     // 锁定时长的上限，超过这个时间没有失败时清零失败次数
+	TrustedProxies        []string `json:"trustedProxies"`        // 可信反向代理的 IP 或 CIDR，只有来自这些地址的 X-Forwarded-For 才会被使用
+}
+
+// DefaultConfig 返回默认限流配置
+func DefaultConfig() Config {
+	return Config{
+		Enabled:               true,
+		IPRequestsPerMinute:   300,
+		IPBurst:               150,
+		NoteRequestsPerMinute: 120,
+		NoteBurst:             60,
+		MaxFailures:           5,
+		LockoutSeconds:        30,
+		MaxLockoutSeconds:     3600,
+		TrustedProxies:        []string{},
+	}
+}
+
+// normalize 将无效的数值替换为默认值
+func (c Config) normalize() Config {
+	d := DefaultConfig()
+	if c.IPRequestsPerMinute <= 0 {
+		c.IPRequestsPerMinute = d.IPRequestsPerMinute
+	}
+	if c.IPBurst <= 0 {
+		c.IPBurst = d.IPBurst
+	}
+	if c.NoteRequestsPerMinute <= 0 {
+		c.NoteRequestsPerMinute = d.NoteRequestsPerMinute
+	}
+	if c.NoteBurst <= 0 {
+		c.NoteBurst = d.NoteBurst
+	}
+	if c.MaxFailures <= 0 {
+		c.MaxFailures = d.MaxFailures
+	}
+	if c.LockoutSeconds <= 0 {
+		c.LockoutSeconds = d.LockoutSeconds
+	}
+	if c.MaxLockoutSeconds < c.LockoutSeconds {
+		c.MaxLockoutSeconds = c.LockoutSeconds
+	}
+	if c.TrustedProxies == nil {
+		c.TrustedProxies = []string{}
+	}
+	return c
+}
+
+// Lockout 表示一个被锁定的客户端 IP 或笔记
+type Lockout struct {
+	Kind     string    `json:"kind"`  // ip 或 note
+	Value    string    `json:"value"` // IP 地址或笔记名称
+	Failures int       `json:"failures"`
+	Until    time.Time `json:"until"`
+}
+
+// bucket 令牌桶
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// take 取出一个令牌，令牌不足时返回需要等待的时间
+func (b *bucket) take(now time.Time, perMinute, burst int) time.Duration {
+	rate := float64(perMinute) / 60
+	b.tokens += now.Sub(b.updated).Seconds() * rate
+	if b.tokens > float64(burst) {
+		b.tokens = float64(burst)
+	}
+	b.updated = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / rate * float64(time.Second))
+}
+
+// failureState 认证失败记录
+type failureState struct {
+	count       int
+	last        time.Time
+	lockedUntil time.Time
+}
+
+// Limiter 按客户端 IP 和笔记限流，并在连续认证失败后按指数增长的时长锁定
+type Limiter struct {
+	mu          sync.Mutex
+	cfg         Config
+	trusted     []*net.IPNet
+	ipBuckets   map[string]*bucket
+	noteBuckets map[string]*bucket
+	failures    map[string]*failureState // kind + ":" + value -> 失败记录
+	lastPrune   time.Time
+
+	// 笔记 -> 在这个笔记上认证失败过的客户端 IP -> 最后一次失败的时间
+	noteClients map[string]map[string]time.Time
+}
+
+// New 创建限流器
+func New() *Limiter {
+	return &Limiter{
+		cfg:         DefaultConfig(),
+		ipBuckets:   make(map[string]*bucket),
+		noteBuckets: make(map[string]*bucket),
+		failures:    make(map[string]*failureState),
+		noteClients: make(map[string]map[string]time.Time),
+	}
+}
+
+// Config 返回当前的限流配置
+func (l *Limiter) Config() Config {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	cfg := l.cfg
+	cfg.TrustedProxies = append([]string{}, l.cfg.TrustedProxies...)
+	return cfg
+}
+
+// SetConfig 更新限流配置，可信代理地址无效时返回错误且不修改配置
+func (l *Limiter) SetConfig(cfg Config) error {
+	cfg = cfg.normalize()
+	trusted, err := parseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.cfg = cfg
+	l.trusted = trusted
+	return nil
+}
+
+// Allow 检查请求是否允许通过，不允许时返回需要等待的时间以及是否因为认证失败被锁定
+// note 为空时只检查客户端 IP；笔记的锁定由 NoteLockedFor 单独检查
+func (l *Limiter) Allow(ip, note string) (wait time.Duration, locked bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.cfg.Enabled {
+		return 0, false
+	}
+
+	now := time.Now()
+	l.prune(now)
+
+	if wait := l.lockedFor(KindIP, ip, now); wait > 0 {
+		return wait, true
+	}
+
+	b, ok := l.ipBuckets[ip]
+	if !ok {
+		b = &bucket{tokens: float64(l.cfg.IPBurst), updated: now}
+		l.ipBuckets[ip] = b
+	}
+	if wait := b.take(now, l.cfg.IPRequestsPerMinute, l.cfg.IPBurst); wait > 0 {
+		return wait, false
+	}
+	if note != "" {
+		b, ok := l.noteBuckets[note]
+		if !ok {
+			b = &bucket{tokens: float64(l.cfg.NoteBurst), updated: now}
+			l.noteBuckets[note] = b
+		}
+		if wait := b.take(now, l.cfg.NoteRequestsPerMinute, l.cfg.NoteBurst); wait > 0 {
+			return wait, false
+		}
+	}
+	return 0, false
+}
+
+// NoteLockedFor 返回客户端 IP 访问笔记需要等待的时间
+// 笔记因连续认证失败被锁定时，只阻止在这个笔记上失败过的 IP，其他客户端（包括笔记的所有者）不受影响
+func (l *Limiter) NoteLockedFor(ip, note string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.cfg.Enabled || note == "" {
+		return 0
+	}
+	if _, failed := l.noteClients[note][ip]; !failed {
+		return 0
+	}
+	return l.lockedFor(KindNote, note, time.Now())
+}
+
+// Failure 记录一次认证失败（错误的管理员令牌、访问令牌、API key、锁令牌或用户密码）
+// note 不为空时同时计入笔记，防止从多个 IP 猜测同一个笔记的锁令牌
+func (l *Limiter) Failure(ip, note string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.cfg.Enabled {
+		return
+	}
+
+	now := time.Now()
+	l.recordFailure(KindIP, ip, now)
+	if note != "" {
+		l.recordFailure(KindNote, note, now)
+		if l.noteClients[note] == nil {
+			l.noteClients[note] = make(map[string]time.Time)
+		}
+		l.noteClients[note][ip] = now
+	}
+}
+
+// Lockouts 返回当前被锁定的客户端 IP 和笔记，按解锁时间排序
+func (l *Limiter) Lockouts() []Lockout {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	lockouts := make([]Lockout, 0)
+	for key, st := range l.failures {
+		if !now.Before(st.lockedUntil) {
+			continue
+		}
+		kind, value, _ := strings.Cut(key, ":")
+		lockouts = append(lockouts, Lockout{Kind: kind, Value: value, Failures: st.count, Until: st.lockedUntil})
+	}
+	sort.Slice(lockouts, func(i, j int) bool { return lockouts[i].Until.Before(lockouts[j].Until) })
+	return lockouts
+}
+
+// Unlock 解除客户端 IP 或笔记的锁定并清零失败次数，返回之前是否有失败记录
+func (l *Limiter) Unlock(kind, value string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	key := kind + ":" + value
+	if _, ok := l.failures[key]; !ok {
+		return false
+	}
+	delete(l.failures, key)
+	if kind == KindNote {
+		delete(l.noteClients, value)
+	}
+	return true
+}
+
+// ClientIP 返回请求的客户端 IP
+// 只有当直接连接的地址是可信代理时才使用 X-Forwarded-For，从右往左取第一个不是可信代理的地址
+func (l *Limiter) ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	l.mu.Lock()
+	trusted := l.trusted
+	l.mu.Unlock()
+	if len(trusted) == 0 || !isTrusted(trusted, host) {
+		return host
+	}
+
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		addr := strings.TrimSpace(forwarded[i])
+		if net.ParseIP(addr) == nil {
+			break
+		}
+		host = addr
+		if !isTrusted(trusted, addr) {
+			break
+		}
+	}
+	return host
+}
+
+// FromTrustedProxy 返回请求是否直接来自可信代理（可以信任 X-Forwarded-* header）
+func (l *Limiter) FromTrustedProxy(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	l.mu.Lock()
+	trusted := l.trusted
+	l.mu.Unlock()
+	return isTrusted(trusted, host)
+}
+
+// lockedFor 返回剩余的锁定时间，调用者必须持有锁
+func (l *Limiter) lockedFor(kind, value string, now time.Time) time.Duration {
+	st, ok := l.failures[kind+":"+value]
+	if !ok || !now.Before(st.lockedUntil) {
+		return 0
+	}
+	return st.lockedUntil.Sub(now)
+}
+
+// recordFailure 增加失败次数，达到上限后按 lockout * 2^(失败次数-上限) 锁定，调用者必须持有锁
+func (l *Limiter) recordFailure(kind, value string, now time.Time) {
+	key := kind + ":" + value
+	maxLockout := time.Duration(l.cfg.MaxLockoutSeconds) * time.Second
+	st, ok := l.failures[key]
+	if !ok || now.Sub(st.last) > maxLockout && !now.Before(st.lockedUntil) {
+		st = &failureState{}
+		l.failures[key] = st
+	}
+	st.count++
+	st.last = now
+	if st.count < l.cfg.MaxFailures {
+		return
+	}
+
+	lockout := time.Duration(l.cfg.LockoutSeconds) * time.Second
+	for i := l.cfg.MaxFailures; i < st.count && lockout < maxLockout; i++ {
+		lockout *= 2
+	}
+	if lockout > maxLockout {
+		lockout = maxLockout
+	}
+	st.lockedUntil = now.Add(lockout)
+}
+
+// prune 删除已经恢复满的令牌桶和过期的失败记录，调用者必须持有锁
+func (l *Limiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < pruneInterval {
+		return
+	}
+	l.lastPrune = now
+
+	ipRefill := time.Duration(float64(l.cfg.IPBurst) / float64(l.cfg.IPRequestsPerMinute) * float64(time.Minute))
+	for ip, b := range l.ipBuckets {
+		if now.Sub(b.updated) > ipRefill {
+			delete(l.ipBuckets, ip)
+		}
+	}
+	noteRefill := time.Duration(float64(l.cfg.NoteBurst) / float64(l.cfg.NoteRequestsPerMinute) * float64(time.Minute))
+	for note, b := range l.noteBuckets {
+		if now.Sub(b.updated) > noteRefill {
+			delete(l.noteBuckets, note)
+		}
+	}
+	maxLockout := time.Duration(l.cfg.MaxLockoutSeconds) * time.Second
+	for key, st := range l.failures {
+		if now.Sub(st.last) > maxLockout && !now.Before(st.lockedUntil) {
+			delete(l.failures, key)
+		}
+	}
+	for note, clients := range l.noteClients {
+		if l.lockedFor(KindNote, note, now) > 0 {
+			continue
+		}
+		for ip, last := range clients {
+			if now.Sub(last) > maxLockout {
+				delete(clients, ip)
+			}
+		}
+		if len(clients) == 0 {
+			delete(l.noteClients, note)
+		}
+	}
+}
+
+// parseTrustedProxies 解析可信代理列表（IP 或 CIDR）
+func parseTrustedProxies(proxies []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(proxies))
+	for _, p := range proxies {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if !strings.Contains(p, "/") {
+			ip := net.ParseIP(p)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy: %s", p)
+			}
+			if ip.To4() != nil {
+				p += "/32"
+			} else {
+				p += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy: %s", p)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+func isTrusted(trusted []*net.IPNet, addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, n := range trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,23 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/hello--world/jot/handlers"
+)
+
+// csrfProtect 中间件：读取请求时下发 CSRF token，修改请求需要通过 CSRF 检查
+func csrfProtect(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, handlers.EnsureCSRFToken(w, r))
+			return
+		}
+		if !handlers.VerifyCSRF(r) {
+			http.Error(w, "Forbidden: CSRF token missing or invalid", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, handlers.EnsureCSRFToken(w, r))
+	})
+}
@@ -0,0 +1,95 @@
+package router
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/hello--world/jot/handlers"
+	"github.com/hello--world/jot/ratelimit"
+)
+
+// statusRecorder 记录响应状态码，并保留 WebSocket 升级和流式响应需要的接口
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := s.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	return hijacker.Hijack()
+}
+
+func (s *statusRecorder) Flush() {
+	if flusher, ok := s.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// hasCredential 检查请求是否提供了访问令牌、API key 或笔记的锁令牌
+// 没有提供凭据的 401（例如未登录）不算作认证失败
+func hasCredential(r *http.Request, noteName string) bool {
+	if r.Header.Get("Authorization") != "" || r.Header.Get("X-Lock-Token") != "" {
+		return true
+	}
+	query := r.URL.Query()
+	if query.Get("token") != "" || query.Get("lock_token") != "" {
+		return true
+	}
+	if cookie, err := r.Cookie("access_token"); err == nil && cookie.Value != "" {
+		return true
+	}
+	if noteName != "" {
+		if cookie, err := r.Cookie("note_lock_" + noteName); err == nil && cookie.Value != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// rateLimit 中间件：按客户端 IP 和笔记限流，认证失败过多的 IP 和笔记被临时锁定（笔记只对失败过的 IP 锁定）
+// 带凭据的 401 响应和 handler 通过 ratelimit.MarkFailure 报告的失败计入失败次数
+func rateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limiter := handlers.RateLimiter(r)
+		ip := limiter.ClientIP(r)
+		noteName := mux.Vars(r)["note"]
+
+		if wait, locked := limiter.Allow(ip, noteName); wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			if locked {
+				http.Error(w, "Too many failed attempts, try again later", http.StatusTooManyRequests)
+			} else {
+				http.Error(w, "Too many requests", http.StatusTooManyRequests)
+			}
+			return
+		}
+		// 笔记被锁定时只阻止在这个笔记上失败过的 IP，带有效登录 session 的请求不受影响
+		if wait := limiter.NoteLockedFor(ip, noteName); wait > 0 && !handlers.HasSession(r) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, "Too many failed attempts, try again later", http.StatusTooManyRequests)
+			return
+		}
+
+		r, failed := ratelimit.TrackFailures(r)
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		if failed() || rec.status == http.StatusUnauthorized && hasCredential(r, noteName) {
+			limiter.Failure(ip, noteName)
+		}
+	})
+}
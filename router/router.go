@@ -6,39 +6,28 @@ import (
 
 	"github.com/gorilla/mux"
 
+	"github.com/hello--world/jot/apikey"
 	"github.com/hello--world/jot/handlers"
 	"github.com/hello--world/jot/htmlPage"
+	"github.com/hello--world/jot/ratelimit"
 )
 
-// requireAccessToken 中间件：如果站点有 access token，需要验证
-func requireAccessToken(next http.Handler, getAccessToken func() string) http.Handler {
+// requireScope 中间件：如果站点需要认证，需要 access token 或拥有指定权限的 API key
+func requireScope(next http.Handler, scope string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		accessToken := getAccessToken()
-		if accessToken != "" {
-			token := r.URL.Query().Get("token")
-			if token == "" {
-				authHeader := r.Header.Get("Authorization")
-				if strings.HasPrefix(authHeader, "Bearer ") {
-					token = strings.TrimPrefix(authHeader, "Bearer ")
-				}
-			}
-			token = strings.TrimSpace(token)
-			if token != accessToken {
-				http.Error(w, "Unauthorized: Access token required", http.StatusUnauthorized)
-				return
-			}
+		if !handlers.Authorize(r, scope) {
+			http.Error(w, "Unauthorized: Access token required", http.StatusUnauthorized)
+			return
 		}
 		next.ServeHTTP(w, r)
 	})
 }
 
 // RouterConfig 路由配置
+// 笔记存储、上传目录、限流器和 WebSocket 按请求的 Host 属于不同的工作区，由 handlers 包选择
 type RouterConfig struct {
-	AdminPath        string
-	UploadPath       string
-	HandleWebSocket  func(http.ResponseWriter, *http.Request)
-	GenerateNoteName func() string
-	GetAccessToken   func() string
+	AdminPath  string
+	HSTSMaxAge int // 通过 HTTPS 访问时 Strict-Transport-Security 的 max-age（秒），0 表示不发送
 }
 
 var config *RouterConfig
@@ -51,6 +40,9 @@ func InitRouter(c *RouterConfig) {
 // SetupRoutes 设置所有路由
 func SetupRoutes() *mux.Router {
 	r := mux.NewRouter()
+	r.Use(hsts)
+	r.Use(rateLimit)
+	r.Use(csrfProtect)
 
 	// Admin routes (must be before /{note} route)
 	r.HandleFunc(config.AdminPath, handlers.HandleAdmin).Methods("GET")
@@ -58,8 +50,12 @@ func SetupRoutes() *mux.Router {
 	// Read-only route (must be before /{note} route)
 	r.HandleFunc("/read/{note}", handlers.HandleReadNote).Methods("GET")
 
+	// User login routes (must be before /{note} route)
+	r.HandleFunc("/login", handlers.HandleUserLogin).Methods("GET", "POST")
+	r.HandleFunc("/logout", handlers.HandleUserLogout).Methods("POST")
+
 	// WebSocket route
-	r.HandleFunc("/ws/{note}", config.HandleWebSocket)
+	r.HandleFunc("/ws/{note}", handlers.HandleWebSocket)
 
 	// Markdown render route
 	r.HandleFunc("/api/markdown", handlers.HandleMarkdownRender).Methods("POST")
@@ -70,13 +66,86 @@ func SetupRoutes() *mux.Router {
 	// File download route with date directory: /uploads/{date}/{filename}
 	r.HandleFunc("/uploads/{date}/{filename}", handlers.HandleFileDownload).Methods("GET")
 
+	// Note revision routes
+	r.HandleFunc("/api/notes/{note}/revisions", handlers.HandleListRevisions).Methods("GET")
+	r.HandleFunc("/api/notes/{note}/revisions/{revision}", handlers.HandleGetRevision).Methods("GET")
+	r.HandleFunc("/api/notes/{note}/revisions/{revision}/restore", handlers.HandleRestoreRevision).Methods("POST")
+	r.HandleFunc("/api/notes/{note}/diff", handlers.HandleDiffRevisions).Methods("GET")
+
+	// Archived note and archive policy routes
+	r.HandleFunc("/api/notes/{note}/unarchive", handlers.HandleUnarchiveNote).Methods("POST")
+	r.HandleFunc("/api/notes/{note}/pin", handlers.HandlePinNote).Methods("POST")
+	r.HandleFunc("/api/archive/plan", handlers.HandleArchivePlan).Methods("GET")
+	r.HandleFunc("/api/archive/usage", handlers.HandleDiskUsage).Methods("GET")
+
+	// Trash routes
+	r.HandleFunc("/api/trash", handlers.HandleListTrash).Methods("GET")
+	r.HandleFunc("/api/trash/{note}/restore", handlers.HandleRestoreTrash).Methods("POST")
+	r.HandleFunc("/api/trash/{note}/purge", handlers.HandlePurgeTrash).Methods("POST")
+
+	// Snapshot export and import routes
+	r.HandleFunc("/api/snapshot/export", handlers.HandleExportSnapshot).Methods("GET")
+	r.HandleFunc("/api/snapshot/import", handlers.HandleImportSnapshot).Methods("POST")
+
+	// Note sharing routes
+	r.HandleFunc("/api/notes/{note}/sharing", handlers.HandleGetSharing).Methods("GET")
+	r.HandleFunc("/api/notes/{note}/sharing", handlers.HandleUpdateSharing).Methods("POST")
+
+	// Note event streams (Server-Sent Events)
+	r.HandleFunc("/api/notes/{note}/events", handlers.HandleNoteEvents).Methods("GET")
+	r.HandleFunc("/api/events", handlers.HandleEvents).Methods("GET")
+
+	// Current user routes
+	r.HandleFunc("/api/me", handlers.HandleCurrentUser).Methods("GET")
+	r.HandleFunc("/api/me/password", handlers.HandleChangePassword).Methods("POST")
+
+	// Versioned JSON REST API
+	r.HandleFunc("/api/v1/notes", handlers.HandleAPINotes)
+	r.HandleFunc("/api/v1/notes/{note}", handlers.HandleAPINote)
+	r.HandleFunc("/api/v1/notes/{note}/meta", handlers.HandleAPINoteMeta)
+	r.PathPrefix("/api/v1/").HandlerFunc(handlers.HandleAPINotFound)
+
+	// Full-text search route
+	r.HandleFunc("/api/search", handlers.HandleSearch).Methods("GET")
+
+	// API key management routes (admin only)
+	r.HandleFunc("/api/keys", handlers.HandleListAPIKeys).Methods("GET")
+	r.HandleFunc("/api/keys", handlers.HandleCreateAPIKey).Methods("POST")
+	r.HandleFunc("/api/keys/{id}/revoke", handlers.HandleRevokeAPIKey).Methods("POST")
+
+	// Webhook management routes (admin only)
+	r.HandleFunc("/api/webhooks", handlers.HandleListWebhooks).Methods("GET")
+	r.HandleFunc("/api/webhooks", handlers.HandleCreateWebhook).Methods("POST")
+	r.HandleFunc("/api/webhooks/{id}/delete", handlers.HandleDeleteWebhook).Methods("POST")
+	r.HandleFunc("/api/webhooks/{id}/test", handlers.HandleTestWebhook).Methods("POST")
+
+	// User management routes (admin only)
+	r.HandleFunc("/api/users", handlers.HandleListUsers).Methods("GET")
+	r.HandleFunc("/api/users", handlers.HandleCreateUser).Methods("POST")
+	r.HandleFunc("/api/users/{username}/delete", handlers.HandleDeleteUser).Methods("POST")
+	r.HandleFunc("/api/users/{username}/password", handlers.HandleResetUserPassword).Methods("POST")
+
+	// Login session management routes (admin only)
+	r.HandleFunc("/api/sessions", handlers.HandleListSessions).Methods("GET")
+	r.HandleFunc("/api/sessions/revoke-all", handlers.HandleRevokeAllSessions).Methods("POST")
+	r.HandleFunc("/api/sessions/{id}/revoke", handlers.HandleRevokeSession).Methods("POST")
+
+	// Rate limit status routes (admin only)
+	r.HandleFunc("/api/rate-limit", handlers.HandleRateLimitStatus).Methods("GET")
+	r.HandleFunc("/api/rate-limit/unlock", handlers.HandleRateLimitUnlock).Methods("POST")
+
+	// Workspace list route (admin of the default workspace only)
+	r.HandleFunc("/api/workspaces", handlers.HandleListWorkspaces).Methods("GET")
+
 	// Update max total size route (admin only)
 	r.HandleFunc("/api/max-total-size", handlers.HandleUpdateMaxTotalSize).Methods("POST")
 
 	// Static file server for uploads (需要 access token 验证)
 	// Support both old format (without date) and new format (with date)
-	uploadsHandler := http.StripPrefix("/uploads/", http.FileServer(http.Dir(config.UploadPath)))
-	r.PathPrefix("/uploads/").Handler(requireAccessToken(uploadsHandler, config.GetAccessToken))
+	uploadsHandler := http.StripPrefix("/uploads/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.FileServer(http.Dir(handlers.UploadPath(r))).ServeHTTP(w, r)
+	}))
+	r.PathPrefix("/uploads/").Handler(requireScope(uploadsHandler, apikey.ScopeRead))
 
 	// Note routes (must be after specific routes)
 	r.HandleFunc("/{note}", handlers.HandleNote).Methods("GET", "POST")
@@ -87,9 +156,8 @@ func SetupRoutes() *mux.Router {
 
 // handleRoot 处理根路径请求
 func handleRoot(w http.ResponseWriter, r *http.Request) {
-	// Check access token if set (only for browser requests, not curl/wget)
-	accessToken := config.GetAccessToken()
-	if accessToken != "" && !strings.HasPrefix(r.UserAgent(), "curl") && !strings.HasPrefix(r.UserAgent(), "Wget") {
+	// Check access token, API key or user login if required (only for browser requests, not curl/wget)
+	if handlers.AuthRequired(r) && handlers.CurrentUser(r) == "" && !strings.HasPrefix(r.UserAgent(), "curl") && !strings.HasPrefix(r.UserAgent(), "Wget") {
 		token := r.URL.Query().Get("token")
 		if token == "" {
 			authHeader := r.Header.Get("Authorization")
@@ -97,24 +165,27 @@ func handleRoot(w http.ResponseWriter, r *http.Request) {
 				token = strings.TrimPrefix(authHeader, "Bearer ")
 			}
 		}
-		if token != accessToken {
+		if !handlers.AuthorizeToken(r, token, apikey.ScopeRead) {
+			if token != "" {
+				ratelimit.MarkFailure(r)
+			}
 			// Show login page
 			w.Header().Set("Content-Type", "text/html; charset=utf-8")
 			w.Write([]byte(htmlPage.AccessLoginHTML))
 			return
 		}
 		// If token is valid, set cookie and redirect without token in URL
-		http.SetCookie(w, &http.Cookie{
+		handlers.SetCookie(w, r, &http.Cookie{
 			Name:     "access_token",
 			Value:    token,
 			Path:     "/",
 			MaxAge:   86400 * 30, // 30 days
-			HttpOnly: false,      // Allow JavaScript to read it
+			HttpOnly: true,       // 页面脚本使用 localStorage 中保存的令牌，cookie 只由浏览器自动发送
 			SameSite: http.SameSiteStrictMode,
 		})
-		noteName := config.GenerateNoteName()
+		noteName := handlers.GenerateNoteName(r)
 		http.Redirect(w, r, "/"+noteName, http.StatusFound)
 		return
 	}
-	http.Redirect(w, r, "/"+config.GenerateNoteName(), http.StatusFound)
+	http.Redirect(w, r, "/"+handlers.GenerateNoteName(r), http.StatusFound)
 }
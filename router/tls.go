@@ -0,0 +1,39 @@
+package router
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/hello--world/jot/handlers"
+)
+
+// hsts 中间件：通过 HTTPS 访问时返回 Strict-Transport-Security header，让浏览器以后只使用 HTTPS
+func hsts(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if config.HSTSMaxAge > 0 && handlers.IsSecureRequest(r) {
+			w.Header().Set("Strict-Transport-Security", "max-age="+strconv.Itoa(config.HSTSMaxAge))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// HTTPSRedirectHandler 把 HTTP 请求重定向到 HTTPS 端口上的相同地址
+func HTTPSRedirectHandler(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if host == "" {
+			http.Error(w, "Bad Request: missing Host header", http.StatusBadRequest)
+			return
+		}
+		if _, port, err := net.SplitHostPort(httpsPort); err == nil && port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		} else if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+			host = "[" + host + "]"
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
@@ -0,0 +1,99 @@
+package session
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileStore 将 session 保存在 JSON 文件中，服务重启后 session 仍然有效
+// 读取走内存，每次修改后整体写回文件（临时文件 + 重命名）
+type FileStore struct {
+	*MemoryStore
+	path string
+}
+
+// OpenFileStore 打开 session 文件，文件不存在时创建空存储，已过期的 session 在加载时丢弃
+func OpenFileStore(path string) (*FileStore, error) {
+	f := &FileStore{MemoryStore: NewMemoryStore(), path: path}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return f, nil
+		}
+		return nil, err
+	}
+
+	var sessions []Session
+	if err := json.Unmarshal(data, &sessions); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for _, s := range sessions {
+		if s.ID != "" && !s.Expired(now) {
+			f.sessions[s.ID] = s
+		}
+	}
+	return f, nil
+}
+
+func (f *FileStore) Put(s Session) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sessions[s.ID] = s
+	return f.save()
+}
+
+func (f *FileStore) Delete(id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.sessions[id]; !ok {
+		return nil
+	}
+	delete(f.sessions, id)
+	return f.save()
+}
+
+func (f *FileStore) DeleteFunc(match func(Session) bool) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	count := f.deleteFunc(match)
+	if count == 0 {
+		return 0, nil
+	}
+	return count, f.save()
+}
+
+// save 将所有 session 写入文件，调用者必须持有写锁
+// 文件包含客户端 IP 等信息，只允许所有者读写
+func (f *FileStore) save() error {
+	sessions := make([]Session, 0, len(f.sessions))
+	for _, s := range f.sessions {
+		sessions = append(sessions, s)
+	}
+	data, err := json.MarshalIndent(sessions, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Chmod(tmpPath, 0600); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, f.path)
+}
@@ -0,0 +1,67 @@
+package session
+
+import (
+	"sort"
+	"sync"
+)
+
+// MemoryStore 内存 session 存储
+type MemoryStore struct {
+	mu       sync.RWMutex
+	sessions map[string]Session
+}
+
+// NewMemoryStore 创建内存 session 存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: make(map[string]Session)}
+}
+
+func (m *MemoryStore) Get(id string) (Session, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	s, ok := m.sessions[id]
+	return s, ok
+}
+
+func (m *MemoryStore) Put(s Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[s.ID] = s
+	return nil
+}
+
+func (m *MemoryStore) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, id)
+	return nil
+}
+
+func (m *MemoryStore) DeleteFunc(match func(Session) bool) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.deleteFunc(match), nil
+}
+
+// deleteFunc 删除匹配的 session，调用者必须持有写锁
+func (m *MemoryStore) deleteFunc(match func(Session) bool) int {
+	count := 0
+	for id, s := range m.sessions {
+		if match(s) {
+			delete(m.sessions, id)
+			count++
+		}
+	}
+	return count
+}
+
+func (m *MemoryStore) List() []Session {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	sessions := make([]Session, 0, len(m.sessions))
+	for _, s := range m.sessions {
+		sessions = append(sessions, s)
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].CreatedAt.After(sessions[j].CreatedAt) })
+	return sessions
+}
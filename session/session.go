@@ -0,0 +1,70 @@
+package session
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// 存储类型
+const (
+	StoreMemory = "memory" // 内存：重启后所有 session 失效
+	StoreFile   = "file"   // JSON 文件：重启后 session 仍然有效
+)
+
+// session 类型
+const (
+	KindAdmin = "admin"
+	KindUser  = "user"
+)
+
+// Session 表示一个登录 session
+// 只保存 token 的 SHA-256 哈希，存储文件泄露时不能直接用于登录
+type Session struct {
+	ID         string    `json:"id"` // token 的 SHA-256 哈希
+	Kind       string    `json:"kind"`
+	Username   string    `json:"username,omitempty"` // 用户 session 对应的用户名（管理员 session 为空）
+	IP         string    `json:"ip"`                 // 最近一次访问的客户端 IP
+	UserAgent  string    `json:"userAgent"`          // 最近一次访问的 User-Agent
+	CreatedAt  time.Time `json:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+}
+
+// Expired 返回 session 是否已经过期
+func (s Session) Expired(now time.Time) bool {
+	return now.After(s.ExpiresAt)
+}
+
+// Store session 存储
+type Store interface {
+	// Get 按 ID 查找 session
+	Get(id string) (Session, bool)
+	// Put 保存 session（ID 相同时覆盖）
+	Put(s Session) error
+	// Delete 删除 session，不存在时不报错
+	Delete(id string) error
+	// DeleteFunc 删除所有 match 返回 true 的 session，返回删除的数量
+	DeleteFunc(match func(Session) bool) (int, error)
+	// List 返回所有 session，按创建时间倒序
+	List() []Session
+}
+
+// OpenStore 按类型打开 session 存储
+func OpenStore(kind, path string) (Store, error) {
+	switch kind {
+	case "", StoreFile:
+		return OpenFileStore(path)
+	case StoreMemory:
+		return NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown session store type: %s (use %s or %s)", kind, StoreFile, StoreMemory)
+	}
+}
+
+// HashToken 计算 token 对应的 session ID
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
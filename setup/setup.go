@@ -2,13 +2,19 @@ package setup
 
 import (
 	"flag"
+	"io"
 	"log"
+	"net/http"
 	"os"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/hello--world/jot/events"
 	"github.com/hello--world/jot/handlers"
+	"github.com/hello--world/jot/ratelimit"
+	"github.com/hello--world/jot/session"
+	"github.com/hello--world/jot/webhook"
 )
 
 // ConfigLoader 用于加载配置
@@ -19,34 +25,65 @@ type ConfigLoader struct {
 	SaveConfig        func()
 	ParseFileSize     func(string) (int64, error)
 	LoadExistingNotes func() error
+	OpenSessionStore  func() error
 	GetConfigLoaded   func() bool
 	SetConfigLoaded   func(bool)
 
 	// 变量设置函数
-	SetAdminPath     func(string)
-	SetPort          func(string)
-	SetNoteNameLen   func(int)
-	SetBackupDays    func(int)
-	SetNoteChars     func(string)
-	SetMaxFileSize   func(int64)
-	SetMaxPathLength func(int)
-	SetMaxTotalSize  func(int64)
-	SetMaxNoteCount  func(int)
-	SetAdminToken    func(string)
-	SetAccessToken   func(string)
+	SetAdminPath        func(string)
+	SetPort             func(string)
+	SetNoteNameLen      func(int)
+	SetBackupDays       func(int)
+	SetNoteChars        func(string)
+	SetMaxFileSize      func(int64)
+	SetMaxPathLength    func(int)
+	SetMaxTotalSize     func(int64)
+	SetMaxNoteCount     func(int)
+	SetAdminToken       func(string)
+	SetAccessToken      func(string)
+	SetMaxRevisions     func(int)
+	SetRevisionDays     func(int)
+	SetTrashDays        func(int)
+	SetArchiveBy        func(string)
+	SetDeleteArchived   func(int)
+	SetArchiveMaxSize   func(int64)
+	SetArchiveKeepLast  func(int)
+	SetOrphanUploadDays func(int)
+	SetOrphanUploadSize func(int64)
+	SetOrphanUploadLast func(int)
+	SetSnapshotMaxSize  func(int64)
+	SetAllowedOrigins   func([]string)
+	SetDataDir          func(string)
+	SetSavePath         func(string)
+	SetBackupPath       func(string)
+	SetUploadPath       func(string)
+	SetRevisionPath     func(string)
+	InitDataDirs        func() error
+	SetTLSCertFile      func(string)
+	SetTLSKeyFile       func(string)
+	SetHTTPRedirectPort func(string)
+	SetHSTSMaxAge       func(int)
+	SetStoreType        func(string)
+	SetSessionStoreType func(string)
 
 	// 变量获取函数
-	GetAdminPath     func() string
-	GetPort          func() string
-	GetNoteNameLen   func() int
-	GetBackupDays    func() int
-	GetNoteChars     func() string
-	GetMaxFileSize   func() int64
-	GetMaxPathLength func() int
-	GetMaxTotalSize  func() int64
-	GetMaxNoteCount  func() int
-	GetAdminToken    func() string
-	GetAccessToken   func() string
+	GetAdminPath        func() string
+	GetPort             func() string
+	GetNoteNameLen      func() int
+	GetBackupDays       func() int
+	GetNoteChars        func() string
+	GetMaxFileSize      func() int64
+	GetMaxPathLength    func() int
+	GetMaxTotalSize     func() int64
+	GetMaxNoteCount     func() int
+	GetAdminToken       func() string
+	GetAccessToken      func() string
+	GetMaxRevisions     func() int
+	GetRevisionDays     func() int
+	GetStoreType        func() string
+	GetSessionStoreType func() string
+	GetTLSCertFile      func() string
+	GetTLSKeyFile       func() string
 }
 
 var loader *ConfigLoader
@@ -56,13 +93,72 @@ func InitConfigLoader(l *ConfigLoader) {
 	loader = l
 }
 
+// splitList 解析逗号分隔的列表，忽略空项
+func splitList(value string) []string {
+	var result []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
 // LoadConfiguration 加载配置（从命令行、环境变量、配置文件）
 func LoadConfiguration() {
 	// Load configuration from command line, environment variable, or .env file
 	tokenFlag := flag.String("token", "", "Admin access token (required)")
 	portFlag := flag.String("port", "", "Server port (default: :8080)")
+	storeFlag := flag.String("store", "", "Note storage backend: fs or bolt (default: fs)")
+	sessionStoreFlag := flag.String("session-store", "", "Login session storage: file or memory (default: file)")
+	tlsCertFlag := flag.String("tls-cert", "", "TLS certificate file, enables HTTPS (reloaded when changed)")
+	tlsKeyFlag := flag.String("tls-key", "", "TLS private key file")
+	httpRedirectFlag := flag.String("http-redirect-port", "", "Port of an HTTP listener that redirects to HTTPS, e.g. :80 (default: disabled)")
+	hstsMaxAgeFlag := flag.Int("hsts-max-age", -1, "HSTS max-age in seconds sent over HTTPS, 0 to disable (default: 31536000)")
+	dataDirFlag := flag.String("data-dir", "", "Data directory for notes, backups, uploads, revisions and config.json (default: current directory)")
+	notesDirFlag := flag.String("notes-dir", "", "Directory for active notes (default: <data-dir>/_tmp)")
+	backupDirFlag := flag.String("backup-dir", "", "Directory for archived notes (default: <data-dir>/bak)")
+	uploadsDirFlag := flag.String("uploads-dir", "", "Directory for uploaded files (default: <data-dir>/uploads)")
+	revisionsDirFlag := flag.String("revisions-dir", "", "Directory for note revisions (default: <data-dir>/revisions)")
+	maxRevisionsFlag := flag.Int("max-revisions", 0, "Maximum revisions kept per note (default: 50)")
+	revisionDaysFlag := flag.Int("revision-days", 0, "Days to keep note revisions (default: 30)")
+	trashDaysFlag := flag.Int("trash-days", 0, "Days to keep deleted notes in the trash (default: 30)")
+	archiveByFlag := flag.String("archive-by", "", "Archive notes not modified or not read for backup-days: modified or read (default: modified)")
+	deleteArchivedDaysFlag := flag.Int("delete-archived-days", 0, "Days to keep archived notes before purging them (default: 0, never)")
+	archiveMaxSizeFlag := flag.String("archive-max-size", "", "Maximum total size of archived notes, oldest archived are purged first (e.g., 500M, default: no limit)")
+	archiveKeepLastFlag := flag.Int("archive-keep-last", 0, "Maximum number of archived notes to keep (default: 0, no limit)")
+	orphanUploadDaysFlag := flag.Int("orphan-upload-days", 0, "Days to keep uploads no note links to (default: 0, no limit)")
+	orphanUploadMaxSizeFlag := flag.String("orphan-upload-max-size", "", "Maximum total size of uploads no note links to (e.g., 100M, default: no limit)")
+	orphanUploadKeepLastFlag := flag.Int("orphan-upload-keep-last", 0, "Maximum number of uploads no note links to (default: 0, no limit)")
+	snapshotMaxSizeFlag := flag.String("snapshot-max-size", "", "Maximum extracted size of an imported snapshot (e.g., 20G, default: 10G)")
+	allowedOriginsFlag := flag.String("allowed-origins", "", "Comma-separated origins allowed besides same-origin for writes and WebSocket (e.g. https://notes.example.com)")
 	flag.Parse()
 
+	// Get data directories from: command line > environment variable > default
+	// 数据目录只在启动时生效，不保存到配置文件；配置文件 config.json 位于数据目录下，所以需要先确定数据目录
+	dataDirs := []struct {
+		flag   *string
+		envKey string
+		set    func(string)
+	}{
+		{dataDirFlag, "DATA_DIR", loader.SetDataDir},
+		{notesDirFlag, "NOTES_DIR", loader.SetSavePath},
+		{backupDirFlag, "BACKUP_DIR", loader.SetBackupPath},
+		{uploadsDirFlag, "UPLOADS_DIR", loader.SetUploadPath},
+		{revisionsDirFlag, "REVISIONS_DIR", loader.SetRevisionPath},
+	}
+	for _, dir := range dataDirs {
+		if *dir.flag != "" {
+			dir.set(*dir.flag)
+		} else if envDir := os.Getenv(dir.envKey); envDir != "" {
+			dir.set(envDir)
+		}
+	}
+	if err := loader.InitDataDirs(); err != nil {
+		log.Fatalf("Error: Failed to create data directories: %v", err)
+	}
+	loader.LoadConfig()
+
 	// Get port from: command line > environment variable > default (port is always configurable)
 	if *portFlag != "" {
 		port := *portFlag
@@ -78,6 +174,58 @@ func LoadConfiguration() {
 		loader.SetPort(port)
 	}
 
+	// Get note storage backend from: command line > environment variable > default
+	// 存储类型只在启动时生效，不保存到配置文件
+	if *storeFlag != "" {
+		loader.SetStoreType(*storeFlag)
+	} else if envStore := os.Getenv("STORE"); envStore != "" {
+		loader.SetStoreType(envStore)
+	}
+
+	// Get login session storage from: command line > environment variable > default
+	// file 存储在重启后保留登录状态；memory 存储在重启后所有 session 失效
+	if *sessionStoreFlag != "" {
+		loader.SetSessionStoreType(*sessionStoreFlag)
+	} else if envSessionStore := os.Getenv("SESSION_STORE"); envSessionStore != "" {
+		loader.SetSessionStoreType(envSessionStore)
+	}
+
+	// Get TLS settings from: command line > environment variable
+	// TLS 配置只在启动时生效，不保存到配置文件
+	if *tlsCertFlag != "" {
+		loader.SetTLSCertFile(*tlsCertFlag)
+	} else if envCert := os.Getenv("TLS_CERT"); envCert != "" {
+		loader.SetTLSCertFile(envCert)
+	}
+	if *tlsKeyFlag != "" {
+		loader.SetTLSKeyFile(*tlsKeyFlag)
+	} else if envKey := os.Getenv("TLS_KEY"); envKey != "" {
+		loader.SetTLSKeyFile(envKey)
+	}
+	if (loader.GetTLSCertFile() == "") != (loader.GetTLSKeyFile() == "") {
+		log.Fatal("Error: Both -tls-cert and -tls-key (or TLS_CERT and TLS_KEY) are required to enable HTTPS")
+	}
+	redirectPort := *httpRedirectFlag
+	if redirectPort == "" {
+		redirectPort = os.Getenv("HTTP_REDIRECT_PORT")
+	}
+	if redirectPort != "" {
+		if loader.GetTLSCertFile() == "" {
+			log.Fatal("Error: -http-redirect-port requires -tls-cert and -tls-key")
+		}
+		if !strings.HasPrefix(redirectPort, ":") {
+			redirectPort = ":" + redirectPort
+		}
+		loader.SetHTTPRedirectPort(redirectPort)
+	}
+	if *hstsMaxAgeFlag >= 0 {
+		loader.SetHSTSMaxAge(*hstsMaxAgeFlag)
+	} else if envHSTS := os.Getenv("HSTS_MAX_AGE"); envHSTS != "" {
+		if maxAge, err := strconv.Atoi(envHSTS); err == nil && maxAge >= 0 {
+			loader.SetHSTSMaxAge(maxAge)
+		}
+	}
+
 	// Check if config file was loaded
 	// If config file exists, use it and ignore env/command line (except port and token)
 	// If config file doesn't exist, use env/command line and save to config file
@@ -160,6 +308,124 @@ func LoadConfiguration() {
 			}
 		}
 
+		// Get max revisions per note from: command line > environment variable > default
+		if *maxRevisionsFlag > 0 {
+			loader.SetMaxRevisions(*maxRevisionsFlag)
+		} else if envRevisions := os.Getenv("MAX_REVISIONS"); envRevisions != "" {
+			if revisions, err := strconv.Atoi(envRevisions); err == nil && revisions > 0 {
+				loader.SetMaxRevisions(revisions)
+			}
+		}
+
+		// Get revision retention days from: command line > environment variable > default
+		if *revisionDaysFlag > 0 {
+			loader.SetRevisionDays(*revisionDaysFlag)
+		} else if envDays := os.Getenv("REVISION_DAYS"); envDays != "" {
+			if days, err := strconv.Atoi(envDays); err == nil && days > 0 {
+				loader.SetRevisionDays(days)
+			}
+		}
+
+		// Get trash retention days from: command line > environment variable > default
+		if *trashDaysFlag > 0 {
+			loader.SetTrashDays(*trashDaysFlag)
+		} else if envDays := os.Getenv("TRASH_DAYS"); envDays != "" {
+			if days, err := strconv.Atoi(envDays); err == nil && days > 0 {
+				loader.SetTrashDays(days)
+			}
+		}
+
+		// Get archive policy from: command line > environment variable > default
+		archiveBy := *archiveByFlag
+		if archiveBy == "" {
+			archiveBy = os.Getenv("ARCHIVE_BY")
+		}
+		switch archiveBy {
+		case "":
+		case "modified", "read":
+			loader.SetArchiveBy(archiveBy)
+		default:
+			log.Fatalf("Error: Invalid archive-by value: %s. Use modified or read", archiveBy)
+		}
+		if *deleteArchivedDaysFlag > 0 {
+			loader.SetDeleteArchived(*deleteArchivedDaysFlag)
+		} else if envDays := os.Getenv("DELETE_ARCHIVED_DAYS"); envDays != "" {
+			if days, err := strconv.Atoi(envDays); err == nil && days > 0 {
+				loader.SetDeleteArchived(days)
+			}
+		}
+
+		// Get archive and orphaned upload retention from: command line > environment variable > default (0, no limit)
+		if *archiveMaxSizeFlag != "" {
+			if size, err := loader.ParseFileSize(*archiveMaxSizeFlag); err == nil && size > 0 {
+				loader.SetArchiveMaxSize(size)
+			} else {
+				log.Fatalf("Error: Invalid archive-max-size format: %s. Use format like 10M, 100MB, 1G", *archiveMaxSizeFlag)
+			}
+		} else if envSize := os.Getenv("ARCHIVE_MAX_SIZE"); envSize != "" {
+			if size, err := loader.ParseFileSize(envSize); err == nil && size > 0 {
+				loader.SetArchiveMaxSize(size)
+			} else {
+				log.Fatalf("Error: Invalid ARCHIVE_MAX_SIZE format: %s. Use format like 10M, 100MB, 1G", envSize)
+			}
+		}
+		if *archiveKeepLastFlag > 0 {
+			loader.SetArchiveKeepLast(*archiveKeepLastFlag)
+		} else if envCount := os.Getenv("ARCHIVE_KEEP_LAST"); envCount != "" {
+			if count, err := strconv.Atoi(envCount); err == nil && count > 0 {
+				loader.SetArchiveKeepLast(count)
+			}
+		}
+		if *orphanUploadDaysFlag > 0 {
+			loader.SetOrphanUploadDays(*orphanUploadDaysFlag)
+		} else if envDays := os.Getenv("ORPHAN_UPLOAD_DAYS"); envDays != "" {
+			if days, err := strconv.Atoi(envDays); err == nil && days > 0 {
+				loader.SetOrphanUploadDays(days)
+			}
+		}
+		if *orphanUploadMaxSizeFlag != "" {
+			if size, err := loader.ParseFileSize(*orphanUploadMaxSizeFlag); err == nil && size > 0 {
+				loader.SetOrphanUploadSize(size)
+			} else {
+				log.Fatalf("Error: Invalid orphan-upload-max-size format: %s. Use format like 10M, 100MB, 1G", *orphanUploadMaxSizeFlag)
+			}
+		} else if envSize := os.Getenv("ORPHAN_UPLOAD_MAX_SIZE"); envSize != "" {
+			if size, err := loader.ParseFileSize(envSize); err == nil && size > 0 {
+				loader.SetOrphanUploadSize(size)
+			} else {
+				log.Fatalf("Error: Invalid ORPHAN_UPLOAD_MAX_SIZE format: %s. Use format like 10M, 100MB, 1G", envSize)
+			}
+		}
+		if *orphanUploadKeepLastFlag > 0 {
+			loader.SetOrphanUploadLast(*orphanUploadKeepLastFlag)
+		} else if envCount := os.Getenv("ORPHAN_UPLOAD_KEEP_LAST"); envCount != "" {
+			if count, err := strconv.Atoi(envCount); err == nil && count > 0 {
+				loader.SetOrphanUploadLast(count)
+			}
+		}
+
+		// Get snapshot import limit from: command line > environment variable > default
+		if *snapshotMaxSizeFlag != "" {
+			if size, err := loader.ParseFileSize(*snapshotMaxSizeFlag); err == nil && size > 0 {
+				loader.SetSnapshotMaxSize(size)
+			} else {
+				log.Fatalf("Error: Invalid snapshot-max-size format: %s. Use format like 10M, 100MB, 1G", *snapshotMaxSizeFlag)
+			}
+		} else if envSize := os.Getenv("SNAPSHOT_MAX_SIZE"); envSize != "" {
+			if size, err := loader.ParseFileSize(envSize); err == nil && size > 0 {
+				loader.SetSnapshotMaxSize(size)
+			} else {
+				log.Fatalf("Error: Invalid SNAPSHOT_MAX_SIZE format: %s. Use format like 10M, 100MB, 1G", envSize)
+			}
+		}
+
+		// Get allowed origins from: command line > environment variable（逗号分隔）
+		if *allowedOriginsFlag != "" {
+			loader.SetAllowedOrigins(splitList(*allowedOriginsFlag))
+		} else if envOrigins := os.Getenv("ALLOWED_ORIGINS"); envOrigins != "" {
+			loader.SetAllowedOrigins(splitList(envOrigins))
+		}
+
 		// Save config to file after loading from env/command line
 		loader.SaveConfig()
 		log.Printf("Configuration loaded from environment/command line and saved to config.json")
@@ -204,35 +470,109 @@ func LoadConfiguration() {
 		loader.SetAccessToken(envAccessToken)
 	}
 
-	// Load existing notes into memory cache
+	// Open note store and load existing notes into memory cache
 	if err := loader.LoadExistingNotes(); err != nil {
-		log.Printf("Warning: Failed to load existing notes: %v", err)
-	} else {
-		log.Printf("Loaded existing notes into memory cache")
+		log.Fatalf("Error: Failed to open note store (%s): %v", loader.GetStoreType(), err)
+	}
+	log.Printf("Loaded existing notes into memory cache (store: %s)", loader.GetStoreType())
+
+	// Open login session store
+	if err := loader.OpenSessionStore(); err != nil {
+		log.Fatalf("Error: Failed to open session store (%s): %v", loader.GetSessionStoreType(), err)
 	}
 }
 
-// InitHandlers 初始化 handlers 包的依赖
+// HandlerInitializer 包含创建 handlers 包依赖需要的函数
 type HandlerInitializer struct {
+	// 工作区名称和绑定的 Host（默认工作区的名称为空）
+	Workspace      string
+	WorkspaceHosts []string
+
 	// Note 转换函数
 	ConvertNoteToHandlerNote func(interface{}) handlers.Note
 	GetAllNotes              func() ([]interface{}, error)
 	GetAllBackupNotes        func() ([]interface{}, error)
 
 	// 笔记操作函数
-	LoadNote            func(string) (string, error)
-	SaveNote            func(string, string) error
-	GenerateNoteName    func() string
-	IsSafeNoteName      func(string) bool
-	GetNotePath         func(string) string
-	FindNotePath        func(string) (string, error)
-	IsNoteExists        func(string) bool
-	GetFileCreationTime func(string) (time.Time, error)
+	LoadNote         func(string) (string, error)
+	SaveNote         func(string, string, string) error
+	GenerateNoteName func() string
+	IsSafeNoteName   func(string) bool
+	StatNote         func(string) (handlers.NoteInfo, error)
+	IsNoteExists     func(string) bool
+
+	SaveNoteIfUnchanged func(string, string, string, string) error
+
+	// 修订版本
+	ListRevisions func(string) ([]handlers.Revision, error)
+	LoadRevision  func(string, string) (string, error)
+	DiffRevisions func(string, string, string) (string, error)
+
+	// 归档笔记
+	FindArchivedNote func(string, string) (handlers.NoteInfo, error)
+	UnarchiveNote    func(string, string, string, string) (handlers.UnarchiveResult, error)
+
+	// 回收站
+	ListTrash        func() ([]handlers.TrashedNote, error)
+	FindTrash        func(string, string) (handlers.TrashedNote, error)
+	RestoreFromTrash func(string, string, string) (handlers.TrashedNote, error)
+	PurgeTrash       func(string, string) (int, error)
+
+	// 快照导出和导入
+	ExportSnapshot func() (io.ReadCloser, error)
+	ImportSnapshot func(io.Reader, string, bool) (handlers.SnapshotReport, error)
+
+	// 归档策略和置顶
+	PlanArchive   func() (handlers.ArchivePlan, error)
+	SetNotePinned func(string, bool) error
+	TouchNote     func(string)
+
+	// 全文搜索
+	SearchNotes func(string, int, func(string, string) bool) ([]handlers.SearchResult, error)
+
+	// API key
+	AuthenticateAPIKey func(string, string) bool
+	HasActiveAPIKeys   func() bool
+	ListAPIKeys        func() []handlers.APIKey
+	CreateAPIKey       func(string, []string, *time.Time) (string, handlers.APIKey, error)
+	RevokeAPIKey       func(string) error
+
+	// 用户账号
+	AuthenticateUser func(string, string) bool
+	UserExists       func(string) bool
+	ListUsers        func() []handlers.User
+	CreateUser       func(string, string) error
+	DeleteUser       func(string) error
+	SetUserPassword  func(string, string) error
+
+	// 笔记所有权和共享
+	GetNoteACL   func(string) (handlers.NoteACL, bool)
+	SetNoteACL   func(string, handlers.NoteACL) error
+	ClaimNote    func(string, string) (bool, error)
+	GetUserNotes func(string) ([]string, map[string]string)
+
+	// 限流
+	RateLimiter *ratelimit.Limiter
+
+	// CSRF 和 WebSocket 允许的 Origin
+	GetAllowedOrigins func() []string
+
+	// 登录 session 存储
+	Sessions            session.Store
+	GetSessionStoreType func() string
+
+	// 笔记事件的 webhook
+	Webhooks *webhook.Manager
+
+	// 笔记事件流（Server-Sent Events）
+	Events *events.Broker
 
 	// 锁相关函数
-	HasNoteLock      func(string) bool
-	GetNoteLockToken func(string) string
-	GetNoteContent   func(string) string
+	HasNoteLock    func(string) bool
+	VerifyNoteLock func(string, string) bool
+	GetNoteContent func(string) string
+	KeepNoteLock   func(string, string) string
+	ContentETag    func(string) string
 
 	// 文件相关
 	GetTotalFileSize func() (int64, error)
@@ -240,6 +580,7 @@ type HandlerInitializer struct {
 
 	// WebSocket
 	BroadcastUpdate func(string, string)
+	HandleWebSocket func(http.ResponseWriter, *http.Request)
 
 	// 配置保存
 	SaveConfig func()
@@ -267,6 +608,32 @@ type HandlerInitializer struct {
 	GetAdminToken    func() string
 	GetAccessToken   func() string
 	GetAdminPath     func() string
+	GetMaxRevisions  func() int
+	SetMaxRevisions  func(int)
+	GetRevisionDays  func() int
+	SetRevisionDays  func(int)
+	GetTrashDays     func() int
+	SetTrashDays     func(int)
+	SetStoreType     func(string)
+
+	// 归档策略
+	GetArchiveBy          func() string
+	SetArchiveBy          func(string)
+	GetDeleteArchivedDays func() int
+	SetDeleteArchivedDays func(int)
+
+	// 备份文件夹和孤立上传文件的保留策略
+	GetArchiveMaxSize       func() int64
+	SetArchiveMaxSize       func(int64)
+	GetArchiveKeepLast      func() int
+	SetArchiveKeepLast      func(int)
+	GetOrphanUploadDays     func() int
+	SetOrphanUploadDays     func(int)
+	GetOrphanUploadMaxSize  func() int64
+	SetOrphanUploadMaxSize  func(int64)
+	GetOrphanUploadKeepLast func() int
+	SetOrphanUploadKeepLast func(int)
+	GetDiskUsage            func() (handlers.DiskUsage, error)
 
 	// 锁操作
 	RLockMaxTotalSize   func()
@@ -279,15 +646,8 @@ type HandlerInitializer struct {
 	UnlockMaxNoteCount  func()
 }
 
-var initializer *HandlerInitializer
-
-// InitHandlerInitializer 初始化 handler 初始化器
-func InitHandlerInitializer(init *HandlerInitializer) {
-	initializer = init
-}
-
-// InitHandlers 初始化 handlers 包
-func InitHandlers() {
+// NewDependencies 根据 handler 初始化器创建 handlers 包的依赖（每个工作区一份）
+func NewDependencies(initializer *HandlerInitializer) *handlers.Dependencies {
 	// 转换 Note 的函数
 	convertNotes := func(notes []interface{}) []handlers.Note {
 		result := make([]handlers.Note, len(notes))
@@ -314,24 +674,76 @@ func InitHandlers() {
 	}
 
 	d := &handlers.Dependencies{
-		AdminToken:  initializer.GetAdminToken(),
-		AccessToken: initializer.GetAccessToken(),
-		AdminPath:   initializer.GetAdminPath(),
-
-		GetAllNotes:         getAllNotesForHandlers,
-		GetAllBackupNotes:   getAllBackupNotesForHandlers,
-		LoadNote:            initializer.LoadNote,
-		SaveNote:            initializer.SaveNote,
-		GenerateNoteName:    initializer.GenerateNoteName,
-		IsSafeNoteName:      initializer.IsSafeNoteName,
-		GetNotePath:         initializer.GetNotePath,
-		FindNotePath:        initializer.FindNotePath,
-		IsNoteExists:        initializer.IsNoteExists,
-		GetFileCreationTime: initializer.GetFileCreationTime,
+		Workspace:      initializer.Workspace,
+		WorkspaceHosts: initializer.WorkspaceHosts,
+
+		AdminToken: initializer.GetAdminToken(),
+		AdminPath:  initializer.GetAdminPath(),
+
+		GetAllNotes:       getAllNotesForHandlers,
+		GetAllBackupNotes: getAllBackupNotesForHandlers,
+		LoadNote:          initializer.LoadNote,
+		SaveNote:          initializer.SaveNote,
+		GenerateNoteName:  initializer.GenerateNoteName,
+		IsSafeNoteName:    initializer.IsSafeNoteName,
+		StatNote:          initializer.StatNote,
+		IsNoteExists:      initializer.IsNoteExists,
+
+		SaveNoteIfUnchanged: initializer.SaveNoteIfUnchanged,
+
+		ListRevisions: initializer.ListRevisions,
+		LoadRevision:  initializer.LoadRevision,
+		DiffRevisions: initializer.DiffRevisions,
+
+		FindArchivedNote: initializer.FindArchivedNote,
+		UnarchiveNote:    initializer.UnarchiveNote,
+
+		ListTrash:        initializer.ListTrash,
+		FindTrash:        initializer.FindTrash,
+		RestoreFromTrash: initializer.RestoreFromTrash,
+		PurgeTrash:       initializer.PurgeTrash,
+
+		ExportSnapshot: initializer.ExportSnapshot,
+		ImportSnapshot: initializer.ImportSnapshot,
+
+		PlanArchive:   initializer.PlanArchive,
+		SetNotePinned: initializer.SetNotePinned,
+		TouchNote:     initializer.TouchNote,
+
+		SearchNotes: initializer.SearchNotes,
+
+		AuthenticateAPIKey: initializer.AuthenticateAPIKey,
+		HasActiveAPIKeys:   initializer.HasActiveAPIKeys,
+		ListAPIKeys:        initializer.ListAPIKeys,
+		CreateAPIKey:       initializer.CreateAPIKey,
+		RevokeAPIKey:       initializer.RevokeAPIKey,
+		AuthenticateUser:   initializer.AuthenticateUser,
+		UserExists:         initializer.UserExists,
+		ListUsers:          initializer.ListUsers,
+		CreateUser:         initializer.CreateUser,
+		DeleteUser:         initializer.DeleteUser,
+		SetUserPassword:    initializer.SetUserPassword,
+		GetNoteACL:         initializer.GetNoteACL,
+		SetNoteACL:         initializer.SetNoteACL,
+		ClaimNote:          initializer.ClaimNote,
+		GetUserNotes:       initializer.GetUserNotes,
+
+		RateLimiter:       initializer.RateLimiter,
+		ClientIP:          initializer.RateLimiter.ClientIP,
+		FromTrustedProxy:  initializer.RateLimiter.FromTrustedProxy,
+		GetAllowedOrigins: initializer.GetAllowedOrigins,
+
+		Sessions:         initializer.Sessions,
+		SessionStoreType: initializer.GetSessionStoreType(),
+
+		Webhooks: initializer.Webhooks,
+		Events:   initializer.Events,
 
 		HasNoteLock:             initializer.HasNoteLock,
-		GetNoteLockToken:        initializer.GetNoteLockToken,
+		VerifyNoteLock:          initializer.VerifyNoteLock,
 		GetNoteContent:          initializer.GetNoteContent,
+		KeepNoteLock:            initializer.KeepNoteLock,
+		ContentETag:             initializer.ContentETag,
 		GetLockTokenFromRequest: handlers.GetLockTokenFromRequest,
 		GetTokenFromRequest:     handlers.GetTokenFromRequest,
 
@@ -339,6 +751,7 @@ func InitHandlers() {
 		ParseFileSize:    initializer.ParseFileSize,
 
 		BroadcastUpdate: initializer.BroadcastUpdate,
+		HandleWebSocket: initializer.HandleWebSocket,
 
 		SaveConfig: initializer.SaveConfig,
 
@@ -360,7 +773,31 @@ func InitHandlers() {
 		GetUploadPath:    initializer.GetUploadPath,
 		SetAdminPath:     initializer.SetAdminPath,
 		SetAccessToken:   initializer.SetAccessToken,
+		GetAccessToken:   initializer.GetAccessToken,
 		SetAdminToken:    initializer.SetAdminToken,
+		GetMaxRevisions:  initializer.GetMaxRevisions,
+		SetMaxRevisions:  initializer.SetMaxRevisions,
+		GetRevisionDays:  initializer.GetRevisionDays,
+		SetRevisionDays:  initializer.SetRevisionDays,
+		GetTrashDays:     initializer.GetTrashDays,
+		SetTrashDays:     initializer.SetTrashDays,
+
+		GetArchiveBy:          initializer.GetArchiveBy,
+		SetArchiveBy:          initializer.SetArchiveBy,
+		GetDeleteArchivedDays: initializer.GetDeleteArchivedDays,
+		SetDeleteArchivedDays: initializer.SetDeleteArchivedDays,
+
+		GetArchiveMaxSize:       initializer.GetArchiveMaxSize,
+		SetArchiveMaxSize:       initializer.SetArchiveMaxSize,
+		GetArchiveKeepLast:      initializer.GetArchiveKeepLast,
+		SetArchiveKeepLast:      initializer.SetArchiveKeepLast,
+		GetOrphanUploadDays:     initializer.GetOrphanUploadDays,
+		SetOrphanUploadDays:     initializer.SetOrphanUploadDays,
+		GetOrphanUploadMaxSize:  initializer.GetOrphanUploadMaxSize,
+		SetOrphanUploadMaxSize:  initializer.SetOrphanUploadMaxSize,
+		GetOrphanUploadKeepLast: initializer.GetOrphanUploadKeepLast,
+		SetOrphanUploadKeepLast: initializer.SetOrphanUploadKeepLast,
+		GetDiskUsage:            initializer.GetDiskUsage,
 
 		RLockMaxTotalSize:   initializer.RLockMaxTotalSize,
 		RUnlockMaxTotalSize: initializer.RUnlockMaxTotalSize,
@@ -371,5 +808,5 @@ func InitHandlers() {
 		LockMaxNoteCount:    initializer.LockMaxNoteCount,
 		UnlockMaxNoteCount:  initializer.UnlockMaxNoteCount,
 	}
-	handlers.Init(d)
+	return d
 }
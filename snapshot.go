@@ -0,0 +1,258 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hello--world/jot/handlers"
+	"github.com/hello--world/jot/snapshot"
+)
+
+// snapshotSource 返回工作区快照包含的数据
+func (ws *workspace) snapshotSource() snapshot.Source {
+	return snapshot.Source{
+		Notes:      ws.noteManager,
+		UploadPath: ws.v.UploadPath,
+		ConfigFile: ws.v.ConfigFile,
+		Workspace:  ws.name,
+		Store:      ws.v.StoreType,
+	}
+}
+
+// exportSnapshot 导出工作区的快照（调用者读取完后需要关闭）
+func (ws *workspace) exportSnapshot() (io.ReadCloser, error) {
+	return snapshot.Create(ws.snapshotSource())
+}
+
+// importSnapshot 导入快照，恢复配置文件后重新加载配置
+// 快照解压后的大小不能超过 SnapshotMaxSize
+func (ws *workspace) importSnapshot(r io.Reader, mode string, restoreConfig bool) (handlers.SnapshotReport, error) {
+	report, err := snapshot.Import(r, ws.snapshotSource(), snapshot.Options{Mode: mode, Config: restoreConfig, MaxSize: ws.v.SnapshotMaxSize})
+	if report.Config {
+		ws.configManager.LoadConfig()
+	}
+	return convertSnapshotReport(report), err
+}
+
+// convertSnapshotReport 将 snapshot.Report 转换为 handlers.SnapshotReport
+func convertSnapshotReport(report snapshot.Report) handlers.SnapshotReport {
+	conflicts := make([]handlers.SnapshotConflict, len(report.Conflicts))
+	for i, c := range report.Conflicts {
+		conflicts[i] = handlers.SnapshotConflict(c)
+	}
+	return handlers.SnapshotReport{
+		Mode:      report.Mode,
+		CreatedAt: report.CreatedAt,
+		Workspace: report.Workspace,
+		Notes:     report.Notes,
+		Archived:  report.Archived,
+		Revisions: report.Revisions,
+		Trash:     report.Trash,
+		Uploads:   report.Uploads,
+		Config:    report.Config,
+		Skipped:   report.Skipped,
+		Conflicts: conflicts,
+	}
+}
+
+const snapshotUsage = `Usage:
+  jot snapshot export [flags]        Export a snapshot (tar.gz) of the instance
+  jot snapshot import [flags] FILE   Import a snapshot ("-" reads from stdin)
+
+Without -url the data directory is read and written directly, so the server must be stopped.
+With -url the snapshot is exported from or imported into a running server through its admin API.
+
+Flags:`
+
+// runSnapshotCommand 运行 snapshot 子命令，返回进程退出码
+func runSnapshotCommand(args []string) int {
+	fs := flag.NewFlagSet("snapshot", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), snapshotUsage)
+		fs.PrintDefaults()
+	}
+	dataDir := fs.String("data-dir", os.Getenv("DATA_DIR"), "Data directory of the instance (default: $DATA_DIR or current directory)")
+	store := fs.String("store", os.Getenv("STORE"), "Note storage backend: fs or bolt (default: $STORE or fs)")
+	serverURL := fs.String("url", "", "URL of a running server, e.g. http://localhost:8080 (default: use the data directory)")
+	apiKey := fs.String("key", os.Getenv("JOT_API_KEY"), "API key with admin scope for -url (default: $JOT_API_KEY)")
+	output := fs.String("o", "", "Export: output file, - for stdout (default: jot-snapshot-YYYYMMDD-HHMMSS.tar.gz)")
+	mode := fs.String("mode", snapshot.ModeMerge, "Import: merge (keep local content and report conflicts) or replace (remove existing data first)")
+	restoreConfig := fs.Bool("config", false, "Import: also replace config.json with the one in the snapshot")
+
+	if len(args) == 0 || (args[0] != "export" && args[0] != "import") {
+		fs.Usage()
+		return 2
+	}
+	command := args[0]
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	var err error
+	switch command {
+	case "export":
+		if fs.NArg() != 0 {
+			fs.Usage()
+			return 2
+		}
+		err = snapshotExportCommand(*serverURL, *apiKey, *dataDir, *store, *output)
+	case "import":
+		if fs.NArg() != 1 {
+			fs.Usage()
+			return 2
+		}
+		err = snapshotImportCommand(*serverURL, *apiKey, *dataDir, *store, fs.Arg(0), *mode, *restoreConfig)
+	}
+	if err != nil {
+		log.Printf("Error: %v", err)
+		return 1
+	}
+	return 0
+}
+
+// snapshotExportCommand 导出快照到文件或标准输出
+func snapshotExportCommand(serverURL, apiKey, dataDir, store, output string) error {
+	var snap io.ReadCloser
+	if serverURL != "" {
+		resp, err := snapshotRequest("GET", serverURL, "/api/snapshot/export", apiKey, nil)
+		if err != nil {
+			return err
+		}
+		snap = resp.Body
+	} else {
+		ws, err := openSnapshotWorkspace(dataDir, store)
+		if err != nil {
+			return err
+		}
+		defer ws.noteManager.Close()
+		if snap, err = ws.exportSnapshot(); err != nil {
+			return err
+		}
+	}
+	defer snap.Close()
+
+	if output == "-" {
+		_, err := io.Copy(os.Stdout, snap)
+		return err
+	}
+	if output == "" {
+		output = fmt.Sprintf("jot-snapshot-%s.tar.gz", time.Now().Format("20060102-150405"))
+	}
+	f, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	size, err := io.Copy(f, snap)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(output)
+		return err
+	}
+	log.Printf("Exported snapshot to %s (%d bytes)", output, size)
+	return nil
+}
+
+// snapshotImportCommand 从文件或标准输入导入快照，输出导入结果和冲突
+func snapshotImportCommand(serverURL, apiKey, dataDir, store, input, mode string, restoreConfig bool) error {
+	in := os.Stdin
+	if input != "-" {
+		f, err := os.Open(input)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	var report handlers.SnapshotReport
+	if serverURL != "" {
+		query := url.Values{"mode": {mode}}
+		if restoreConfig {
+			query.Set("config", "true")
+		}
+		resp, err := snapshotRequest("POST", serverURL, "/api/snapshot/import?"+query.Encode(), apiKey, in)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		var result struct {
+			Report handlers.SnapshotReport `json:"report"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return fmt.Errorf("invalid response from server: %v", err)
+		}
+		report = result.Report
+	} else {
+		ws, err := openSnapshotWorkspace(dataDir, store)
+		if err != nil {
+			return err
+		}
+		defer ws.noteManager.Close()
+		if report, err = ws.importSnapshot(in, mode, restoreConfig); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Imported snapshot created at %s (mode: %s)\n", report.CreatedAt.Format("2006-01-02 15:04:05"), report.Mode)
+	fmt.Printf("  notes: %d, archived: %d, revisions: %d, trash: %d, uploads: %d, config: %t, skipped: %d\n",
+		report.Notes, report.Archived, report.Revisions, report.Trash, report.Uploads, report.Config, report.Skipped)
+	if len(report.Conflicts) > 0 {
+		fmt.Printf("  %d conflict(s), local content kept:\n", len(report.Conflicts))
+		for _, c := range report.Conflicts {
+			fmt.Printf("    %s: %s\n", c.Path, c.Reason)
+		}
+	}
+	return nil
+}
+
+// openSnapshotWorkspace 打开数据目录中的默认工作区（不启动服务器）
+func openSnapshotWorkspace(dataDir, store string) (*workspace, error) {
+	ws := newWorkspace("", nil, nil)
+	ws.v.DataDir = dataDir
+	if store != "" {
+		ws.v.StoreType = store
+	}
+	if err := ws.v.InitDataDirs(); err != nil {
+		return nil, err
+	}
+	ws.configManager.LoadConfig()
+	if err := ws.initNoteManager(); err != nil {
+		return nil, fmt.Errorf("open data directory %s (stop the server or use -url): %v", ws.v.DataDir, err)
+	}
+	return ws, nil
+}
+
+// snapshotRequest 向运行中的服务器发送快照请求，响应状态不是 200 时返回错误
+func snapshotRequest(method, serverURL, path, apiKey string, body io.Reader) (*http.Response, error) {
+	if apiKey == "" {
+		return nil, fmt.Errorf("an API key with admin scope is required for -url (use -key or JOT_API_KEY)")
+	}
+	req, err := http.NewRequest(method, strings.TrimSuffix(serverURL, "/")+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/gzip")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("server returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
@@ -0,0 +1,486 @@
+package snapshot
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hello--world/jot/note"
+)
+
+// 快照格式：tar.gz，笔记数据的路径见 note.Snapshot*，上传文件在 uploads/ 下，
+// 配置文件为 config.json，最后一个文件是清单 manifest.json
+const (
+	Version      = 1
+	ManifestFile = "manifest.json"
+	UploadsDir   = "uploads"
+	ConfigFile   = "config.json"
+)
+
+// 导入模式
+const (
+	ModeMerge   = "merge"   // 合并：本地没有的文件直接导入，内容不同的保留本地内容并记录为冲突
+	ModeReplace = "replace" // 替换：先删除所有现有的笔记和上传文件
+)
+
+// File 清单中的一个文件
+type File struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	SHA256  string    `json:"sha256"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// Manifest 快照清单，记录快照中每个文件的大小和 SHA-256 校验和
+type Manifest struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	Workspace string    `json:"workspace,omitempty"`
+	Store     string    `json:"store"` // 导出时使用的存储类型（快照格式与存储类型无关）
+	Notes     int       `json:"notes"`
+	Archived  int       `json:"archived"`
+	Uploads   int       `json:"uploads"`
+	Config    bool      `json:"config"`
+	Files     []File    `json:"files"`
+}
+
+// Source 快照包含的数据
+type Source struct {
+	Notes      *note.Manager
+	UploadPath string
+	ConfigFile string // 配置文件路径，为空时不导出或导入配置
+	Workspace  string
+	Store      string
+}
+
+// Options 导入选项
+type Options struct {
+	Mode    string // ModeMerge（默认）或 ModeReplace
+	Config  bool   // 是否用快照中的配置文件替换现有的配置文件
+	MaxSize int64  // 快照解压后的最大大小（包括 tar 头），超出时拒绝导入；0 表示不限制
+}
+
+// Report 导入结果
+type Report struct {
+	Mode      string                  `json:"mode"`
+	CreatedAt time.Time               `json:"created_at"` // 快照的创建时间
+	Workspace string                  `json:"workspace,omitempty"`
+	Notes     int                     `json:"notes"`
+	Archived  int                     `json:"archived"`
+	Revisions int                     `json:"revisions"`
+	Trash     int                     `json:"trash"`
+	Uploads   int                     `json:"uploads"`
+	Config    bool                    `json:"config"`  // 是否恢复了配置文件
+	Skipped   int                     `json:"skipped"` // 本地已有相同内容而跳过的文件
+	Conflicts []note.SnapshotConflict `json:"conflicts"`
+}
+
+// Snapshot 写入临时文件的快照，读取完后需要调用 Close 删除临时文件
+type Snapshot struct {
+	Manifest Manifest
+	file     *os.File
+}
+
+// Read 读取快照的 tar.gz 内容
+func (s *Snapshot) Read(p []byte) (int, error) {
+	return s.file.Read(p)
+}
+
+// Close 关闭并删除临时文件
+func (s *Snapshot) Close() error {
+	err := s.file.Close()
+	os.Remove(s.file.Name())
+	return err
+}
+
+// writer 写入 tar 并记录清单
+type writer struct {
+	tw       *tar.Writer
+	manifest *Manifest
+}
+
+// add 写入一个文件并计算校验和
+func (w *writer) add(name string, modTime time.Time, size int64, r io.Reader) error {
+	if err := w.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     size,
+		Mode:     0644,
+		ModTime:  modTime,
+	}); err != nil {
+		return err
+	}
+	h := sha256.New()
+	if _, err := io.CopyN(io.MultiWriter(w.tw, h), r, size); err != nil {
+		return fmt.Errorf("write %s: %v", name, err)
+	}
+	w.manifest.Files = append(w.manifest.Files, File{
+		Path:    name,
+		Size:    size,
+		SHA256:  hex.EncodeToString(h.Sum(nil)),
+		ModTime: modTime,
+	})
+	return nil
+}
+
+// Create 导出快照到临时文件
+// 笔记数据在阻止修改笔记的情况下导出（同一时刻的状态），上传文件和配置文件在之后导出
+func Create(src Source) (*Snapshot, error) {
+	f, err := os.CreateTemp("", "jot-snapshot-*.tar.gz")
+	if err != nil {
+		return nil, err
+	}
+	s := &Snapshot{file: f}
+	if err := s.write(src); err != nil {
+		s.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// write 写入快照的所有文件，最后写入清单
+func (s *Snapshot) write(src Source) error {
+	s.Manifest = Manifest{
+		Version:   Version,
+		CreatedAt: time.Now(),
+		Workspace: src.Workspace,
+		Store:     src.Store,
+		Files:     make([]File, 0),
+	}
+	gz := gzip.NewWriter(s.file)
+	w := &writer{tw: tar.NewWriter(gz), manifest: &s.Manifest}
+
+	if err := src.Notes.ExportSnapshot(func(name string, modTime time.Time, data []byte) error {
+		switch {
+		case strings.HasPrefix(name, note.SnapshotActiveDir+"/"):
+			s.Manifest.Notes++
+		case strings.HasPrefix(name, note.SnapshotArchivedDir+"/"):
+			s.Manifest.Archived++
+		}
+		return w.add(name, modTime, int64(len(data)), bytes.NewReader(data))
+	}); err != nil {
+		return err
+	}
+
+	// 上传文件不会被修改，不需要阻止修改笔记
+	if src.UploadPath != "" {
+		err := filepath.WalkDir(src.UploadPath, func(p string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(src.UploadPath, p)
+			if err != nil {
+				return err
+			}
+			f, err := os.Open(p)
+			if err != nil {
+				// 导出期间被删除的文件跳过
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			defer f.Close()
+			fi, err := f.Stat()
+			if err != nil {
+				return err
+			}
+			s.Manifest.Uploads++
+			return w.add(path.Join(UploadsDir, filepath.ToSlash(rel)), fi.ModTime(), fi.Size(), f)
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	if src.ConfigFile != "" {
+		data, err := os.ReadFile(src.ConfigFile)
+		if err == nil {
+			s.Manifest.Config = true
+			if err := w.add(ConfigFile, time.Now(), int64(len(data)), bytes.NewReader(data)); err != nil {
+				return err
+			}
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	manifest, err := json.MarshalIndent(s.Manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := w.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     ManifestFile,
+		Size:     int64(len(manifest)),
+		Mode:     0644,
+		ModTime:  s.Manifest.CreatedAt,
+	}); err != nil {
+		return err
+	}
+	if _, err := w.tw.Write(manifest); err != nil {
+		return err
+	}
+	if err := w.tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// Import 导入快照：先解压到临时目录并按清单校验所有文件，校验通过后再修改现有数据
+// 快照无效或校验失败时返回包装 os.ErrInvalid 的错误，不修改任何数据
+func Import(r io.Reader, dst Source, opts Options) (Report, error) {
+	switch opts.Mode {
+	case "":
+		opts.Mode = ModeMerge
+	case ModeMerge, ModeReplace:
+	default:
+		return Report{}, fmt.Errorf("unknown import mode %q (use %s or %s): %w", opts.Mode, ModeMerge, ModeReplace, os.ErrInvalid)
+	}
+
+	dir, err := os.MkdirTemp("", "jot-snapshot-*")
+	if err != nil {
+		return Report{}, err
+	}
+	defer os.RemoveAll(dir)
+
+	manifest, err := extract(r, dir, opts.MaxSize)
+	if err != nil {
+		return Report{}, err
+	}
+
+	report := Report{
+		Mode:      opts.Mode,
+		CreatedAt: manifest.CreatedAt,
+		Workspace: manifest.Workspace,
+	}
+	replace := opts.Mode == ModeReplace
+	// 所有权记录只在同时恢复配置文件（账号）时导入
+	restoreConfig := opts.Config && manifest.Config && dst.ConfigFile != ""
+	result, err := dst.Notes.ImportSnapshot(dir, replace, restoreConfig)
+	report.Notes = result.Notes
+	report.Archived = result.Archived
+	report.Revisions = result.Revisions
+	report.Trash = result.Trash
+	report.Skipped = result.Skipped
+	report.Conflicts = result.Conflicts
+	if err != nil {
+		return report, err
+	}
+
+	if dst.UploadPath != "" {
+		if err := importUploads(dir, dst.UploadPath, manifest, replace, &report); err != nil {
+			return report, err
+		}
+	}
+
+	if restoreConfig {
+		data, err := os.ReadFile(filepath.Join(dir, ConfigFile))
+		if err != nil {
+			return report, err
+		}
+		if err := writeFile(dst.ConfigFile, data, time.Now()); err != nil {
+			return report, err
+		}
+		report.Config = true
+	}
+
+	log.Printf("Imported snapshot created at %s (%s): %d note(s), %d archived, %d revision(s), %d trashed, %d upload(s), %d skipped, %d conflict(s)",
+		manifest.CreatedAt.Format("2006-01-02 15:04:05"), opts.Mode, report.Notes, report.Archived, report.Revisions, report.Trash, report.Uploads, report.Skipped, len(report.Conflicts))
+	return report, nil
+}
+
+// extract 解压快照到 dir，并按清单校验每个文件的大小和校验和
+// maxSize 大于 0 时限制解压后的总大小，每个条目至少占用一个 tar 头，所以条目数量也受到限制
+func extract(r io.Reader, dir string, maxSize int64) (Manifest, error) {
+	var manifest Manifest
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return manifest, fmt.Errorf("not a tar.gz snapshot: %v: %w", err, os.ErrInvalid)
+	}
+	defer gz.Close()
+
+	in := io.Reader(gz)
+	var limited *io.LimitedReader
+	if maxSize > 0 {
+		limited = &io.LimitedReader{R: gz, N: maxSize + 1}
+		in = limited
+	}
+	// readError 在解压的内容超出 maxSize 时返回大小错误，否则返回 err
+	readError := func(err error) error {
+		if limited != nil && limited.N <= 0 {
+			return fmt.Errorf("snapshot exceeds the maximum size of %d bytes when extracted: %w", maxSize, os.ErrInvalid)
+		}
+		return err
+	}
+
+	sums := make(map[string]File)
+	tr := tar.NewReader(in)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return manifest, readError(fmt.Errorf("read snapshot: %v: %w", err, os.ErrInvalid))
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := hdr.Name
+		if !isSafePath(name) {
+			return manifest, fmt.Errorf("invalid path %q in snapshot: %w", name, os.ErrInvalid)
+		}
+		if name == ManifestFile {
+			if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+				return manifest, readError(fmt.Errorf("invalid %s: %v: %w", ManifestFile, err, os.ErrInvalid))
+			}
+			continue
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return manifest, err
+		}
+		f, err := os.Create(target)
+		if err != nil {
+			return manifest, err
+		}
+		h := sha256.New()
+		size, err := io.Copy(io.MultiWriter(f, h), tr)
+		f.Close()
+		if err != nil {
+			return manifest, readError(fmt.Errorf("read %s: %v: %w", name, err, os.ErrInvalid))
+		}
+		sums[name] = File{Path: name, Size: size, SHA256: hex.EncodeToString(h.Sum(nil))}
+	}
+	if err := readError(nil); err != nil {
+		return manifest, err
+	}
+
+	if manifest.Version == 0 {
+		return manifest, fmt.Errorf("snapshot has no %s: %w", ManifestFile, os.ErrInvalid)
+	}
+	if manifest.Version > Version {
+		return manifest, fmt.Errorf("snapshot version %d is newer than supported version %d: %w", manifest.Version, Version, os.ErrInvalid)
+	}
+	for _, file := range manifest.Files {
+		got, ok := sums[file.Path]
+		if !ok {
+			return manifest, fmt.Errorf("file %s listed in %s is missing: %w", file.Path, ManifestFile, os.ErrInvalid)
+		}
+		if got.Size != file.Size || got.SHA256 != file.SHA256 {
+			return manifest, fmt.Errorf("checksum mismatch for %s: %w", file.Path, os.ErrInvalid)
+		}
+		delete(sums, file.Path)
+	}
+	if len(sums) > 0 {
+		extra := make([]string, 0, len(sums))
+		for name := range sums {
+			extra = append(extra, name)
+		}
+		sort.Strings(extra)
+		return manifest, fmt.Errorf("files not listed in %s: %s: %w", ManifestFile, strings.Join(extra, ", "), os.ErrInvalid)
+	}
+	if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(note.SnapshotIndexFile))); err != nil {
+		return manifest, fmt.Errorf("snapshot has no %s: %w", note.SnapshotIndexFile, os.ErrInvalid)
+	}
+	return manifest, nil
+}
+
+// importUploads 导入快照中的上传文件，replace 为 true 时先删除所有现有的上传文件
+func importUploads(dir, uploadPath string, manifest Manifest, replace bool, report *Report) error {
+	if replace {
+		entries, err := os.ReadDir(uploadPath)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		for _, entry := range entries {
+			if err := os.RemoveAll(filepath.Join(uploadPath, entry.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	for _, file := range manifest.Files {
+		rel := strings.TrimPrefix(file.Path, UploadsDir+"/")
+		if rel == file.Path {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(file.Path)))
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(uploadPath, filepath.FromSlash(rel))
+		if existing, err := os.ReadFile(target); err == nil {
+			if bytes.Equal(existing, data) {
+				report.Skipped++
+			} else {
+				report.Conflicts = append(report.Conflicts, note.SnapshotConflict{Path: file.Path, Reason: "file exists with different content"})
+			}
+			continue
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+		if err := writeFile(target, data, file.ModTime); err != nil {
+			return err
+		}
+		report.Uploads++
+	}
+	return nil
+}
+
+// writeFile 写入文件（先写入临时文件再重命名）并设置修改时间
+func writeFile(name string, data []byte, modTime time.Time) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if !modTime.IsZero() {
+		os.Chtimes(name, modTime, modTime)
+	}
+	return nil
+}
+
+// isSafePath 检查快照中的路径是否为不包含 .. 的相对路径
+func isSafePath(name string) bool {
+	if name == "" || strings.HasPrefix(name, "/") || strings.Contains(name, "\\") {
+		return false
+	}
+	return path.Clean(name) == name && name != "." && !strings.HasPrefix(name, "../") && name != ".."
+}
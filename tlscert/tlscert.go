@@ -0,0 +1,104 @@
+package tlscert
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// checkInterval 检查证书文件是否修改的最小间隔
+const checkInterval = 10 * time.Second
+
+// Reloader 提供 TLS 证书，证书或私钥文件修改后自动重新加载（例如 certbot 续期后不需要重启）
+type Reloader struct {
+	certFile string
+	keyFile  string
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	certMod   time.Time
+	keyMod    time.Time
+	lastCheck time.Time
+}
+
+// New 加载证书和私钥，文件不存在或不匹配时返回错误
+func New(certFile, keyFile string) (*Reloader, error) {
+	if certFile == "" || keyFile == "" {
+		return nil, fmt.Errorf("both certificate and key files are required")
+	}
+	r := &Reloader{certFile: certFile, keyFile: keyFile}
+	certMod, keyMod, err := r.modTimes()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.load(certMod, keyMod); err != nil {
+		return nil, err
+	}
+	r.lastCheck = time.Now()
+	return r, nil
+}
+
+// modTimes 返回证书和私钥文件的修改时间
+func (r *Reloader) modTimes() (time.Time, time.Time, error) {
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return certInfo.ModTime(), keyInfo.ModTime(), nil
+}
+
+// load 读取证书和私钥，调用方需要持有锁（New 中除外）
+func (r *Reloader) load(certMod, keyMod time.Time) error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.cert = &cert
+	r.certMod = certMod
+	r.keyMod = keyMod
+	return nil
+}
+
+// GetCertificate 用于 tls.Config.GetCertificate，返回当前证书
+// 每 checkInterval 最多检查一次文件修改时间，文件修改后重新加载；加载失败时继续使用旧证书
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(r.lastCheck) < checkInterval {
+		return r.cert, nil
+	}
+	r.lastCheck = now
+
+	certMod, keyMod, err := r.modTimes()
+	if err != nil {
+		log.Printf("Warning: Failed to check TLS certificate files: %v", err)
+		return r.cert, nil
+	}
+	if certMod.Equal(r.certMod) && keyMod.Equal(r.keyMod) {
+		return r.cert, nil
+	}
+	// 证书和私钥可能不是同时写入的，不匹配时下次检查再试
+	if err := r.load(certMod, keyMod); err != nil {
+		log.Printf("Warning: Failed to reload TLS certificate, keeping the previous one: %v", err)
+		return r.cert, nil
+	}
+	log.Printf("Reloaded TLS certificate from %s", r.certFile)
+	return r.cert, nil
+}
+
+// TLSConfig 返回使用自动重新加载证书的 TLS 配置
+func (r *Reloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: r.GetCertificate,
+	}
+}
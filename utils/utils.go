@@ -15,19 +15,13 @@ import (
 // Windows: 通过 syscall 获取真实的创建时间
 // Linux/Unix: 使用修改时间作为近似值（大多数文件系统不存储创建时间）
 // 其他平台: 使用修改时间作为近似值
-func GetFileCreationTime(path string) (time.Time, error) {
-	// 获取文件信息
-	info, err := os.Stat(path)
-	if err != nil {
-		return time.Time{}, err
-	}
-
+func GetFileCreationTime(info os.FileInfo) time.Time {
 	// Windows: 通过 Win32FileAttributeData 获取创建时间
 	// 使用 build tags 来避免在非 Windows 平台上编译错误
 	if runtime.GOOS == "windows" {
 		creationTime := GetFileCreationTimeWindows(info)
 		if !creationTime.IsZero() {
-			return creationTime, nil
+			return creationTime
 		}
 	}
 
@@ -39,7 +33,7 @@ func GetFileCreationTime(path string) (time.Time, error) {
 	// 这里统一使用修改时间作为回退
 
 	// 其他平台或获取失败时，使用修改时间作为近似值
-	return info.ModTime(), nil
+	return info.ModTime()
 }
 
 // ParseFileSize parses a file size string like "10M", "100MB", "1G" into bytes
@@ -99,25 +93,10 @@ func ParseFileSize(sizeStr string) (int64, error) {
 	}
 }
 
-// GetTotalFileSize calculates the total size of all files in savePath and uploadPath (excluding backupPath)
-func GetTotalFileSize(savePath, uploadPath string) (int64, error) {
+// GetDirSize calculates the total size of all files in dir
+func GetDirSize(dir string) (int64, error) {
 	var totalSize int64
-
-	// Calculate size of notes in savePath
-	if err := filepath.Walk(savePath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if !info.IsDir() {
-			totalSize += info.Size()
-		}
-		return nil
-	}); err != nil && !os.IsNotExist(err) {
-		return 0, err
-	}
-
-	// Calculate size of uploaded files in uploadPath
-	if err := filepath.Walk(uploadPath, func(path string, info os.FileInfo, err error) error {
+	if err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
@@ -128,7 +107,6 @@ func GetTotalFileSize(savePath, uploadPath string) (int64, error) {
 	}); err != nil && !os.IsNotExist(err) {
 		return 0, err
 	}
-
 	return totalSize, nil
 }
 
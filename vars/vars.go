@@ -2,17 +2,35 @@ package vars
 
 import (
 	"os"
+	"path/filepath"
 	"sync"
 )
 
+// 数据目录下的默认目录和文件名
 const (
-	SavePath   = "_tmp"
-	BackupPath = "bak"
-	UploadPath = "uploads" // Directory for uploaded files
+	SaveDir     = "_tmp"
+	BackupDir   = "bak"
+	UploadDir   = "uploads"       // Directory for uploaded files
+	RevisionDir = "revisions"     // Directory for note revisions
+	TrashDir    = "trash"         // Directory for deleted notes
+	ConfigFile  = "config.json"   // Configuration file
+	StoreFile   = "notes.db"      // Database file for the bolt note store
+	ACLFile     = "acl.json"      // Note ownership and sharing records
+	SessionFile = "sessions.json" // Login sessions for the file session store
+	WebhookFile = "webhooks.json" // Webhook delivery queue and log
+	MetaFile    = "meta.json"     // Pinned notes and last read times
 )
 
 // Vars 存储全局变量
 type Vars struct {
+	DataDir      string // 数据根目录，默认为当前目录
+	SavePath     string // 活跃笔记目录，未单独指定时为 DataDir/_tmp
+	BackupPath   string // 备份笔记目录，未单独指定时为 DataDir/bak
+	UploadPath   string // 上传文件目录，未单独指定时为 DataDir/uploads
+	RevisionPath string // 修订版本目录，未单独指定时为 DataDir/revisions
+	TrashPath    string // 回收站目录：DataDir/trash
+	ConfigFile   string // 配置文件路径：DataDir/config.json
+
 	AdminPath        string
 	Port             string
 	NoteNameLen      int
@@ -27,11 +45,35 @@ type Vars struct {
 	MaxNoteCountLock *sync.RWMutex
 	AdminToken       string
 	AccessToken      string
+	MaxRevisions     int
+	RevisionDays     int
+	TrashDays        int      // 删除的笔记在回收站中保留的天数
+	StoreType        string   // 笔记存储类型：fs 或 bolt
+	SessionStoreType string   // 登录 session 存储类型：file 或 memory
+	AllowedOrigins   []string // 除同源外允许发起修改请求和 WebSocket 连接的 Origin
+	TLSCertFile      string   // TLS 证书文件，设置后使用 HTTPS
+	TLSKeyFile       string   // TLS 私钥文件
+	HTTPRedirectPort string   // HTTP 重定向到 HTTPS 的监听端口，为空时不监听
+	HSTSMaxAge       int      // HSTS max-age（秒），0 表示不发送
+
+	// 归档策略：活跃笔记超过 BackupDays 天未使用时移动到备份文件夹
+	ArchiveBy          string // 归档依据：modified（最后修改时间）或 read（最后读取时间）
+	DeleteArchivedDays int    // 归档笔记保留的天数（之后直接删除），0 表示不删除
+
+	// 保留策略（由备份调度器每天执行），0 表示不限制
+	ArchiveMaxSize       int64 // 归档笔记的最大总大小，超出时直接删除最早归档的笔记
+	ArchiveKeepLast      int   // 最多保留最近归档的多少条笔记
+	OrphanUploadDays     int   // 没有被任何笔记引用的上传文件保留的天数
+	OrphanUploadMaxSize  int64 // 未被引用的上传文件的最大总大小，超出时删除最早上传的文件
+	OrphanUploadKeepLast int   // 最多保留最近上传的多少个未被引用的文件
+
+	SnapshotMaxSize int64 // 导入快照时解压后的最大大小，0 表示不限制
 }
 
 // NewVars 创建新的变量管理器
 func NewVars() *Vars {
 	v := &Vars{
+		DataDir:          ".",
 		AdminPath:        "/admin",
 		Port:             ":8080",
 		NoteNameLen:      3,
@@ -46,12 +88,50 @@ func NewVars() *Vars {
 		MaxNoteCountLock: &sync.RWMutex{},
 		AdminToken:       "",
 		AccessToken:      "",
+		MaxRevisions:     50,
+		RevisionDays:     30,
+		TrashDays:        30,
+		ArchiveBy:        "modified",
+		StoreType:        "fs",
+		SessionStoreType: "file",
+		HSTSMaxAge:       31536000,
+		SnapshotMaxSize:  10 * 1024 * 1024 * 1024,
 	}
+	return v
+}
 
-	// 创建必要的目录
-	os.MkdirAll(SavePath, 0755)
-	os.MkdirAll(BackupPath, 0755)
-	os.MkdirAll(UploadPath, 0755)
+// InitDataDirs 确定各个数据目录的路径（没有单独指定的目录位于 DataDir 下）并创建目录
+// 需要在解析命令行参数之后、加载配置文件之前调用
+func (v *Vars) InitDataDirs() error {
+	if v.DataDir == "" {
+		v.DataDir = "."
+	}
+	dirs := []struct {
+		path *string
+		name string
+	}{
+		{&v.SavePath, SaveDir},
+		{&v.BackupPath, BackupDir},
+		{&v.UploadPath, UploadDir},
+		{&v.RevisionPath, RevisionDir},
+		{&v.TrashPath, TrashDir},
+	}
+	if err := os.MkdirAll(v.DataDir, 0755); err != nil {
+		return err
+	}
+	v.ConfigFile = v.DataFile(ConfigFile)
+	for _, dir := range dirs {
+		if *dir.path == "" {
+			*dir.path = filepath.Join(v.DataDir, dir.name)
+		}
+		if err := os.MkdirAll(*dir.path, 0755); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	return v
+// DataFile 返回数据目录下文件的路径（配置文件、数据库文件等）
+func (v *Vars) DataFile(name string) string {
+	return filepath.Join(v.DataDir, name)
 }
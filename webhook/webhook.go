@@ -0,0 +1,588 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 事件类型
+const (
+	EventCreated    = "note.created"
+	EventUpdated    = "note.updated"
+	EventLocked     = "note.locked"
+	EventDeleted    = "note.deleted"
+	EventArchived   = "note.archived"
+	EventUnarchived = "note.unarchived"
+	EventPing       = "ping" // 管理后台发送的测试事件，不受事件过滤影响
+)
+
+// Events 可以订阅的事件
+var Events = []string{EventCreated, EventUpdated, EventLocked, EventDeleted, EventArchived, EventUnarchived}
+
+// 投递状态
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusFailed    = "failed"
+)
+
+const (
+	// maxAttempts 每次投递的最大尝试次数，之后记录为失败
+	maxAttempts = 10
+	// retryBaseDelay 第一次重试的等待时间，之后每次翻倍，最长 retryMaxDelay
+	retryBaseDelay = 30 * time.Second
+	retryMaxDelay  = time.Hour
+	// maxLogEntries 保留的已完成投递记录数量
+	maxLogEntries = 100
+	// requestTimeout 单次请求的超时时间
+	requestTimeout = 10 * time.Second
+	// maxErrorLength 投递记录中保留的错误信息（包括响应内容）长度
+	maxErrorLength = 200
+)
+
+// 签名和元数据 header
+const (
+	HeaderEvent     = "X-Jot-Event"
+	HeaderDelivery  = "X-Jot-Delivery"
+	HeaderSignature = "X-Jot-Signature" // sha256=<请求体的 HMAC-SHA256，十六进制>
+)
+
+var (
+	ErrNotFound     = errors.New("webhook not found")
+	ErrInvalidName  = errors.New("webhook name is required")
+	ErrInvalidURL   = errors.New("webhook url must be an absolute http or https url")
+	ErrInvalidEvent = errors.New("invalid webhook event")
+)
+
+// Hook 表示一个 webhook，保存在配置文件中
+type Hook struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret,omitempty"` // 用于计算签名
+	Events    []string  `json:"events"`           // 为空时接收所有事件
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Subscribed 检查 webhook 是否订阅了事件
+func (h *Hook) Subscribed(event string) bool {
+	if event == EventPing || len(h.Events) == 0 {
+		return true
+	}
+	for _, e := range h.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// NoteData 笔记事件请求体中的 data 字段
+type NoteData struct {
+	Workspace string    `json:"workspace,omitempty"` // 默认工作区为空
+	Name      string    `json:"name"`
+	DateDir   string    `json:"dateDir,omitempty"`
+	Size      int64     `json:"size"`
+	Locked    bool      `json:"locked"`
+	Content   string    `json:"content,omitempty"` // 不含锁标记的内容；删除、归档和有锁的笔记没有
+	Time      time.Time `json:"time"`
+}
+
+// Delivery 表示一次投递（同一个事件发送给一个 webhook），重试时使用相同的 ID
+type Delivery struct {
+	ID          string          `json:"id"`
+	HookID      string          `json:"hookId"`
+	HookName    string          `json:"hookName"`
+	Event       string          `json:"event"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"`
+	Attempts    int             `json:"attempts"`
+	CreatedAt   time.Time       `json:"createdAt"`
+	NextAttempt time.Time       `json:"nextAttempt"`
+	LastAttempt *time.Time      `json:"lastAttempt,omitempty"`
+	StatusCode  int             `json:"statusCode,omitempty"` // 最后一次尝试的响应状态码
+	Error       string          `json:"error,omitempty"`      // 最后一次尝试的错误
+}
+
+// state 保存在队列文件中的待投递队列和投递记录
+type state struct {
+	Queue []*Delivery `json:"queue"`
+	Log   []*Delivery `json:"log"`
+}
+
+// Manager 管理 webhook 和投递队列
+// webhook 保存在配置文件中（通过 OnChange），待投递队列和投递记录保存在队列文件中，重启后继续投递
+type Manager struct {
+	mu     sync.Mutex
+	hooks  []*Hook
+	queue  []*Delivery // 按创建顺序
+	log    []*Delivery // 已完成的投递，最新的在前
+	path   string      // 队列文件，为空时不持久化
+	client *http.Client
+	wake   chan struct{}
+	done   chan struct{} // 投递 goroutine 退出时关闭
+
+	// OnChange 在 webhook 被创建或删除后调用（不持有锁）
+	OnChange func()
+}
+
+// NewManager 创建 webhook 管理器
+func NewManager() *Manager {
+	return &Manager{
+		client: &http.Client{Timeout: requestTimeout},
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// Open 读取队列文件中未完成的投递和投递记录，文件不存在时使用空队列
+func (m *Manager) Open(path string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.path = path
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var s state
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+	m.queue = s.Queue
+	m.log = s.Log
+	return nil
+}
+
+// Load 替换当前的所有 webhook（从配置文件加载时使用）
+func (m *Manager) Load(hooks []Hook) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hooks = make([]*Hook, 0, len(hooks))
+	for i := range hooks {
+		hook := hooks[i]
+		m.hooks = append(m.hooks, &hook)
+	}
+}
+
+// Hooks 返回所有 webhook 的副本（包括 secret）
+func (m *Manager) Hooks() []Hook {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	hooks := make([]Hook, len(m.hooks))
+	for i, hook := range m.hooks {
+		hooks[i] = *hook
+		hooks[i].Events = append([]string(nil), hook.Events...)
+	}
+	return hooks
+}
+
+// Create 创建 webhook，secret 为空时自动生成
+func (m *Manager) Create(name, rawURL, secret string, events []string) (Hook, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return Hook{}, ErrInvalidName
+	}
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Hook{}, ErrInvalidURL
+	}
+	for _, event := range events {
+		if !IsValidEvent(event) {
+			return Hook{}, fmt.Errorf("%w: %s", ErrInvalidEvent, event)
+		}
+	}
+	if secret = strings.TrimSpace(secret); secret == "" {
+		if secret, err = randomHex(24); err != nil {
+			return Hook{}, err
+		}
+	}
+
+	m.mu.Lock()
+	var id string
+	for id == "" || m.find(id) != nil {
+		if id, err = randomHex(4); err != nil {
+			m.mu.Unlock()
+			return Hook{}, err
+		}
+	}
+	hook := &Hook{
+		ID:        id,
+		Name:      name,
+		URL:       u.String(),
+		Secret:    secret,
+		Events:    append([]string(nil), events...),
+		CreatedAt: time.Now(),
+	}
+	m.hooks = append(m.hooks, hook)
+	created := *hook
+	m.mu.Unlock()
+
+	m.changed()
+	return created, nil
+}
+
+// Delete 删除 webhook，它还没有完成的投递会被丢弃
+func (m *Manager) Delete(id string) error {
+	m.mu.Lock()
+	index := -1
+	for i, hook := range m.hooks {
+		if hook.ID == id {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		m.mu.Unlock()
+		return ErrNotFound
+	}
+	m.hooks = append(m.hooks[:index], m.hooks[index+1:]...)
+	queue := m.queue[:0]
+	for _, d := range m.queue {
+		if d.HookID != id {
+			queue = append(queue, d)
+		}
+	}
+	m.queue = queue
+	m.saveLocked()
+	m.mu.Unlock()
+
+	m.changed()
+	return nil
+}
+
+// Notify 把事件加入所有订阅了它的 webhook 的投递队列，data 作为请求体的 data 字段
+func (m *Manager) Notify(event string, data interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	added := false
+	for _, hook := range m.hooks {
+		if hook.Subscribed(event) {
+			if err := m.enqueueLocked(hook, event, data); err != nil {
+				log.Printf("Error queueing webhook %s event %s: %v", hook.Name, event, err)
+				continue
+			}
+			added = true
+		}
+	}
+	if added {
+		m.saveLocked()
+		m.signal()
+	}
+}
+
+// Ping 向 webhook 发送测试事件
+func (m *Manager) Ping(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	hook := m.find(id)
+	if hook == nil {
+		return ErrNotFound
+	}
+	if err := m.enqueueLocked(hook, EventPing, map[string]string{"webhook": hook.Name}); err != nil {
+		return err
+	}
+	m.saveLocked()
+	m.signal()
+	return nil
+}
+
+// Deliveries 返回待投递的投递（最早的在前）和已完成的投递记录（最新的在前）
+func (m *Manager) Deliveries() []Delivery {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result := make([]Delivery, 0, len(m.queue)+len(m.log))
+	for _, d := range m.queue {
+		result = append(result, *d)
+	}
+	for _, d := range m.log {
+		result = append(result, *d)
+	}
+	return result
+}
+
+// Start 启动投递 goroutine，ctx 取消后停止（正在发送的请求被取消，留在队列中下次启动时重试）
+func (m *Manager) Start(ctx context.Context) {
+	m.done = make(chan struct{})
+	go func() {
+		defer close(m.done)
+		for {
+			d, wait := m.next()
+			if d != nil {
+				m.deliver(ctx, d)
+				continue
+			}
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-m.wake:
+			case <-timer.C:
+			}
+			timer.Stop()
+		}
+	}()
+}
+
+// Wait 等待投递 goroutine 退出，ctx 超时时返回 ctx 的错误
+func (m *Manager) Wait(ctx context.Context) error {
+	if m.done == nil {
+		return nil
+	}
+	select {
+	case <-m.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// next 返回已到重试时间的投递；没有时返回到下一次重试的等待时间
+func (m *Manager) next() (*Delivery, time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	wait := retryMaxDelay
+	for _, d := range m.queue {
+		if !d.NextAttempt.After(now) {
+			return d, 0
+		}
+		if until := d.NextAttempt.Sub(now); until < wait {
+			wait = until
+		}
+	}
+	return nil, wait
+}
+
+// deliver 发送一次投递并记录结果：成功（2xx）或达到最大尝试次数后移到投递记录，否则按退避时间重试
+func (m *Manager) deliver(ctx context.Context, d *Delivery) {
+	m.mu.Lock()
+	var hook Hook
+	if h := m.find(d.HookID); h != nil {
+		hook = *h
+	}
+	m.mu.Unlock()
+	if hook.ID == "" {
+		m.finish(d, StatusFailed, 0, "webhook deleted")
+		return
+	}
+
+	statusCode, err := m.send(ctx, hook, d)
+	if ctx.Err() != nil {
+		// 关闭时取消的请求不计入尝试次数
+		return
+	}
+
+	m.mu.Lock()
+	now := time.Now()
+	d.Attempts++
+	d.LastAttempt = &now
+	d.StatusCode = statusCode
+	d.Error = ""
+	if err != nil {
+		d.Error = truncate(err.Error())
+	}
+	m.mu.Unlock()
+
+	switch {
+	case err == nil:
+		m.finish(d, StatusDelivered, statusCode, "")
+	case d.Attempts >= maxAttempts:
+		log.Printf("Webhook %s: giving up on delivery %s (%s) after %d attempts: %v", hook.Name, d.ID, d.Event, d.Attempts, err)
+		m.finish(d, StatusFailed, statusCode, d.Error)
+	default:
+		m.mu.Lock()
+		d.NextAttempt = now.Add(retryDelay(d.Attempts))
+		m.saveLocked()
+		m.mu.Unlock()
+	}
+}
+
+// send 发送请求，非 2xx 响应作为错误返回
+func (m *Manager) send(ctx context.Context, hook Hook, d *Delivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "jot-webhook")
+	req.Header.Set(HeaderEvent, d.Event)
+	req.Header.Set(HeaderDelivery, d.ID)
+	req.Header.Set(HeaderSignature, Sign(hook.Secret, d.Payload))
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorLength))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return resp.StatusCode, nil
+}
+
+// finish 把投递从队列移到投递记录
+func (m *Manager) finish(d *Delivery, status string, statusCode int, errMsg string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d.Status = status
+	d.StatusCode = statusCode
+	d.Error = errMsg
+	for i, queued := range m.queue {
+		if queued == d {
+			m.queue = append(m.queue[:i], m.queue[i+1:]...)
+			break
+		}
+	}
+	m.log = append([]*Delivery{d}, m.log...)
+	if len(m.log) > maxLogEntries {
+		m.log = m.log[:maxLogEntries]
+	}
+	m.saveLocked()
+}
+
+// enqueueLocked 创建投递并加入队列，调用者必须持有锁
+func (m *Manager) enqueueLocked(hook *Hook, event string, data interface{}) error {
+	id, err := randomHex(8)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	payload, err := json.Marshal(map[string]interface{}{
+		"id":        id,
+		"event":     event,
+		"timestamp": now,
+		"data":      data,
+	})
+	if err != nil {
+		return err
+	}
+	m.queue = append(m.queue, &Delivery{
+		ID:          id,
+		HookID:      hook.ID,
+		HookName:    hook.Name,
+		Event:       event,
+		Payload:     payload,
+		Status:      StatusPending,
+		CreatedAt:   now,
+		NextAttempt: now,
+	})
+	return nil
+}
+
+// saveLocked 将队列和投递记录写入队列文件（临时文件 + 重命名），调用者必须持有锁
+func (m *Manager) saveLocked() {
+	if m.path == "" {
+		return
+	}
+	// 不使用缩进：缩进会改变 payload 的内容，重试时的请求体必须与第一次相同
+	data, err := json.Marshal(state{Queue: m.queue, Log: m.log})
+	if err != nil {
+		log.Printf("Error encoding webhook queue: %v", err)
+		return
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(m.path), filepath.Base(m.path)+".tmp*")
+	if err != nil {
+		log.Printf("Error saving webhook queue: %v", err)
+		return
+	}
+	tmpPath := tmp.Name()
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, m.path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		log.Printf("Error saving webhook queue: %v", err)
+	}
+}
+
+// signal 唤醒投递 goroutine
+func (m *Manager) signal() {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+// find 根据 ID 查找 webhook，调用者必须持有锁
+func (m *Manager) find(id string) *Hook {
+	for _, hook := range m.hooks {
+		if hook.ID == id {
+			return hook
+		}
+	}
+	return nil
+}
+
+func (m *Manager) changed() {
+	if m.OnChange != nil {
+		m.OnChange()
+	}
+}
+
+// Sign 计算请求体的签名：sha256=<HMAC-SHA256(secret, body)>
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// IsValidEvent 检查事件名称是否可以订阅
+func IsValidEvent(event string) bool {
+	for _, e := range Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// retryDelay 返回第 attempts 次失败后的重试等待时间
+func retryDelay(attempts int) time.Duration {
+	delay := retryBaseDelay
+	for i := 1; i < attempts && delay < retryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > retryMaxDelay {
+		delay = retryMaxDelay
+	}
+	return delay
+}
+
+func truncate(s string) string {
+	if len(s) > maxErrorLength {
+		return s[:maxErrorLength] + "..."
+	}
+	return s
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,392 @@
+package websocket
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+const (
+	// 协同编辑的修改在内存中合并，最多延迟这么久写入磁盘
+	persistDelay = time.Second
+	// 每个文档保留的历史操作数量，用于变换客户端基于旧版本的操作
+	maxHistory = 500
+)
+
+var (
+	errStaleRevision = errors.New("revision is too old, please resync")
+	errNoteLocked    = errors.New("Note is locked. Provide lock_token parameter or Authorization header.")
+	errReadOnly      = errors.New("Access token or API key with write scope required")
+
+	// ErrNoteModified 由 NoteFuncs.SaveNoteIfUnchanged 返回，表示笔记已被其他请求修改
+	ErrNoteModified = errors.New("note was modified by another request")
+)
+
+// cursor 表示客户端的光标和选区，单位为 UTF-16 码元
+type cursor struct {
+	Anchor int `json:"anchor"`
+	Head   int `json:"head"`
+}
+
+// document 表示一个正在被协同编辑的笔记
+type document struct {
+	name string
+
+	saveLock    sync.Mutex // 串行化保存，最后一个客户端离开时会等待正在进行的定时保存完成
+	mu          sync.Mutex
+	content     []uint16
+	base        []uint16     // 上次从磁盘加载或保存的内容（不带锁头部），保存时用于检测其他请求的修改
+	lockHeader  string       // 笔记的锁头部，保存时原样保留
+	rev         int          // 已应用的操作数
+	history     []*Operation // history[i] 把版本 historyBase+i 变为下一版本
+	historyBase int
+	clients     map[*client]*cursor
+	dirty       bool
+	saveTimer   *time.Timer
+	creator     string // 在空文档中开始编辑的用户，笔记新建时成为所有者
+	editor      string // 最后一次修改文档的操作者，保存时记录在笔记事件中
+}
+
+// text 返回文档的 UTF-8 内容
+func (d *document) text() string {
+	return string(utf16.Decode(d.content))
+}
+
+// apply 应用一个已变换到当前版本的操作，调用时需持有 d.mu
+func (d *document) apply(op *Operation) error {
+	newContent, err := op.Apply(d.content)
+	if err != nil {
+		return err
+	}
+	d.content = newContent
+	d.rev++
+	d.history = append(d.history, op)
+	if len(d.history) > maxHistory {
+		drop := len(d.history) - maxHistory
+		d.history = append([]*Operation(nil), d.history[drop:]...)
+		d.historyBase += drop
+	}
+	for _, cur := range d.clients {
+		if cur != nil {
+			cur.Anchor = op.TransformIndex(cur.Anchor)
+			cur.Head = op.TransformIndex(cur.Head)
+		}
+	}
+	return nil
+}
+
+// broadcast 向文档的所有客户端（except 除外）发送消息，调用时需持有 d.mu
+func (d *document) broadcast(message interface{}, except *client) {
+	for c := range d.clients {
+		if c == except {
+			continue
+		}
+		if err := c.send(message); err != nil {
+			c.conn.Close()
+		}
+	}
+}
+
+// initMessage 生成客户端连接或重新同步时的完整状态，调用时需持有 d.mu
+func (m *Manager) initMessage(d *document, c *client) map[string]interface{} {
+	cursors := []map[string]interface{}{}
+	for other, cur := range d.clients {
+		if other == c || cur == nil {
+			continue
+		}
+		cursors = append(cursors, cursorMessage(other, cur))
+	}
+	text := d.text()
+	return map[string]interface{}{
+		"type":      "init",
+		"client_id": c.id,
+		"color":     c.color,
+		"rev":       d.rev,
+		"content":   text,
+		"etag":      m.Notes.ContentETag(text),
+		"cursors":   cursors,
+	}
+}
+
+func cursorMessage(c *client, cur *cursor) map[string]interface{} {
+	return map[string]interface{}{
+		"type":      "cursor",
+		"client_id": c.id,
+		"color":     c.color,
+		"anchor":    cur.Anchor,
+		"head":      cur.Head,
+	}
+}
+
+// openDocument 获取笔记的协同文档并注册客户端，文档不存在时从磁盘加载
+func (m *Manager) openDocument(noteName string, c *client) (*document, error) {
+	m.documentsLock.Lock()
+	defer m.documentsLock.Unlock()
+
+	d := m.documents[noteName]
+	if d == nil {
+		rawContent, err := m.Notes.LoadNote(noteName)
+		if err != nil {
+			return nil, err
+		}
+		lockHeader := m.lockHeader(rawContent)
+		content := utf16.Encode([]rune(rawContent[len(lockHeader):]))
+		d = &document{
+			name:       noteName,
+			content:    content,
+			base:       content,
+			lockHeader: lockHeader,
+			clients:    make(map[*client]*cursor),
+		}
+		m.documents[noteName] = d
+	}
+
+	d.mu.Lock()
+	d.clients[c] = nil
+	c.send(m.initMessage(d, c))
+	d.mu.Unlock()
+	return d, nil
+}
+
+// closeDocument 注销客户端，最后一个客户端离开时写入未保存的修改并释放文档
+func (m *Manager) closeDocument(d *document, c *client) {
+	m.documentsLock.Lock()
+	defer m.documentsLock.Unlock()
+
+	d.mu.Lock()
+	delete(d.clients, c)
+	d.broadcast(map[string]interface{}{
+		"type":      "leave",
+		"client_id": c.id,
+	}, nil)
+	empty := len(d.clients) == 0
+	d.mu.Unlock()
+
+	if empty {
+		delete(m.documents, d.name)
+		m.saveDocument(d)
+	}
+}
+
+// lockHeader 返回笔记内容中的锁头部，未锁定时返回空字符串
+func (m *Manager) lockHeader(rawContent string) string {
+	if !m.Notes.HasNoteLock(rawContent) {
+		return ""
+	}
+	return rawContent[:len(rawContent)-len(m.Notes.GetNoteContent(rawContent))]
+}
+
+// canEdit 检查客户端能否修改文档（笔记被锁定时需要验证锁密码），调用时需持有 d.mu
+func (m *Manager) canEdit(d *document, c *client) bool {
+	if d.lockHeader == "" || c.verifiedLock == d.lockHeader {
+		return true
+	}
+	if !m.Notes.VerifyNoteLock(d.lockHeader, c.lockToken) {
+		return false
+	}
+	c.verifiedLock = d.lockHeader
+	return true
+}
+
+// handleOperation 处理客户端发送的操作：变换到当前版本、应用、确认并广播
+func (m *Manager) handleOperation(d *document, c *client, rev int, op *Operation) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !c.canWrite {
+		return errReadOnly
+	}
+	if !m.canEdit(d, c) {
+		return errNoteLocked
+	}
+	if rev < d.historyBase || rev > d.rev {
+		return errStaleRevision
+	}
+
+	// 依次对客户端未看到的操作做变换
+	for _, concurrent := range d.history[rev-d.historyBase:] {
+		transformed, _, err := TransformOperations(op, concurrent)
+		if err != nil {
+			return err
+		}
+		op = transformed
+	}
+
+	newContent, err := op.Apply(d.content)
+	if err != nil {
+		return err
+	}
+	if maxSize := m.Notes.GetMaxFileSize(); int64(utf8Size(newContent)) > maxSize {
+		return fmt.Errorf("File size exceeds maximum limit of %d bytes (%d MB)", maxSize, maxSize/(1024*1024))
+	}
+	if len(d.content) == 0 && c.username != "" {
+		d.creator = c.username
+	}
+	if err := d.apply(op); err != nil {
+		return err
+	}
+	d.editor = c.actor
+
+	text := d.text()
+	etag := m.Notes.ContentETag(text)
+	c.send(map[string]interface{}{
+		"type": "ack",
+		"rev":  d.rev,
+		"etag": etag,
+	})
+	d.broadcast(map[string]interface{}{
+		"type":      "op",
+		"rev":       d.rev,
+		"op":        op,
+		"client_id": c.id,
+		"etag":      etag,
+	}, c)
+
+	d.dirty = true
+	m.scheduleSave(d)
+	return nil
+}
+
+// scheduleSave 在 persistDelay 后保存文档，调用时需持有 d.mu
+func (m *Manager) scheduleSave(d *document) {
+	if d.saveTimer == nil {
+		d.saveTimer = time.AfterFunc(persistDelay, func() {
+			m.saveDocument(d)
+		})
+	}
+}
+
+// rebase 把磁盘上被其他请求修改的内容合并到文档并广播，调用时需持有 d.mu
+// 文档中未保存的修改变换到磁盘内容之上，合并后仍有未保存的修改时安排保存
+func (m *Manager) rebase(d *document, rawContent string) {
+	d.lockHeader = m.lockHeader(rawContent)
+	saved := utf16.Encode([]rune(rawContent[len(d.lockHeader):]))
+	remote := DiffOperation(d.base, saved)
+	local := DiffOperation(d.base, d.content)
+	d.base = saved
+
+	if !remote.IsNoop() {
+		// 以一次替换操作的形式应用，客户端未确认的操作会基于它做变换
+		_, op, err := TransformOperations(local, remote)
+		if err != nil {
+			log.Printf("Error merging update to note %s, discarding unsaved edits: %v", d.name, err)
+			op = DiffOperation(d.content, saved)
+		}
+		if !op.IsNoop() {
+			if err := d.apply(op); err != nil {
+				log.Printf("Error applying update to note %s: %v", d.name, err)
+				return
+			}
+			d.broadcast(map[string]interface{}{
+				"type": "op",
+				"rev":  d.rev,
+				"op":   op,
+				"etag": m.Notes.ContentETag(d.text()),
+			}, nil)
+		}
+	}
+
+	d.dirty = !slices.Equal(d.content, d.base)
+	if d.dirty {
+		m.scheduleSave(d)
+	}
+}
+
+// handleCursor 记录客户端的光标并广播给其他客户端
+func (m *Manager) handleCursor(d *document, c *client, cur cursor) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	size := len(d.content)
+	cur.Anchor = max(0, min(cur.Anchor, size))
+	cur.Head = max(0, min(cur.Head, size))
+	d.clients[c] = &cur
+	d.broadcast(cursorMessage(c, &cur), c)
+}
+
+// saveDocument 将文档的修改通过笔记管理器写入磁盘
+// 只有磁盘内容仍是上次加载或保存的内容时才写入，否则先合并其他请求的修改再重新保存
+func (m *Manager) saveDocument(d *document) {
+	d.saveLock.Lock()
+	defer d.saveLock.Unlock()
+
+	d.mu.Lock()
+	if d.saveTimer != nil {
+		d.saveTimer.Stop()
+		d.saveTimer = nil
+	}
+	if !d.dirty {
+		d.mu.Unlock()
+		return
+	}
+	d.dirty = false
+	text := d.content
+	lockHeader := d.lockHeader
+	expected := lockHeader + string(utf16.Decode(d.base))
+	creator := d.creator
+	d.creator = ""
+	editor := d.editor
+	d.mu.Unlock()
+
+	// 保留笔记的锁；内容为空时和 HTTP 保存一样删除笔记
+	content := string(utf16.Decode(text))
+	if content != "" && lockHeader != "" {
+		content = m.Notes.KeepNoteLock(lockHeader, content)
+	}
+
+	// 新建的笔记归属于开始编辑的用户
+	isNew := creator != "" && content != "" && expected == ""
+
+	err := m.Notes.CheckNoteQuota(d.name, int64(len(content)))
+	if err == nil {
+		err = m.Notes.SaveNoteIfUnchanged(d.name, expected, content, editor)
+	}
+	if err == nil {
+		d.mu.Lock()
+		d.base = text
+		d.mu.Unlock()
+		if isNew {
+			m.Notes.ClaimNote(d.name, creator)
+		}
+		return
+	}
+	if errors.Is(err, ErrNoteModified) {
+		// 笔记已被 HTTP 保存等其他请求修改：合并磁盘上的内容，稍后重新保存
+		rawContent, loadErr := m.Notes.LoadNote(d.name)
+		if loadErr == nil {
+			d.mu.Lock()
+			if d.creator == "" {
+				d.creator = creator
+			}
+			m.rebase(d, rawContent)
+			d.mu.Unlock()
+			return
+		}
+		err = loadErr
+	}
+	log.Printf("Error saving collaborative note %s: %v", d.name, err)
+	d.mu.Lock()
+	d.dirty = true
+	if d.creator == "" {
+		d.creator = creator
+	}
+	d.broadcast(map[string]interface{}{
+		"type":    "error",
+		"message": err.Error(),
+	}, nil)
+	d.mu.Unlock()
+}
+
+// utf8Size 返回 UTF-16 内容编码为 UTF-8 后的字节数
+func utf8Size(content []uint16) int {
+	size := 0
+	for _, r := range utf16.Decode(content) {
+		size += utf8.RuneLen(r)
+	}
+	return size
+}
@@ -0,0 +1,283 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"unicode/utf16"
+)
+
+// 文本操作（OT），与前端的 TextOp 对应
+// JSON 格式为数组：正整数表示保留，负整数表示删除，字符串表示插入
+// 所有长度和位置均以 UTF-16 码元为单位，与浏览器 textarea 保持一致
+
+const (
+	opRetain = iota
+	opInsert
+	opDelete
+)
+
+// opComponent 表示操作中的一个片段
+type opComponent struct {
+	kind int
+	n    int      // retain / delete 的长度
+	text []uint16 // insert 的内容
+}
+
+func (c opComponent) length() int {
+	if c.kind == opInsert {
+		return len(c.text)
+	}
+	return c.n
+}
+
+// Operation 表示对文档的一次完整修改
+type Operation struct {
+	components   []opComponent
+	baseLength   int // 应用前的文档长度
+	targetLength int // 应用后的文档长度
+}
+
+var errOperationMismatch = errors.New("operation length does not match document")
+
+// Retain 保留 n 个字符
+func (o *Operation) Retain(n int) *Operation {
+	if n <= 0 {
+		return o
+	}
+	o.baseLength += n
+	o.targetLength += n
+	if last := len(o.components) - 1; last >= 0 && o.components[last].kind == opRetain {
+		o.components[last].n += n
+		return o
+	}
+	o.components = append(o.components, opComponent{kind: opRetain, n: n})
+	return o
+}
+
+// Insert 插入文本
+func (o *Operation) Insert(text []uint16) *Operation {
+	if len(text) == 0 {
+		return o
+	}
+	o.targetLength += len(text)
+	last := len(o.components) - 1
+	if last >= 0 && o.components[last].kind == opInsert {
+		o.components[last].text = append(o.components[last].text, text...)
+		return o
+	}
+	// 插入总是放在删除之前，保证相同效果的操作有唯一的表示
+	if last >= 0 && o.components[last].kind == opDelete {
+		if last > 0 && o.components[last-1].kind == opInsert {
+			o.components[last-1].text = append(o.components[last-1].text, text...)
+			return o
+		}
+		del := o.components[last]
+		o.components[last] = opComponent{kind: opInsert, text: append([]uint16(nil), text...)}
+		o.components = append(o.components, del)
+		return o
+	}
+	o.components = append(o.components, opComponent{kind: opInsert, text: append([]uint16(nil), text...)})
+	return o
+}
+
+// Delete 删除 n 个字符
+func (o *Operation) Delete(n int) *Operation {
+	if n <= 0 {
+		return o
+	}
+	o.baseLength += n
+	if last := len(o.components) - 1; last >= 0 && o.components[last].kind == opDelete {
+		o.components[last].n += n
+		return o
+	}
+	o.components = append(o.components, opComponent{kind: opDelete, n: n})
+	return o
+}
+
+// IsNoop 检查操作是否不修改文档
+func (o *Operation) IsNoop() bool {
+	for _, c := range o.components {
+		if c.kind != opRetain {
+			return false
+		}
+	}
+	return true
+}
+
+// Apply 将操作应用到文档
+func (o *Operation) Apply(doc []uint16) ([]uint16, error) {
+	if len(doc) != o.baseLength {
+		return nil, errOperationMismatch
+	}
+	result := make([]uint16, 0, o.targetLength)
+	pos := 0
+	for _, c := range o.components {
+		switch c.kind {
+		case opRetain:
+			result = append(result, doc[pos:pos+c.n]...)
+			pos += c.n
+		case opInsert:
+			result = append(result, c.text...)
+		case opDelete:
+			pos += c.n
+		}
+	}
+	return result, nil
+}
+
+// TransformIndex 将文档中的位置按操作进行变换（用于光标）
+func (o *Operation) TransformIndex(index int) int {
+	newIndex := index
+	pos := 0
+	for _, c := range o.components {
+		if pos > index {
+			break
+		}
+		switch c.kind {
+		case opRetain:
+			pos += c.n
+		case opInsert:
+			newIndex += len(c.text)
+		case opDelete:
+			newIndex -= min(index-pos, c.n)
+			pos += c.n
+		}
+	}
+	return newIndex
+}
+
+// TransformOperations 变换两个并发操作 a 和 b（基于同一文档）
+// 返回 a' 和 b'，满足 apply(apply(doc, a), b') == apply(apply(doc, b), a')
+// 两个操作在同一位置插入时，a 的插入在前
+func TransformOperations(a, b *Operation) (*Operation, *Operation, error) {
+	if a.baseLength != b.baseLength {
+		return nil, nil, errOperationMismatch
+	}
+	aPrime, bPrime := &Operation{}, &Operation{}
+	ops1, ops2 := a.components, b.components
+	i1, i2 := 0, 0
+	var c1, c2 *opComponent
+	next1 := func() {
+		c1 = nil
+		if i1 < len(ops1) {
+			c := ops1[i1]
+			c1 = &c
+			i1++
+		}
+	}
+	next2 := func() {
+		c2 = nil
+		if i2 < len(ops2) {
+			c := ops2[i2]
+			c2 = &c
+			i2++
+		}
+	}
+	next1()
+	next2()
+
+	for c1 != nil || c2 != nil {
+		if c1 != nil && c1.kind == opInsert {
+			aPrime.Insert(c1.text)
+			bPrime.Retain(len(c1.text))
+			next1()
+			continue
+		}
+		if c2 != nil && c2.kind == opInsert {
+			aPrime.Retain(len(c2.text))
+			bPrime.Insert(c2.text)
+			next2()
+			continue
+		}
+		if c1 == nil || c2 == nil {
+			return nil, nil, errOperationMismatch
+		}
+
+		minLen := min(c1.n, c2.n)
+		switch {
+		case c1.kind == opRetain && c2.kind == opRetain:
+			aPrime.Retain(minLen)
+			bPrime.Retain(minLen)
+		case c1.kind == opDelete && c2.kind == opDelete:
+			// 双方都删除了同一段，无需处理
+		case c1.kind == opDelete && c2.kind == opRetain:
+			aPrime.Delete(minLen)
+		case c1.kind == opRetain && c2.kind == opDelete:
+			bPrime.Delete(minLen)
+		}
+
+		c1.n -= minLen
+		c2.n -= minLen
+		if c1.n == 0 {
+			next1()
+		}
+		if c2.n == 0 {
+			next2()
+		}
+	}
+	return aPrime, bPrime, nil
+}
+
+// DiffOperation 根据公共前缀和后缀生成从 oldDoc 到 newDoc 的操作
+func DiffOperation(oldDoc, newDoc []uint16) *Operation {
+	prefix := 0
+	for prefix < len(oldDoc) && prefix < len(newDoc) && oldDoc[prefix] == newDoc[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(oldDoc)-prefix && suffix < len(newDoc)-prefix &&
+		oldDoc[len(oldDoc)-1-suffix] == newDoc[len(newDoc)-1-suffix] {
+		suffix++
+	}
+	op := &Operation{}
+	op.Retain(prefix)
+	op.Delete(len(oldDoc) - prefix - suffix)
+	op.Insert(newDoc[prefix : len(newDoc)-suffix])
+	op.Retain(suffix)
+	return op
+}
+
+// MarshalJSON 编码为 [retain, "insert", -delete, ...] 格式
+func (o *Operation) MarshalJSON() ([]byte, error) {
+	items := make([]interface{}, 0, len(o.components))
+	for _, c := range o.components {
+		switch c.kind {
+		case opRetain:
+			items = append(items, c.n)
+		case opInsert:
+			items = append(items, string(utf16.Decode(c.text)))
+		case opDelete:
+			items = append(items, -c.n)
+		}
+	}
+	return json.Marshal(items)
+}
+
+// UnmarshalJSON 从 [retain, "insert", -delete, ...] 格式解码
+func (o *Operation) UnmarshalJSON(data []byte) error {
+	var items []interface{}
+	if err := json.Unmarshal(data, &items); err != nil {
+		return err
+	}
+	*o = Operation{}
+	for _, item := range items {
+		switch v := item.(type) {
+		case float64:
+			n := int(v)
+			if float64(n) != v || n == 0 {
+				return fmt.Errorf("invalid operation component: %v", v)
+			}
+			if n > 0 {
+				o.Retain(n)
+			} else {
+				o.Delete(-n)
+			}
+		case string:
+			o.Insert(utf16.Encode([]rune(v)))
+		default:
+			return fmt.Errorf("invalid operation component: %v", v)
+		}
+	}
+	return nil
+}
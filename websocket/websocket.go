@@ -1,52 +1,111 @@
 package websocket
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
+
+	"github.com/hello--world/jot/apikey"
 )
 
 var (
-	upgrader = websocket.Upgrader{
-		CheckOrigin: func(r *http.Request) bool {
-			return true
-		},
-	}
-	clients     = make(map[string]map[*websocket.Conn]bool)
-	clientsLock = sync.RWMutex{}
+	// 远程光标使用的颜色
+	cursorColors = []string{"#e91e63", "#2196f3", "#4caf50", "#ff9800", "#9c27b0", "#009688", "#f44336", "#3f51b5"}
+	clientSeq    uint64
+)
+
+const (
+	writeTimeout = 10 * time.Second
+	// closeTimeout 关闭时等待客户端回应关闭帧的时间
+	closeTimeout = 2 * time.Second
 )
 
-// Manager 管理 WebSocket 连接
+// NoteFuncs 协同编辑读写笔记所需的函数
+type NoteFuncs struct {
+	LoadNote                func(string) (string, error)
+	SaveNoteIfUnchanged     func(string, string, string, string) error // 名称、期望的当前内容、内容、操作者，当前内容不同时返回 ErrNoteModified
+	HasNoteLock             func(string) bool
+	VerifyNoteLock          func(string, string) bool
+	KeepNoteLock            func(string, string) string
+	GetNoteContent          func(string) string
+	ContentETag             func(string) string
+	GetLockTokenFromRequest func(*http.Request, string) string
+	GetMaxFileSize          func() int64
+	CheckNoteQuota          func(string, int64) error
+	ClaimNote               func(string, string) // 将新建的笔记归属于用户（笔记已有所有者时不修改）
+}
+
+// Manager 管理 WebSocket 连接和协同编辑的文档
 type Manager struct {
-	IsSafeNoteName      func(string) bool
-	GetAccessToken      func() string
-	GetTokenFromRequest func(*http.Request) string
+	IsSafeNoteName func(string) bool
+	Authorize      func(*http.Request, string, string) bool // 检查请求能否以指定权限访问笔记（站点认证和笔记所有权）
+	CurrentUser    func(*http.Request) string               // 返回已登录的用户名
+	Actor          func(*http.Request) string               // 返回记录在笔记事件中的操作者
+	Notes          NoteFuncs
+
+	upgrader      websocket.Upgrader
+	documents     map[string]*document
+	documentsLock sync.Mutex
+	shuttingDown  bool           // 由 documentsLock 保护，关闭后拒绝新连接
+	connections   sync.WaitGroup // 正在处理的连接，连接结束时已保存协同编辑的修改
+}
+
+// client 表示一个 WebSocket 连接
+type client struct {
+	id           string
+	color        string
+	conn         *websocket.Conn
+	writeLock    sync.Mutex
+	lockToken    string
+	verifiedLock string // 已验证过锁密码的锁头部
+	canWrite     bool   // 是否拥有 write 权限（只读 key 只能查看）
+	username     string // 已登录的用户名，新建笔记时成为所有者
+	actor        string // 操作者，保存时记录在笔记事件中
+}
+
+// send 发送 JSON 消息，同一连接的写操作需要串行
+func (c *client) send(message interface{}) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	return c.conn.WriteJSON(message)
+}
+
+// clientMessage 客户端发送的消息
+// op: {"type":"op","rev":3,"op":[5,"abc",-2]}
+// cursor: {"type":"cursor","anchor":5,"head":8}
+type clientMessage struct {
+	Type   string     `json:"type"`
+	Rev    int        `json:"rev"`
+	Op     *Operation `json:"op"`
+	Anchor int        `json:"anchor"`
+	Head   int        `json:"head"`
 }
 
 // NewManager 创建新的 WebSocket 管理器
-func NewManager(isSafeNoteName func(string) bool, getAccessToken func() string, getTokenFromRequest func(*http.Request) string) *Manager {
+// checkOrigin 检查升级请求的 Origin，拒绝跨站页面发起的连接
+func NewManager(isSafeNoteName func(string) bool, authorize func(*http.Request, string, string) bool, currentUser, actor func(*http.Request) string, checkOrigin func(*http.Request) bool, notes NoteFuncs) *Manager {
 	return &Manager{
-		IsSafeNoteName:      isSafeNoteName,
-		GetAccessToken:      getAccessToken,
-		GetTokenFromRequest: getTokenFromRequest,
+		IsSafeNoteName: isSafeNoteName,
+		Authorize:      authorize,
+		CurrentUser:    currentUser,
+		Actor:          actor,
+		Notes:          notes,
+		upgrader:       websocket.Upgrader{CheckOrigin: checkOrigin},
+		documents:      make(map[string]*document),
 	}
 }
 
 // HandleWebSocket 处理 WebSocket 连接
 func (m *Manager) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
-	// 检查 access token（如果站点有 token，需要验证）
-	accessToken := m.GetAccessToken()
-	if accessToken != "" {
-		token := m.GetTokenFromRequest(r)
-		if token != accessToken {
-			http.Error(w, "Unauthorized: Access token required", http.StatusUnauthorized)
-			return
-		}
-	}
-
 	vars := mux.Vars(r)
 	noteName := vars["note"]
 
@@ -56,58 +115,153 @@ func (m *Manager) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	conn, err := upgrader.Upgrade(w, r, nil)
+	// 检查 read 权限（站点认证和笔记所有权），提交操作还需要 write 权限
+	if !m.Authorize(r, noteName, apikey.ScopeRead) {
+		http.Error(w, "Unauthorized: Access token required", http.StatusUnauthorized)
+		return
+	}
+
+	// Check note lock: 锁定的笔记需要锁密码才能订阅和编辑
+	lockToken := m.Notes.GetLockTokenFromRequest(r, noteName)
+	rawContent, err := m.Notes.LoadNote(noteName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if m.Notes.HasNoteLock(rawContent) && !m.Notes.VerifyNoteLock(rawContent, lockToken) {
+		http.Error(w, "Unauthorized: Note is locked. Provide lock_token parameter or Authorization header.", http.StatusUnauthorized)
+		return
+	}
+
+	m.documentsLock.Lock()
+	if m.shuttingDown {
+		m.documentsLock.Unlock()
+		http.Error(w, "Server is shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	m.connections.Add(1)
+	m.documentsLock.Unlock()
+	defer m.connections.Done()
+
+	conn, err := m.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("WebSocket upgrade error: %v", err)
 		return
 	}
 	defer conn.Close()
 
+	seq := atomic.AddUint64(&clientSeq, 1)
+	c := &client{
+		id:        fmt.Sprintf("c%d", seq),
+		color:     cursorColors[int(seq)%len(cursorColors)],
+		conn:      conn,
+		lockToken: lockToken,
+		canWrite:  m.Authorize(r, noteName, apikey.ScopeWrite),
+		username:  m.CurrentUser(r),
+		actor:     m.Actor(r),
+	}
+
 	// Register client
-	clientsLock.Lock()
-	if clients[noteName] == nil {
-		clients[noteName] = make(map[*websocket.Conn]bool)
+	d, err := m.openDocument(noteName, c)
+	if err != nil {
+		log.Printf("WebSocket open note error: %v", err)
+		return
 	}
-	clients[noteName][conn] = true
-	clientsLock.Unlock()
 
 	// Unregister on disconnect
-	defer func() {
-		clientsLock.Lock()
-		delete(clients[noteName], conn)
-		if len(clients[noteName]) == 0 {
-			delete(clients, noteName)
-		}
-		clientsLock.Unlock()
-	}()
+	defer m.closeDocument(d, c)
 
-	// Keep connection alive
 	for {
-		_, _, err := conn.ReadMessage()
+		_, data, err := conn.ReadMessage()
 		if err != nil {
 			break
 		}
+		var msg clientMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			continue
+		}
+
+		switch msg.Type {
+		case "op":
+			if msg.Op == nil {
+				continue
+			}
+			if err := m.handleOperation(d, c, msg.Rev, msg.Op); err != nil {
+				// 操作无法应用时发送错误，并让客户端以服务器内容重新同步
+				c.send(map[string]interface{}{
+					"type":    "error",
+					"message": err.Error(),
+					"resync":  true,
+				})
+				d.mu.Lock()
+				c.send(m.initMessage(d, c))
+				d.mu.Unlock()
+			}
+		case "cursor":
+			m.handleCursor(d, c, cursor{Anchor: msg.Anchor, Head: msg.Head})
+		}
 	}
 }
 
-// BroadcastUpdate 广播更新
-func BroadcastUpdate(noteName, content string) {
-	clientsLock.RLock()
-	defer clientsLock.RUnlock()
-
-	if clients[noteName] == nil {
-		return
+// Shutdown 向所有客户端发送关闭帧（1001 Going Away），等待连接结束并保存协同编辑的修改
+// ctx 超时时强制关闭剩余的连接，返回 ctx 的错误
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.documentsLock.Lock()
+	m.shuttingDown = true
+	var clients []*client
+	for _, d := range m.documents {
+		d.mu.Lock()
+		for c := range d.clients {
+			clients = append(clients, c)
+		}
+		d.mu.Unlock()
 	}
+	m.documentsLock.Unlock()
 
-	message := map[string]interface{}{
-		"type":    "update",
-		"content": content,
+	closeMessage := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+	deadline := time.Now().Add(closeTimeout)
+	for _, c := range clients {
+		c.conn.WriteControl(websocket.CloseMessage, closeMessage, deadline)
+		// 客户端没有回应关闭帧时，读取超时后结束连接
+		c.conn.SetReadDeadline(deadline)
 	}
 
-	for conn := range clients[noteName] {
-		if err := conn.WriteJSON(message); err != nil {
-			delete(clients[noteName], conn)
-			conn.Close()
+	done := make(chan struct{})
+	go func() {
+		m.connections.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		for _, c := range clients {
+			c.conn.Close()
 		}
+		return ctx.Err()
+	}
+}
+
+// BroadcastUpdate 将通过 HTTP 保存的内容合并到协同文档并广播给客户端
+// 客户端还没有保存的修改会变换到新内容之上，不会被覆盖；content 可能已被之后的保存取代，所以以磁盘上的内容为准
+func (m *Manager) BroadcastUpdate(noteName, content string) {
+	m.documentsLock.Lock()
+	d := m.documents[noteName]
+	m.documentsLock.Unlock()
+	if d == nil {
+		return
+	}
+
+	// 等待正在进行的保存完成，以磁盘上的内容（包括可能已被修改的锁）为准合并
+	d.saveLock.Lock()
+	defer d.saveLock.Unlock()
+	rawContent, err := m.Notes.LoadNote(noteName)
+	if err != nil {
+		log.Printf("Error loading note %s: %v", noteName, err)
+		return
 	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	m.rebase(d, rawContent)
 }
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/hello--world/jot/account"
+	"github.com/hello--world/jot/apikey"
+	"github.com/hello--world/jot/backup"
+	"github.com/hello--world/jot/config"
+	"github.com/hello--world/jot/events"
+	"github.com/hello--world/jot/handlers"
+	"github.com/hello--world/jot/note"
+	"github.com/hello--world/jot/ratelimit"
+	"github.com/hello--world/jot/session"
+	"github.com/hello--world/jot/setup"
+	"github.com/hello--world/jot/vars"
+	"github.com/hello--world/jot/webhook"
+	"github.com/hello--world/jot/websocket"
+)
+
+// workspaceNamePattern 工作区名称只能包含小写字母、数字和短横线（用作默认数据目录名）
+var workspaceNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+
+// workspace 包含一个工作区的变量和管理器，每个工作区的笔记、配置、令牌、账号和 session 互相独立
+type workspace struct {
+	name  string
+	hosts []string
+
+	v              *vars.Vars
+	noteManager    *note.Manager
+	configManager  *config.Manager
+	wsManager      *websocket.Manager
+	apiKeyManager  *apikey.Manager
+	accountManager *account.Manager
+	sessionStore   session.Store
+	rateLimiter    *ratelimit.Limiter
+	webhooks       *webhook.Manager
+	events         *events.Broker
+	backupManager  *backup.Manager
+	deps           *handlers.Dependencies
+}
+
+// newWorkspace 创建工作区的变量和管理器（笔记存储在加载配置之后打开）
+// defs 用于读写配置文件中的工作区定义，只有默认工作区需要
+func newWorkspace(name string, hosts []string, defs *[]config.Workspace) *workspace {
+	ws := &workspace{
+		name:           name,
+		hosts:          hosts,
+		v:              vars.NewVars(),
+		apiKeyManager:  apikey.NewManager(),
+		accountManager: account.NewManager(),
+		rateLimiter:    ratelimit.New(),
+		webhooks:       webhook.NewManager(),
+		events:         events.NewBroker(),
+	}
+	v := ws.v
+
+	// 初始化配置管理器（配置文件位于数据目录下，确定数据目录后才会加载）
+	ws.configManager = config.NewManager(
+		&v.ConfigFile,
+		&v.AdminToken,
+		&v.AccessToken,
+		&v.AdminPath,
+		&v.NoteNameLen,
+		&v.BackupDays,
+		&v.MaxPathLength,
+		&v.MaxNoteCount,
+		&v.NoteChars,
+		&v.MaxFileSize,
+		&v.MaxTotalSize,
+		v.MaxTotalSizeLock,
+		v.MaxNoteCountLock,
+		&v.MaxRevisions,
+		&v.RevisionDays,
+		&v.TrashDays,
+		&v.ArchiveBy,
+		&v.DeleteArchivedDays,
+		&v.ArchiveMaxSize,
+		&v.ArchiveKeepLast,
+		&v.OrphanUploadDays,
+		&v.OrphanUploadMaxSize,
+		&v.OrphanUploadKeepLast,
+		&v.SnapshotMaxSize,
+		ws.apiKeyManager,
+		ws.accountManager,
+		ws.rateLimiter,
+		ws.webhooks,
+		&v.AllowedOrigins,
+		defs,
+	)
+	ws.apiKeyManager.OnChange = func() { ws.configManager.SaveConfig() }
+	ws.accountManager.OnChange = func() { ws.configManager.SaveConfig() }
+	ws.webhooks.OnChange = func() { ws.configManager.SaveConfig() }
+
+	// 初始化 WebSocket 管理器
+	ws.wsManager = websocket.NewManager(
+		func(name string) bool { return ws.noteManager.IsSafeNoteName(name) },
+		handlers.AuthorizeNote,
+		handlers.CurrentUser,
+		handlers.Actor,
+		handlers.CheckWebSocketOrigin,
+		websocket.NoteFuncs{
+			LoadNote:                func(name string) (string, error) { return ws.noteManager.LoadNote(name) },
+			HasNoteLock:             note.HasNoteLock,
+			VerifyNoteLock:          note.VerifyNoteLock,
+			KeepNoteLock:            note.KeepNoteLock,
+			GetNoteContent:          note.GetNoteContent,
+			ContentETag:             note.ContentETag,
+			GetLockTokenFromRequest: handlers.GetLockTokenFromRequest,
+			GetMaxFileSize:          func() int64 { return v.MaxFileSize },
+			ClaimNote: func(name, owner string) {
+				if _, err := ws.noteManager.ACL.Claim(name, owner); err != nil {
+					log.Printf("Error setting owner of note %s: %v", name, err)
+				}
+			},
+			CheckNoteQuota: func(name string, size int64) error {
+				_, err := ws.deps.CheckNoteQuota(name, size)
+				return err
+			},
+			SaveNoteIfUnchanged: func(name, expected, content, actor string) error {
+				err := ws.noteManager.SaveNoteIfUnchanged(name, expected, content, actor)
+				if errors.Is(err, note.ErrNoteModified) {
+					return websocket.ErrNoteModified
+				}
+				return err
+			},
+		},
+	)
+	return ws
+}
+
+// load 加载工作区：确定数据目录，读取工作区自己的配置文件（不存在时用定义中的初始令牌创建），
+// 打开笔记存储和 session 存储。端口、TLS、存储类型和管理后台路径与默认工作区相同
+func (ws *workspace) load(def config.Workspace) error {
+	defaults := mainWorkspace.v
+	ws.v.DataDir = def.DataDir
+	if ws.v.DataDir == "" {
+		ws.v.DataDir = filepath.Join(defaults.DataDir, "workspaces", def.Name)
+	}
+	ws.v.StoreType = defaults.StoreType
+	ws.v.SessionStoreType = defaults.SessionStoreType
+	if err := ws.v.InitDataDirs(); err != nil {
+		return err
+	}
+
+	if !ws.configManager.LoadConfig() {
+		ws.v.AdminToken = def.AdminToken
+		ws.v.AccessToken = def.AccessToken
+		if ws.v.AdminToken != "" {
+			ws.configManager.SaveConfig()
+		}
+	}
+	if ws.v.AdminToken == "" {
+		return fmt.Errorf("no admin token (set adminToken in the workspace definition or in %s)", ws.v.ConfigFile)
+	}
+	ws.v.AdminPath = defaults.AdminPath
+
+	if err := ws.initNoteManager(); err != nil {
+		return err
+	}
+	if err := ws.initSessionStore(); err != nil {
+		return err
+	}
+	ws.deps = setup.NewDependencies(ws.handlerInitializer())
+	return handlers.AddWorkspace(ws.deps)
+}
+
+// loadWorkspaces 加载默认工作区配置文件中定义的所有工作区，定义无效时退出
+func loadWorkspaces() {
+	names := make(map[string]bool)
+	for _, def := range workspaceDefs {
+		if !workspaceNamePattern.MatchString(def.Name) {
+			log.Fatalf("Error: Invalid workspace name %q (use lowercase letters, digits and '-')", def.Name)
+		}
+		if names[def.Name] {
+			log.Fatalf("Error: Duplicate workspace name %q", def.Name)
+		}
+		names[def.Name] = true
+		if len(def.Hosts) == 0 {
+			log.Fatalf("Error: Workspace %s has no hosts", def.Name)
+		}
+
+		ws := newWorkspace(def.Name, def.Hosts, nil)
+		if err := ws.load(def); err != nil {
+			log.Fatalf("Error: Failed to load workspace %s: %v", def.Name, err)
+		}
+		workspaces = append(workspaces, ws)
+		log.Printf("Loaded workspace %s (hosts %s, data in %s)", def.Name, strings.Join(def.Hosts, ", "), ws.v.DataDir)
+	}
+}
+
+// noteEvent 把笔记事件发送给 webhook 和事件流的订阅者
+func (ws *workspace) noteEvent(e note.Event) {
+	ws.notifyWebhooks(e)
+
+	data := events.NoteData{
+		Name:      e.Name,
+		DateDir:   e.DateDir,
+		Size:      e.Size,
+		Locked:    e.Locked,
+		ETag:      e.ETag,
+		UpdatedAt: e.Time,
+		By:        e.Actor,
+	}
+	if !e.Locked && (e.Type == note.EventCreated || e.Type == note.EventUpdated || e.Type == note.EventUnarchived) {
+		content := e.Content
+		data.Content = &content
+	}
+	ws.events.Publish(e.Type, e.Name, data)
+}
+
+// notifyWebhooks 把笔记事件加入订阅了它的 webhook 的投递队列
+func (ws *workspace) notifyWebhooks(e note.Event) {
+	ws.webhooks.Notify("note."+e.Type, webhook.NoteData{
+		Workspace: ws.name,
+		Name:      e.Name,
+		DateDir:   e.DateDir,
+		Size:      e.Size,
+		Locked:    e.Locked,
+		Content:   e.Content,
+		Time:      e.Time,
+	})
+}
+
+// allWorkspaces 返回默认工作区和所有其他工作区
+func allWorkspaces() []*workspace {
+	return append([]*workspace{mainWorkspace}, workspaces...)
+}